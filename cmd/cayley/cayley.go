@@ -33,10 +33,12 @@ import (
 	"github.com/google/cayley/internal/http"
 
 	// Load all supported backends.
+	_ "github.com/google/cayley/graph/badger"
 	_ "github.com/google/cayley/graph/bolt"
 	_ "github.com/google/cayley/graph/leveldb"
 	_ "github.com/google/cayley/graph/memstore"
 	_ "github.com/google/cayley/graph/mongo"
+	_ "github.com/google/cayley/graph/redis"
 	_ "github.com/google/cayley/graph/sql"
 
 	// Load writer registry
@@ -224,7 +226,7 @@ func main() {
 		if err != nil {
 			break
 		}
-		if !graph.IsPersistent(cfg.DatabaseType) {
+		if !db.IsPersistent(cfg) {
 			err = internal.Load(handle.QuadWriter, cfg, *quadFile, *quadType)
 			if err != nil {
 				break
@@ -249,7 +251,7 @@ func main() {
 		if err != nil {
 			break
 		}
-		if !graph.IsPersistent(cfg.DatabaseType) {
+		if !db.IsPersistent(cfg) {
 			err = internal.Load(handle.QuadWriter, cfg, "", *quadType)
 			if err != nil {
 				break
@@ -271,14 +273,14 @@ func main() {
 		if err != nil {
 			break
 		}
-		if !graph.IsPersistent(cfg.DatabaseType) {
+		if !db.IsPersistent(cfg) {
 			err = internal.Load(handle.QuadWriter, cfg, "", *quadType)
 			if err != nil {
 				break
 			}
 		}
 
-		http.Serve(handle, cfg)
+		err = http.Serve(handle, cfg)
 
 		handle.Close()
 
@@ -27,10 +27,12 @@ import (
 
 	// Load all supported backends.
 
+	_ "github.com/google/cayley/graph/badger"
 	_ "github.com/google/cayley/graph/bolt"
 	_ "github.com/google/cayley/graph/leveldb"
 	_ "github.com/google/cayley/graph/memstore"
 	_ "github.com/google/cayley/graph/mongo"
+	_ "github.com/google/cayley/graph/redis"
 )
 
 var (
@@ -0,0 +1,268 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badger
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/graphtest"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/kv"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/writer"
+)
+
+func TestCreateDatabase(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Log(tmpDir)
+
+	err = createNewBadger(tmpDir, nil)
+	if err != nil {
+		t.Fatal("Failed to create Badger database.", err)
+	}
+
+	qs, err := newQuadStore(tmpDir, nil)
+	if qs == nil || err != nil {
+		t.Fatal("Failed to create badger QuadStore.", err)
+	}
+	if s := qs.Size(); s != 0 {
+		t.Errorf("Unexpected size, got:%d expected:0", s)
+	}
+	qs.Close()
+
+	err = createNewBadger("/dev/null/some terrible path", nil)
+	if err == nil {
+		t.Errorf("Created Badger database for bad path.")
+	}
+}
+
+func makeBadger(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	opts := graph.Options{"nosync": true}
+	err = createNewBadger(tmpDir, opts)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatal("Failed to create Badger database.", err)
+	}
+	qs, err := newQuadStore(tmpDir, opts)
+	if qs == nil || err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatal("Failed to create Badger QuadStore.", err)
+	}
+	return qs, nil, func() {
+		qs.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestBadgerAll(t *testing.T) {
+	graphtest.TestAll(t, makeBadger, &graphtest.Config{
+		SkipNodeDelAfterQuadDel: true,
+	})
+}
+
+func TestLoadDatabase(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Log(tmpDir)
+
+	err = createNewBadger(tmpDir, nil)
+	if err != nil {
+		t.Fatal("Failed to create Badger database.", err)
+	}
+
+	qs, err := newQuadStore(tmpDir, nil)
+	if qs == nil || err != nil {
+		t.Fatal("Failed to create badger QuadStore.", err)
+	}
+
+	w, _ := writer.NewSingleReplication(qs, nil)
+	err = w.WriteQuad(quad.Make(
+		"Something",
+		"points_to",
+		"Something Else",
+		"context",
+	))
+	if err != nil {
+		t.Fatal("Failed to add quad:", err)
+	}
+	for _, pq := range []string{"Something", "points_to", "Something Else", "context"} {
+		if got := quad.StringOf(qs.NameOf(qs.ValueOf(quad.Raw(pq)))); got != pq {
+			t.Errorf("Failed to roundtrip %q, got:%q expect:%q", pq, got, pq)
+		}
+	}
+	if s := qs.Size(); s != 1 {
+		t.Errorf("Unexpected quadstore size, got:%d expect:1", s)
+	}
+	qs.Close()
+
+	err = createNewBadger(tmpDir, nil)
+	if err != graph.ErrDatabaseExists {
+		t.Fatal("Failed to create Badger database.", err)
+	}
+	qs, err = newQuadStore(tmpDir, nil)
+	if qs == nil || err != nil {
+		t.Fatal("Failed to create badger QuadStore.", err)
+	}
+	w, _ = writer.NewSingleReplication(qs, nil)
+
+	ts2, didConvert := qs.(*kv.QuadStore)
+	if !didConvert {
+		t.Errorf("Could not convert from generic to Badger QuadStore")
+	}
+
+	//Test horizon
+	horizon := qs.Horizon()
+	if horizon.Int() != 1 {
+		t.Errorf("Unexpected horizon value, got:%d expect:1", horizon.Int())
+	}
+
+	w.WriteQuads(graphtest.MakeQuadSet())
+	if s := qs.Size(); s != 12 {
+		t.Errorf("Unexpected quadstore size, got:%d expect:12", s)
+	}
+	if s := ts2.SizeOf(qs.ValueOf(quad.Raw("B"))); s != 5 {
+		t.Errorf("Unexpected quadstore size, got:%d expect:5", s)
+	}
+	horizon = qs.Horizon()
+	if horizon.Int() != 12 {
+		t.Errorf("Unexpected horizon value, got:%d expect:12", horizon.Int())
+	}
+
+	w.RemoveQuad(quad.Make(
+		"A",
+		"follows",
+		"B",
+		"",
+	))
+	if s := qs.Size(); s != 11 {
+		t.Errorf("Unexpected quadstore size after RemoveQuad, got:%d expect:11", s)
+	}
+	if s := ts2.SizeOf(qs.ValueOf(quad.Raw("B"))); s != 4 {
+		t.Errorf("Unexpected quadstore size, got:%d expect:4", s)
+	}
+
+	qs.Close()
+}
+
+func TestOptimize(t *testing.T) {
+	qs, opts, closer := makeBadger(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	// With an linksto-fixed pair
+	fixed := qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("F")))
+	fixed.Tagger().Add("internal")
+	lto := iterator.NewLinksTo(qs, fixed, quad.Object)
+
+	oldIt := lto.Clone()
+	newIt, ok := lto.Optimize()
+	if !ok {
+		t.Errorf("Failed to optimize iterator")
+	}
+	if newIt.Type() != kv.Type() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), kv.Type())
+	}
+
+	newQuads := graphtest.IteratedQuads(t, qs, newIt)
+	oldQuads := graphtest.IteratedQuads(t, qs, oldIt)
+	if !reflect.DeepEqual(newQuads, oldQuads) {
+		t.Errorf("Optimized iteration does not match original")
+	}
+
+	graph.Next(context.TODO(), oldIt)
+	oldResults := make(map[string]graph.Value)
+	oldIt.TagResults(oldResults)
+	graph.Next(context.TODO(), newIt)
+	newResults := make(map[string]graph.Value)
+	newIt.TagResults(newResults)
+	if !reflect.DeepEqual(newResults, oldResults) {
+		t.Errorf("Discordant tag results, new:%v old:%v", newResults, oldResults)
+	}
+}
+
+func TestOptimizeHasA(t *testing.T) {
+	qs, opts, closer := makeBadger(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	// The predicates of the quads with B as their subject.
+	fixed := qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("B")))
+	hasa := iterator.NewHasA(qs, iterator.NewLinksTo(qs, fixed, quad.Subject), quad.Predicate)
+	hasa.Tagger().Add("predicate")
+
+	oldIt := hasa.Clone()
+	newIt, ok := hasa.Optimize()
+	if !ok {
+		t.Errorf("Failed to optimize iterator")
+	}
+	if newIt.Type() != kv.HasAType() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), kv.HasAType())
+	}
+
+	newNames := graphtest.IteratedRawStrings(t, qs, newIt)
+	oldNames := graphtest.IteratedRawStrings(t, qs, oldIt)
+	if !reflect.DeepEqual(newNames, oldNames) {
+		t.Errorf("Optimized iteration does not match original, new:%v old:%v", newNames, oldNames)
+	}
+
+	newIt.Reset()
+	ctx := context.TODO()
+	for _, name := range []string{"follows", "status"} {
+		if !newIt.Contains(ctx, qs.ValueOf(quad.Raw(name))) {
+			t.Errorf("Failed to contain %q", name)
+		}
+		results := make(map[string]graph.Value)
+		newIt.TagResults(results)
+		if got := quad.StringOf(qs.NameOf(results["predicate"])); got != name {
+			t.Errorf("Unexpected tag result, got:%q expect:%q", got, name)
+		}
+		if newIt.NextPath(ctx) {
+			t.Errorf("Unexpected next path for %q", name)
+		}
+	}
+	if newIt.Contains(ctx, qs.ValueOf(quad.Raw("cool"))) {
+		t.Errorf("Contains an object of B")
+	}
+
+	// The index of the objects is not sorted by predicate.
+	fixed = qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("B")))
+	hasa = iterator.NewHasA(qs, iterator.NewLinksTo(qs, fixed, quad.Object), quad.Predicate)
+	newIt, _ = hasa.Optimize()
+	if newIt.Type() != graph.HasA {
+		t.Errorf("Unexpected optimization, got:%v expect:%v", newIt.Type(), graph.HasA)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package badger is a quad store backed by Badger, a pure Go LSM tree which
+// keeps the values apart from the keys, so that writes are cheap. The keys
+// and values are the ones of the kv package.
+package badger
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger"
+	"github.com/golang/glog"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/kv"
+)
+
+func init() {
+	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
+		NewFunc:           newQuadStore,
+		NewForRequestFunc: nil,
+		UpgradeFunc:       upgradeBadger,
+		InitFunc:          createNewBadger,
+		IsPersistent:      true,
+	})
+}
+
+const (
+	QuadStoreType = "badger"
+)
+
+// DB is a Badger database, as a kv.DB.
+type DB struct {
+	db *badger.DB
+}
+
+var _ kv.DB = (*DB)(nil)
+
+// logger sends the logs of Badger to glog, which only shows its debug logs
+// at a high verbosity.
+type logger struct{}
+
+func (logger) Errorf(f string, v ...interface{})   { glog.Errorf("badger: "+f, v...) }
+func (logger) Warningf(f string, v ...interface{}) { glog.Warningf("badger: "+f, v...) }
+func (logger) Infof(f string, v ...interface{})    { glog.V(1).Infof("badger: "+f, v...) }
+func (logger) Debugf(f string, v ...interface{})   { glog.V(3).Infof("badger: "+f, v...) }
+
+func openBadger(path string, options graph.Options) (*DB, error) {
+	nosync, _, err := options.BoolKey("nosync")
+	if err != nil {
+		return nil, err
+	}
+	opts := badger.DefaultOptions(path).
+		WithSyncWrites(!nosync).
+		WithLogger(logger{})
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+func createNewBadger(path string, options graph.Options) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		glog.Errorf("Error: could not create database: %v", err)
+		return err
+	}
+	db, err := openBadger(path, options)
+	if err != nil {
+		glog.Errorf("Error: could not create database: %v", err)
+		return err
+	}
+	defer db.Close()
+	return kv.Init(db)
+}
+
+func newQuadStore(path string, options graph.Options) (graph.QuadStore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := openBadger(path, options)
+	if err != nil {
+		glog.Errorln("Error, could not open! ", err)
+		return nil, err
+	}
+	qs, err := kv.New(QuadStoreType, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return qs, nil
+}
+
+func upgradeBadger(path string, opts graph.Options) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("badger: no database at %s. Run init for your config to create it.", path)
+	}
+	db, err := openBadger(path, opts)
+	if err != nil {
+		glog.Errorln("Error, couldn't open! ", err)
+		return err
+	}
+	defer db.Close()
+	return kv.Upgrade(db)
+}
+
+func (db *DB) Get(key []byte) ([]byte, error) {
+	var out []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		out, err = item.ValueCopy(nil)
+		return err
+	})
+	return out, err
+}
+
+// Scan returns a cursor which holds the read transaction it was opened in
+// until it is closed. The values are only read for the keys which ask for
+// them.
+func (db *DB) Scan(prefix []byte) kv.Cursor {
+	txn := db.db.NewTransaction(false)
+	iter := txn.NewIterator(badger.IteratorOptions{
+		PrefetchValues: false,
+		Prefix:         prefix,
+	})
+	return &cursor{txn: txn, iter: iter, prefix: prefix}
+}
+
+type cursor struct {
+	txn    *badger.Txn
+	iter   *badger.Iterator
+	prefix []byte
+	item   *badger.Item
+	val    []byte
+	err    error
+}
+
+func (c *cursor) Next() bool {
+	if c.iter == nil || c.err != nil {
+		return false
+	}
+	if c.item == nil {
+		c.iter.Seek(c.prefix)
+	} else {
+		c.iter.Next()
+	}
+	c.val = nil
+	if !c.iter.Valid() {
+		c.item = nil
+		return false
+	}
+	c.item = c.iter.Item()
+	return true
+}
+
+func (c *cursor) Key() []byte { return c.item.Key() }
+
+func (c *cursor) Val() []byte {
+	if c.val == nil {
+		c.val, c.err = c.item.ValueCopy(c.val)
+	}
+	return c.val
+}
+
+func (c *cursor) Err() error { return c.err }
+
+func (c *cursor) Close() error {
+	if c.iter != nil {
+		c.iter.Close()
+		c.txn.Discard()
+		c.iter, c.item = nil, nil
+	}
+	return nil
+}
+
+func (db *DB) NewBatch() kv.Batch {
+	return &batch{db: db.db}
+}
+
+// batch holds the writes until they are made in a Badger transaction, which
+// is committed and replaced by a new one when it grows too big.
+type batch struct {
+	db  *badger.DB
+	ops []op
+}
+
+type op struct {
+	key, val []byte
+	del      bool
+}
+
+func (b *batch) Put(key, val []byte) {
+	b.ops = append(b.ops, op{key: key, val: val})
+}
+
+func (b *batch) Delete(key []byte) {
+	b.ops = append(b.ops, op{key: key, del: true})
+}
+
+func (b *batch) Commit() error {
+	txn := b.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+	for _, o := range b.ops {
+		err := o.apply(txn)
+		if err == badger.ErrTxnTooBig {
+			if err = txn.Commit(); err != nil {
+				return err
+			}
+			txn = b.db.NewTransaction(true)
+			err = o.apply(txn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (o op) apply(txn *badger.Txn) error {
+	if o.del {
+		return txn.Delete(o.key)
+	}
+	return txn.Set(o.key, o.val)
+}
+
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// Metrics reports the sizes of the LSM tree and of the value log.
+func (db *DB) Metrics() map[string]float64 {
+	lsm, vlog := db.db.Size()
+	return map[string]float64{
+		"badger_lsm_size_bytes":       float64(lsm),
+		"badger_value_log_size_bytes": float64(vlog),
+	}
+}
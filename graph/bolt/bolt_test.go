@@ -20,14 +20,17 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/graphtest"
 	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/kv"
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/writer"
 )
 
-var _ graphtest.ValueSizer = (*QuadStore)(nil)
+var _ graphtest.ValueSizer = (*kv.QuadStore)(nil)
 
 func TestCreateDatabase(t *testing.T) {
 	tmpFile, err := ioutil.TempFile(os.TempDir(), "cayley_test")
@@ -130,7 +133,7 @@ func TestLoadDatabase(t *testing.T) {
 	}
 	w, _ = writer.NewSingleReplication(qs, nil)
 
-	ts2, didConvert := qs.(*QuadStore)
+	ts2, didConvert := qs.(*kv.QuadStore)
 	if !didConvert {
 		t.Errorf("Could not convert from generic to Bolt QuadStore")
 	}
@@ -186,8 +189,8 @@ func TestOptimize(t *testing.T) {
 	if !ok {
 		t.Errorf("Failed to optimize iterator")
 	}
-	if newIt.Type() != Type() {
-		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), Type())
+	if newIt.Type() != kv.Type() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), kv.Type())
 	}
 
 	newQuads := graphtest.IteratedQuads(t, qs, newIt)
@@ -196,10 +199,10 @@ func TestOptimize(t *testing.T) {
 		t.Errorf("Optimized iteration does not match original")
 	}
 
-	graph.Next(oldIt)
+	graph.Next(context.TODO(), oldIt)
 	oldResults := make(map[string]graph.Value)
 	oldIt.TagResults(oldResults)
-	graph.Next(newIt)
+	graph.Next(context.TODO(), newIt)
 	newResults := make(map[string]graph.Value)
 	newIt.TagResults(newResults)
 	if !reflect.DeepEqual(newResults, oldResults) {
@@ -15,18 +15,30 @@
 package bolt
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
+
 	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/kv"
 	"github.com/google/cayley/graph/proto"
+	"github.com/google/cayley/quad"
 )
 
+// Before the kv package, the stores kept their indexes, nodes, log and
+// metadata in buckets of their own, with their own versions.
 const latestDataVersion = 3
 const nilDataVersion = 1
 
+// kvDataVersion is the version of the kv layout which the buckets of the
+// latest version are moved to.
+const kvDataVersion = 2
+
 type upgradeFunc func(*bolt.DB) error
 
 var migrateFunctions = []upgradeFunc{
@@ -35,16 +47,53 @@ var migrateFunctions = []upgradeFunc{
 	upgrade2To3,
 }
 
+var (
+	// Short hand for direction permutations.
+	spo = [4]quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
+	osp = [4]quad.Direction{quad.Object, quad.Subject, quad.Predicate, quad.Label}
+	pos = [4]quad.Direction{quad.Predicate, quad.Object, quad.Subject, quad.Label}
+	cps = [4]quad.Direction{quad.Label, quad.Predicate, quad.Subject, quad.Object}
+
+	// Byte arrays for each bucket name.
+	spoBucket  = bucketFor(spo)
+	ospBucket  = bucketFor(osp)
+	posBucket  = bucketFor(pos)
+	cpsBucket  = bucketFor(cps)
+	logBucket  = []byte("log")
+	nodeBucket = []byte("node")
+	metaBucket = []byte("meta")
+)
+
+func bucketFor(d [4]quad.Direction) []byte {
+	return []byte{d[0].Prefix(), d[1].Prefix(), d[2].Prefix(), d[3].Prefix()}
+}
+
+// hasBuckets returns whether the database has the buckets of the stores made
+// before the kv package.
+func hasBuckets(db *bolt.DB) bool {
+	return hasBucket(db, metaBucket)
+}
+
 func upgradeBolt(path string, opts graph.Options) error {
 	db, err := bolt.Open(path, 0600, nil)
-	defer db.Close()
-
 	if err != nil {
 		glog.Errorln("Error, couldn't open! ", err)
 		return err
 	}
+	defer db.Close()
+
+	if hasBuckets(db) {
+		if err = upgradeBuckets(db); err != nil {
+			return err
+		}
+	}
+	return kv.Upgrade(&DB{db: db})
+}
+
+func upgradeBuckets(db *bolt.DB) error {
 	var version int64
-	err = db.View(func(tx *bolt.Tx) error {
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
 		version, err = getInt64ForMetaKey(tx, "version", nilDataVersion)
 		return err
 	})
@@ -53,11 +102,6 @@ func upgradeBolt(path string, opts graph.Options) error {
 		return err
 	}
 
-	if version == latestDataVersion {
-		fmt.Printf("Already at latest version: %d\n", latestDataVersion)
-		return nil
-	}
-
 	if version > latestDataVersion {
 		err := fmt.Errorf("Unknown data version: %d -- upgrade this tool", version)
 		glog.Errorln("error:", err)
@@ -72,7 +116,52 @@ func upgradeBolt(path string, opts graph.Options) error {
 		setVersion(db, i+1)
 	}
 
-	return nil
+	return upgradeBucketsToKV(db)
+}
+
+func setVersion(db *bolt.DB, version int64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		buf := new(bytes.Buffer)
+		err := binary.Write(buf, binary.LittleEndian, version)
+		if err != nil {
+			glog.Errorf("Couldn't convert version!")
+			return err
+		}
+		b := tx.Bucket(metaBucket)
+		werr := b.Put([]byte("version"), buf.Bytes())
+		if werr != nil {
+			glog.Error("Couldn't write version!")
+			return werr
+		}
+		return nil
+	})
+}
+
+func getInt64ForMetaKey(tx *bolt.Tx, key string, empty int64) (int64, error) {
+	var out int64
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return empty, nil
+	}
+	data := b.Get([]byte(key))
+	if data == nil {
+		return empty, nil
+	}
+	buf := bytes.NewBuffer(data)
+	err := binary.Read(buf, binary.LittleEndian, &out)
+	if err != nil {
+		return 0, err
+	}
+	return out, nil
+}
+
+func deltaToProto(delta graph.Delta) proto.LogDelta {
+	var newd proto.LogDelta
+	newd.ID = uint64(delta.ID.Int())
+	newd.Action = int32(delta.Action)
+	newd.Timestamp = delta.Timestamp.UnixNano()
+	newd.Quad = proto.MakeQuad(delta.Quad)
+	return newd
 }
 
 type v1ValueData struct {
@@ -214,3 +303,97 @@ func upgrade2To3(db *bolt.DB) error {
 	}
 	return nil
 }
+
+// upgradeBucketsToKV moves the keys of the buckets to the bucket of the kv
+// layout, and removes the buckets.
+func upgradeBucketsToKV(db *bolt.DB) error {
+	fmt.Println("Upgrading v3 to the kv layout...")
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := tx.CreateBucketIfNotExists(dataBucket)
+		if err != nil {
+			return err
+		}
+		data.FillPercent = localFillPercent
+		move := func(bucket []byte, keyFor func(k []byte) ([]byte, error)) error {
+			fmt.Println("Upgrading bucket", string(bucket))
+			b := tx.Bucket(bucket)
+			if b == nil {
+				return nil
+			}
+			err := b.ForEach(func(k, v []byte) error {
+				nk, err := keyFor(k)
+				if err != nil || nk == nil {
+					return err
+				}
+				return data.Put(nk, append([]byte{}, v...))
+			})
+			if err != nil {
+				return err
+			}
+			return tx.DeleteBucket(bucket)
+		}
+		// The size in the meta bucket may have been overwritten by the
+		// horizon, so it is counted again from the quads which are live.
+		var size int64
+		err = tx.Bucket(spoBucket).ForEach(func(k, v []byte) error {
+			var h proto.HistoryEntry
+			if err := h.Unmarshal(v); err != nil {
+				return err
+			}
+			if len(h.History)%2 != 0 {
+				size++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, bucket := range [][]byte{spoBucket, ospBucket, posBucket, cpsBucket} {
+			prefix := string(bucket[:2])
+			err := move(bucket, func(k []byte) ([]byte, error) {
+				return kv.IndexKey(prefix, k), nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = move(nodeBucket, func(k []byte) ([]byte, error) {
+			return kv.ValueKey(k), nil
+		})
+		if err != nil {
+			return err
+		}
+		err = move(logBucket, func(k []byte) ([]byte, error) {
+			id, err := strconv.ParseInt(string(k), 16, 64)
+			if err != nil {
+				return nil, err
+			}
+			return kv.DeltaKey(id), nil
+		})
+		if err != nil {
+			return err
+		}
+		err = move(metaBucket, func(k []byte) ([]byte, error) {
+			if string(k) == "horizon" {
+				return []byte(kv.HorizonKey), nil
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, val := range map[string]int64{
+			kv.SizeKey:    size,
+			kv.VersionKey: kvDataVersion,
+		} {
+			buf := new(bytes.Buffer)
+			if err := binary.Write(buf, binary.LittleEndian, val); err != nil {
+				return err
+			}
+			if err := data.Put([]byte(key), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -12,21 +12,19 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package bolt is a quad store backed by a Bolt file, through the kv package.
+// The keys of the store are kept in a single bucket.
 package bolt
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
-	"fmt"
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 
 	"github.com/google/cayley/graph"
-	"github.com/google/cayley/graph/iterator"
-	"github.com/google/cayley/graph/proto"
-	"github.com/google/cayley/quad"
+	"github.com/google/cayley/graph/kv"
 )
 
 func init() {
@@ -39,38 +37,25 @@ func init() {
 	})
 }
 
-var (
-	errNoBucket = errors.New("bolt: bucket is missing")
-)
-
 const localFillPercent = 0.7
 
 const (
 	QuadStoreType = "bolt"
 )
 
-var _ graph.Keyer = (*Token)(nil)
-
-type Token struct {
-	nodes  bool
-	bucket []byte
-	key    []byte
-}
+var (
+	dataBucket = []byte("data")
 
-func (t *Token) IsNode() bool { return t.nodes }
+	// bufferSize is the number of keys a cursor reads at a time.
+	bufferSize = 50
+)
 
-func (t *Token) Key() interface{} {
-	return fmt.Sprint(t.bucket, t.key)
+// DB is a Bolt database, as a kv.DB.
+type DB struct {
+	db *bolt.DB
 }
 
-type QuadStore struct {
-	db      *bolt.DB
-	path    string
-	open    bool
-	size    int64
-	horizon int64
-	version int64
-}
+var _ kv.DB = (*DB)(nil)
 
 func createNewBolt(path string, _ graph.Options) error {
 	db, err := bolt.Open(path, 0600, nil)
@@ -79,491 +64,171 @@ func createNewBolt(path string, _ graph.Options) error {
 		return err
 	}
 	defer db.Close()
-	qs := &QuadStore{}
-	qs.db = db
-	defer qs.Close()
-	err = qs.getMetadata()
-	if err != errNoBucket {
+	if hasBuckets(db) {
 		return graph.ErrDatabaseExists
 	}
-	err = qs.createBuckets()
-	if err != nil {
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(dataBucket)
 		return err
-	}
-	err = setVersion(qs.db, latestDataVersion)
+	})
 	if err != nil {
 		return err
 	}
-	qs.Close()
-	return nil
+	return kv.Init(&DB{db: db})
 }
 
 func newQuadStore(path string, options graph.Options) (graph.QuadStore, error) {
-	var qs QuadStore
-	var err error
 	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
 		glog.Errorln("Error, couldn't open! ", err)
 		return nil, err
 	}
-	qs.db = db
 	// BoolKey returns false on non-existence. IE, Sync by default.
-	qs.db.NoSync, _, err = options.BoolKey("nosync")
+	db.NoSync, _, err = options.BoolKey("nosync")
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
-	err = qs.getMetadata()
-	if err == errNoBucket {
+	if hasBuckets(db) {
+		db.Close()
+		return nil, errors.New("bolt: data version is out of date. Run cayleyupgrade for your config to update the data.")
+	} else if !hasBucket(db, dataBucket) {
+		db.Close()
 		return nil, errors.New("bolt: quadstore has not been initialised")
-	} else if err != nil {
-		return nil, err
 	}
-	if qs.version != latestDataVersion {
-		return nil, errors.New("bolt: data version is out of date. Run cayleyupgrade for your config to update the data.")
+	qs, err := kv.New(QuadStoreType, &DB{db: db})
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-	return &qs, nil
+	return qs, nil
 }
 
-func (qs *QuadStore) createBuckets() error {
-	return qs.db.Update(func(tx *bolt.Tx) error {
-		var err error
-		for _, index := range [][4]quad.Direction{spo, osp, pos, cps} {
-			_, err = tx.CreateBucket(bucketFor(index))
-			if err != nil {
-				return fmt.Errorf("could not create bucket: %s", err)
-			}
-		}
-		_, err = tx.CreateBucket(logBucket)
-		if err != nil {
-			return fmt.Errorf("could not create bucket: %s", err)
-		}
-		_, err = tx.CreateBucket(nodeBucket)
-		if err != nil {
-			return fmt.Errorf("could not create bucket: %s", err)
-		}
-		_, err = tx.CreateBucket(metaBucket)
-		if err != nil {
-			return fmt.Errorf("could not create bucket: %s", err)
-		}
+func hasBucket(db *bolt.DB, name []byte) bool {
+	var ok bool
+	db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(name) != nil
 		return nil
 	})
+	return ok
 }
 
-func setVersion(db *bolt.DB, version int64) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, binary.LittleEndian, version)
-		if err != nil {
-			glog.Errorf("Couldn't convert version!")
-			return err
-		}
-		b := tx.Bucket(metaBucket)
-		werr := b.Put([]byte("version"), buf.Bytes())
-		if werr != nil {
-			glog.Error("Couldn't write version!")
-			return werr
+func (db *DB) Get(key []byte) ([]byte, error) {
+	var out []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(dataBucket).Get(key); v != nil {
+			out = append([]byte{}, v...)
 		}
 		return nil
 	})
-}
-
-func (qs *QuadStore) Size() int64 {
-	return qs.size
-}
-
-func (qs *QuadStore) Horizon() graph.PrimaryKey {
-	return graph.NewSequentialKey(qs.horizon)
-}
-
-func (qs *QuadStore) createDeltaKeyFor(id int64) []byte {
-	return []byte(fmt.Sprintf("%018x", id))
-}
-
-func bucketFor(d [4]quad.Direction) []byte {
-	return []byte{d[0].Prefix(), d[1].Prefix(), d[2].Prefix(), d[3].Prefix()}
-}
-
-func (qs *QuadStore) createKeyFor(d [4]quad.Direction, q quad.Quad) []byte {
-	key := make([]byte, quad.HashSize*4)
-	quad.HashTo(q.Get(d[0]), key[quad.HashSize*0:quad.HashSize*1])
-	quad.HashTo(q.Get(d[1]), key[quad.HashSize*1:quad.HashSize*2])
-	quad.HashTo(q.Get(d[2]), key[quad.HashSize*2:quad.HashSize*3])
-	quad.HashTo(q.Get(d[3]), key[quad.HashSize*3:quad.HashSize*4])
-	return key
-}
-
-func (qs *QuadStore) createValueKeyFor(s quad.Value) []byte {
-	return quad.HashOf(s)
-}
-
-var (
-	// Short hand for direction permutations.
-	spo = [4]quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
-	osp = [4]quad.Direction{quad.Object, quad.Subject, quad.Predicate, quad.Label}
-	pos = [4]quad.Direction{quad.Predicate, quad.Object, quad.Subject, quad.Label}
-	cps = [4]quad.Direction{quad.Label, quad.Predicate, quad.Subject, quad.Object}
-
-	// Byte arrays for each bucket name.
-	spoBucket  = bucketFor(spo)
-	ospBucket  = bucketFor(osp)
-	posBucket  = bucketFor(pos)
-	cpsBucket  = bucketFor(cps)
-	logBucket  = []byte("log")
-	nodeBucket = []byte("node")
-	metaBucket = []byte("meta")
-)
-
-func deltaToProto(delta graph.Delta) proto.LogDelta {
-	var newd proto.LogDelta
-	newd.ID = uint64(delta.ID.Int())
-	newd.Action = int32(delta.Action)
-	newd.Timestamp = delta.Timestamp.UnixNano()
-	newd.Quad = proto.MakeQuad(delta.Quad)
-	return newd
-}
-
-func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
-	oldSize := qs.size
-	oldHorizon := qs.horizon
-	err := qs.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(logBucket)
-		b.FillPercent = localFillPercent
-		resizeMap := make(map[quad.Value]int64)
-		sizeChange := int64(0)
-		for _, d := range deltas {
-			if d.Action != graph.Add && d.Action != graph.Delete {
-				return errors.New("bolt: invalid action")
-			}
-			p := deltaToProto(d)
-			bytes, err := p.Marshal()
-			if err != nil {
-				return err
-			}
-			err = b.Put(qs.createDeltaKeyFor(d.ID.Int()), bytes)
-			if err != nil {
-				return err
-			}
-		}
-		for _, d := range deltas {
-			err := qs.buildQuadWrite(tx, d.Quad, d.ID.Int(), d.Action == graph.Add)
-			if err != nil {
-				if err == graph.ErrQuadExists && ignoreOpts.IgnoreDup {
-					continue
-				}
-				if err == graph.ErrQuadNotExist && ignoreOpts.IgnoreMissing {
-					continue
-				}
-				return err
-			}
-			delta := int64(1)
-			if d.Action == graph.Delete {
-				delta = int64(-1)
-			}
-			resizeMap[d.Quad.Subject] += delta
-			resizeMap[d.Quad.Predicate] += delta
-			resizeMap[d.Quad.Object] += delta
-			if d.Quad.Label != nil {
-				resizeMap[d.Quad.Label] += delta
-			}
-			sizeChange += delta
-			qs.horizon = d.ID.Int()
-		}
-		for k, v := range resizeMap {
-			if v != 0 {
-				err := qs.UpdateValueKeyBy(k, v, tx)
-				if err != nil {
-					return err
-				}
+	return out, err
+}
+
+// Scan returns a cursor which reads the keys a few at a time, each time in a
+// new transaction, so that it does not keep the writes from remapping the
+// file.
+func (db *DB) Scan(prefix []byte) kv.Cursor {
+	return &cursor{db: db.db, prefix: prefix, i: -1}
+}
+
+type cursor struct {
+	db     *bolt.DB
+	prefix []byte
+	last   []byte
+	keys   [][]byte
+	vals   [][]byte
+	i      int
+	done   bool
+	err    error
+}
+
+func (c *cursor) Next() bool {
+	c.i++
+	if c.i < len(c.keys) {
+		return true
+	}
+	if c.done {
+		return false
+	}
+	c.keys, c.vals, c.i = c.keys[:0], c.vals[:0], 0
+	c.err = c.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Bucket(dataBucket).Cursor()
+		var k, v []byte
+		if c.last == nil {
+			k, v = cur.Seek(c.prefix)
+		} else if k, v = cur.Seek(c.last); bytes.Equal(k, c.last) {
+			k, v = cur.Next()
+		}
+		for ; k != nil && bytes.HasPrefix(k, c.prefix); k, v = cur.Next() {
+			if len(c.keys) == bufferSize {
+				return nil
 			}
+			c.keys = append(c.keys, append([]byte{}, k...))
+			c.vals = append(c.vals, append([]byte{}, v...))
 		}
-		qs.size += sizeChange
-		return qs.WriteHorizonAndSize(tx)
+		c.done = true
+		return nil
 	})
-
-	if err != nil {
-		glog.Error("Couldn't write to DB for Delta set. Error: ", err)
-		qs.horizon = oldHorizon
-		qs.size = oldSize
-		return err
+	if c.err != nil || len(c.keys) == 0 {
+		c.done = true
+		return false
 	}
-	return nil
+	c.last = c.keys[len(c.keys)-1]
+	return true
 }
 
-func (qs *QuadStore) buildQuadWrite(tx *bolt.Tx, q quad.Quad, id int64, isAdd bool) error {
-	var entry proto.HistoryEntry
-	b := tx.Bucket(spoBucket)
-	b.FillPercent = localFillPercent
-	data := b.Get(qs.createKeyFor(spo, q))
-	if data != nil {
-		// We got something.
-		err := entry.Unmarshal(data)
-		if err != nil {
-			return err
-		}
-	}
-
-	if isAdd && len(entry.History)%2 == 1 {
-		glog.Errorf("attempt to add existing quad %v: %#v", entry, q)
-		return graph.ErrQuadExists
-	}
-	if !isAdd && len(entry.History)%2 == 0 {
-		glog.Errorf("attempt to delete non-existent quad %v: %#v", entry, q)
-		return graph.ErrQuadNotExist
-	}
-
-	entry.History = append(entry.History, uint64(id))
+func (c *cursor) Key() []byte { return c.keys[c.i] }
+func (c *cursor) Val() []byte { return c.vals[c.i] }
+func (c *cursor) Err() error  { return c.err }
 
-	bytes, err := entry.Marshal()
-	if err != nil {
-		glog.Errorf("Couldn't write to buffer for entry %#v: %s", entry, err)
-		return err
-	}
-	for _, index := range [][4]quad.Direction{spo, osp, pos, cps} {
-		if index == cps && q.Get(quad.Label) == nil {
-			continue
-		}
-		b := tx.Bucket(bucketFor(index))
-		b.FillPercent = localFillPercent
-		err = b.Put(qs.createKeyFor(index, q), bytes)
-		if err != nil {
-			return err
-		}
-	}
+func (c *cursor) Close() error {
+	c.keys, c.vals = nil, nil
+	c.done = true
 	return nil
 }
 
-func (qs *QuadStore) UpdateValueKeyBy(name quad.Value, amount int64, tx *bolt.Tx) error {
-	value := proto.NodeData{
-		Value: proto.MakeValue(name),
-		Size:  amount,
-	}
-	b := tx.Bucket(nodeBucket)
-	b.FillPercent = localFillPercent
-	key := qs.createValueKeyFor(name)
-	data := b.Get(key)
-
-	if data != nil {
-		// Node exists in the database -- unmarshal and update.
-		var oldvalue proto.NodeData
-		err := oldvalue.Unmarshal(data)
-		if err != nil {
-			glog.Errorf("Error: couldn't reconstruct value: %v", err)
-			return err
-		}
-		oldvalue.Size += amount
-		value = oldvalue
-	}
-
-	// Are we deleting something?
-	if value.Size <= 0 {
-		value.Size = 0
-	}
-
-	// Repackage and rewrite.
-	bytes, err := value.Marshal()
-	if err != nil {
-		glog.Errorf("Couldn't write to buffer for value %s: %s", name, err)
-		return err
-	}
-	err = b.Put(key, bytes)
-	return err
+func (db *DB) NewBatch() kv.Batch {
+	return &batch{db: db.db}
 }
 
-func (qs *QuadStore) WriteHorizonAndSize(tx *bolt.Tx) error {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, qs.size)
-	if err != nil {
-		glog.Errorf("Couldn't convert size!")
-		return err
-	}
-	b := tx.Bucket(metaBucket)
-	b.FillPercent = localFillPercent
-	werr := b.Put([]byte("size"), buf.Bytes())
-	if werr != nil {
-		glog.Error("Couldn't write size!")
-		return werr
-	}
-	buf.Reset()
-	err = binary.Write(buf, binary.LittleEndian, qs.horizon)
-
-	if err != nil {
-		glog.Errorf("Couldn't convert horizon!")
-	}
-
-	werr = b.Put([]byte("horizon"), buf.Bytes())
-
-	if werr != nil {
-		glog.Error("Couldn't write horizon!")
-		return werr
-	}
-	return err
+// batch holds the writes until they are made in a single transaction.
+type batch struct {
+	db  *bolt.DB
+	ops []op
 }
 
-func (qs *QuadStore) Close() {
-	qs.db.Update(func(tx *bolt.Tx) error {
-		return qs.WriteHorizonAndSize(tx)
-	})
-	qs.db.Close()
-	qs.open = false
+type op struct {
+	key, val []byte
+	del      bool
 }
 
-func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
-	var d proto.LogDelta
-	tok := k.(*Token)
-	err := qs.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(tok.bucket)
-		data := b.Get(tok.key)
-		if data == nil {
-			return nil
-		}
-		var in proto.HistoryEntry
-		err := in.Unmarshal(data)
-		if err != nil {
-			return err
-		}
-		if len(in.History) == 0 {
-			return nil
-		}
-		b = tx.Bucket(logBucket)
-		data = b.Get(qs.createDeltaKeyFor(int64(in.History[len(in.History)-1])))
-		if data == nil {
-			// No harm, no foul.
-			return nil
-		}
-		return d.Unmarshal(data)
-	})
-	if err != nil {
-		glog.Error("Error getting quad: ", err)
-		return quad.Quad{}
-	}
-	return d.Quad.ToNative()
+func (b *batch) Put(key, val []byte) {
+	b.ops = append(b.ops, op{key: key, val: val})
 }
 
-func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
-	return &Token{
-		bucket: nodeBucket,
-		key:    qs.createValueKeyFor(s),
-	}
+func (b *batch) Delete(key []byte) {
+	b.ops = append(b.ops, op{key: key, del: true})
 }
 
-func (qs *QuadStore) valueData(t *Token) proto.NodeData {
-	var out proto.NodeData
-	if glog.V(3) {
-		glog.V(3).Infof("%s %v", string(t.bucket), t.key)
-	}
-	err := qs.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(t.bucket)
-		data := b.Get(t.key)
-		if data != nil {
-			return out.Unmarshal(data)
+func (b *batch) Commit() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(dataBucket)
+		bk.FillPercent = localFillPercent
+		for _, o := range b.ops {
+			var err error
+			if o.del {
+				err = bk.Delete(o.key)
+			} else {
+				err = bk.Put(o.key, o.val)
+			}
+			if err != nil {
+				return err
+			}
 		}
 		return nil
 	})
-	if err != nil {
-		glog.Errorln("Error: couldn't get value")
-		return proto.NodeData{}
-	}
-	return out
-}
-
-func (qs *QuadStore) NameOf(k graph.Value) quad.Value {
-	if k == nil {
-		glog.V(2).Info("k was nil")
-		return nil
-	}
-	v := qs.valueData(k.(*Token))
-	return v.GetNativeValue()
-}
-
-func (qs *QuadStore) SizeOf(k graph.Value) int64 {
-	if k == nil {
-		return -1
-	}
-	return int64(qs.valueData(k.(*Token)).Size)
-}
-
-func getInt64ForMetaKey(tx *bolt.Tx, key string, empty int64) (int64, error) {
-	var out int64
-	b := tx.Bucket(metaBucket)
-	if b == nil {
-		return empty, errNoBucket
-	}
-	data := b.Get([]byte(key))
-	if data == nil {
-		return empty, nil
-	}
-	buf := bytes.NewBuffer(data)
-	err := binary.Read(buf, binary.LittleEndian, &out)
-	if err != nil {
-		return 0, err
-	}
-	return out, nil
-}
-
-func (qs *QuadStore) getMetadata() error {
-	err := qs.db.View(func(tx *bolt.Tx) error {
-		var err error
-		qs.size, err = getInt64ForMetaKey(tx, "size", 0)
-		if err != nil {
-			return err
-		}
-		qs.version, err = getInt64ForMetaKey(tx, "version", nilDataVersion)
-		if err != nil {
-			return err
-		}
-		qs.horizon, err = getInt64ForMetaKey(tx, "horizon", 0)
-		return err
-	})
-	return err
-}
-
-func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Value) graph.Iterator {
-	var bucket []byte
-	switch d {
-	case quad.Subject:
-		bucket = spoBucket
-	case quad.Predicate:
-		bucket = posBucket
-	case quad.Object:
-		bucket = ospBucket
-	case quad.Label:
-		bucket = cpsBucket
-	default:
-		panic("unreachable " + d.String())
-	}
-	return NewIterator(bucket, d, val, qs)
-}
-
-func (qs *QuadStore) NodesAllIterator() graph.Iterator {
-	return NewAllIterator(nodeBucket, quad.Any, qs)
-}
-
-func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
-	return NewAllIterator(posBucket, quad.Predicate, qs)
-}
-
-func (qs *QuadStore) QuadDirection(val graph.Value, d quad.Direction) graph.Value {
-	v := val.(*Token)
-	offset := PositionOf(v, d, qs)
-	if offset != -1 {
-		return &Token{
-			bucket: nodeBucket,
-			key:    v.key[offset : offset+quad.HashSize],
-		}
-	}
-	return qs.ValueOf(qs.Quad(v).Get(d))
-}
-
-func compareTokens(a, b graph.Value) bool {
-	atok := a.(*Token)
-	btok := b.(*Token)
-	return bytes.Equal(atok.key, btok.key) && bytes.Equal(atok.bucket, btok.bucket)
-}
-
-func (qs *QuadStore) FixedIterator() graph.FixedIterator {
-	return iterator.NewFixed(compareTokens)
 }
 
-func (qs *QuadStore) Type() string {
-	return QuadStoreType
+func (db *DB) Close() error {
+	return db.db.Close()
 }
@@ -0,0 +1,185 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+// Describes iterator trees as query plans, and measures the time spent in
+// their iterators.
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cayley/quad"
+)
+
+// IteratorPlan describes an iterator of a query plan with its estimated
+// costs and, once it ran, the calls actually made to it. The times include
+// the calls made to the subiterators.
+type IteratorPlan struct {
+	UID          uint64
+	Type         Type
+	Name         string         `json:",omitempty"`
+	Tags         []string       `json:",omitempty"`
+	Direction    quad.Direction `json:",omitempty"`
+	Size         int64
+	ExactSize    bool
+	NextCost     int64
+	ContainsCost int64
+	Next         int64          `json:",omitempty"`
+	Contains     int64          `json:",omitempty"`
+	ContainsNext int64          `json:",omitempty"`
+	NextTime     time.Duration  `json:",omitempty"`
+	ContainsTime time.Duration  `json:",omitempty"`
+	SubIts       []IteratorPlan `json:",omitempty"`
+}
+
+// DumpPlan describes the iterator tree, with the times recorded by the
+// profile, which may be nil.
+func DumpPlan(it Iterator, p *Profile) IteratorPlan {
+	desc := it.Describe()
+	stats := it.Stats()
+	out := IteratorPlan{
+		UID:          it.UID(),
+		Type:         it.Type(),
+		Name:         desc.Name,
+		Tags:         desc.Tags,
+		Direction:    desc.Direction,
+		NextCost:     stats.NextCost,
+		ContainsCost: stats.ContainsCost,
+		Next:         stats.Next,
+		Contains:     stats.Contains,
+		ContainsNext: stats.ContainsNext,
+	}
+	out.Size, out.ExactSize = it.Size()
+	if p != nil {
+		out.NextTime, out.ContainsTime = p.Times(out.UID)
+	}
+	for _, sub := range it.SubIterators() {
+		out.SubIts = append(out.SubIts, DumpPlan(sub, p))
+	}
+	return out
+}
+
+type callTimes struct {
+	next, contains           time.Duration
+	nextStart, containsStart time.Time
+}
+
+var (
+	// profiling is the number of running profiles, so that the iterators
+	// only look up their timers when there are some.
+	profiling int32
+
+	profileLock sync.Mutex
+	profiled    = make(map[uint64]*callTimes)
+)
+
+// Profile records the time spent in the Next and Contains calls of
+// iterators, from the calls to NextLogIn and ContainsLogIn to the calls to
+// NextLogOut and ContainsLogOut.
+type Profile struct {
+	uids    []uint64
+	stopped bool
+	times   map[uint64]callTimes
+}
+
+// StartProfile starts recording the times of the given iterator trees.
+func StartProfile(its ...Iterator) *Profile {
+	p := &Profile{}
+	atomic.AddInt32(&profiling, 1)
+	for _, it := range its {
+		p.Add(it)
+	}
+	return p
+}
+
+// Add starts recording the times of the iterator tree.
+func (p *Profile) Add(it Iterator) {
+	if p.stopped {
+		return
+	}
+	profileLock.Lock()
+	p.add(it)
+	profileLock.Unlock()
+}
+
+func (p *Profile) add(it Iterator) {
+	uid := it.UID()
+	if _, ok := profiled[uid]; !ok {
+		profiled[uid] = &callTimes{}
+		p.uids = append(p.uids, uid)
+	}
+	for _, sub := range it.SubIterators() {
+		p.add(sub)
+	}
+}
+
+// Stop stops recording the times, which are kept in the profile.
+func (p *Profile) Stop() {
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	p.times = make(map[uint64]callTimes, len(p.uids))
+	profileLock.Lock()
+	for _, uid := range p.uids {
+		p.times[uid] = *profiled[uid]
+		delete(profiled, uid)
+	}
+	profileLock.Unlock()
+	atomic.AddInt32(&profiling, -1)
+}
+
+// Times returns the time spent in the Next and Contains calls of the
+// iterator. The profile has to be stopped.
+func (p *Profile) Times(uid uint64) (next, contains time.Duration) {
+	t := p.times[uid]
+	return t.next, t.contains
+}
+
+// profileIn records the start of a call, if the iterator is profiled.
+func profileIn(it Iterator, next bool) {
+	if atomic.LoadInt32(&profiling) == 0 {
+		return
+	}
+	profileLock.Lock()
+	if t := profiled[it.UID()]; t != nil {
+		if next {
+			t.nextStart = time.Now()
+		} else {
+			t.containsStart = time.Now()
+		}
+	}
+	profileLock.Unlock()
+}
+
+// profileOut adds the duration of a call to the time of the iterator.
+func profileOut(it Iterator, next bool) {
+	if atomic.LoadInt32(&profiling) == 0 {
+		return
+	}
+	profileLock.Lock()
+	if t := profiled[it.UID()]; t != nil {
+		if next && !t.nextStart.IsZero() {
+			t.next += time.Since(t.nextStart)
+			t.nextStart = time.Time{}
+		} else if !next && !t.containsStart.IsZero() {
+			t.contains += time.Since(t.containsStart)
+			t.containsStart = time.Time{}
+		}
+	}
+	profileLock.Unlock()
+}
@@ -24,6 +24,7 @@ import (
 
 	"appengine/datastore"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 )
 
 type Iterator struct {
@@ -142,7 +143,7 @@ func (it *Iterator) Close() error {
 func (it *Iterator) Tagger() *graph.Tagger {
 	return &it.tags
 }
-func (it *Iterator) Contains(v graph.Value) bool {
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 	graph.ContainsLogIn(it, v)
 	if it.isAll {
 		// The result needs to be set, so when contains is called, the result can be retrieved
@@ -200,7 +201,7 @@ func (it *Iterator) Clone() graph.Iterator {
 	return m
 }
 
-func (it *Iterator) NextPath() bool {
+func (it *Iterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -213,7 +214,7 @@ func (it *Iterator) Result() graph.Value {
 	return it.result
 }
 
-func (it *Iterator) Next() bool {
+func (it *Iterator) Next(ctx context.Context) bool {
 	if it.offset+1 < len(it.buffer) {
 		it.offset++
 		it.result = &Token{Kind: it.kind, Hash: it.buffer[it.offset]}
@@ -222,6 +223,10 @@ func (it *Iterator) Next() bool {
 	if it.done {
 		return false
 	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
 	// Reset buffer and offset
 	it.offset = 0
 	it.buffer = make([]string, 0, bufferSize)
@@ -413,6 +413,9 @@ func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
 }
 
 func (qs *QuadStore) NameOf(val graph.Value) quad.Value {
+	if v, ok := val.(graph.PreFetchedValue); ok {
+		return v.NameOf()
+	}
 	if qs.context == nil {
 		glog.Error("Error in NameOf, context is nil, graph not correctly initialised")
 		return nil
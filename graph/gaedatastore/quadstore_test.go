@@ -23,6 +23,7 @@ import (
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/writer"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
 
 	"appengine/aetest"
 )
@@ -138,7 +139,7 @@ func TestIterators(t *testing.T) {
 	key := gqs.createKeyForQuad(quad.Make("G", "status", "cool", "status_graph"))
 	token := &Token{quadKind, key.StringID()}
 
-	require.True(t, it.Contains(token), "Contains failed")
+	require.True(t, it.Contains(context.TODO(), token), "Contains failed")
 
 	// Test cloning an iterator
 	var it2 graph.Iterator
@@ -11,6 +11,7 @@ import (
 	"github.com/google/cayley/writer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
 )
 
 type DatabaseFunc func(t testing.TB) (graph.QuadStore, graph.Options, func())
@@ -38,6 +39,7 @@ func TestAll(t testing.TB, gen DatabaseFunc, conf *Config) {
 	TestLoadOneQuad(t, gen)
 	if !conf.SkipIntHorizon {
 		TestHorizonInt(t, gen, conf)
+		TestDeltaLog(t, gen)
 	}
 	TestIterator(t, gen)
 	TestSetIterator(t, gen)
@@ -47,6 +49,7 @@ func TestAll(t testing.TB, gen DatabaseFunc, conf *Config) {
 	TestLoadTypedQuads(t, gen, conf)
 	TestAddRemove(t, gen, conf)
 	TestIteratorsAndNextResultOrderA(t, gen)
+	TestIteratorCancel(t, gen)
 	if !conf.UnTyped {
 		TestCompareTypedValues(t, gen, conf)
 	}
@@ -93,7 +96,7 @@ func MakeQuadSet() []quad.Quad {
 
 func IteratedQuads(t testing.TB, qs graph.QuadStore, it graph.Iterator) []quad.Quad {
 	var res quad.ByQuadString
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		res = append(res, qs.Quad(it.Result()))
 	}
 	require.Nil(t, it.Err())
@@ -131,7 +134,7 @@ func ExpectIteratedValues(t testing.TB, qs graph.QuadStore, it graph.Iterator, e
 
 func IteratedRawStrings(t testing.TB, qs graph.QuadStore, it graph.Iterator) []string {
 	var res []string
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		res = append(res, qs.NameOf(it.Result()).String())
 	}
 	require.Nil(t, it.Err())
@@ -141,7 +144,7 @@ func IteratedRawStrings(t testing.TB, qs graph.QuadStore, it graph.Iterator) []s
 
 func IteratedValues(t testing.TB, qs graph.QuadStore, it graph.Iterator) []quad.Value {
 	var res []quad.Value
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		res = append(res, qs.NameOf(it.Result()))
 	}
 	require.Nil(t, it.Err())
@@ -169,6 +172,48 @@ func TestLoadOneQuad(t testing.TB, gen DatabaseFunc) {
 	require.Equal(t, int64(1), qs.Size(), "Unexpected quadstore size")
 }
 
+// TestDeltaLog checks the deltas logged by the quad stores which implement
+// graph.DeltaLog with sequential keys.
+func TestDeltaLog(t testing.TB, gen DatabaseFunc) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	dl, ok := qs.(graph.DeltaLog)
+	if !ok {
+		return
+	}
+	w := MakeWriter(t, qs, opts, MakeQuadSet()...)
+	del := quad.Make("A", "follows", "B", "")
+	err := w.RemoveQuad(del)
+	require.Nil(t, err)
+
+	deltas, err := dl.DeltasSince(nil, 0)
+	require.Nil(t, err)
+	require.Equal(t, 12, len(deltas), "Unexpected number of deltas")
+	for i := range deltas {
+		require.Equal(t, int64(i+1), deltas[i].ID.Int(), "Unexpected delta ID")
+	}
+	require.Equal(t, MakeQuadSet()[0], deltas[0].Quad, "Unexpected quad of the first delta")
+	require.Equal(t, graph.Add, deltas[0].Action, "Unexpected action of the first delta")
+	require.Equal(t, del, deltas[11].Quad, "Unexpected quad of the last delta")
+	require.Equal(t, graph.Delete, deltas[11].Action, "Unexpected action of the last delta")
+
+	horizon := graph.NewSequentialKey(10)
+	deltas, err = dl.DeltasSince(&horizon, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(deltas), "Unexpected number of deltas after the horizon")
+	require.Equal(t, int64(11), deltas[0].ID.Int(), "Unexpected delta ID")
+
+	deltas, err = dl.DeltasSince(nil, 5)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(deltas), "Unexpected number of deltas with a limit")
+
+	horizon = qs.Horizon()
+	deltas, err = dl.DeltasSince(&horizon, 0)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(deltas), "Unexpected deltas after the horizon of the store")
+}
+
 type ValueSizer interface {
 	SizeOf(graph.Value) int64
 }
@@ -257,13 +302,13 @@ func TestIterator(t testing.TB, gen DatabaseFunc) {
 	}
 
 	for _, pq := range expect {
-		require.True(t, it.Contains(qs.ValueOf(quad.Raw(pq))), "Failed to find and check %q correctly", pq)
+		require.True(t, it.Contains(context.TODO(), qs.ValueOf(quad.Raw(pq))), "Failed to find and check %q correctly", pq)
 
 	}
 	// FIXME(kortschak) Why does this fail?
 	/*
 		for _, pq := range []string{"baller"} {
-			if it.Contains(qs.ValueOf(pq)) {
+			if it.Contains(context.TODO(), qs.ValueOf(pq)) {
 				t.Errorf("Failed to check %q correctly", pq)
 			}
 		}
@@ -274,7 +319,7 @@ func TestIterator(t testing.TB, gen DatabaseFunc) {
 	optIt, changed = it.Optimize()
 	require.True(t, !changed && optIt == it, "Optimize unexpectedly changed iterator: %v, %T", changed, optIt)
 
-	require.True(t, graph.Next(it))
+	require.True(t, graph.Next(context.TODO(), it))
 
 	q := qs.Quad(it.Result())
 	require.Nil(t, it.Err())
@@ -290,6 +335,26 @@ func TestIterator(t testing.TB, gen DatabaseFunc) {
 	require.True(t, ok, "Failed to find %q during iteration, got:%q", q, set)
 }
 
+func TestIteratorCancel(t testing.TB, gen DatabaseFunc) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, it := range []graph.Iterator{
+		qs.NodesAllIterator(),
+		qs.QuadsAllIterator(),
+		qs.QuadIterator(quad.Subject, qs.ValueOf(quad.Raw("C"))),
+	} {
+		require.False(t, graph.Next(ctx, it), "Iterator %T returned a result after cancellation", it)
+		require.Equal(t, context.Canceled, it.Err(), "Unexpected error from %T", it)
+		it.Close()
+	}
+}
+
 func TestSetIterator(t testing.TB, gen DatabaseFunc) {
 	qs, opts, closer := gen(t)
 	defer closer()
@@ -581,7 +646,7 @@ func TestIteratorsAndNextResultOrderA(t testing.TB, gen DatabaseFunc) {
 	outerAnd.AddSubIterator(fixed)
 	outerAnd.AddSubIterator(hasa)
 
-	require.True(t, outerAnd.Next(), "Expected one matching subtree")
+	require.True(t, outerAnd.Next(context.TODO()), "Expected one matching subtree")
 
 	val := outerAnd.Result()
 	require.Equal(t, quad.Raw("C"), qs.NameOf(val))
@@ -592,7 +657,7 @@ func TestIteratorsAndNextResultOrderA(t testing.TB, gen DatabaseFunc) {
 	)
 	for {
 		got = append(got, qs.NameOf(all.Result()).String())
-		if !outerAnd.NextPath() {
+		if !outerAnd.NextPath(context.TODO()) {
 			break
 		}
 	}
@@ -600,7 +665,7 @@ func TestIteratorsAndNextResultOrderA(t testing.TB, gen DatabaseFunc) {
 
 	require.Equal(t, expect, got)
 
-	require.True(t, !outerAnd.Next(), "More than one possible top level output?")
+	require.True(t, !outerAnd.Next(context.TODO()), "More than one possible top level output?")
 }
 
 const lt, lte, gt, gte = iterator.CompareLT, iterator.CompareLTE, iterator.CompareGT, iterator.CompareGTE
@@ -22,6 +22,8 @@ import (
 	"sync"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/quad"
 )
 
@@ -95,10 +97,10 @@ type Iterator interface {
 	//
 	// To get the full results of iteration, do the following:
 	//
-	//  for graph.Next(it) {
+	//  for graph.Next(ctx, it) {
 	//  	val := it.Result()
 	//  	... do things with val.
-	//  	for it.NextPath() {
+	//  	for it.NextPath(ctx) {
 	//  		... find other paths to iterate
 	//  	}
 	//  }
@@ -108,10 +110,14 @@ type Iterator interface {
 	//
 	// NextPath() advances iterators that may have more than one valid result,
 	// from the bottom up.
-	NextPath() bool
+	//
+	// The context passed to Next, NextPath and Contains is used to abort the
+	// iteration. Once the context is done, these methods return false and Err
+	// returns the context error.
+	NextPath(ctx context.Context) bool
 
 	// Contains returns whether the value is within the set held by the iterator.
-	Contains(Value) bool
+	Contains(ctx context.Context, v Value) bool
 
 	// Err returns any error that was encountered by the Iterator.
 	Err() error
@@ -177,7 +183,7 @@ type Nexter interface {
 	// the Result method. It returns false if no further advancement is possible, or if an
 	// error was encountered during iteration.  Err should be consulted to distinguish
 	// between the two cases.
-	Next() bool
+	Next(ctx context.Context) bool
 
 	Iterator
 }
@@ -185,9 +191,9 @@ type Nexter interface {
 // Next is a convenience function that conditionally calls the Next method
 // of an Iterator if it is a Nexter. If the Iterator is not a Nexter, Next
 // returns false.
-func Next(it Iterator) bool {
+func Next(ctx context.Context, it Iterator) bool {
 	if n, ok := it.(Nexter); ok {
-		return n.Next()
+		return n.Next(ctx)
 	}
 	glog.Errorln("Nexting an un-nextable iterator")
 	return false
@@ -242,6 +248,10 @@ const (
 	Optional
 	Materialize
 	Unique
+	Limit
+	Skip
+	Sort
+	Recursive
 )
 
 var (
@@ -264,6 +274,10 @@ var (
 		"optional",
 		"materialize",
 		"unique",
+		"limit",
+		"skip",
+		"sort",
+		"recursive",
 	}
 )
 
@@ -330,14 +344,17 @@ func DumpStats(it Iterator) StatsContainer {
 
 // Utility logging functions for when an iterator gets called Next upon, or Contains upon, as
 // well as what they return. Highly useful for tracing the execution path of a query.
+// They also record the time spent in the calls when the iterator is profiled.
 
 func ContainsLogIn(it Iterator, val Value) {
+	profileIn(it, false)
 	if glog.V(4) {
 		glog.V(4).Infof("%s %d CHECK CONTAINS %v", strings.ToUpper(it.Type().String()), it.UID(), val)
 	}
 }
 
 func ContainsLogOut(it Iterator, val Value, good bool) bool {
+	profileOut(it, false)
 	if glog.V(4) {
 		if good {
 			glog.V(4).Infof("%s %d CHECK CONTAINS %v GOOD", strings.ToUpper(it.Type().String()), it.UID(), val)
@@ -349,12 +366,14 @@ func ContainsLogOut(it Iterator, val Value, good bool) bool {
 }
 
 func NextLogIn(it Iterator) {
+	profileIn(it, true)
 	if glog.V(4) {
 		glog.V(4).Infof("%s %d NEXT", strings.ToUpper(it.Type().String()), it.UID())
 	}
 }
 
 func NextLogOut(it Iterator, val Value, ok bool) bool {
+	profileOut(it, true)
 	if glog.V(4) {
 		if ok {
 			glog.V(4).Infof("%s %d NEXT IS %v", strings.ToUpper(it.Type().String()), it.UID(), val)
@@ -23,6 +23,8 @@ package iterator
 // the base iterators, and it helps just to see it here.
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -35,6 +37,7 @@ type Int64 struct {
 	at       int64
 	result   int64
 	runstats graph.IteratorStats
+	err      error
 }
 
 type Int64Node int64
@@ -63,6 +66,7 @@ func (it *Int64) UID() uint64 {
 // Start back at the beginning
 func (it *Int64) Reset() {
 	it.at = it.min
+	it.err = nil
 }
 
 func (it *Int64) Close() error {
@@ -100,9 +104,13 @@ func (it *Int64) Describe() graph.Description {
 
 // Next() on an Int64 all iterator is a simple incrementing counter.
 // Return the next integer, and mark it as the result.
-func (it *Int64) Next() bool {
+func (it *Int64) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return graph.NextLogOut(it, nil, false)
+	}
 	if it.at == -1 {
 		return graph.NextLogOut(it, nil, false)
 	}
@@ -116,7 +124,7 @@ func (it *Int64) Next() bool {
 }
 
 func (it *Int64) Err() error {
-	return nil
+	return it.err
 }
 
 func (it *Int64) toValue(v int64) graph.Value {
@@ -130,7 +138,7 @@ func (it *Int64) Result() graph.Value {
 	return it.toValue(it.result)
 }
 
-func (it *Int64) NextPath() bool {
+func (it *Int64) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -148,7 +156,7 @@ func (it *Int64) Size() (int64, bool) {
 
 // Contains() for an Int64 is merely seeing if the passed value is
 // withing the range, assuming the value is an int64.
-func (it *Int64) Contains(tsv graph.Value) bool {
+func (it *Int64) Contains(ctx context.Context, tsv graph.Value) bool {
 	graph.ContainsLogIn(it, tsv)
 	it.runstats.Contains += 1
 	var v int64
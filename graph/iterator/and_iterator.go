@@ -16,6 +16,8 @@
 package iterator
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -137,12 +139,12 @@ func (it *And) AddSubIterator(sub graph.Iterator) {
 // subiterators, it must choose one subiterator to produce a candidate, and check
 // this value against the subiterators. A productive choice of primary iterator
 // is therefore very important.
-func (it *And) Next() bool {
+func (it *And) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
-	for graph.Next(it.primaryIt) {
+	for graph.Next(ctx, it.primaryIt) {
 		curr := it.primaryIt.Result()
-		if it.subItsContain(curr, nil) {
+		if it.subItsContain(ctx, curr, nil) {
 			it.result = curr
 			return graph.NextLogOut(it, curr, true)
 		}
@@ -160,14 +162,14 @@ func (it *And) Result() graph.Value {
 }
 
 // Checks a value against the non-primary iterators, in order.
-func (it *And) subItsContain(val graph.Value, lastResult graph.Value) bool {
+func (it *And) subItsContain(ctx context.Context, val graph.Value, lastResult graph.Value) bool {
 	var subIsGood = true
 	for i, sub := range it.internalIterators {
-		subIsGood = sub.Contains(val)
+		subIsGood = sub.Contains(ctx, val)
 		if !subIsGood {
 			if lastResult != nil {
 				for j := 0; j < i; j++ {
-					it.internalIterators[j].Contains(lastResult)
+					it.internalIterators[j].Contains(ctx, lastResult)
 				}
 			}
 			break
@@ -176,10 +178,10 @@ func (it *And) subItsContain(val graph.Value, lastResult graph.Value) bool {
 	return subIsGood
 }
 
-func (it *And) checkContainsList(val graph.Value, lastResult graph.Value) bool {
+func (it *And) checkContainsList(ctx context.Context, val graph.Value, lastResult graph.Value) bool {
 	ok := true
 	for i, c := range it.checkList {
-		ok = c.Contains(val)
+		ok = c.Contains(ctx, val)
 		if !ok {
 			it.err = c.Err()
 			if it.err != nil {
@@ -195,7 +197,7 @@ func (it *And) checkContainsList(val graph.Value, lastResult graph.Value) bool {
 					// seeking back exactly one -- so we check all the prior iterators
 					// with the (already verified) result and throw away the result,
 					// which will be 'true'
-					it.checkList[j].Contains(lastResult)
+					it.checkList[j].Contains(ctx, lastResult)
 
 					it.err = it.checkList[j].Err()
 					if it.err != nil {
@@ -213,23 +215,23 @@ func (it *And) checkContainsList(val graph.Value, lastResult graph.Value) bool {
 }
 
 // Check a value against the entire iterator, in order.
-func (it *And) Contains(val graph.Value) bool {
+func (it *And) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
 	lastResult := it.result
 	if it.checkList != nil {
-		return it.checkContainsList(val, lastResult)
+		return it.checkContainsList(ctx, val, lastResult)
 	}
-	mainGood := it.primaryIt.Contains(val)
+	mainGood := it.primaryIt.Contains(ctx, val)
 	if mainGood {
-		othersGood := it.subItsContain(val, lastResult)
+		othersGood := it.subItsContain(ctx, val, lastResult)
 		if othersGood {
 			it.result = val
 			return graph.ContainsLogOut(it, val, true)
 		}
 	}
 	if lastResult != nil {
-		it.primaryIt.Contains(lastResult)
+		it.primaryIt.Contains(ctx, lastResult)
 	}
 	return graph.ContainsLogOut(it, val, false)
 }
@@ -253,8 +255,8 @@ func (it *And) Size() (int64, bool) {
 // An And has no NextPath of its own -- that is, there are no other values
 // which satisfy our previous result that are not the result itself. Our
 // subiterators might, however, so just pass the call recursively.
-func (it *And) NextPath() bool {
-	if it.primaryIt.NextPath() {
+func (it *And) NextPath(ctx context.Context) bool {
+	if it.primaryIt.NextPath(ctx) {
 		return true
 	}
 	it.err = it.primaryIt.Err()
@@ -262,7 +264,7 @@ func (it *And) NextPath() bool {
 		return false
 	}
 	for _, sub := range it.internalIterators {
-		if sub.NextPath() {
+		if sub.NextPath(ctx) {
 			return true
 		}
 
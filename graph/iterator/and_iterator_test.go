@@ -18,6 +18,8 @@ import (
 	"errors"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -41,7 +43,7 @@ func TestTag(t *testing.T) {
 		t.Errorf("Cannot get tag back, got %s", out[0])
 	}
 
-	if !and.Next() {
+	if !and.Next(context.TODO()) {
 		t.Errorf("And did not next")
 	}
 	val := and.Result()
@@ -85,15 +87,15 @@ func TestAndAndFixedIterators(t *testing.T) {
 		t.Error("not accurate")
 	}
 
-	if !and.Next() || and.Result().(Int64Node) != 3 {
+	if !and.Next(context.TODO()) || and.Result().(Int64Node) != 3 {
 		t.Error("Incorrect first value")
 	}
 
-	if !and.Next() || and.Result().(Int64Node) != 4 {
+	if !and.Next(context.TODO()) || and.Result().(Int64Node) != 4 {
 		t.Error("Incorrect second value")
 	}
 
-	if and.Next() {
+	if and.Next(context.TODO()) {
 		t.Error("Too many values")
 	}
 
@@ -127,7 +129,7 @@ func TestNonOverlappingFixedIterators(t *testing.T) {
 		t.Error("not accurate")
 	}
 
-	if and.Next() {
+	if and.Next(context.TODO()) {
 		t.Error("Too many values")
 	}
 
@@ -144,15 +146,15 @@ func TestAllIterators(t *testing.T) {
 	and.AddSubIterator(all2)
 	and.AddSubIterator(all1)
 
-	if !and.Next() || and.Result().(Int64Node) != Int64Node(4) {
+	if !and.Next(context.TODO()) || and.Result().(Int64Node) != Int64Node(4) {
 		t.Error("Incorrect first value")
 	}
 
-	if !and.Next() || and.Result().(Int64Node) != Int64Node(5) {
+	if !and.Next(context.TODO()) || and.Result().(Int64Node) != Int64Node(5) {
 		t.Error("Incorrect second value")
 	}
 
-	if and.Next() {
+	if and.Next(context.TODO()) {
 		t.Error("Too many values")
 	}
 }
@@ -169,7 +171,7 @@ func TestAndIteratorErr(t *testing.T) {
 	and.AddSubIterator(allErr)
 	and.AddSubIterator(NewInt64(1, 5, true))
 
-	if and.Next() != false {
+	if and.Next(context.TODO()) != false {
 		t.Errorf("And iterator did not pass through initial 'false'")
 	}
 	if and.Err() != wantErr {
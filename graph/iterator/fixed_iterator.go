@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"sort"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -119,7 +121,7 @@ func (it *Fixed) Describe() graph.Description {
 func (it *Fixed) Type() graph.Type { return graph.Fixed }
 
 // Check if the passed value is equal to one of the values stored in the iterator.
-func (it *Fixed) Contains(v graph.Value) bool {
+func (it *Fixed) Contains(ctx context.Context, v graph.Value) bool {
 	// Could be optimized by keeping it sorted or using a better datastructure.
 	// However, for fixed iterators, which are by definition kind of tiny, this
 	// isn't a big issue.
@@ -134,7 +136,7 @@ func (it *Fixed) Contains(v graph.Value) bool {
 }
 
 // Next advances the iterator.
-func (it *Fixed) Next() bool {
+func (it *Fixed) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	if it.lastIndex == len(it.values) {
 		return graph.NextLogOut(it, nil, false)
@@ -153,7 +155,7 @@ func (it *Fixed) Result() graph.Value {
 	return it.result
 }
 
-func (it *Fixed) NextPath() bool {
+func (it *Fixed) NextPath(ctx context.Context) bool {
 	return false
 }
 
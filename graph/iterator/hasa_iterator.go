@@ -35,6 +35,7 @@ package iterator
 
 import (
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
@@ -143,7 +144,7 @@ func (it *HasA) Describe() graph.Description {
 // Check a value against our internal iterator. In order to do this, we must first open a new
 // iterator of "quads that have `val` in our direction", given to us by the quad store,
 // and then Next() values out of that iterator and Contains() them against our subiterator.
-func (it *HasA) Contains(val graph.Value) bool {
+func (it *HasA) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
 	if glog.V(4) {
@@ -154,7 +155,7 @@ func (it *HasA) Contains(val graph.Value) bool {
 		it.resultIt.Close()
 	}
 	it.resultIt = it.qs.QuadIterator(it.dir, val)
-	ok := it.NextContains()
+	ok := it.NextContains(ctx)
 	if it.err != nil {
 		return false
 	}
@@ -164,14 +165,14 @@ func (it *HasA) Contains(val graph.Value) bool {
 // NextContains() is shared code between Contains() and GetNextResult() -- calls next on the
 // result iterator (a quad iterator based on the last checked value) and returns true if
 // another match is made.
-func (it *HasA) NextContains() bool {
-	for graph.Next(it.resultIt) {
+func (it *HasA) NextContains(ctx context.Context) bool {
+	for graph.Next(ctx, it.resultIt) {
 		it.runstats.ContainsNext += 1
 		link := it.resultIt.Result()
 		if glog.V(4) {
 			glog.V(4).Infoln("Quad is", it.qs.Quad(link))
 		}
-		if it.primaryIt.Contains(link) {
+		if it.primaryIt.Contains(ctx, link) {
 			it.result = it.qs.QuadDirection(link, it.dir)
 			return true
 		}
@@ -181,7 +182,7 @@ func (it *HasA) NextContains() bool {
 }
 
 // Get the next result that matches this branch.
-func (it *HasA) NextPath() bool {
+func (it *HasA) NextPath(ctx context.Context) bool {
 	// Order here is important. If the subiterator has a NextPath, then we
 	// need do nothing -- there is a next result, and we shouldn't move forward.
 	// However, we then need to get the next result from our last Contains().
@@ -189,7 +190,7 @@ func (it *HasA) NextPath() bool {
 	// The upshot is, the end of NextPath() bubbles up from the bottom of the
 	// iterator tree up, and we need to respect that.
 	glog.V(4).Infoln("HASA", it.UID(), "NextPath")
-	if it.primaryIt.NextPath() {
+	if it.primaryIt.NextPath(ctx) {
 		return true
 	}
 	it.err = it.primaryIt.Err()
@@ -197,7 +198,7 @@ func (it *HasA) NextPath() bool {
 		return false
 	}
 
-	result := it.NextContains() // Sets it.err if there's an error
+	result := it.NextContains(ctx) // Sets it.err if there's an error
 	if it.err != nil {
 		return false
 	}
@@ -208,7 +209,7 @@ func (it *HasA) NextPath() bool {
 // Next advances the iterator. This is simpler than Contains. We have a
 // subiterator we can get a value from, and we can take that resultant quad,
 // pull our direction out of it, and return that.
-func (it *HasA) Next() bool {
+func (it *HasA) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
 	if it.resultIt != nil {
@@ -216,7 +217,7 @@ func (it *HasA) Next() bool {
 	}
 	it.resultIt = &Null{}
 
-	if !graph.Next(it.primaryIt) {
+	if !graph.Next(ctx, it.primaryIt) {
 		it.err = it.primaryIt.Err()
 		return graph.NextLogOut(it, nil, false)
 	}
@@ -18,6 +18,8 @@ import (
 	"errors"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/quad"
 )
 
@@ -28,7 +30,7 @@ func TestHasAIteratorErr(t *testing.T) {
 	// TODO(andrew-d): pass a non-nil quadstore
 	hasa := NewHasA(nil, errIt, quad.Subject)
 
-	if hasa.Next() != false {
+	if hasa.Next(context.TODO()) != false {
 		t.Errorf("HasA iterator did not pass through initial 'false'")
 	}
 	if hasa.Err() != wantErr {
@@ -19,6 +19,8 @@ package iterator
 import (
 	"sync/atomic"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -64,7 +66,7 @@ func (it *Null) TagResults(dst map[string]graph.Value) {
 	}
 }
 
-func (it *Null) Contains(graph.Value) bool {
+func (it *Null) Contains(ctx context.Context, v graph.Value) bool {
 	return false
 }
 
@@ -84,7 +86,7 @@ func (it *Null) Describe() graph.Description {
 	}
 }
 
-func (it *Null) Next() bool {
+func (it *Null) Next(ctx context.Context) bool {
 	return false
 }
 
@@ -100,7 +102,7 @@ func (it *Null) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func (it *Null) NextPath() bool {
+func (it *Null) NextPath(ctx context.Context) bool {
 	return false
 }
 
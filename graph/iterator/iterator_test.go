@@ -1,6 +1,8 @@
 package iterator
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -20,7 +22,7 @@ func newTestIterator(next bool, err error) graph.Iterator {
 	}
 }
 
-func (it *testIterator) Next() bool {
+func (it *testIterator) Next(ctx context.Context) bool {
 	return it.NextVal
 }
 
@@ -0,0 +1,212 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+)
+
+// Limit iterator stops iterating once a certain number of results were
+// returned by its primary iterator. Every result path counts, so both Next
+// and NextPath are limited. Contains is passed through to the primary
+// iterator.
+//
+// A limit that is zero or negative means no limit.
+type Limit struct {
+	uid       uint64
+	tags      graph.Tagger
+	qs        graph.QuadStore
+	limit     int64
+	count     int64
+	primaryIt graph.Iterator
+	result    graph.Value
+	runstats  graph.IteratorStats
+	err       error
+}
+
+func NewLimit(qs graph.QuadStore, primaryIt graph.Iterator, limit int64) *Limit {
+	return &Limit{
+		uid:       NextUID(),
+		qs:        qs,
+		limit:     limit,
+		primaryIt: primaryIt,
+	}
+}
+
+func (it *Limit) UID() uint64 {
+	return it.uid
+}
+
+// Reset resets the internal iterators and the iterator itself.
+func (it *Limit) Reset() {
+	it.result = nil
+	it.count = 0
+	it.err = nil
+	it.primaryIt.Reset()
+}
+
+func (it *Limit) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Limit) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+
+	it.primaryIt.TagResults(dst)
+}
+
+func (it *Limit) Clone() graph.Iterator {
+	l := NewLimit(it.qs, it.primaryIt.Clone(), it.limit)
+	l.tags.CopyFrom(it)
+	return l
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Limit) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.primaryIt}
+}
+
+// Limit returns the maximal number of results this iterator will return.
+func (it *Limit) Limit() int64 {
+	return it.limit
+}
+
+func (it *Limit) done() bool {
+	return it.limit > 0 && it.count >= it.limit
+}
+
+// Next advances the primary iterator, unless the limit was reached.
+func (it *Limit) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.runstats.Next += 1
+	if it.done() {
+		return graph.NextLogOut(it, nil, false)
+	}
+	if graph.Next(ctx, it.primaryIt) {
+		it.result = it.primaryIt.Result()
+		it.count++
+		return graph.NextLogOut(it, it.result, true)
+	}
+	it.err = it.primaryIt.Err()
+	return graph.NextLogOut(it, nil, false)
+}
+
+func (it *Limit) Err() error {
+	return it.err
+}
+
+func (it *Limit) Result() graph.Value {
+	return it.result
+}
+
+// Contains checks whether the passed value is part of the primary iterator.
+// The limit is not taken into account.
+func (it *Limit) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	ok := it.primaryIt.Contains(ctx, val)
+	if ok {
+		it.result = val
+	}
+	it.err = it.primaryIt.Err()
+	return graph.ContainsLogOut(it, val, ok)
+}
+
+// NextPath checks whether there is another path. Each path counts towards the
+// limit, so the primary iterator is only called if the limit is not reached
+// yet.
+func (it *Limit) NextPath(ctx context.Context) bool {
+	if it.done() {
+		return false
+	}
+	if it.primaryIt.NextPath(ctx) {
+		it.count++
+		return true
+	}
+	it.err = it.primaryIt.Err()
+	return false
+}
+
+// Close closes the primary iterator.
+func (it *Limit) Close() error {
+	return it.primaryIt.Close()
+}
+
+func (it *Limit) Type() graph.Type { return graph.Limit }
+
+func (it *Limit) Optimize() (graph.Iterator, bool) {
+	newPrimary, optimized := it.primaryIt.Optimize()
+	if optimized {
+		it.primaryIt = newPrimary
+		if it.primaryIt.Type() == graph.Null {
+			return it.primaryIt, true
+		}
+	}
+	if it.limit <= 0 {
+		// No limit, nothing to do here.
+		it.primaryIt.Tagger().CopyFrom(it)
+		return it.primaryIt, true
+	}
+	if it.qs != nil {
+		// Ask the graph.QuadStore if the limit can be pushed down to
+		// the backend.
+		newReplacement, hasOne := it.qs.OptimizeIterator(it)
+		if hasOne {
+			return newReplacement, true
+		}
+	}
+	return it, false
+}
+
+func (it *Limit) Stats() graph.IteratorStats {
+	primaryStats := it.primaryIt.Stats()
+	if it.limit > 0 && primaryStats.Size > it.limit {
+		primaryStats.Size = it.limit
+	}
+	primaryStats.Next = it.runstats.Next
+	primaryStats.Contains = it.runstats.Contains
+	primaryStats.ContainsNext = it.runstats.ContainsNext
+	return primaryStats
+}
+
+func (it *Limit) Size() (int64, bool) {
+	primarySize, exact := it.primaryIt.Size()
+	if it.limit > 0 && primarySize > it.limit {
+		return it.limit, exact
+	}
+	return primarySize, exact
+}
+
+func (it *Limit) Describe() graph.Description {
+	primary := it.primaryIt.Describe()
+	size, _ := it.Size()
+	return graph.Description{
+		UID:      it.UID(),
+		Type:     it.Type(),
+		Tags:     it.tags.Tags(),
+		Size:     size,
+		Iterator: &primary,
+	}
+}
+
+var _ graph.Nexter = &Limit{}
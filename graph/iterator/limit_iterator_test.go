@@ -0,0 +1,44 @@
+package iterator
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestLimitIteratorBasics(t *testing.T) {
+	allIt := NewFixed(Identity)
+	for i := 1; i <= 5; i++ {
+		allIt.Add(Int64Node(i))
+	}
+
+	l := NewLimit(nil, allIt, 3)
+
+	if v, _ := l.Size(); v != 3 {
+		t.Errorf("Limit iterator returns incorrect size: got:%d expected:%d", v, 3)
+	}
+
+	expect := []int{1, 2, 3}
+	for i := 0; i < 2; i++ {
+		if got := iterated(l); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to iterate Limit correctly on repeat %d: got:%v expected:%v", i, got, expect)
+		}
+		l.Reset()
+	}
+
+	// Contains ignores the limit.
+	for _, v := range []int{1, 2, 3, 4, 5} {
+		if !l.Contains(context.TODO(), Int64Node(v)) {
+			t.Errorf("Failed to find a correct value in the limit iterator.")
+		}
+	}
+
+	// A limit greater than the number of results has no effect.
+	allIt.Reset()
+	l = NewLimit(nil, allIt, 10)
+	expect = []int{1, 2, 3, 4, 5}
+	if got := iterated(l); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to iterate Limit correctly: got:%v expected:%v", got, expect)
+	}
+}
@@ -30,6 +30,8 @@ package iterator
 // Can be seen as the dual of the HasA iterator.
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 )
@@ -111,11 +113,11 @@ func (it *LinksTo) Describe() graph.Description {
 
 // If it checks in the right direction for the subiterator, it is a valid link
 // for the LinksTo.
-func (it *LinksTo) Contains(val graph.Value) bool {
+func (it *LinksTo) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
 	node := it.qs.QuadDirection(val, it.dir)
-	if it.primaryIt.Contains(node) {
+	if it.primaryIt.Contains(ctx, node) {
 		it.result = val
 		return graph.ContainsLogOut(it, val, true)
 	}
@@ -150,10 +152,10 @@ func (it *LinksTo) Optimize() (graph.Iterator, bool) {
 }
 
 // Next()ing a LinksTo operates as described above.
-func (it *LinksTo) Next() bool {
+func (it *LinksTo) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
-	if graph.Next(it.nextIt) {
+	if graph.Next(ctx, it.nextIt) {
 		it.runstats.ContainsNext += 1
 		it.result = it.nextIt.Result()
 		return graph.NextLogOut(it, it.result, true)
@@ -166,7 +168,7 @@ func (it *LinksTo) Next() bool {
 	}
 
 	// Subiterator is empty, get another one
-	if !graph.Next(it.primaryIt) {
+	if !graph.Next(ctx, it.primaryIt) {
 		// Possibly save error
 		it.err = it.primaryIt.Err()
 
@@ -177,7 +179,7 @@ func (it *LinksTo) Next() bool {
 	it.nextIt = it.qs.QuadIterator(it.dir, it.primaryIt.Result())
 
 	// Recurse -- return the first in the next set.
-	return it.Next()
+	return it.Next(ctx)
 }
 
 func (it *LinksTo) Err() error {
@@ -202,8 +204,8 @@ func (it *LinksTo) Close() error {
 }
 
 // We won't ever have a new result, but our subiterators might.
-func (it *LinksTo) NextPath() bool {
-	ok := it.primaryIt.NextPath()
+func (it *LinksTo) NextPath(ctx context.Context) bool {
+	ok := it.primaryIt.NextPath(ctx)
 	if !ok {
 		it.err = it.primaryIt.Err()
 	}
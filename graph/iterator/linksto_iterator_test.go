@@ -17,6 +17,8 @@ package iterator
 import (
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/quad"
 )
 
@@ -33,7 +35,7 @@ func TestLinksTo(t *testing.T) {
 	}
 	fixed.Add(val)
 	lto := NewLinksTo(qs, fixed, quad.Object)
-	if !lto.Next() {
+	if !lto.Next(context.TODO()) {
 		t.Error("At least one quad matches the fixed object")
 	}
 	val = lto.Result()
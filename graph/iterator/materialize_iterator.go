@@ -18,6 +18,7 @@ package iterator
 
 import (
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 )
@@ -180,17 +181,17 @@ func (it *Materialize) Stats() graph.IteratorStats {
 	}
 }
 
-func (it *Materialize) Next() bool {
+func (it *Materialize) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
 	if !it.hasRun {
-		it.materializeSet()
+		it.materializeSet(ctx)
 	}
 	if it.err != nil {
 		return false
 	}
 	if it.aborted {
-		n := graph.Next(it.subIt)
+		n := graph.Next(ctx, it.subIt)
 		it.err = it.subIt.Err()
 		return n
 	}
@@ -207,17 +208,17 @@ func (it *Materialize) Err() error {
 	return it.err
 }
 
-func (it *Materialize) Contains(v graph.Value) bool {
+func (it *Materialize) Contains(ctx context.Context, v graph.Value) bool {
 	graph.ContainsLogIn(it, v)
 	it.runstats.Contains += 1
 	if !it.hasRun {
-		it.materializeSet()
+		it.materializeSet(ctx)
 	}
 	if it.err != nil {
 		return false
 	}
 	if it.aborted {
-		return it.subIt.Contains(v)
+		return it.subIt.Contains(ctx, v)
 	}
 	key := graph.ToKey(v)
 	if i, ok := it.containsMap[key]; ok {
@@ -228,15 +229,15 @@ func (it *Materialize) Contains(v graph.Value) bool {
 	return graph.ContainsLogOut(it, v, false)
 }
 
-func (it *Materialize) NextPath() bool {
+func (it *Materialize) NextPath(ctx context.Context) bool {
 	if !it.hasRun {
-		it.materializeSet()
+		it.materializeSet(ctx)
 	}
 	if it.err != nil {
 		return false
 	}
 	if it.aborted {
-		return it.subIt.NextPath()
+		return it.subIt.NextPath(ctx)
 	}
 
 	it.subindex++
@@ -248,9 +249,9 @@ func (it *Materialize) NextPath() bool {
 	return true
 }
 
-func (it *Materialize) materializeSet() {
+func (it *Materialize) materializeSet(ctx context.Context) {
 	i := 0
-	for graph.Next(it.subIt) {
+	for graph.Next(ctx, it.subIt) {
 		i++
 		if i > abortMaterializeAt {
 			it.aborted = true
@@ -267,7 +268,7 @@ func (it *Materialize) materializeSet() {
 		it.subIt.TagResults(tags)
 		it.values[index] = append(it.values[index], result{id: id, tags: tags})
 		it.actualSize += 1
-		for it.subIt.NextPath() {
+		for it.subIt.NextPath(ctx) {
 			i++
 			if i > abortMaterializeAt {
 				it.aborted = true
@@ -17,6 +17,8 @@ package iterator
 import (
 	"errors"
 	"testing"
+
+	"golang.org/x/net/context"
 )
 
 func TestMaterializeIteratorError(t *testing.T) {
@@ -27,7 +29,7 @@ func TestMaterializeIteratorError(t *testing.T) {
 	// underlying iterator returns an error.
 	mIt := NewMaterialize(errIt)
 
-	if mIt.Next() != false {
+	if mIt.Next(context.TODO()) != false {
 		t.Errorf("Materialize iterator did not pass through underlying 'false'")
 	}
 	if mIt.Err() != wantErr {
@@ -50,7 +52,7 @@ func TestMaterializeIteratorErrorAbort(t *testing.T) {
 
 	// We should get all the underlying values...
 	for i := 0; i < abortMaterializeAt+1; i++ {
-		if !mIt.Next() {
+		if !mIt.Next(context.TODO()) {
 			t.Errorf("Materialize iterator returned spurious 'false' on iteration %d", i)
 			return
 		}
@@ -61,7 +63,7 @@ func TestMaterializeIteratorErrorAbort(t *testing.T) {
 	}
 
 	// ... and then the error value.
-	if mIt.Next() != false {
+	if mIt.Next(context.TODO()) != false {
 		t.Errorf("Materialize iterator did not pass through underlying 'false'")
 	}
 	if mIt.Err() != wantErr {
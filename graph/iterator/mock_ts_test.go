@@ -35,6 +35,12 @@ func (qs *store) valueAt(i int) quad.Value {
 	if err == nil {
 		return quad.Int(iv)
 	}
+	switch qs.data[i] {
+	case "true":
+		return quad.Bool(true)
+	case "false":
+		return quad.Bool(false)
+	}
 	return quad.String(qs.data[i])
 }
 
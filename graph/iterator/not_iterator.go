@@ -1,6 +1,8 @@
 package iterator
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -69,12 +71,12 @@ func (it *Not) SubIterators() []graph.Iterator {
 // Next advances the Not iterator. It returns whether there is another valid
 // new value. It fetches the next value of the all iterator which is not
 // contained by the primary iterator.
-func (it *Not) Next() bool {
+func (it *Not) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
 
-	for graph.Next(it.allIt) {
-		if curr := it.allIt.Result(); !it.primaryIt.Contains(curr) {
+	for graph.Next(ctx, it.allIt) {
+		if curr := it.allIt.Result(); !it.primaryIt.Contains(ctx, curr) {
 			it.result = curr
 			it.runstats.ContainsNext += 1
 			return graph.NextLogOut(it, curr, true)
@@ -95,11 +97,11 @@ func (it *Not) Result() graph.Value {
 // Contains checks whether the passed value is part of the primary iterator's
 // complement. For a valid value, it updates the Result returned by the iterator
 // to the value itself.
-func (it *Not) Contains(val graph.Value) bool {
+func (it *Not) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
 
-	if it.primaryIt.Contains(val) {
+	if it.primaryIt.Contains(ctx, val) {
 		return graph.ContainsLogOut(it, val, false)
 	}
 
@@ -115,7 +117,7 @@ func (it *Not) Contains(val graph.Value) bool {
 
 // NextPath checks whether there is another path. Not applicable, hence it will
 // return false.
-func (it *Not) NextPath() bool {
+func (it *Not) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+
+	"golang.org/x/net/context"
 )
 
 func TestNotIteratorBasics(t *testing.T) {
@@ -32,13 +34,13 @@ func TestNotIteratorBasics(t *testing.T) {
 	}
 
 	for _, v := range []int{1, 3} {
-		if !not.Contains(Int64Node(v)) {
+		if !not.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as true", v)
 		}
 	}
 
 	for _, v := range []int{2, 4} {
-		if not.Contains(Int64Node(v)) {
+		if not.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as false", v)
 		}
 	}
@@ -52,7 +54,7 @@ func TestNotIteratorErr(t *testing.T) {
 
 	not := NewNot(toComplementIt, allIt)
 
-	if not.Next() != false {
+	if not.Next(context.TODO()) != false {
 		t.Errorf("Not iterator did not pass through initial 'false'")
 	}
 	if not.Err() != wantErr {
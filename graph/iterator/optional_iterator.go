@@ -27,6 +27,8 @@ package iterator
 // -- all things in the graph. It matches everything (as does the regex "(a)?")
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -83,9 +85,9 @@ func (it *Optional) Result() graph.Value {
 // An optional iterator only has a next result if, (a) last time we checked
 // we had any results whatsoever, and (b) there was another subresult in our
 // optional subbranch.
-func (it *Optional) NextPath() bool {
+func (it *Optional) NextPath(ctx context.Context) bool {
 	if it.lastCheck {
-		ok := it.subIt.NextPath()
+		ok := it.subIt.NextPath(ctx)
 		if !ok {
 			it.err = it.subIt.Err()
 		}
@@ -102,8 +104,8 @@ func (it *Optional) SubIterators() []graph.Iterator {
 // Contains() is the real hack of this iterator. It always returns true, regardless
 // of whether the subiterator matched. But we keep track of whether the subiterator
 // matched for results purposes.
-func (it *Optional) Contains(val graph.Value) bool {
-	checked := it.subIt.Contains(val)
+func (it *Optional) Contains(ctx context.Context, val graph.Value) bool {
+	checked := it.subIt.Contains(ctx, val)
 	it.lastCheck = checked
 	it.err = it.subIt.Err()
 	it.result = val
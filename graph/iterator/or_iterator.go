@@ -22,6 +22,8 @@ package iterator
 // May return the same value twice -- once for each branch.
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -124,7 +126,7 @@ func (it *Or) AddSubIterator(sub graph.Iterator) {
 // Next advances the Or graph.iterator. Because the Or is the union of its
 // subiterators, it must produce from all subiterators -- unless it it
 // shortcircuiting, in which case, it is the first one that returns anything.
-func (it *Or) Next() bool {
+func (it *Or) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	var first bool
 	for {
@@ -134,7 +136,7 @@ func (it *Or) Next() bool {
 		}
 		curIt := it.internalIterators[it.currentIterator]
 
-		if graph.Next(curIt) {
+		if graph.Next(ctx, curIt) {
 			it.result = curIt.Result()
 			return graph.NextLogOut(it, it.result, true)
 		}
@@ -165,10 +167,10 @@ func (it *Or) Result() graph.Value {
 }
 
 // Checks a value against the iterators, in order.
-func (it *Or) subItsContain(val graph.Value) (bool, error) {
+func (it *Or) subItsContain(ctx context.Context, val graph.Value) (bool, error) {
 	var subIsGood = false
 	for i, sub := range it.internalIterators {
-		subIsGood = sub.Contains(val)
+		subIsGood = sub.Contains(ctx, val)
 		if subIsGood {
 			it.currentIterator = i
 			break
@@ -183,9 +185,9 @@ func (it *Or) subItsContain(val graph.Value) (bool, error) {
 }
 
 // Check a value against the entire graph.iterator, in order.
-func (it *Or) Contains(val graph.Value) bool {
+func (it *Or) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
-	anyGood, err := it.subItsContain(val)
+	anyGood, err := it.subItsContain(ctx, val)
 	if err != nil {
 		it.err = err
 		return false
@@ -228,10 +230,10 @@ func (it *Or) Size() (int64, bool) {
 // which satisfy our previous result that are not the result itself. Our
 // subiterators might, however, so just pass the call recursively. In the case of
 // shortcircuiting, only allow new results from the currently checked graph.iterator
-func (it *Or) NextPath() bool {
+func (it *Or) NextPath(ctx context.Context) bool {
 	if it.currentIterator != -1 {
 		currIt := it.internalIterators[it.currentIterator]
-		ok := currIt.NextPath()
+		ok := currIt.NextPath(ctx)
 		if !ok {
 			it.err = currIt.Err()
 		}
@@ -19,12 +19,14 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
 func iterated(it graph.Iterator) []int {
 	var res []int
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		res = append(res, int(it.Result().(Int64Node)))
 	}
 	return res
@@ -63,13 +65,13 @@ func TestOrIteratorBasics(t *testing.T) {
 	}
 
 	for _, v := range []int{2, 3, 21} {
-		if !or.Contains(Int64Node(v)) {
+		if !or.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as true", v)
 		}
 	}
 
 	for _, v := range []int{22, 5, 0} {
-		if or.Contains(Int64Node(v)) {
+		if or.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as false", v)
 		}
 	}
@@ -122,12 +124,12 @@ func TestShortCircuitingOrBasics(t *testing.T) {
 	or.AddSubIterator(f1)
 	or.AddSubIterator(f2)
 	for _, v := range []int{2, 3, 21} {
-		if !or.Contains(Int64Node(v)) {
+		if !or.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as true", v)
 		}
 	}
 	for _, v := range []int{22, 5, 0} {
-		if or.Contains(Int64Node(v)) {
+		if or.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to correctly check %d as false", v)
 		}
 	}
@@ -162,14 +164,14 @@ func TestOrIteratorErr(t *testing.T) {
 	or.AddSubIterator(orErr)
 	or.AddSubIterator(NewInt64(1, 5, true))
 
-	if !or.Next() {
+	if !or.Next(context.TODO()) {
 		t.Errorf("Failed to iterate Or correctly")
 	}
 	if got := or.Result(); got.(Int64Node) != 1 {
 		t.Errorf("Failed to iterate Or correctly, got:%v expect:1", got)
 	}
 
-	if or.Next() != false {
+	if or.Next(context.TODO()) != false {
 		t.Errorf("Or iterator did not pass through underlying 'false'")
 	}
 	if or.Err() != wantErr {
@@ -185,7 +187,7 @@ func TestShortCircuitOrIteratorErr(t *testing.T) {
 	or.AddSubIterator(orErr)
 	or.AddSubIterator(NewInt64(1, 5, true))
 
-	if or.Next() != false {
+	if or.Next(context.TODO()) != false {
 		t.Errorf("Or iterator did not pass through underlying 'false'")
 	}
 	if or.Err() != wantErr {
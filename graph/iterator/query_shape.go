@@ -15,6 +15,8 @@
 package iterator
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 )
@@ -129,7 +131,7 @@ func (s *queryShape) MakeNode(it graph.Iterator) *Node {
 		}
 	case graph.Fixed:
 		n.IsFixed = true
-		for graph.Next(it) {
+		for graph.Next(context.TODO(), it) {
 			n.Values = append(n.Values, s.qs.NameOf(it.Result()).String())
 		}
 	case graph.HasA:
@@ -0,0 +1,374 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+// Defines the Recursive iterator, which follows a morphism again and again,
+// starting from the results of its subiterator.
+//
+// The traversal is breadth-first: all the nodes reachable in one step from the
+// starting nodes are returned first, then the ones reachable in two steps, and
+// so on, until no new nodes are found or the maximal depth is reached. Every
+// node is returned only once, at the depth it was first reached at. The
+// starting nodes are not returned, unless they can be reached from the other
+// starting nodes.
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+// recursiveBaseTag tags the nodes of each step, so the node a result was
+// reached from is known.
+const recursiveBaseTag = "__base_recursive"
+
+type Recursive struct {
+	uid      uint64
+	tags     graph.Tagger
+	subIt    graph.Iterator
+	result   graph.Value
+	runstats graph.IteratorStats
+	err      error
+
+	qs        graph.QuadStore
+	morphism  graph.ApplyMorphism
+	maxDepth  int
+	depthTags []string
+
+	// next is the traversal of Next, and contains the one of Contains, which
+	// runs over a clone of the subiterator, so that Contains does not move
+	// Next forward. cur is the traversal the result was found by.
+	next     *traversal
+	contains *traversal
+	cur      *traversal
+}
+
+// traversal is the state of a breadth-first traversal from the results of a
+// subiterator.
+type traversal struct {
+	subIt    graph.Iterator
+	started  bool
+	depth    int
+	frontier []graph.Value
+	nextIt   graph.Iterator
+	seen     map[graph.Value]int
+	// pathTags keeps the tags of the starting node each node descends from.
+	pathTags map[graph.Value]map[string]graph.Value
+}
+
+func newTraversal(subIt graph.Iterator) *traversal {
+	return &traversal{
+		subIt:    subIt,
+		seen:     make(map[graph.Value]int),
+		pathTags: make(map[graph.Value]map[string]graph.Value),
+	}
+}
+
+// NewRecursive creates a new Recursive iterator, that applies the morphism to
+// the results of the subiterator, and then to its own results, up to maxDepth
+// times. A zero or negative maxDepth means no limit.
+func NewRecursive(qs graph.QuadStore, subIt graph.Iterator, morphism graph.ApplyMorphism, maxDepth int) *Recursive {
+	return &Recursive{
+		uid:      NextUID(),
+		subIt:    subIt,
+		qs:       qs,
+		morphism: morphism,
+		maxDepth: maxDepth,
+		next:     newTraversal(subIt),
+	}
+}
+
+func (it *Recursive) UID() uint64 {
+	return it.uid
+}
+
+// AddDepthTag adds a tag, that will be set to the depth at which the current
+// result was reached.
+func (it *Recursive) AddDepthTag(tag string) {
+	it.depthTags = append(it.depthTags, tag)
+}
+
+// Reset resets the internal iterators and the iterator itself.
+func (it *Recursive) Reset() {
+	it.result = nil
+	it.cur = nil
+	it.err = nil
+	it.subIt.Reset()
+	it.next.reset()
+	if it.contains != nil {
+		it.contains.subIt.Reset()
+		it.contains.reset()
+	}
+}
+
+func (t *traversal) reset() {
+	if t.nextIt != nil {
+		t.nextIt.Close()
+		t.nextIt = nil
+	}
+	t.started = false
+	t.depth = 0
+	t.frontier = nil
+	t.seen = make(map[graph.Value]int)
+	t.pathTags = make(map[graph.Value]map[string]graph.Value)
+}
+
+func (t *traversal) close() {
+	if t.nextIt != nil {
+		t.nextIt.Close()
+		t.nextIt = nil
+	}
+	t.seen = nil
+	t.pathTags = nil
+}
+
+func (it *Recursive) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Recursive) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+
+	if it.result == nil || it.cur == nil {
+		return
+	}
+	key := graph.ToKey(it.result)
+	for tag, value := range it.cur.pathTags[key] {
+		dst[tag] = value
+	}
+	if len(it.depthTags) != 0 {
+		depth := graph.PreFetched(quad.Int(it.cur.seen[key]))
+		for _, tag := range it.depthTags {
+			dst[tag] = depth
+		}
+	}
+}
+
+func (it *Recursive) Clone() graph.Iterator {
+	r := NewRecursive(it.qs, it.subIt.Clone(), it.morphism, it.maxDepth)
+	r.tags.CopyFrom(it)
+	r.depthTags = append([]string(nil), it.depthTags...)
+	return r
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Recursive) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.subIt}
+}
+
+// start collects the starting nodes from the subiterator.
+func (t *traversal) start(ctx context.Context) error {
+	t.started = true
+	for graph.Next(ctx, t.subIt) {
+		val := t.subIt.Result()
+		key := graph.ToKey(val)
+		if _, ok := t.pathTags[key]; !ok {
+			tags := make(map[string]graph.Value)
+			t.subIt.TagResults(tags)
+			t.pathTags[key] = tags
+			t.frontier = append(t.frontier, val)
+		}
+	}
+	return t.subIt.Err()
+}
+
+// nextStep applies the morphism to the nodes found at the current depth.
+func (it *Recursive) nextStep(t *traversal) bool {
+	if len(t.frontier) == 0 || (it.maxDepth > 0 && t.depth >= it.maxDepth) {
+		return false
+	}
+	fixed := it.qs.FixedIterator()
+	for _, v := range t.frontier {
+		fixed.Add(v)
+	}
+	fixed.Tagger().Add(recursiveBaseTag)
+	t.frontier = nil
+	t.depth++
+	t.nextIt, _ = it.morphism(it.qs, fixed).Optimize()
+	return true
+}
+
+// advance moves the traversal to the next node that was not seen before.
+func (it *Recursive) advance(ctx context.Context, t *traversal) (graph.Value, error) {
+	if !t.started {
+		if err := t.start(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if t.nextIt == nil && !it.nextStep(t) {
+			return nil, nil
+		}
+		for graph.Next(ctx, t.nextIt) {
+			val := t.nextIt.Result()
+			key := graph.ToKey(val)
+			if _, ok := t.seen[key]; ok {
+				continue
+			}
+			t.seen[key] = t.depth
+			tags := make(map[string]graph.Value)
+			t.nextIt.TagResults(tags)
+			if base, ok := tags[recursiveBaseTag]; ok {
+				if baseTags, ok := t.pathTags[graph.ToKey(base)]; ok {
+					t.pathTags[key] = baseTags
+				}
+			}
+			t.frontier = append(t.frontier, val)
+			return val, nil
+		}
+		err := t.nextIt.Err()
+		t.nextIt.Close()
+		t.nextIt = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Next advances the traversal to the next node that was not seen before.
+func (it *Recursive) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.runstats.Next += 1
+	val, err := it.advance(ctx, it.next)
+	if err != nil || val == nil {
+		it.err = err
+		return graph.NextLogOut(it, nil, false)
+	}
+	it.result = val
+	it.cur = it.next
+	return graph.NextLogOut(it, val, true)
+}
+
+func (it *Recursive) Err() error {
+	return it.err
+}
+
+func (it *Recursive) Result() graph.Value {
+	return it.result
+}
+
+// Contains checks whether the passed value can be reached by the traversal.
+// Contains runs a traversal of its own over a clone of the subiterator, so it
+// does not move Next forward. The traversal is continued until the value is
+// found, and is kept for the next calls, so a value which can not be reached
+// costs a full traversal the first time.
+func (it *Recursive) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	key := graph.ToKey(val)
+	if _, ok := it.next.seen[key]; ok {
+		it.result, it.cur = val, it.next
+		return graph.ContainsLogOut(it, val, true)
+	}
+	if it.contains == nil {
+		it.contains = newTraversal(it.subIt.Clone())
+	}
+	t := it.contains
+	if _, ok := t.seen[key]; ok {
+		it.result, it.cur = val, t
+		return graph.ContainsLogOut(it, val, true)
+	}
+	for {
+		it.runstats.ContainsNext += 1
+		got, err := it.advance(ctx, t)
+		if err != nil {
+			it.err = err
+			return graph.ContainsLogOut(it, val, false)
+		} else if got == nil {
+			return graph.ContainsLogOut(it, val, false)
+		}
+		if graph.ToKey(got) == key {
+			it.result, it.cur = val, t
+			return graph.ContainsLogOut(it, val, true)
+		}
+	}
+}
+
+// NextPath always returns false, since every node is returned only once, at
+// the first path it was reached by.
+func (it *Recursive) NextPath(ctx context.Context) bool {
+	return false
+}
+
+// Close closes the subiterators and the iterators of the current steps.
+func (it *Recursive) Close() error {
+	it.next.close()
+	it.dropContains()
+	return it.subIt.Close()
+}
+
+// dropContains closes the traversal of Contains, with its clone of the
+// subiterator.
+func (it *Recursive) dropContains() {
+	if it.contains != nil {
+		it.contains.close()
+		it.contains.subIt.Close()
+		it.contains = nil
+	}
+}
+
+func (it *Recursive) Type() graph.Type { return graph.Recursive }
+
+func (it *Recursive) Optimize() (graph.Iterator, bool) {
+	newIt, optimized := it.subIt.Optimize()
+	if optimized {
+		it.subIt = newIt
+		it.next.subIt = newIt
+		it.dropContains()
+		if it.subIt.Type() == graph.Null {
+			return it.subIt, true
+		}
+	}
+	return it, false
+}
+
+// recursiveFactor is a guess of how much the traversal multiplies the size of
+// the starting set.
+const recursiveFactor = 5
+
+func (it *Recursive) Stats() graph.IteratorStats {
+	subStats := it.subIt.Stats()
+	return graph.IteratorStats{
+		NextCost:     subStats.NextCost * recursiveFactor,
+		ContainsCost: subStats.NextCost * recursiveFactor,
+		Size:         subStats.Size * recursiveFactor,
+		Next:         it.runstats.Next,
+		Contains:     it.runstats.Contains,
+		ContainsNext: it.runstats.ContainsNext,
+	}
+}
+
+func (it *Recursive) Size() (int64, bool) {
+	return it.Stats().Size, false
+}
+
+func (it *Recursive) Describe() graph.Description {
+	primary := it.subIt.Describe()
+	return graph.Description{
+		UID:      it.UID(),
+		Type:     it.Type(),
+		Tags:     it.tags.Tags(),
+		Iterator: &primary,
+	}
+}
+
+var _ graph.Nexter = &Recursive{}
@@ -0,0 +1,99 @@
+package iterator
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+// childrenMorphism maps every node n of a binary tree to its children 2n and
+// 2n+1, for nodes below 8.
+func childrenMorphism(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	out := NewFixed(Identity)
+	for graph.Next(context.TODO(), it) {
+		n := it.Result().(Int64Node)
+		if n < 8 {
+			out.Add(2 * n)
+			out.Add(2*n + 1)
+		}
+	}
+	return out
+}
+
+func TestRecursiveIteratorBasics(t *testing.T) {
+	qs := &store{}
+	start := NewFixed(Identity)
+	start.Add(Int64Node(1))
+
+	r := NewRecursive(qs, start, childrenMorphism, 0)
+	expect := []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	for i := 0; i < 2; i++ {
+		if got := iterated(r); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to iterate Recursive correctly on repeat %d: got:%v expected:%v", i, got, expect)
+		}
+		r.Reset()
+	}
+
+	if !r.Contains(context.TODO(), Int64Node(9)) {
+		t.Errorf("Failed to find a reachable value in the recursive iterator.")
+	}
+	if r.Contains(context.TODO(), Int64Node(1)) {
+		t.Errorf("Found the starting value in the recursive iterator.")
+	}
+}
+
+func TestRecursiveIteratorDepth(t *testing.T) {
+	qs := &store{}
+	start := NewFixed(Identity)
+	start.Add(Int64Node(1))
+
+	r := NewRecursive(qs, start, childrenMorphism, 2)
+	r.AddDepthTag("depth")
+
+	expect := map[int]int{2: 1, 3: 1, 4: 2, 5: 2, 6: 2, 7: 2}
+	got := make(map[int]int)
+	for graph.Next(context.TODO(), r) {
+		tags := make(map[string]graph.Value)
+		r.TagResults(tags)
+		depth, ok := tags["depth"].(graph.PreFetchedValue)
+		if !ok {
+			t.Fatalf("Unexpected depth tag: %v", tags["depth"])
+		}
+		got[int(r.Result().(Int64Node))] = int(depth.NameOf().(quad.Int))
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to iterate Recursive with max depth: got:%v expected:%v", got, expect)
+	}
+}
+
+func TestRecursiveIteratorContainsThenNext(t *testing.T) {
+	qs := &store{}
+	start := NewFixed(Identity)
+	start.Add(Int64Node(1))
+
+	r := NewRecursive(qs, start, childrenMorphism, 2)
+	r.AddDepthTag("depth")
+	if !r.Contains(context.TODO(), Int64Node(6)) {
+		t.Errorf("Failed to find a reachable value in the recursive iterator.")
+	}
+	tags := make(map[string]graph.Value)
+	r.TagResults(tags)
+	if depth, ok := tags["depth"].(graph.PreFetchedValue); !ok || depth.NameOf() != quad.Int(2) {
+		t.Errorf("Unexpected depth tag after Contains: %v", tags["depth"])
+	}
+	if r.Contains(context.TODO(), Int64Node(9)) {
+		t.Errorf("Found a value deeper than the max depth in the recursive iterator.")
+	}
+
+	expect := []int{2, 3, 4, 5, 6, 7}
+	if got := iterated(r); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to iterate Recursive after Contains: got:%v expected:%v", got, expect)
+	}
+	if !r.Contains(context.TODO(), Int64Node(6)) {
+		t.Errorf("Failed to find a reachable value after iterating the recursive iterator.")
+	}
+}
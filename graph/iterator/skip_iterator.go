@@ -0,0 +1,217 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+)
+
+// Skip iterator skips a certain number of results of its primary iterator.
+// As with Limit, every result path counts, so the skipped results may end in
+// the middle of the paths of a single value. Contains is passed through to the
+// primary iterator.
+type Skip struct {
+	uid       uint64
+	tags      graph.Tagger
+	qs        graph.QuadStore
+	skip      int64
+	skipped   int64
+	primaryIt graph.Iterator
+	result    graph.Value
+	runstats  graph.IteratorStats
+	err       error
+}
+
+func NewSkip(qs graph.QuadStore, primaryIt graph.Iterator, skip int64) *Skip {
+	return &Skip{
+		uid:       NextUID(),
+		qs:        qs,
+		skip:      skip,
+		primaryIt: primaryIt,
+	}
+}
+
+func (it *Skip) UID() uint64 {
+	return it.uid
+}
+
+// Reset resets the internal iterators and the iterator itself.
+func (it *Skip) Reset() {
+	it.result = nil
+	it.skipped = 0
+	it.err = nil
+	it.primaryIt.Reset()
+}
+
+func (it *Skip) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Skip) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+
+	it.primaryIt.TagResults(dst)
+}
+
+func (it *Skip) Clone() graph.Iterator {
+	s := NewSkip(it.qs, it.primaryIt.Clone(), it.skip)
+	s.tags.CopyFrom(it)
+	return s
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Skip) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.primaryIt}
+}
+
+// Skip returns the number of results this iterator skips.
+func (it *Skip) Skip() int64 {
+	return it.skip
+}
+
+// Next advances the primary iterator, skipping results until the requested
+// number of them were skipped.
+func (it *Skip) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.runstats.Next += 1
+	for graph.Next(ctx, it.primaryIt) {
+		if it.skipped < it.skip {
+			it.skipped++
+			if !it.skipPaths(ctx) {
+				continue
+			}
+		}
+		it.result = it.primaryIt.Result()
+		return graph.NextLogOut(it, it.result, true)
+	}
+	it.err = it.primaryIt.Err()
+	return graph.NextLogOut(it, nil, false)
+}
+
+// skipPaths skips the remaining paths of the current result. It returns true
+// if the primary iterator was left on a path that should not be skipped.
+func (it *Skip) skipPaths(ctx context.Context) bool {
+	for it.skipped < it.skip {
+		if !it.primaryIt.NextPath(ctx) {
+			return false
+		}
+		it.skipped++
+	}
+	return it.primaryIt.NextPath(ctx)
+}
+
+func (it *Skip) Err() error {
+	return it.err
+}
+
+func (it *Skip) Result() graph.Value {
+	return it.result
+}
+
+// Contains checks whether the passed value is part of the primary iterator.
+// Skipped results are not taken into account.
+func (it *Skip) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	ok := it.primaryIt.Contains(ctx, val)
+	if ok {
+		it.result = val
+	}
+	it.err = it.primaryIt.Err()
+	return graph.ContainsLogOut(it, val, ok)
+}
+
+// NextPath checks whether there is another path. All the skipping is done
+// by Next, so this simply asks the primary iterator.
+func (it *Skip) NextPath(ctx context.Context) bool {
+	if it.primaryIt.NextPath(ctx) {
+		return true
+	}
+	it.err = it.primaryIt.Err()
+	return false
+}
+
+// Close closes the primary iterator.
+func (it *Skip) Close() error {
+	return it.primaryIt.Close()
+}
+
+func (it *Skip) Type() graph.Type { return graph.Skip }
+
+func (it *Skip) Optimize() (graph.Iterator, bool) {
+	newPrimary, optimized := it.primaryIt.Optimize()
+	if optimized {
+		it.primaryIt = newPrimary
+		if it.primaryIt.Type() == graph.Null {
+			return it.primaryIt, true
+		}
+	}
+	if it.skip <= 0 {
+		// Nothing to skip.
+		it.primaryIt.Tagger().CopyFrom(it)
+		return it.primaryIt, true
+	}
+	if it.qs != nil {
+		// Ask the graph.QuadStore if skipping can be done by the backend.
+		newReplacement, hasOne := it.qs.OptimizeIterator(it)
+		if hasOne {
+			return newReplacement, true
+		}
+	}
+	return it, false
+}
+
+func (it *Skip) Stats() graph.IteratorStats {
+	primaryStats := it.primaryIt.Stats()
+	primaryStats.Size -= it.skip
+	if primaryStats.Size < 0 {
+		primaryStats.Size = 0
+	}
+	primaryStats.Next = it.runstats.Next
+	primaryStats.Contains = it.runstats.Contains
+	primaryStats.ContainsNext = it.runstats.ContainsNext
+	return primaryStats
+}
+
+func (it *Skip) Size() (int64, bool) {
+	primarySize, exact := it.primaryIt.Size()
+	primarySize -= it.skip
+	if primarySize < 0 {
+		primarySize = 0
+	}
+	return primarySize, exact
+}
+
+func (it *Skip) Describe() graph.Description {
+	primary := it.primaryIt.Describe()
+	size, _ := it.Size()
+	return graph.Description{
+		UID:      it.UID(),
+		Type:     it.Type(),
+		Tags:     it.tags.Tags(),
+		Size:     size,
+		Iterator: &primary,
+	}
+}
+
+var _ graph.Nexter = &Skip{}
@@ -0,0 +1,50 @@
+package iterator
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSkipIteratorBasics(t *testing.T) {
+	allIt := NewFixed(Identity)
+	for i := 1; i <= 5; i++ {
+		allIt.Add(Int64Node(i))
+	}
+
+	s := NewSkip(nil, allIt, 2)
+
+	if v, _ := s.Size(); v != 3 {
+		t.Errorf("Skip iterator returns incorrect size: got:%d expected:%d", v, 3)
+	}
+
+	expect := []int{3, 4, 5}
+	for i := 0; i < 2; i++ {
+		if got := iterated(s); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to iterate Skip correctly on repeat %d: got:%v expected:%v", i, got, expect)
+		}
+		s.Reset()
+	}
+
+	// Contains ignores skipped values.
+	for _, v := range []int{1, 2, 3, 4, 5} {
+		if !s.Contains(context.TODO(), Int64Node(v)) {
+			t.Errorf("Failed to find a correct value in the skip iterator.")
+		}
+	}
+
+	// Skip combined with a limit returns a window of the results.
+	s.Reset()
+	l := NewLimit(nil, NewSkip(nil, allIt, 1), 2)
+	expect = []int{2, 3}
+	if got := iterated(l); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to iterate Skip with Limit correctly: got:%v expected:%v", got, expect)
+	}
+
+	allIt.Reset()
+	s = NewSkip(nil, allIt, 10)
+	if got := iterated(s); len(got) != 0 {
+		t.Errorf("Failed to skip all values: got:%v", got)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+// Sort iterator returns the results of its primary iterator ordered by their
+// values, or by the values of one of their tags. It reads all the paths of the
+// primary iterator on the first call to Next, and returns every path as a
+// separate result, so NextPath always returns false. Contains is passed
+// through to the primary iterator.
+type Sort struct {
+	uid       uint64
+	tags      graph.Tagger
+	qs        graph.QuadStore
+	primaryIt graph.Iterator
+	by        string
+	desc      bool
+	results   []result
+	index     int
+	loaded    bool
+	contained bool
+	runstats  graph.IteratorStats
+	err       error
+}
+
+// NewSort creates a Sort iterator ordering the results by the values of the
+// given tag, or by the results themselves if the tag is empty.
+func NewSort(qs graph.QuadStore, primaryIt graph.Iterator, by string, desc bool) *Sort {
+	return &Sort{
+		uid:       NextUID(),
+		qs:        qs,
+		primaryIt: primaryIt,
+		by:        by,
+		desc:      desc,
+		index:     -1,
+	}
+}
+
+func (it *Sort) UID() uint64 {
+	return it.uid
+}
+
+// Reset resets the position in the sorted results, which are kept.
+func (it *Sort) Reset() {
+	it.index = -1
+	it.contained = false
+	it.err = nil
+	it.primaryIt.Reset()
+}
+
+func (it *Sort) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Sort) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+
+	if it.contained {
+		it.primaryIt.TagResults(dst)
+		return
+	}
+	if it.index >= 0 && it.index < len(it.results) {
+		for tag, value := range it.results[it.index].tags {
+			dst[tag] = value
+		}
+	}
+}
+
+func (it *Sort) Clone() graph.Iterator {
+	s := NewSort(it.qs, it.primaryIt.Clone(), it.by, it.desc)
+	s.tags.CopyFrom(it)
+	return s
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Sort) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.primaryIt}
+}
+
+// By returns the tag the results are ordered by, which is empty when they are
+// ordered by their own values.
+func (it *Sort) By() string {
+	return it.by
+}
+
+// Descending returns whether the results are in descending order.
+func (it *Sort) Descending() bool {
+	return it.desc
+}
+
+// load reads all the paths of the primary iterator and sorts them.
+func (it *Sort) load(ctx context.Context) {
+	it.loaded = true
+	var keys []quad.Value
+	add := func() {
+		tags := make(map[string]graph.Value)
+		it.primaryIt.TagResults(tags)
+		r := result{id: it.primaryIt.Result(), tags: tags}
+		it.results = append(it.results, r)
+		v := r.id
+		if it.by != "" {
+			v = tags[it.by]
+		}
+		var key quad.Value
+		if v != nil {
+			key = it.qs.NameOf(v)
+		}
+		keys = append(keys, key)
+	}
+	for graph.Next(ctx, it.primaryIt) {
+		add()
+		for it.primaryIt.NextPath(ctx) {
+			add()
+		}
+	}
+	if it.err = it.primaryIt.Err(); it.err == nil {
+		it.err = ctx.Err()
+	}
+	sort.Stable(byValue{results: it.results, keys: keys, desc: it.desc})
+}
+
+// Next returns the next result in order.
+func (it *Sort) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.runstats.Next += 1
+	it.contained = false
+	if !it.loaded {
+		it.load(ctx)
+	}
+	if it.err != nil || it.index+1 >= len(it.results) {
+		it.index = len(it.results)
+		return graph.NextLogOut(it, nil, false)
+	}
+	it.index++
+	return graph.NextLogOut(it, it.Result(), true)
+}
+
+func (it *Sort) Err() error {
+	return it.err
+}
+
+func (it *Sort) Result() graph.Value {
+	if it.contained {
+		return it.primaryIt.Result()
+	}
+	if it.index < 0 || it.index >= len(it.results) {
+		return nil
+	}
+	return it.results[it.index].id
+}
+
+// Contains checks whether the passed value is part of the primary iterator.
+func (it *Sort) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	ok := it.primaryIt.Contains(ctx, val)
+	it.contained = ok
+	it.err = it.primaryIt.Err()
+	return graph.ContainsLogOut(it, val, ok)
+}
+
+// NextPath returns the other paths of a value found by Contains. When
+// iterating, every path is a separate result.
+func (it *Sort) NextPath(ctx context.Context) bool {
+	if !it.contained {
+		return false
+	}
+	if it.primaryIt.NextPath(ctx) {
+		return true
+	}
+	it.err = it.primaryIt.Err()
+	return false
+}
+
+// Close closes the primary iterator and drops the sorted results.
+func (it *Sort) Close() error {
+	it.results = nil
+	it.loaded = false
+	it.index = -1
+	return it.primaryIt.Close()
+}
+
+func (it *Sort) Type() graph.Type { return graph.Sort }
+
+func (it *Sort) Optimize() (graph.Iterator, bool) {
+	newPrimary, optimized := it.primaryIt.Optimize()
+	if optimized {
+		it.primaryIt = newPrimary
+		if it.primaryIt.Type() == graph.Null {
+			return it.primaryIt, true
+		}
+	}
+	if it.qs != nil {
+		// Ask the graph.QuadStore if sorting can be done by the backend.
+		newReplacement, hasOne := it.qs.OptimizeIterator(it)
+		if hasOne {
+			return newReplacement, true
+		}
+	}
+	return it, false
+}
+
+// Stats adds the cost of reading all the results of the primary iterator to
+// the cost of the first Next.
+func (it *Sort) Stats() graph.IteratorStats {
+	primaryStats := it.primaryIt.Stats()
+	primaryStats.NextCost += primaryStats.NextCost * primaryStats.Size / (primaryStats.Size + 1)
+	primaryStats.Next = it.runstats.Next
+	primaryStats.Contains = it.runstats.Contains
+	primaryStats.ContainsNext = it.runstats.ContainsNext
+	return primaryStats
+}
+
+func (it *Sort) Size() (int64, bool) {
+	if it.loaded {
+		return int64(len(it.results)), true
+	}
+	return it.primaryIt.Size()
+}
+
+func (it *Sort) Describe() graph.Description {
+	primary := it.primaryIt.Describe()
+	size, _ := it.Size()
+	return graph.Description{
+		UID:      it.UID(),
+		Type:     it.Type(),
+		Tags:     it.tags.Tags(),
+		Size:     size,
+		Iterator: &primary,
+	}
+}
+
+var _ graph.Nexter = &Sort{}
+
+type byValue struct {
+	results []result
+	keys    []quad.Value
+	desc    bool
+}
+
+func (s byValue) Len() int { return len(s.results) }
+func (s byValue) Less(i, j int) bool {
+	ri, rj := valueRank(s.keys[i]), valueRank(s.keys[j])
+	if ri != rj {
+		return ri < rj
+	}
+	if s.desc {
+		return CompareValues(s.keys[j], s.keys[i]) < 0
+	}
+	return CompareValues(s.keys[i], s.keys[j]) < 0
+}
+func (s byValue) Swap(i, j int) {
+	s.results[i], s.results[j] = s.results[j], s.results[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// Ranks of the kinds of values, in the order they are sorted in. The order of
+// the kinds does not depend on the direction of the sort.
+const (
+	rankNumber = iota
+	rankTime
+	rankString
+	rankOther
+	rankNil // Results without a value come last.
+)
+
+func valueRank(v quad.Value) int {
+	switch v.(type) {
+	case nil:
+		return rankNil
+	case quad.Int, quad.Float:
+		return rankNumber
+	case quad.Time:
+		return rankTime
+	case quad.String, quad.IRI, quad.BNode, quad.TypedString, quad.LangString:
+		return rankString
+	}
+	return rankOther
+}
+
+func stringOf(v quad.Value) string {
+	switch v := v.(type) {
+	case quad.String:
+		return string(v)
+	case quad.IRI:
+		return string(v)
+	case quad.BNode:
+		return string(v)
+	case quad.TypedString:
+		return string(v.Value)
+	case quad.LangString:
+		return string(v.Value)
+	}
+	return quad.StringOf(v)
+}
+
+// CompareValues compares two values by their native types, and returns a
+// negative number if a is lower than b, zero if they are equal and a positive
+// number otherwise. Integers and floats are compared as numbers, times by
+// their instants, and strings, IRIs and blank nodes by their text. Values of
+// different kinds are ordered numbers first, then times, then strings, then
+// anything else, and nil values come last.
+func CompareValues(a, b quad.Value) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return compareInts(int64(ra), int64(rb))
+	}
+	switch ra {
+	case rankNumber:
+		if ai, ok := a.(quad.Int); ok {
+			if bi, ok := b.(quad.Int); ok {
+				return compareInts(int64(ai), int64(bi))
+			}
+		}
+		return compareFloats(toFloat(a), toFloat(b))
+	case rankTime:
+		ta, tb := time.Time(a.(quad.Time)), time.Time(b.(quad.Time))
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	case rankString:
+		return compareStrings(stringOf(a), stringOf(b))
+	}
+	return compareStrings(quad.StringOf(a), quad.StringOf(b))
+}
+
+func toFloat(v quad.Value) float64 {
+	switch v := v.(type) {
+	case quad.Int:
+		return float64(v)
+	case quad.Float:
+		return float64(v)
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package iterator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+func TestSortIteratorBasics(t *testing.T) {
+	qs := &store{
+		parse: true,
+		data:  []string{"10", "b", "9", "a", "100"},
+	}
+	allIt := NewFixed(Identity)
+	for i := range qs.data {
+		allIt.Add(Int64Node(i))
+	}
+
+	s := NewSort(qs, allIt, "", false)
+	// Numbers are compared as numbers, and come before strings.
+	expect := []int{2, 0, 4, 3, 1}
+	for i := 0; i < 2; i++ {
+		if got := iterated(s); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to iterate Sort correctly on repeat %d: got:%v expected:%v", i, got, expect)
+		}
+		s.Reset()
+	}
+	if v, exact := s.Size(); v != 5 || !exact {
+		t.Errorf("Sort iterator returns incorrect size: got:%d expected:%d", v, 5)
+	}
+
+	// Descending order keeps numbers before strings.
+	s = NewSort(qs, allIt.Clone(), "", true)
+	expect = []int{4, 0, 2, 1, 3}
+	if got := iterated(s); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to iterate descending Sort correctly: got:%v expected:%v", got, expect)
+	}
+
+	if !s.Contains(context.TODO(), Int64Node(3)) || s.Contains(context.TODO(), Int64Node(7)) {
+		t.Errorf("Failed to pass Contains to the primary iterator.")
+	}
+}
+
+func TestSortIteratorByTag(t *testing.T) {
+	qs := &store{
+		parse: true,
+		data:  []string{"30", "25", "40"},
+	}
+	ages := NewFixed(Identity)
+	for i := range qs.data {
+		ages.Add(Int64Node(i))
+	}
+	ages.Tagger().Add("age")
+
+	s := NewSort(qs, ages, "age", true)
+	var got []quad.Value
+	for graph.Next(context.TODO(), s) {
+		tags := make(map[string]graph.Value)
+		s.TagResults(tags)
+		got = append(got, qs.NameOf(tags["age"]))
+	}
+	expect := []quad.Value{quad.Int(40), quad.Int(30), quad.Int(25)}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Failed to sort by tag: got:%v expected:%v", got, expect)
+	}
+}
+
+func TestSortIteratorMissingTag(t *testing.T) {
+	qs := &store{
+		parse: true,
+		data:  []string{"true", "10", "false", "a"},
+	}
+	tagged := NewFixed(Identity)
+	for i := range qs.data {
+		tagged.Add(Int64Node(i))
+	}
+	tagged.Tagger().Add("v")
+	untagged := NewFixed(Identity)
+	untagged.Add(Int64Node(5))
+	untagged.Add(Int64Node(6))
+	or := NewOr()
+	or.AddSubIterator(tagged)
+	or.AddSubIterator(untagged)
+
+	// Results without the tag come after the booleans, in both directions.
+	for _, c := range []struct {
+		desc   bool
+		expect []int
+	}{
+		{false, []int{1, 3, 2, 0, 5, 6}},
+		{true, []int{1, 3, 0, 2, 5, 6}},
+	} {
+		s := NewSort(qs, or.Clone(), "v", c.desc)
+		if got := iterated(s); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("Failed to sort results without the tag (descending:%v): got:%v expected:%v", c.desc, got, c.expect)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		a, b   quad.Value
+		expect int
+	}{
+		{quad.Int(2), quad.Int(10), -1},
+		{quad.Float(2.5), quad.Int(2), 1},
+		{quad.Int(3), quad.Float(3), 0},
+		{quad.Time(now), quad.Time(now.Add(time.Hour)), -1},
+		{quad.String("b"), quad.String("a"), 1},
+		{quad.IRI("a"), quad.String("a"), 0},
+		{quad.Int(100), quad.String("1"), -1},
+		{quad.String("a"), quad.Time(now), 1},
+		{nil, quad.String("a"), 1},
+		{nil, quad.Bool(true), 1},
+		{quad.Bool(false), quad.Bool(true), -1},
+	} {
+		if got := CompareValues(c.a, c.b); got != c.expect {
+			t.Errorf("Unexpected comparison of %v and %v: got:%d expected:%d", c.a, c.b, got, c.expect)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package iterator
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 )
 
@@ -66,11 +68,11 @@ func (it *Unique) SubIterators() []graph.Iterator {
 
 // Next advances the subiterator, continuing until it returns a value which it
 // has not previously seen.
-func (it *Unique) Next() bool {
+func (it *Unique) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 	it.runstats.Next += 1
 
-	for graph.Next(it.subIt) {
+	for graph.Next(ctx, it.subIt) {
 		curr := it.subIt.Result()
 		if ok := it.seen[curr]; !ok {
 			it.result = curr
@@ -92,16 +94,16 @@ func (it *Unique) Result() graph.Value {
 
 // Contains checks whether the passed value is part of the primary iterator,
 // which is irrelevant for uniqueness.
-func (it *Unique) Contains(val graph.Value) bool {
+func (it *Unique) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
-	return graph.ContainsLogOut(it, val, it.subIt.Contains(val))
+	return graph.ContainsLogOut(it, val, it.subIt.Contains(ctx, val))
 }
 
 // NextPath for unique always returns false. If we were to return multiple
 // paths, we'd no longer be a unique result, so we have to choose only the first
 // path that got us here. Unique is serious on this point.
-func (it *Unique) NextPath() bool {
+func (it *Unique) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -3,6 +3,8 @@ package iterator
 import (
 	"reflect"
 	"testing"
+
+	"golang.org/x/net/context"
 )
 
 func TestUniqueIteratorBasics(t *testing.T) {
@@ -24,7 +26,7 @@ func TestUniqueIteratorBasics(t *testing.T) {
 	}
 
 	for _, v := range []int{1, 2, 3} {
-		if !u.Contains(Int64Node(v)) {
+		if !u.Contains(context.TODO(), Int64Node(v)) {
 			t.Errorf("Failed to find a correct value in the unique iterator.")
 		}
 	}
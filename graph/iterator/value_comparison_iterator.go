@@ -27,6 +27,8 @@ package iterator
 // In MQL terms, this is the [{"age>=": 21}] concept.
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 	"time"
@@ -190,8 +192,8 @@ func (it *Comparison) Clone() graph.Iterator {
 	return out
 }
 
-func (it *Comparison) Next() bool {
-	for graph.Next(it.subIt) {
+func (it *Comparison) Next(ctx context.Context) bool {
+	for graph.Next(ctx, it.subIt) {
 		val := it.subIt.Result()
 		if it.doComparison(val) {
 			it.result = val
@@ -210,9 +212,9 @@ func (it *Comparison) Result() graph.Value {
 	return it.result
 }
 
-func (it *Comparison) NextPath() bool {
+func (it *Comparison) NextPath(ctx context.Context) bool {
 	for {
-		hasNext := it.subIt.NextPath()
+		hasNext := it.subIt.NextPath(ctx)
 		if !hasNext {
 			it.err = it.subIt.Err()
 			return false
@@ -229,11 +231,11 @@ func (it *Comparison) SubIterators() []graph.Iterator {
 	return []graph.Iterator{it.subIt}
 }
 
-func (it *Comparison) Contains(val graph.Value) bool {
+func (it *Comparison) Contains(ctx context.Context, val graph.Value) bool {
 	if !it.doComparison(val) {
 		return false
 	}
-	ok := it.subIt.Contains(val)
+	ok := it.subIt.Contains(ctx, val)
 	if !ok {
 		it.err = it.subIt.Err()
 	}
@@ -19,6 +19,8 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 )
@@ -145,7 +147,7 @@ func TestValueComparison(t *testing.T) {
 		vc := NewComparison(test.iterator(), test.operator, test.operand, qs)
 
 		var got []quad.Value
-		for vc.Next() {
+		for vc.Next(context.TODO()) {
 			got = append(got, qs.NameOf(vc.Result()))
 		}
 		if !reflect.DeepEqual(got, test.expect) {
@@ -231,7 +233,7 @@ var vciContainsTests = []struct {
 func TestVCIContains(t *testing.T) {
 	for _, test := range vciContainsTests {
 		vc := NewComparison(test.iterator(), test.operator, test.val, test.qs)
-		if vc.Contains(test.check) != test.expect {
+		if vc.Contains(context.TODO(), test.check) != test.expect {
 			t.Errorf("Failed to show %s", test.message)
 		}
 	}
@@ -261,7 +263,7 @@ func TestComparisonIteratorErr(t *testing.T) {
 	for _, test := range comparisonIteratorTests {
 		vc := NewComparison(errIt, CompareLT, test.val, test.qs)
 
-		if vc.Next() != false {
+		if vc.Next(context.TODO()) != false {
 			t.Errorf("Comparison iterator did not pass through initial 'false': %s", test.message)
 		}
 		if vc.Err() != wantErr {
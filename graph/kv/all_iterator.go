@@ -1,4 +1,4 @@
-// Copyright 2014 The Cayley Authors. All rights reserved.
+// Copyright 2016 The Cayley Authors. All rights reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -12,13 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package leveldb
+package kv
 
 import (
-	"bytes"
-
-	ldbit "github.com/syndtr/goleveldb/leveldb/iterator"
-	"github.com/syndtr/goleveldb/leveldb/opt"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
@@ -31,38 +28,21 @@ type AllIterator struct {
 	tags   graph.Tagger
 	prefix []byte
 	dir    quad.Direction
-	open   bool
-	iter   ldbit.Iterator
+	done   bool
+	scan   Cursor
 	qs     *QuadStore
-	ro     *opt.ReadOptions
 	result graph.Value
+	err    error
 }
 
 func NewAllIterator(prefix string, d quad.Direction, qs *QuadStore) *AllIterator {
-	opts := &opt.ReadOptions{
-		DontFillCache: true,
-	}
-
-	it := AllIterator{
+	return &AllIterator{
 		nodes:  prefix == "z",
 		uid:    iterator.NextUID(),
-		ro:     opts,
-		iter:   qs.db.NewIterator(nil, opts),
 		prefix: []byte(prefix),
 		dir:    d,
-		open:   true,
 		qs:     qs,
 	}
-
-	it.iter.Seek(it.prefix)
-	if !it.iter.Valid() {
-		// FIXME(kortschak) What are the semantics here? Is this iterator usable?
-		// If not, we should return nil *Iterator and an error.
-		it.open = false
-		it.iter.Release()
-	}
-
-	return &it
 }
 
 func (it *AllIterator) UID() uint64 {
@@ -70,15 +50,10 @@ func (it *AllIterator) UID() uint64 {
 }
 
 func (it *AllIterator) Reset() {
-	if !it.open {
-		it.iter = it.qs.db.NewIterator(nil, it.ro)
-		it.open = true
-	}
-	it.iter.Seek(it.prefix)
-	if !it.iter.Valid() {
-		it.open = false
-		it.iter.Release()
-	}
+	it.Close()
+	it.err = nil
+	it.done = false
+	it.result = nil
 }
 
 func (it *AllIterator) Tagger() *graph.Tagger {
@@ -101,35 +76,52 @@ func (it *AllIterator) Clone() graph.Iterator {
 	return out
 }
 
-func (it *AllIterator) Next() bool {
-	if !it.open {
+func (it *AllIterator) Next(ctx context.Context) bool {
+	if it.done {
 		it.result = nil
 		return false
 	}
-	var out []byte
-	out = make([]byte, len(it.iter.Key()))
-	copy(out, it.iter.Key())
-	it.iter.Next()
-	if !it.iter.Valid() {
-		it.Close()
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		it.result = nil
+		return false
+	}
+	if it.scan == nil {
+		it.scan = it.qs.db.Scan(it.prefix)
 	}
-	if !bytes.HasPrefix(out, it.prefix) {
+	var (
+		key []byte
+		err error
+	)
+	if it.nodes {
+		if it.scan.Next() {
+			key = make([]byte, len(it.scan.Key()))
+			copy(key, it.scan.Key())
+		} else {
+			err = it.scan.Err()
+		}
+	} else {
+		key, err = nextLive(it.scan)
+	}
+	if err != nil || key == nil {
+		it.err = err
+		it.result = nil
 		it.Close()
 		return false
 	}
-	it.result = Token(out)
+	it.result = Token(key)
 	return true
 }
 
 func (it *AllIterator) Err() error {
-	return it.iter.Error()
+	return it.err
 }
 
 func (it *AllIterator) Result() graph.Value {
 	return it.result
 }
 
-func (it *AllIterator) NextPath() bool {
+func (it *AllIterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -138,26 +130,28 @@ func (it *AllIterator) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func (it *AllIterator) Contains(v graph.Value) bool {
+func (it *AllIterator) Contains(ctx context.Context, v graph.Value) bool {
 	it.result = v
 	return true
 }
 
 func (it *AllIterator) Close() error {
-	if it.open {
-		it.iter.Release()
-		it.open = false
+	var err error
+	if it.scan != nil {
+		err = it.scan.Close()
+		it.scan = nil
 	}
-	return nil
+	it.done = true
+	return err
 }
 
+// Size returns the estimate of the database, if it has one. Otherwise it is
+// the number of quads, which is also the estimate of the number of nodes.
 func (it *AllIterator) Size() (int64, bool) {
-	size, err := it.qs.SizeOfPrefix(it.prefix)
-	if err == nil {
+	if size, ok := it.qs.sizeOfPrefix(it.prefix); ok {
 		return size, false
 	}
-	// INT64_MAX
-	return int64(^uint64(0) >> 1), false
+	return it.qs.Size(), !it.nodes
 }
 
 func (it *AllIterator) Describe() graph.Description {
@@ -0,0 +1,224 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+// HasA is a HasA over the quads linked to a node, for the directions which are
+// the first two of an index, as Subject then Predicate in spo. It reads the
+// nodes from the keys of the index, and checks a node with a single seek of
+// the keys starting with both of them.
+type HasA struct {
+	uid     uint64
+	tags    graph.Tagger
+	prefix  []byte
+	checkID []byte
+	dir     quad.Direction
+	hasaDir quad.Direction
+	done    bool
+	scan    Cursor
+	check   Cursor
+	qs      *QuadStore
+	result  graph.Value
+	err     error
+}
+
+// NewHasA returns the nodes in the hasaDir direction of the quads with the
+// value in the d direction. The index of d must be sorted by hasaDir next.
+func NewHasA(d, hasaDir quad.Direction, value graph.Value, qs *QuadStore) *HasA {
+	vb := value.(Token)
+	p := make([]byte, 0, 2+quad.HashSize)
+	p = append(p, []byte(indexPrefix(d))...)
+	p = append(p, []byte(vb[1:])...)
+
+	return &HasA{
+		uid:     iterator.NextUID(),
+		prefix:  p,
+		checkID: vb,
+		dir:     d,
+		hasaDir: hasaDir,
+		qs:      qs,
+	}
+}
+
+func (it *HasA) UID() uint64 {
+	return it.uid
+}
+
+func (it *HasA) Reset() {
+	it.Close()
+	it.err = nil
+	it.done = false
+	it.result = nil
+}
+
+func (it *HasA) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *HasA) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+}
+
+func (it *HasA) Clone() graph.Iterator {
+	out := NewHasA(it.dir, it.hasaDir, Token(it.checkID), it.qs)
+	out.tags.CopyFrom(it)
+	return out
+}
+
+func (it *HasA) closeCheck() {
+	if it.check != nil {
+		it.check.Close()
+		it.check = nil
+	}
+}
+
+func (it *HasA) Close() error {
+	if it.scan != nil {
+		it.scan.Close()
+		it.scan = nil
+	}
+	it.closeCheck()
+	it.done = true
+	return nil
+}
+
+// Next returns the node of each quad in turn, so the nodes of several quads
+// are returned as many times, as with the HasA it replaces.
+func (it *HasA) Next(ctx context.Context) bool {
+	it.closeCheck()
+	if it.done {
+		it.result = nil
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		it.result = nil
+		return false
+	}
+	if it.scan == nil {
+		it.scan = it.qs.db.Scan(it.prefix)
+	}
+	key, err := nextLive(it.scan)
+	if err != nil || key == nil {
+		it.err = err
+		it.result = nil
+		it.Close()
+		return false
+	}
+	it.result = it.qs.QuadDirection(Token(key), it.hasaDir)
+	return true
+}
+
+func (it *HasA) Err() error {
+	return it.err
+}
+
+func (it *HasA) Result() graph.Value {
+	return it.result
+}
+
+func (it *HasA) Contains(ctx context.Context, v graph.Value) bool {
+	it.closeCheck()
+	val := v.(Token)
+	if !val.IsNode() {
+		return false
+	}
+	p := make([]byte, 0, len(it.prefix)+quad.HashSize)
+	p = append(p, it.prefix...)
+	p = append(p, val[1:]...)
+	it.check = it.qs.db.Scan(p)
+	return it.nextCheck(val)
+}
+
+// NextPath goes on with the quads linking the node last checked.
+func (it *HasA) NextPath(ctx context.Context) bool {
+	if it.check == nil {
+		return false
+	}
+	return it.nextCheck(it.result.(Token))
+}
+
+func (it *HasA) nextCheck(val Token) bool {
+	key, err := nextLive(it.check)
+	if err != nil || key == nil {
+		it.err = err
+		it.closeCheck()
+		return false
+	}
+	it.result = val
+	return true
+}
+
+// No subiterators.
+func (it *HasA) SubIterators() []graph.Iterator {
+	return nil
+}
+
+// Size returns the number of quads linked to the node, which is the most
+// nodes it returns.
+func (it *HasA) Size() (int64, bool) {
+	return it.qs.SizeOf(Token(it.checkID)), false
+}
+
+func (it *HasA) Describe() graph.Description {
+	size, _ := it.Size()
+	return graph.Description{
+		UID:       it.UID(),
+		Name:      it.qs.NameOf(Token(it.checkID)).String(),
+		Type:      it.Type(),
+		Tags:      it.tags.Tags(),
+		Size:      size,
+		Direction: it.hasaDir,
+	}
+}
+
+var hasaType graph.Type
+
+func init() {
+	hasaType = graph.RegisterIterator("kv_hasa")
+}
+
+func HasAType() graph.Type { return hasaType }
+
+func (it *HasA) Type() graph.Type { return hasaType }
+func (it *HasA) Sorted() bool     { return false }
+
+func (it *HasA) Optimize() (graph.Iterator, bool) {
+	return it, false
+}
+
+func (it *HasA) Stats() graph.IteratorStats {
+	s, _ := it.Size()
+	return graph.IteratorStats{
+		ContainsCost: 1,
+		NextCost:     2,
+		Size:         s,
+	}
+}
+
+var _ graph.Nexter = &HasA{}
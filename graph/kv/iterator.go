@@ -1,4 +1,4 @@
-// Copyright 2014 The Cayley Authors. All rights reserved.
+// Copyright 2016 The Cayley Authors. All rights reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -12,14 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package leveldb
+package kv
 
 import (
 	"bytes"
 
-	"github.com/golang/glog"
-	ldbit "github.com/syndtr/goleveldb/leveldb/iterator"
-	"github.com/syndtr/goleveldb/leveldb/opt"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
@@ -27,18 +25,43 @@ import (
 	"github.com/google/cayley/quad"
 )
 
+// nextLive returns a copy of the next key of a quad which is not deleted, or
+// nil at the end of the scan.
+func nextLive(c Cursor) ([]byte, error) {
+	for c.Next() {
+		live, err := isLiveValue(c.Val())
+		if err != nil {
+			return nil, err
+		} else if !live {
+			continue
+		}
+		key := make([]byte, len(c.Key()))
+		copy(key, c.Key())
+		return key, nil
+	}
+	return nil, c.Err()
+}
+
+func isLiveValue(val []byte) (bool, error) {
+	var entry proto.HistoryEntry
+	if err := entry.Unmarshal(val); err != nil {
+		return false, err
+	}
+	return len(entry.History)%2 != 0, nil
+}
+
 type Iterator struct {
 	uid            uint64
 	tags           graph.Tagger
 	nextPrefix     []byte
 	checkID        []byte
 	dir            quad.Direction
-	open           bool
-	iter           ldbit.Iterator
+	done           bool
+	scan           Cursor
 	qs             *QuadStore
-	ro             *opt.ReadOptions
 	originalPrefix string
 	result         graph.Value
+	err            error
 }
 
 func NewIterator(prefix string, d quad.Direction, value graph.Value, qs *QuadStore) *Iterator {
@@ -47,30 +70,14 @@ func NewIterator(prefix string, d quad.Direction, value graph.Value, qs *QuadSto
 	p = append(p, []byte(prefix)...)
 	p = append(p, []byte(vb[1:])...)
 
-	opts := &opt.ReadOptions{
-		DontFillCache: true,
-	}
-
-	it := Iterator{
+	return &Iterator{
 		uid:            iterator.NextUID(),
 		nextPrefix:     p,
 		checkID:        vb,
 		dir:            d,
 		originalPrefix: prefix,
-		ro:             opts,
-		iter:           qs.db.NewIterator(nil, opts),
-		open:           true,
 		qs:             qs,
 	}
-
-	ok := it.iter.Seek(it.nextPrefix)
-	if !ok {
-		it.open = false
-		it.iter.Release()
-		glog.Error("Opening LevelDB iterator couldn't seek to location ", it.nextPrefix)
-	}
-
-	return &it
 }
 
 func (it *Iterator) UID() uint64 {
@@ -78,15 +85,10 @@ func (it *Iterator) UID() uint64 {
 }
 
 func (it *Iterator) Reset() {
-	if !it.open {
-		it.iter = it.qs.db.NewIterator(nil, it.ro)
-		it.open = true
-	}
-	ok := it.iter.Seek(it.nextPrefix)
-	if !ok {
-		it.open = false
-		it.iter.Release()
-	}
+	it.Close()
+	it.err = nil
+	it.done = false
+	it.result = nil
 }
 
 func (it *Iterator) Tagger() *graph.Tagger {
@@ -110,65 +112,48 @@ func (it *Iterator) Clone() graph.Iterator {
 }
 
 func (it *Iterator) Close() error {
-	if it.open {
-		it.iter.Release()
-		it.open = false
+	var err error
+	if it.scan != nil {
+		err = it.scan.Close()
+		it.scan = nil
 	}
-	return nil
+	it.done = true
+	return err
 }
 
-func (it *Iterator) isLiveValue(val []byte) bool {
-	var entry proto.HistoryEntry
-	entry.Unmarshal(val)
-	return len(entry.History)%2 != 0
-}
-
-func (it *Iterator) Next() bool {
-	if it.iter == nil {
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.done {
 		it.result = nil
 		return false
 	}
-	if !it.open {
+	if err := ctx.Err(); err != nil {
+		it.err = err
 		it.result = nil
 		return false
 	}
-	if !it.iter.Valid() {
+	if it.scan == nil {
+		it.scan = it.qs.db.Scan(it.nextPrefix)
+	}
+	key, err := nextLive(it.scan)
+	if err != nil || key == nil {
+		it.err = err
 		it.result = nil
 		it.Close()
 		return false
 	}
-	if bytes.HasPrefix(it.iter.Key(), it.nextPrefix) {
-		if !it.isLiveValue(it.iter.Value()) {
-			ok := it.iter.Next()
-			if !ok {
-				it.Close()
-				return false
-			}
-			return it.Next()
-		}
-		out := make([]byte, len(it.iter.Key()))
-		copy(out, it.iter.Key())
-		it.result = Token(out)
-		ok := it.iter.Next()
-		if !ok {
-			it.Close()
-		}
-		return true
-	}
-	it.Close()
-	it.result = nil
-	return false
+	it.result = Token(key)
+	return true
 }
 
 func (it *Iterator) Err() error {
-	return it.iter.Error()
+	return it.err
 }
 
 func (it *Iterator) Result() graph.Value {
 	return it.result
 }
 
-func (it *Iterator) NextPath() bool {
+func (it *Iterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -177,7 +162,7 @@ func (it *Iterator) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func PositionOf(prefix []byte, d quad.Direction, qs *QuadStore) int {
+func positionOf(prefix []byte, d quad.Direction) int {
 	if bytes.Equal(prefix, []byte("sp")) {
 		switch d {
 		case quad.Subject:
@@ -229,12 +214,12 @@ func PositionOf(prefix []byte, d quad.Direction, qs *QuadStore) int {
 	panic("unreachable")
 }
 
-func (it *Iterator) Contains(v graph.Value) bool {
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 	val := v.(Token)
 	if val.IsNode() {
 		return false
 	}
-	offset := PositionOf(val[0:2], it.dir, it.qs)
+	offset := positionOf(val[0:2], it.dir)
 	if bytes.HasPrefix(val[offset:], it.checkID[1:]) {
 		// You may ask, why don't we check to see if it's a valid (not deleted) quad
 		// again?
@@ -243,8 +228,9 @@ func (it *Iterator) Contains(v graph.Value) bool {
 		// first place, we had to have done the check already; it came from a Next().
 		//
 		// However, if it ever starts coming from somewhere else, it'll be more
-		// efficient to change the interface of the graph.Value for LevelDB to a
+		// efficient to change the interface of the graph.Value for the store to a
 		// struct with a flag for isValid, to save another random read.
+		it.result = val
 		return true
 	}
 	return false
@@ -266,15 +252,15 @@ func (it *Iterator) Describe() graph.Description {
 	}
 }
 
-var levelDBType graph.Type
+var kvType graph.Type
 
 func init() {
-	levelDBType = graph.RegisterIterator("leveldb")
+	kvType = graph.RegisterIterator("kv")
 }
 
-func Type() graph.Type { return levelDBType }
+func Type() graph.Type { return kvType }
 
-func (it *Iterator) Type() graph.Type { return levelDBType }
+func (it *Iterator) Type() graph.Type { return kvType }
 func (it *Iterator) Sorted() bool     { return false }
 
 func (it *Iterator) Optimize() (graph.Iterator, bool) {
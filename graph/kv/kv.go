@@ -0,0 +1,59 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kv is a quad store on top of any ordered key-value store, which
+// only has to implement DB to get the indexes, the migrations and the
+// iterators of the store.
+//
+// The quads are indexed in the spo, osp, pos and cps orders, under the keys
+// made of the first two directions of the order and the hashes of the four
+// nodes, with their proto.HistoryEntry. The nodes are kept with their
+// proto.NodeData under 'z' and their hash, and the deltas as proto.LogDelta
+// under 'd' and their ID.
+package kv
+
+// DB is an ordered key-value store.
+type DB interface {
+	// Get returns the value of the key, or nil if there is none.
+	Get(key []byte) ([]byte, error)
+	// Scan returns a cursor over the keys with the prefix, in order.
+	Scan(prefix []byte) Cursor
+	// NewBatch returns a set of writes, which are applied together when it
+	// is committed.
+	NewBatch() Batch
+	Close() error
+}
+
+// Cursor is an iterator over the keys of a DB. The key and the value are
+// only valid until the next call to Next.
+type Cursor interface {
+	Next() bool
+	Key() []byte
+	Val() []byte
+	Err() error
+	Close() error
+}
+
+// Batch is a set of writes to a DB. It is not used after Commit.
+type Batch interface {
+	Put(key, val []byte)
+	Delete(key []byte)
+	Commit() error
+}
+
+// Sizer is an optional interface for DBs which estimate the number of keys
+// with a prefix.
+type Sizer interface {
+	SizeOfPrefix(prefix []byte) (int64, error)
+}
@@ -0,0 +1,232 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/graphtest"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+// memDB is a DB in a map, which scans a snapshot of its keys.
+type memDB struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func (db *memDB) Get(key []byte) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.data[string(key)], nil
+}
+
+func (db *memDB) Scan(prefix []byte) Cursor {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	c := &memCursor{i: -1}
+	for k, v := range db.data {
+		if strings.HasPrefix(k, string(prefix)) {
+			c.keys = append(c.keys, k)
+			c.vals = append(c.vals, v)
+		}
+	}
+	sort.Sort(c)
+	return c
+}
+
+func (db *memDB) NewBatch() Batch { return &memBatch{db: db, ops: make(map[string][]byte)} }
+
+func (db *memDB) Close() error { return nil }
+
+type memCursor struct {
+	keys []string
+	vals [][]byte
+	i    int
+}
+
+func (c *memCursor) Len() int           { return len(c.keys) }
+func (c *memCursor) Less(i, j int) bool { return c.keys[i] < c.keys[j] }
+func (c *memCursor) Swap(i, j int) {
+	c.keys[i], c.keys[j] = c.keys[j], c.keys[i]
+	c.vals[i], c.vals[j] = c.vals[j], c.vals[i]
+}
+
+func (c *memCursor) Next() bool {
+	c.i++
+	return c.i < len(c.keys)
+}
+
+func (c *memCursor) Key() []byte  { return []byte(c.keys[c.i]) }
+func (c *memCursor) Val() []byte  { return c.vals[c.i] }
+func (c *memCursor) Err() error   { return nil }
+func (c *memCursor) Close() error { return nil }
+
+// memBatch holds the values to write, with nil for the deleted keys.
+type memBatch struct {
+	db  *memDB
+	ops map[string][]byte
+}
+
+func (b *memBatch) Put(key, val []byte) { b.ops[string(key)] = val }
+func (b *memBatch) Delete(key []byte)   { b.ops[string(key)] = nil }
+
+func (b *memBatch) Commit() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	for k, v := range b.ops {
+		if v == nil {
+			delete(b.db.data, k)
+		} else {
+			b.db.data[k] = v
+		}
+	}
+	return nil
+}
+
+func makeMem(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	db := &memDB{data: make(map[string][]byte)}
+	if err := Init(db); err != nil {
+		t.Fatal("Failed to init the database.", err)
+	}
+	qs, err := New("mem_kv", db)
+	if err != nil {
+		t.Fatal("Failed to create the QuadStore.", err)
+	}
+	return qs, nil, func() {
+		qs.Close()
+	}
+}
+
+func TestKVAll(t *testing.T) {
+	graphtest.TestAll(t, makeMem, &graphtest.Config{
+		SkipNodeDelAfterQuadDel: true,
+	})
+}
+
+func TestInitExisting(t *testing.T) {
+	db := &memDB{data: make(map[string][]byte)}
+	if err := Init(db); err != nil {
+		t.Fatal("Failed to init the database.", err)
+	}
+	if err := Init(db); err != graph.ErrDatabaseExists {
+		t.Errorf("Unexpected error on second init, got:%v expect:%v", err, graph.ErrDatabaseExists)
+	}
+	if _, err := New("mem_kv", &memDB{data: make(map[string][]byte)}); err == nil {
+		t.Errorf("Created a QuadStore without init")
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	qs, opts, closer := makeMem(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	// With an linksto-fixed pair
+	fixed := qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("F")))
+	fixed.Tagger().Add("internal")
+	lto := iterator.NewLinksTo(qs, fixed, quad.Object)
+
+	oldIt := lto.Clone()
+	newIt, ok := lto.Optimize()
+	if !ok {
+		t.Errorf("Failed to optimize iterator")
+	}
+	if newIt.Type() != Type() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), Type())
+	}
+
+	newQuads := graphtest.IteratedQuads(t, qs, newIt)
+	oldQuads := graphtest.IteratedQuads(t, qs, oldIt)
+	if !reflect.DeepEqual(newQuads, oldQuads) {
+		t.Errorf("Optimized iteration does not match original")
+	}
+
+	graph.Next(context.TODO(), oldIt)
+	oldResults := make(map[string]graph.Value)
+	oldIt.TagResults(oldResults)
+	graph.Next(context.TODO(), newIt)
+	newResults := make(map[string]graph.Value)
+	newIt.TagResults(newResults)
+	if !reflect.DeepEqual(newResults, oldResults) {
+		t.Errorf("Discordant tag results, new:%v old:%v", newResults, oldResults)
+	}
+}
+
+func TestOptimizeHasA(t *testing.T) {
+	qs, opts, closer := makeMem(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	// The predicates of the quads with B as their subject.
+	fixed := qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("B")))
+	hasa := iterator.NewHasA(qs, iterator.NewLinksTo(qs, fixed, quad.Subject), quad.Predicate)
+	hasa.Tagger().Add("predicate")
+
+	oldIt := hasa.Clone()
+	newIt, ok := hasa.Optimize()
+	if !ok {
+		t.Errorf("Failed to optimize iterator")
+	}
+	if newIt.Type() != HasAType() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), HasAType())
+	}
+
+	newNames := graphtest.IteratedRawStrings(t, qs, newIt)
+	oldNames := graphtest.IteratedRawStrings(t, qs, oldIt)
+	if !reflect.DeepEqual(newNames, oldNames) {
+		t.Errorf("Optimized iteration does not match original, new:%v old:%v", newNames, oldNames)
+	}
+
+	newIt.Reset()
+	ctx := context.TODO()
+	for _, name := range []string{"follows", "status"} {
+		if !newIt.Contains(ctx, qs.ValueOf(quad.Raw(name))) {
+			t.Errorf("Failed to contain %q", name)
+		}
+		results := make(map[string]graph.Value)
+		newIt.TagResults(results)
+		if got := quad.StringOf(qs.NameOf(results["predicate"])); got != name {
+			t.Errorf("Unexpected tag result, got:%q expect:%q", got, name)
+		}
+		if newIt.NextPath(ctx) {
+			t.Errorf("Unexpected next path for %q", name)
+		}
+	}
+	if newIt.Contains(ctx, qs.ValueOf(quad.Raw("cool"))) {
+		t.Errorf("Contains an object of B")
+	}
+
+	// The index of the objects is not sorted by predicate.
+	fixed = qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("B")))
+	hasa = iterator.NewHasA(qs, iterator.NewLinksTo(qs, fixed, quad.Object), quad.Predicate)
+	newIt, _ = hasa.Optimize()
+	if newIt.Type() != graph.HasA {
+		t.Errorf("Unexpected optimization, got:%v expect:%v", newIt.Type(), graph.HasA)
+	}
+}
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package leveldb
+package kv
 
 import (
 	"encoding/json"
@@ -20,32 +20,24 @@ import (
 	"strconv"
 
 	"github.com/golang/glog"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/proto"
 	"github.com/google/cayley/quad"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const latestDataVersion = 2
 const nilDataVersion = 1
 
-type upgradeFunc func(*leveldb.DB) error
+type upgradeFunc func(DB) error
 
 var migrateFunctions = []upgradeFunc{
 	nil,
 	upgrade1To2,
 }
 
-func upgradeLevelDB(path string, opts graph.Options) error {
-	db, err := leveldb.OpenFile(path, &opt.Options{})
-	defer db.Close()
-
-	if err != nil {
-		glog.Errorln("Error, couldn't open! ", err)
-		return err
-	}
+// Upgrade migrates the data of the database to the latest version.
+func Upgrade(db DB) error {
 	version, err := getVersion(db)
 	if err != nil {
 		glog.Errorln("error:", err)
@@ -68,13 +60,32 @@ func upgradeLevelDB(path string, opts graph.Options) error {
 		if err != nil {
 			return err
 		}
-		setVersion(db, i+1, nil)
+		if err = setVersion(db, i+1); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func upgrade1To2(db *leveldb.DB) error {
+// rewrite calls fn with the keys and the values with the prefix, and commits
+// the writes it makes once they are all read.
+func rewrite(db DB, prefix []byte, fn func(b Batch, k, v []byte) error) error {
+	b := db.NewBatch()
+	c := db.Scan(prefix)
+	defer c.Close()
+	for c.Next() {
+		if err := fn(b, c.Key(), c.Val()); err != nil {
+			return err
+		}
+	}
+	if err := c.Err(); err != nil {
+		return err
+	}
+	return b.Commit()
+}
+
+func upgrade1To2(db DB) error {
 	fmt.Println("Upgrading v1 to v2...")
 
 	type v1IndexEntry struct {
@@ -97,36 +108,30 @@ func upgrade1To2(db *leveldb.DB) error {
 		cpsPref = []byte{cps[0].Prefix(), cps[1].Prefix()}
 	)
 
-	{
-		fmt.Println("Upgrading bucket z")
-		it := db.NewIterator(&util.Range{Start: []byte{'z'}, Limit: []byte{'z' + 1}}, nil)
-		for it.Next() {
-			k, v := it.Key(), it.Value()
-			var val v1ValueData
-			if err := json.Unmarshal(v, &val); err != nil {
-				return err
-			}
-			node := proto.NodeData{
-				Size:  val.Size,
-				Value: proto.MakeValue(quad.Raw(val.Name)),
-			}
-			nv, err := node.Marshal()
-			if err != nil {
-				return err
-			}
-			if err = db.Put(k, nv, nil); err != nil {
-				return err
-			}
+	fmt.Println("Upgrading bucket z")
+	err := rewrite(db, []byte{'z'}, func(b Batch, k, v []byte) error {
+		var val v1ValueData
+		if err := json.Unmarshal(v, &val); err != nil {
+			return err
 		}
-		it.Release()
+		node := proto.NodeData{
+			Size:  val.Size,
+			Value: proto.MakeValue(quad.Raw(val.Name)),
+		}
+		nv, err := node.Marshal()
+		if err != nil {
+			return err
+		}
+		b.Put(copyBytes(k), nv)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	for _, pref := range [4][]byte{spoPref, ospPref, posPref, cpsPref} {
 		fmt.Println("Upgrading bucket", string(pref))
-		end := []byte{pref[0], pref[1] + 1}
-		it := db.NewIterator(&util.Range{Start: pref, Limit: end}, nil)
-		for it.Next() {
-			k, v := it.Key(), it.Value()
+		err := rewrite(db, pref, func(b Batch, k, v []byte) error {
 			var entry v1IndexEntry
 			if err := json.Unmarshal(v, &entry); err != nil {
 				return err
@@ -140,41 +145,35 @@ func upgrade1To2(db *leveldb.DB) error {
 			if err != nil {
 				return err
 			}
-			if err = db.Put(k, nv, nil); err != nil {
-				return err
-			}
+			b.Put(copyBytes(k), nv)
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		it.Release()
 	}
 
-	{
-		fmt.Println("Upgrading bucket d")
-		it := db.NewIterator(&util.Range{Start: []byte{'d'}, Limit: []byte{'d' + 1}}, nil)
-		for it.Next() {
-			k, v := it.Key(), it.Value()
-			id, err := strconv.ParseInt(string(k[1:]), 16, 64)
-			if err != nil {
-				return err
-			}
-			nk := createDeltaKeyFor(id)
-			var val graph.Delta
-			if err := json.Unmarshal(v, &val); err != nil {
-				return err
-			}
-			p := deltaToProto(val)
-			nv, err := p.Marshal()
-			if err != nil {
-				return err
-			}
-			b := &leveldb.Batch{}
-			b.Put(nk, nv)
-			b.Delete(k)
-			if err = db.Write(b, nil); err != nil {
-				return err
-			}
+	fmt.Println("Upgrading bucket d")
+	return rewrite(db, []byte{'d'}, func(b Batch, k, v []byte) error {
+		id, err := strconv.ParseInt(string(k[1:]), 16, 64)
+		if err != nil {
+			return err
 		}
-		it.Release()
-	}
+		var val graph.Delta
+		if err := json.Unmarshal(v, &val); err != nil {
+			return err
+		}
+		p := deltaToProto(&val)
+		nv, err := p.Marshal()
+		if err != nil {
+			return err
+		}
+		b.Put(DeltaKey(id), nv)
+		b.Delete(copyBytes(k))
+		return nil
+	})
+}
 
-	return nil
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
 }
@@ -0,0 +1,558 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/proto"
+	"github.com/google/cayley/quad"
+)
+
+// Keys of the metadata of the store.
+const (
+	HorizonKey = "__horizon"
+	SizeKey    = "__size"
+	VersionKey = "__version"
+)
+
+var order = binary.LittleEndian
+
+var _ graph.Keyer = (Token)(nil)
+
+type Token []byte
+
+func (t Token) IsNode() bool { return len(t) > 0 && t[0] == 'z' }
+
+func (t Token) Key() interface{} {
+	return string(t)
+}
+
+type QuadStore struct {
+	db  DB
+	typ string
+
+	// mu serializes the writes, which update the size and the horizon.
+	mu      sync.RWMutex
+	size    int64
+	horizon int64
+}
+
+// Init writes the metadata of a new quad store to the database, or returns
+// graph.ErrDatabaseExists if it already holds one.
+func Init(db DB) error {
+	vers, err := getVersion(db)
+	if err != nil {
+		glog.Errorln("couldn't read from the database during init")
+		return err
+	} else if vers != nilDataVersion {
+		return graph.ErrDatabaseExists
+	}
+	if err = setVersion(db, latestDataVersion); err != nil {
+		glog.Errorln("couldn't write the version during init")
+		return err
+	}
+	return nil
+}
+
+// New returns the quad store of the database, which must have been
+// initialized by Init and be at the latest version. The type is the one of
+// the registered quad store.
+func New(typ string, db DB) (*QuadStore, error) {
+	vers, err := getVersion(db)
+	if err != nil {
+		glog.Errorln("Error, could not read version info! ", err)
+		return nil, err
+	} else if vers == nilDataVersion {
+		return nil, fmt.Errorf("%s: quadstore has not been initialised", typ)
+	} else if vers != latestDataVersion {
+		return nil, fmt.Errorf("%s: data version is out of date (%d vs %d). Run cayleyupgrade for your config to update the data.", typ, vers, latestDataVersion)
+	}
+	qs := &QuadStore{db: db, typ: typ}
+	if qs.size, err = getInt64ForKey(db, SizeKey); err != nil {
+		return nil, err
+	}
+	if qs.horizon, err = getInt64ForKey(db, HorizonKey); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
+
+func setVersion(db DB, version int64) error {
+	b := db.NewBatch()
+	b.Put([]byte(VersionKey), int64Bytes(version))
+	if err := b.Commit(); err != nil {
+		glog.Error("Couldn't write version!")
+		return err
+	}
+	return nil
+}
+
+func getVersion(db DB) (int64, error) {
+	data, err := db.Get([]byte(VersionKey))
+	if err != nil {
+		return 0, err
+	} else if data == nil {
+		return nilDataVersion, nil
+	} else if len(data) != 8 {
+		return 0, fmt.Errorf("version value format is unknown")
+	}
+	return int64(order.Uint64(data)), nil
+}
+
+func int64Bytes(v int64) []byte {
+	buf := make([]byte, 8)
+	order.PutUint64(buf, uint64(v))
+	return buf
+}
+
+func getInt64ForKey(db DB, key string) (int64, error) {
+	b, err := db.Get([]byte(key))
+	if err != nil {
+		glog.Errorln("could not read " + key + ": " + err.Error())
+		return 0, err
+	} else if b == nil {
+		// Must be a new database. Cool
+		return 0, nil
+	} else if len(b) != 8 {
+		return 0, fmt.Errorf("could not parse %s", key)
+	}
+	return int64(order.Uint64(b)), nil
+}
+
+// Metrics reports the metrics of the database, if it has any.
+func (qs *QuadStore) Metrics() map[string]float64 {
+	if m, ok := qs.db.(graph.MetricsReporter); ok {
+		return m.Metrics()
+	}
+	return nil
+}
+
+func (qs *QuadStore) Size() int64 {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	return qs.size
+}
+
+func (qs *QuadStore) Horizon() graph.PrimaryKey {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	return graph.NewSequentialKey(qs.horizon)
+}
+
+func createKeyFor(d [4]quad.Direction, q quad.Quad) []byte {
+	key := make([]byte, 2+(quad.HashSize*4))
+	key[0] = d[0].Prefix()
+	key[1] = d[1].Prefix()
+	quad.HashTo(q.Get(d[0]), key[2+quad.HashSize*0:2+quad.HashSize*1])
+	quad.HashTo(q.Get(d[1]), key[2+quad.HashSize*1:2+quad.HashSize*2])
+	quad.HashTo(q.Get(d[2]), key[2+quad.HashSize*2:2+quad.HashSize*3])
+	quad.HashTo(q.Get(d[3]), key[2+quad.HashSize*3:2+quad.HashSize*4])
+	return key
+}
+
+func createValueKeyFor(s quad.Value) []byte {
+	key := make([]byte, 1+quad.HashSize)
+	key[0] = 'z'
+	quad.HashTo(s, key[1:])
+	return key
+}
+
+// DeltaKey returns the key of the delta with the ID.
+func DeltaKey(id int64) []byte {
+	key := make([]byte, 9)
+	key[0] = 'd'
+	order.PutUint64(key[1:], uint64(id))
+	return key
+}
+
+// IndexKey returns the key of the quad in the index which starts with the
+// directions, as "sp" for the spo index. The hashes are the ones of the nodes
+// in the order of the index.
+func IndexKey(prefix string, hashes []byte) []byte {
+	return append([]byte(prefix), hashes...)
+}
+
+// ValueKey returns the key of the node with the hash.
+func ValueKey(hash []byte) []byte {
+	return append([]byte{'z'}, hash...)
+}
+
+// Short hand for direction permutations.
+var (
+	spo = [4]quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
+	osp = [4]quad.Direction{quad.Object, quad.Subject, quad.Predicate, quad.Label}
+	pos = [4]quad.Direction{quad.Predicate, quad.Object, quad.Subject, quad.Label}
+	cps = [4]quad.Direction{quad.Label, quad.Predicate, quad.Subject, quad.Object}
+)
+
+func deltaToProto(delta *graph.Delta) proto.LogDelta {
+	var newd proto.LogDelta
+	newd.ID = uint64(delta.ID.Int())
+	newd.Action = int32(delta.Action)
+	newd.Timestamp = delta.Timestamp.UnixNano()
+	newd.Quad = proto.MakeQuad(delta.Quad)
+	return newd
+}
+
+func protoToDelta(p *proto.LogDelta) graph.Delta {
+	return graph.Delta{
+		ID:        graph.NewSequentialKey(int64(p.ID)),
+		Quad:      p.Quad.ToNative(),
+		Action:    graph.Procedure(p.Action),
+		Timestamp: time.Unix(0, p.Timestamp),
+	}
+}
+
+// DeltasSince returns the deltas logged after the horizon. The keys of the log
+// are not ordered by ID, so the deltas are read one ID at a time up to the
+// horizon of the store, skipping the IDs of the deltas which failed.
+func (qs *QuadStore) DeltasSince(horizon *graph.PrimaryKey, limit int) ([]graph.Delta, error) {
+	var id int64
+	if horizon != nil {
+		id = horizon.Int()
+	}
+	last := qs.Horizon()
+	end := last.Int()
+	var deltas []graph.Delta
+	for id++; id <= end && (limit <= 0 || len(deltas) < limit); id++ {
+		b, err := qs.db.Get(DeltaKey(id))
+		if err != nil {
+			return deltas, err
+		} else if b == nil {
+			continue
+		}
+		var p proto.LogDelta
+		if err := p.Unmarshal(b); err != nil {
+			return deltas, err
+		}
+		deltas = append(deltas, protoToDelta(&p))
+	}
+	return deltas, nil
+}
+
+// quadWrite is the new history of a quad changed by a transaction.
+type quadWrite struct {
+	quad  quad.Quad
+	entry proto.HistoryEntry
+}
+
+// ApplyDeltas checks the deltas against the store and the deltas before them,
+// and only then writes them in a single batch, along with the size and the
+// horizon of the store. The deltas which are ignored are not logged.
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	var (
+		applied    []int
+		quads      = make(map[string]*quadWrite)
+		resizeMap  = make(map[quad.Value]int64)
+		sizeChange int64
+		horizon    = qs.horizon
+	)
+	for i := range deltas {
+		d := &deltas[i]
+		if d.Action != graph.Add && d.Action != graph.Delete {
+			return fmt.Errorf("%s: invalid action", qs.typ)
+		}
+		key := string(createKeyFor(spo, d.Quad))
+		w, ok := quads[key]
+		if !ok {
+			w = &quadWrite{quad: d.Quad}
+			data, err := qs.db.Get([]byte(key))
+			if err != nil {
+				glog.Error("could not access DB to prepare index: ", err)
+				return err
+			} else if data != nil {
+				if err := w.entry.Unmarshal(data); err != nil {
+					return err
+				}
+			}
+		}
+		isAdd := d.Action == graph.Add
+		if isAdd && len(w.entry.History)%2 == 1 {
+			if ignoreOpts.IgnoreDup {
+				continue
+			}
+			if glog.V(2) {
+				glog.Errorf("attempt to add existing quad %v: %#v", w.entry, d.Quad)
+			}
+			return graph.ErrQuadExists
+		}
+		if !isAdd && len(w.entry.History)%2 == 0 {
+			if ignoreOpts.IgnoreMissing {
+				continue
+			}
+			if glog.V(2) {
+				glog.Errorf("attempt to delete non-existent quad %v: %#v", w.entry, d.Quad)
+			}
+			return graph.ErrQuadNotExist
+		}
+		w.entry.History = append(w.entry.History, uint64(d.ID.Int()))
+		quads[key] = w
+		applied = append(applied, i)
+
+		delta := int64(1)
+		if !isAdd {
+			delta = -1
+		}
+		resizeMap[d.Quad.Subject] += delta
+		resizeMap[d.Quad.Predicate] += delta
+		resizeMap[d.Quad.Object] += delta
+		if d.Quad.Label != nil {
+			resizeMap[d.Quad.Label] += delta
+		}
+		sizeChange += delta
+		horizon = d.ID.Int()
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	b := qs.db.NewBatch()
+	for _, i := range applied {
+		p := deltaToProto(&deltas[i])
+		bytes, err := p.Marshal()
+		if err != nil {
+			return err
+		}
+		b.Put(DeltaKey(deltas[i].ID.Int()), bytes)
+	}
+	for _, w := range quads {
+		if err := writeQuad(b, w); err != nil {
+			return err
+		}
+	}
+	for k, v := range resizeMap {
+		if v != 0 {
+			if err := qs.updateValueKeyBy(b, k, v); err != nil {
+				return err
+			}
+		}
+	}
+	b.Put([]byte(SizeKey), int64Bytes(qs.size+sizeChange))
+	b.Put([]byte(HorizonKey), int64Bytes(horizon))
+	if err := b.Commit(); err != nil {
+		glog.Error("could not write to DB for quadset.")
+		return err
+	}
+	qs.size += sizeChange
+	qs.horizon = horizon
+	return nil
+}
+
+func writeQuad(b Batch, w *quadWrite) error {
+	bytes, err := w.entry.Marshal()
+	if err != nil {
+		glog.Errorf("could not write to buffer for entry %#v: %s", w.entry, err)
+		return err
+	}
+	b.Put(createKeyFor(spo, w.quad), bytes)
+	b.Put(createKeyFor(osp, w.quad), bytes)
+	b.Put(createKeyFor(pos, w.quad), bytes)
+	if w.quad.Get(quad.Label) != nil {
+		b.Put(createKeyFor(cps, w.quad), bytes)
+	}
+	return nil
+}
+
+func (qs *QuadStore) updateValueKeyBy(b Batch, name quad.Value, amount int64) error {
+	value := proto.NodeData{
+		Value: proto.MakeValue(name),
+		Size:  amount,
+	}
+	key := createValueKeyFor(name)
+	data, err := qs.db.Get(key)
+	if err != nil {
+		glog.Errorf("Error reading Value %s from the DB.", name)
+		return err
+	}
+
+	// Node exists in the database -- unmarshal and update.
+	if data != nil {
+		var oldvalue proto.NodeData
+		err = oldvalue.Unmarshal(data)
+		if err != nil {
+			glog.Errorf("Error: could not reconstruct value: %v", err)
+			return err
+		}
+		oldvalue.Size += amount
+		value = oldvalue
+	}
+
+	// Are we deleting something?
+	if value.Size <= 0 {
+		value.Size = 0
+	}
+
+	// Repackage and rewrite.
+	bytes, err := value.Marshal()
+	if err != nil {
+		glog.Errorf("could not write to buffer for value %s: %s", name, err)
+		return err
+	}
+	b.Put(key, bytes)
+	return nil
+}
+
+func (qs *QuadStore) Close() {
+	if err := qs.db.Close(); err != nil {
+		glog.Errorf("could not close %s: %v", qs.typ, err)
+	}
+}
+
+func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
+	var in proto.HistoryEntry
+	b, err := qs.db.Get(k.(Token))
+	if err != nil {
+		glog.Error("Error: could not get quad from DB.")
+		return quad.Quad{}
+	} else if b == nil {
+		// No harm, no foul.
+		return quad.Quad{}
+	}
+	err = in.Unmarshal(b)
+	if err != nil {
+		glog.Error("Error: could not reconstruct history.", err)
+		return quad.Quad{}
+	} else if len(in.History) == 0 {
+		return quad.Quad{}
+	}
+	b, err = qs.db.Get(DeltaKey(int64(in.History[len(in.History)-1])))
+	if err != nil {
+		glog.Error("Error: could not get quad from DB.")
+		return quad.Quad{}
+	} else if b == nil {
+		// No harm, no foul.
+		return quad.Quad{}
+	}
+	var d proto.LogDelta
+	err = d.Unmarshal(b)
+	if err != nil {
+		glog.Error("Error: could not reconstruct quad.", err)
+		return quad.Quad{}
+	}
+	return d.Quad.ToNative()
+}
+
+func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
+	return Token(createValueKeyFor(s))
+}
+
+func (qs *QuadStore) valueData(key []byte) proto.NodeData {
+	var out proto.NodeData
+	if glog.V(3) {
+		glog.V(3).Infof("%c %v", key[0], key)
+	}
+	b, err := qs.db.Get(key)
+	if err != nil {
+		glog.Errorln("Error: could not get value from DB")
+		return out
+	}
+	if b != nil {
+		err = out.Unmarshal(b)
+		if err != nil {
+			glog.Errorln("Error: could not reconstruct value")
+			return proto.NodeData{}
+		}
+	}
+	return out
+}
+
+func (qs *QuadStore) NameOf(k graph.Value) quad.Value {
+	if k == nil {
+		glog.V(2).Info("k was nil")
+		return nil
+	} else if v, ok := k.(graph.PreFetchedValue); ok {
+		return v.NameOf()
+	}
+	v := qs.valueData(k.(Token))
+	return v.GetNativeValue()
+}
+
+func (qs *QuadStore) SizeOf(k graph.Value) int64 {
+	if k == nil {
+		return 0
+	}
+	return int64(qs.valueData(k.(Token)).Size)
+}
+
+// sizeOfPrefix estimates the number of keys with the prefix, if the database
+// can tell.
+func (qs *QuadStore) sizeOfPrefix(pre []byte) (int64, bool) {
+	s, ok := qs.db.(Sizer)
+	if !ok {
+		return 0, false
+	}
+	size, err := s.SizeOfPrefix(pre)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Value) graph.Iterator {
+	return NewIterator(indexPrefix(d), d, val, qs)
+}
+
+// indexPrefix returns the prefix of the index of the quads by the direction.
+func indexPrefix(d quad.Direction) string {
+	switch d {
+	case quad.Subject:
+		return "sp"
+	case quad.Predicate:
+		return "po"
+	case quad.Object:
+		return "os"
+	case quad.Label:
+		return "cp"
+	}
+	panic("unreachable " + d.String())
+}
+
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	return NewAllIterator("z", quad.Any, qs)
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return NewAllIterator("po", quad.Predicate, qs)
+}
+
+func (qs *QuadStore) QuadDirection(val graph.Value, d quad.Direction) graph.Value {
+	v := val.(Token)
+	offset := positionOf(v[0:2], d)
+	return Token(append([]byte("z"), v[offset:offset+quad.HashSize]...))
+}
+
+func compareBytes(a, b graph.Value) bool {
+	return bytes.Equal(a.(Token), b.(Token))
+}
+
+func (qs *QuadStore) FixedIterator() graph.FixedIterator {
+	return iterator.NewFixed(compareBytes)
+}
+
+func (qs *QuadStore) Type() string {
+	return qs.typ
+}
@@ -1,4 +1,4 @@
-// Copyright 2014 The Cayley Authors. All rights reserved.
+// Copyright 2016 The Cayley Authors. All rights reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -12,18 +12,22 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package bolt
+package kv
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
 )
 
 func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
 	switch it.Type() {
 	case graph.LinksTo:
 		return qs.optimizeLinksTo(it.(*iterator.LinksTo))
-
+	case graph.HasA:
+		return qs.optimizeHasA(it.(*iterator.HasA))
 	}
 	return it, false
 }
@@ -37,7 +41,7 @@ func (qs *QuadStore) optimizeLinksTo(it *iterator.LinksTo) (graph.Iterator, bool
 	if primary.Type() == graph.Fixed {
 		size, _ := primary.Size()
 		if size == 1 {
-			if !graph.Next(primary) {
+			if !graph.Next(context.TODO(), primary) {
 				panic("unexpected size during optimize")
 			}
 			val := primary.Result()
@@ -53,3 +57,39 @@ func (qs *QuadStore) optimizeLinksTo(it *iterator.LinksTo) (graph.Iterator, bool
 	}
 	return it, false
 }
+
+// indexNext returns the direction which the index of the direction is sorted
+// by after it.
+func indexNext(d quad.Direction) quad.Direction {
+	switch d {
+	case quad.Subject:
+		return quad.Predicate
+	case quad.Predicate:
+		return quad.Object
+	case quad.Object:
+		return quad.Subject
+	case quad.Label:
+		return quad.Predicate
+	}
+	return quad.Any
+}
+
+// optimizeHasA replaces the HasA of the quads linked to a node by the reads of
+// an index, when the index of the link is sorted by the direction of the HasA
+// next. The quads are not tagged, as they are never read.
+func (qs *QuadStore) optimizeHasA(it *iterator.HasA) (graph.Iterator, bool) {
+	subs := it.SubIterators()
+	if len(subs) != 1 {
+		return it, false
+	}
+	primary, ok := subs[0].(*Iterator)
+	if !ok || len(primary.tags.Tags()) != 0 || len(primary.tags.Fixed()) != 0 {
+		return it, false
+	}
+	if indexNext(primary.dir) != it.Direction() {
+		return it, false
+	}
+	newIt := NewHasA(primary.dir, it.Direction(), Token(primary.checkID), qs)
+	newIt.Tagger().CopyFrom(it)
+	return newIt, true
+}
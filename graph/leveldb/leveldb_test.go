@@ -20,9 +20,12 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/graphtest"
 	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/kv"
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/writer"
 )
@@ -132,7 +135,7 @@ func TestLoadDatabase(t *testing.T) {
 	}
 	w, _ = writer.NewSingleReplication(qs, nil)
 
-	ts2, didConvert := qs.(*QuadStore)
+	ts2, didConvert := qs.(*kv.QuadStore)
 	if !didConvert {
 		t.Errorf("Could not convert from generic to LevelDB QuadStore")
 	}
@@ -188,8 +191,8 @@ func TestOptimize(t *testing.T) {
 	if !ok {
 		t.Errorf("Failed to optimize iterator")
 	}
-	if newIt.Type() != Type() {
-		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), Type())
+	if newIt.Type() != kv.Type() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), kv.Type())
 	}
 
 	newQuads := graphtest.IteratedQuads(t, qs, newIt)
@@ -198,10 +201,10 @@ func TestOptimize(t *testing.T) {
 		t.Errorf("Optimized iteration does not match original")
 	}
 
-	graph.Next(oldIt)
+	graph.Next(context.TODO(), oldIt)
 	oldResults := make(map[string]graph.Value)
 	oldIt.TagResults(oldResults)
-	graph.Next(newIt)
+	graph.Next(context.TODO(), newIt)
 	newResults := make(map[string]graph.Value)
 	newIt.TagResults(newResults)
 	if !reflect.DeepEqual(newResults, oldResults) {
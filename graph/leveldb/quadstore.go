@@ -12,23 +12,22 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package leveldb is a quad store backed by LevelDB, through the kv package.
 package leveldb
 
 import (
-	"bytes"
-	"encoding/binary"
-	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/syndtr/goleveldb/leveldb"
+	ldbit "github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
 
 	"github.com/google/cayley/graph"
-	"github.com/google/cayley/graph/iterator"
-	"github.com/google/cayley/graph/proto"
-	"github.com/google/cayley/quad"
+	"github.com/google/cayley/graph/kv"
 )
 
 func init() {
@@ -45,34 +44,17 @@ const (
 	DefaultCacheSize       = 2
 	DefaultWriteBufferSize = 20
 	QuadStoreType          = "leveldb"
-	horizonKey             = "__horizon"
-	sizeKey                = "__size"
-	versionKey             = "__version"
 )
 
-var order = binary.LittleEndian
-
-var _ graph.Keyer = (Token)(nil)
-
-type Token []byte
-
-func (t Token) IsNode() bool { return len(t) > 0 && t[0] == 'z' }
-
-func (t Token) Key() interface{} {
-	return string(t)
-}
-
-type QuadStore struct {
-	dbOpts    *opt.Options
+// DB is a LevelDB database, as a kv.DB.
+type DB struct {
 	db        *leveldb.DB
-	path      string
-	open      bool
-	size      int64
-	horizon   int64
 	writeopts *opt.WriteOptions
 	readopts  *opt.ReadOptions
 }
 
+var _ kv.DB = (*DB)(nil)
+
 func createNewLevelDB(path string, _ graph.Options) error {
 	opts := &opt.Options{}
 	db, err := leveldb.OpenFile(path, opts)
@@ -80,34 +62,18 @@ func createNewLevelDB(path string, _ graph.Options) error {
 		glog.Errorf("Error: could not create database: %v", err)
 		return err
 	}
-	defer db.Close()
-	qs := &QuadStore{}
-	qs.db = db
-	qs.writeopts = &opt.WriteOptions{
-		Sync: true,
-	}
-	qs.readopts = &opt.ReadOptions{}
-	_, err = qs.db.Get([]byte(horizonKey), qs.readopts)
-	if err != nil && err != leveldb.ErrNotFound {
-		glog.Errorln("couldn't read from leveldb during init")
-		return err
-	}
-	if err != leveldb.ErrNotFound {
-		return graph.ErrDatabaseExists
-	}
-	// Write some metadata
-	if err = setVersion(qs.db, latestDataVersion, qs.writeopts); err != nil {
-		glog.Errorln("couldn't write leveldb version during init")
-		return err
+	ldb := &DB{
+		db: db,
+		writeopts: &opt.WriteOptions{
+			Sync: true,
+		},
+		readopts: &opt.ReadOptions{},
 	}
-	qs.Close()
-	return nil
+	defer ldb.Close()
+	return kv.Init(ldb)
 }
 
 func newQuadStore(path string, options graph.Options) (graph.QuadStore, error) {
-	var qs QuadStore
-	var err error
-	qs.path = path
 	cacheSize := DefaultCacheSize
 	val, ok, err := options.IntKey("cache_size_mb")
 	if err != nil {
@@ -115,10 +81,10 @@ func newQuadStore(path string, options graph.Options) (graph.QuadStore, error) {
 	} else if ok {
 		cacheSize = val
 	}
-	qs.dbOpts = &opt.Options{
+	dbOpts := &opt.Options{
 		BlockCacheCapacity: cacheSize * opt.MiB,
 	}
-	qs.dbOpts.ErrorIfMissing = true
+	dbOpts.ErrorIfMissing = true
 
 	writeBufferSize := DefaultWriteBufferSize
 	val, ok, err = options.IntKey("writeBufferSize")
@@ -127,386 +93,135 @@ func newQuadStore(path string, options graph.Options) (graph.QuadStore, error) {
 	} else if ok {
 		writeBufferSize = val
 	}
-	qs.dbOpts.WriteBuffer = writeBufferSize * opt.MiB
-	qs.writeopts = &opt.WriteOptions{
-		Sync: false,
-	}
-	qs.readopts = &opt.ReadOptions{}
-	db, err := leveldb.OpenFile(qs.path, qs.dbOpts)
+	dbOpts.WriteBuffer = writeBufferSize * opt.MiB
+	db, err := leveldb.OpenFile(path, dbOpts)
 	if err != nil {
 		glog.Errorln("Error, could not open! ", err)
 		return nil, err
 	}
-	qs.db = db
-	glog.Infoln(qs.GetStats())
-	vers, err := getVersion(qs.db)
-	if err != nil {
-		glog.Errorln("Error, could not read version info! ", err)
-		db.Close()
-		return nil, err
-	} else if vers != latestDataVersion {
-		return nil, fmt.Errorf("leveldb: data version is out of date (%d vs %d). Run cayleyupgrade for your config to update the data.", vers, latestDataVersion)
+	ldb := &DB{
+		db: db,
+		writeopts: &opt.WriteOptions{
+			Sync: false,
+		},
+		readopts: &opt.ReadOptions{},
 	}
-	err = qs.getMetadata()
+	glog.Infoln(ldb.GetStats())
+	qs, err := kv.New(QuadStoreType, ldb)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
-	return &qs, nil
+	return qs, nil
 }
 
-func setVersion(db *leveldb.DB, version int64, wo *opt.WriteOptions) error {
-	buf := make([]byte, 8)
-	order.PutUint64(buf, uint64(version))
-	err := db.Put([]byte(versionKey), buf, wo)
+func upgradeLevelDB(path string, opts graph.Options) error {
+	db, err := leveldb.OpenFile(path, &opt.Options{})
 	if err != nil {
-		glog.Error("Couldn't write version!")
+		glog.Errorln("Error, couldn't open! ", err)
 		return err
 	}
-	return nil
+	ldb := &DB{
+		db:        db,
+		writeopts: &opt.WriteOptions{},
+		readopts:  &opt.ReadOptions{},
+	}
+	defer ldb.Close()
+	return kv.Upgrade(ldb)
 }
 
-func getVersion(db *leveldb.DB) (int64, error) {
-	data, err := db.Get([]byte(versionKey), nil)
+func (db *DB) Get(key []byte) ([]byte, error) {
+	b, err := db.db.Get(key, db.readopts)
 	if err == leveldb.ErrNotFound {
-		return nilDataVersion, nil
-	} else if len(data) != 8 {
-		return 0, fmt.Errorf("version value format is unknown")
+		return nil, nil
 	}
-	return int64(order.Uint64(data)), nil
+	return b, err
 }
 
-func (qs *QuadStore) GetStats() string {
-	out := ""
-	stats, err := qs.db.GetProperty("leveldb.stats")
-	if err == nil {
-		out += fmt.Sprintln("Stats: ", stats)
-	}
-	out += fmt.Sprintln("Size: ", qs.size)
-	return out
+func (db *DB) Scan(prefix []byte) kv.Cursor {
+	return cursor{db.db.NewIterator(util.BytesPrefix(prefix), &opt.ReadOptions{
+		DontFillCache: true,
+	})}
 }
 
-func (qs *QuadStore) Size() int64 {
-	return qs.size
+type cursor struct {
+	ldbit.Iterator
 }
 
-func (qs *QuadStore) Horizon() graph.PrimaryKey {
-	return graph.NewSequentialKey(qs.horizon)
+func (c cursor) Val() []byte { return c.Value() }
+func (c cursor) Err() error  { return c.Error() }
+
+func (c cursor) Close() error {
+	c.Release()
+	return nil
 }
 
-func createKeyFor(d [4]quad.Direction, q quad.Quad) []byte {
-	key := make([]byte, 2+(quad.HashSize*4))
-	key[0] = d[0].Prefix()
-	key[1] = d[1].Prefix()
-	quad.HashTo(q.Get(d[0]), key[2+quad.HashSize*0:2+quad.HashSize*1])
-	quad.HashTo(q.Get(d[1]), key[2+quad.HashSize*1:2+quad.HashSize*2])
-	quad.HashTo(q.Get(d[2]), key[2+quad.HashSize*2:2+quad.HashSize*3])
-	quad.HashTo(q.Get(d[3]), key[2+quad.HashSize*3:2+quad.HashSize*4])
-	return key
+func (db *DB) NewBatch() kv.Batch {
+	return &batch{db: db}
 }
 
-func createValueKeyFor(s quad.Value) []byte {
-	key := make([]byte, 1+quad.HashSize)
-	key[0] = 'z'
-	quad.HashTo(s, key[1:])
-	return key
+type batch struct {
+	db *DB
+	leveldb.Batch
 }
 
-func createDeltaKeyFor(id int64) []byte {
-	key := make([]byte, 9)
-	key[0] = 'd'
-	order.PutUint64(key[1:], uint64(id))
-	return key
+func (b *batch) Commit() error {
+	return b.db.db.Write(&b.Batch, b.db.writeopts)
 }
 
-// Short hand for direction permutations.
-var (
-	spo = [4]quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
-	osp = [4]quad.Direction{quad.Object, quad.Subject, quad.Predicate, quad.Label}
-	pos = [4]quad.Direction{quad.Predicate, quad.Object, quad.Subject, quad.Label}
-	cps = [4]quad.Direction{quad.Label, quad.Predicate, quad.Subject, quad.Object}
-)
+func (db *DB) Close() error {
+	return db.db.Close()
+}
 
-func deltaToProto(delta graph.Delta) proto.LogDelta {
-	var newd proto.LogDelta
-	newd.ID = uint64(delta.ID.Int())
-	newd.Action = int32(delta.Action)
-	newd.Timestamp = delta.Timestamp.UnixNano()
-	newd.Quad = proto.MakeQuad(delta.Quad)
-	return newd
+func (db *DB) GetStats() string {
+	out := ""
+	stats, err := db.db.GetProperty("leveldb.stats")
+	if err == nil {
+		out += fmt.Sprintln("Stats: ", stats)
+	}
+	return out
 }
 
-func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
-	batch := &leveldb.Batch{}
-	resizeMap := make(map[quad.Value]int64)
-	sizeChange := int64(0)
-	for _, d := range deltas {
-		if d.Action != graph.Add && d.Action != graph.Delete {
-			return errors.New("leveldb: invalid action")
-		}
-		p := deltaToProto(d)
-		bytes, err := p.Marshal()
-		if err != nil {
-			return err
-		}
-		batch.Put(createDeltaKeyFor(d.ID.Int()), bytes)
-		err = qs.buildQuadWrite(batch, d.Quad, d.ID.Int(), d.Action == graph.Add)
-		if err != nil {
-			if err == graph.ErrQuadExists && ignoreOpts.IgnoreDup {
+// Metrics reports the tables, size and compactions of every level, as found
+// in GetStats, along with the caches and open tables of LevelDB.
+func (db *DB) Metrics() map[string]float64 {
+	m := make(map[string]float64)
+	if stats, err := db.db.GetProperty("leveldb.stats"); err == nil {
+		for _, line := range strings.Split(stats, "\n") {
+			f := strings.Split(line, "|")
+			if len(f) != 6 {
 				continue
 			}
-			if err == graph.ErrQuadNotExist && ignoreOpts.IgnoreMissing {
+			level := strings.TrimSpace(f[0])
+			if _, err := strconv.Atoi(level); err != nil {
 				continue
 			}
-			return err
-		}
-		delta := int64(1)
-		if d.Action == graph.Delete {
-			delta = int64(-1)
-		}
-		resizeMap[d.Quad.Subject] += delta
-		resizeMap[d.Quad.Predicate] += delta
-		resizeMap[d.Quad.Object] += delta
-		if d.Quad.Label != nil {
-			resizeMap[d.Quad.Label] += delta
-		}
-		sizeChange += delta
-		qs.horizon = d.ID.Int()
-	}
-	for k, v := range resizeMap {
-		if v != 0 {
-			err := qs.UpdateValueKeyBy(k, v, batch)
-			if err != nil {
-				return err
+			for i, name := range []string{
+				"leveldb_level_tables",
+				"leveldb_level_size_mb",
+				"leveldb_level_compaction_seconds",
+				"leveldb_level_compaction_read_mb",
+				"leveldb_level_compaction_write_mb",
+			} {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(f[i+1]), 64); err == nil {
+					m[name+`{level="`+level+`"}`] = v
+				}
 			}
 		}
 	}
-	err := qs.db.Write(batch, qs.writeopts)
-	if err != nil {
-		glog.Error("could not write to DB for quadset.")
-		return err
-	}
-	qs.size += sizeChange
-	return nil
-}
-
-func (qs *QuadStore) buildQuadWrite(batch *leveldb.Batch, q quad.Quad, id int64, isAdd bool) error {
-	var entry proto.HistoryEntry
-	data, err := qs.db.Get(createKeyFor(spo, q), qs.readopts)
-	if err != nil && err != leveldb.ErrNotFound {
-		glog.Error("could not access DB to prepare index: ", err)
-		return err
-	}
-	if data != nil {
-		// We got something.
-		err = entry.Unmarshal(data)
+	for _, p := range []string{"cachedblock", "openedtables", "alivesnaps", "aliveiters"} {
+		v, err := db.db.GetProperty("leveldb." + p)
 		if err != nil {
-			return err
+			continue
 		}
-	}
-
-	if isAdd && len(entry.History)%2 == 1 {
-		if glog.V(2) {
-			glog.Errorf("attempt to add existing quad %v: %#v", entry, q)
-		}
-		return graph.ErrQuadExists
-	}
-	if !isAdd && len(entry.History)%2 == 0 {
-		if glog.V(2) {
-			glog.Errorf("attempt to delete non-existent quad %v: %#c", entry, q)
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			m["leveldb_"+p] = float64(n)
 		}
-		return graph.ErrQuadNotExist
 	}
-
-	entry.History = append(entry.History, uint64(id))
-
-	bytes, err := entry.Marshal()
-	if err != nil {
-		glog.Errorf("could not write to buffer for entry %#v: %s", entry, err)
-		return err
-	}
-	batch.Put(createKeyFor(spo, q), bytes)
-	batch.Put(createKeyFor(osp, q), bytes)
-	batch.Put(createKeyFor(pos, q), bytes)
-	if q.Get(quad.Label) != nil {
-		batch.Put(createKeyFor(cps, q), bytes)
-	}
-	return nil
+	return m
 }
 
-func (qs *QuadStore) UpdateValueKeyBy(name quad.Value, amount int64, batch *leveldb.Batch) error {
-	value := proto.NodeData{
-		Value: proto.MakeValue(name),
-		Size:  amount,
-	}
-	key := createValueKeyFor(name)
-	b, err := qs.db.Get(key, qs.readopts)
-
-	// Error getting the node from the database.
-	if err != nil && err != leveldb.ErrNotFound {
-		glog.Errorf("Error reading Value %s from the DB.", name)
-		return err
-	}
-
-	// Node exists in the database -- unmarshal and update.
-	if b != nil && err != leveldb.ErrNotFound {
-		var oldvalue proto.NodeData
-		err = oldvalue.Unmarshal(b)
-		if err != nil {
-			glog.Errorf("Error: could not reconstruct value: %v", err)
-			return err
-		}
-		oldvalue.Size += amount
-		value = oldvalue
-	}
-
-	// Are we deleting something?
-	if value.Size <= 0 {
-		value.Size = 0
-	}
-
-	// Repackage and rewrite.
-	bytes, err := value.Marshal()
-	if err != nil {
-		glog.Errorf("could not write to buffer for value %s: %s", name, err)
-		return err
-	}
-	if batch == nil {
-		qs.db.Put(key, bytes, qs.writeopts)
-	} else {
-		batch.Put(key, bytes)
-	}
-	return nil
-}
-
-func (qs *QuadStore) Close() {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, order, qs.size)
-	if err == nil {
-		werr := qs.db.Put([]byte(sizeKey), buf.Bytes(), qs.writeopts)
-		if werr != nil {
-			glog.Error("could not write size before closing!")
-		}
-	} else {
-		glog.Errorf("could not convert size before closing!")
-	}
-	buf.Reset()
-	err = binary.Write(buf, order, qs.horizon)
-	if err == nil {
-		werr := qs.db.Put([]byte(horizonKey), buf.Bytes(), qs.writeopts)
-		if werr != nil {
-			glog.Error("could not write horizon before closing!")
-		}
-	} else {
-		glog.Errorf("could not convert horizon before closing!")
-	}
-	qs.db.Close()
-	qs.open = false
-}
-
-func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
-	var in proto.HistoryEntry
-	b, err := qs.db.Get(k.(Token), qs.readopts)
-	if err == leveldb.ErrNotFound {
-		// No harm, no foul.
-		return quad.Quad{}
-	} else if err != nil {
-		glog.Error("Error: could not get quad from DB.")
-		return quad.Quad{}
-	}
-	err = in.Unmarshal(b)
-	if err != nil {
-		glog.Error("Error: could not reconstruct history.", err)
-		return quad.Quad{}
-	}
-	b, err = qs.db.Get(createDeltaKeyFor(int64(in.History[len(in.History)-1])), qs.readopts)
-	if err == leveldb.ErrNotFound {
-		// No harm, no foul.
-		return quad.Quad{}
-	} else if err != nil {
-		glog.Error("Error: could not get quad from DB.")
-		return quad.Quad{}
-	}
-	var d proto.LogDelta
-	err = d.Unmarshal(b)
-	if err != nil {
-		glog.Error("Error: could not reconstruct quad.", err)
-		return quad.Quad{}
-	}
-	return d.Quad.ToNative()
-}
-
-func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
-	return Token(createValueKeyFor(s))
-}
-
-func (qs *QuadStore) valueData(key []byte) proto.NodeData {
-	var out proto.NodeData
-	if glog.V(3) {
-		glog.V(3).Infof("%c %v", key[0], key)
-	}
-	b, err := qs.db.Get(key, qs.readopts)
-	if err != nil && err != leveldb.ErrNotFound {
-		glog.Errorln("Error: could not get value from DB")
-		return out
-	}
-	if b != nil && err != leveldb.ErrNotFound {
-		err = out.Unmarshal(b)
-		if err != nil {
-			glog.Errorln("Error: could not reconstruct value")
-			return proto.NodeData{}
-		}
-	}
-	return out
-}
-
-func (qs *QuadStore) NameOf(k graph.Value) quad.Value {
-	if k == nil {
-		glog.V(2).Info("k was nil")
-		return nil
-	}
-	v := qs.valueData(k.(Token))
-	return v.GetNativeValue()
-}
-
-func (qs *QuadStore) SizeOf(k graph.Value) int64 {
-	if k == nil {
-		return 0
-	}
-	return int64(qs.valueData(k.(Token)).Size)
-}
-
-func (qs *QuadStore) getInt64ForKey(key string, empty int64) (int64, error) {
-	var out int64
-	b, err := qs.db.Get([]byte(key), qs.readopts)
-	if err != nil && err != leveldb.ErrNotFound {
-		glog.Errorln("could not read " + key + ": " + err.Error())
-		return 0, err
-	}
-	if err == leveldb.ErrNotFound {
-		// Must be a new database. Cool
-		return empty, nil
-	}
-	buf := bytes.NewBuffer(b)
-	err = binary.Read(buf, order, &out)
-	if err != nil {
-		glog.Errorln("Error: could not parse", key)
-		return 0, err
-	}
-	return out, nil
-}
-
-func (qs *QuadStore) getMetadata() error {
-	var err error
-	qs.size, err = qs.getInt64ForKey(sizeKey, 0)
-	if err != nil {
-		return err
-	}
-	qs.horizon, err = qs.getInt64ForKey(horizonKey, 0)
-	return err
-}
-
-func (qs *QuadStore) SizeOfPrefix(pre []byte) (int64, error) {
+func (db *DB) SizeOfPrefix(pre []byte) (int64, error) {
 	limit := make([]byte, len(pre))
 	copy(limit, pre)
 	end := len(limit) - 1
@@ -514,52 +229,9 @@ func (qs *QuadStore) SizeOfPrefix(pre []byte) (int64, error) {
 	ranges := make([]util.Range, 1)
 	ranges[0].Start = pre
 	ranges[0].Limit = limit
-	sizes, err := qs.db.SizeOf(ranges)
+	sizes, err := db.db.SizeOf(ranges)
 	if err == nil {
 		return (int64(sizes[0]) >> 6) + 1, nil
 	}
 	return 0, nil
 }
-
-func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Value) graph.Iterator {
-	var prefix string
-	switch d {
-	case quad.Subject:
-		prefix = "sp"
-	case quad.Predicate:
-		prefix = "po"
-	case quad.Object:
-		prefix = "os"
-	case quad.Label:
-		prefix = "cp"
-	default:
-		panic("unreachable " + d.String())
-	}
-	return NewIterator(prefix, d, val, qs)
-}
-
-func (qs *QuadStore) NodesAllIterator() graph.Iterator {
-	return NewAllIterator("z", quad.Any, qs)
-}
-
-func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
-	return NewAllIterator("po", quad.Predicate, qs)
-}
-
-func (qs *QuadStore) QuadDirection(val graph.Value, d quad.Direction) graph.Value {
-	v := val.(Token)
-	offset := PositionOf(v[0:2], d, qs)
-	return Token(append([]byte("z"), v[offset:offset+quad.HashSize]...))
-}
-
-func compareBytes(a, b graph.Value) bool {
-	return bytes.Equal(a.(Token), b.(Token))
-}
-
-func (qs *QuadStore) FixedIterator() graph.FixedIterator {
-	return iterator.NewFixed(compareBytes)
-}
-
-func (qs *QuadStore) Type() string {
-	return QuadStoreType
-}
@@ -15,6 +15,8 @@
 package memstore
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 )
@@ -44,21 +46,21 @@ func (it *nodesAllIterator) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func (it *nodesAllIterator) Next() bool {
-	if !it.Int64.Next() {
+func (it *nodesAllIterator) Next(ctx context.Context) bool {
+	if !it.Int64.Next(ctx) {
 		return false
 	}
 	it.qs.idmu.RLock()
 	_, ok := it.qs.revIDMap[int64(it.Int64.Result().(iterator.Int64Node))]
 	it.qs.idmu.RUnlock()
 	if !ok {
-		return it.Next()
+		return it.Next(ctx)
 	}
 	return true
 }
 
 func (it *nodesAllIterator) Err() error {
-	return nil
+	return it.Int64.Err()
 }
 
 func newQuadsAllIterator(qs *QuadStore) *quadsAllIterator {
@@ -71,9 +73,9 @@ func newQuadsAllIterator(qs *QuadStore) *quadsAllIterator {
 	return &out
 }
 
-func (it *quadsAllIterator) Next() (next bool) {
+func (it *quadsAllIterator) Next(ctx context.Context) (next bool) {
 	for {
-		next = it.Int64.Next()
+		next = it.Int64.Next(ctx)
 		if !next {
 			break
 		}
@@ -93,6 +95,19 @@ func (it *quadsAllIterator) Next() (next bool) {
 	return
 }
 
+// The ranges of IDs have gaps left by the deleted nodes and quads, so their
+// sizes are only upper bounds.
+
+func (it *nodesAllIterator) Size() (int64, bool) {
+	size, _ := it.Int64.Size()
+	return size, false
+}
+
+func (it *quadsAllIterator) Size() (int64, bool) {
+	size, _ := it.Int64.Size()
+	return size, false
+}
+
 // Override Optimize from it.Int64 - it will hide our Next implementation in other cases.
 
 func (it *nodesAllIterator) Optimize() (graph.Iterator, bool) { return it, false }
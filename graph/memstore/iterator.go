@@ -19,6 +19,8 @@ import (
 	"io"
 	"math"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
@@ -57,6 +59,7 @@ func (it *Iterator) UID() uint64 {
 
 func (it *Iterator) Reset() {
 	it.iter = it.tree.SeekFirst()
+	it.err = nil
 }
 
 func (it *Iterator) Tagger() *graph.Tagger {
@@ -109,12 +112,16 @@ func (it *Iterator) checkValid(index int64) bool {
 	return valid
 }
 
-func (it *Iterator) Next() bool {
+func (it *Iterator) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
 
 	if it.iter == nil {
 		return graph.NextLogOut(it, nil, false)
 	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return graph.NextLogOut(it, nil, false)
+	}
 	result, err := it.iter.Next()
 	if err != nil {
 		if err != io.EOF {
@@ -123,7 +130,7 @@ func (it *Iterator) Next() bool {
 		return graph.NextLogOut(it, nil, false)
 	}
 	if !it.checkValid(result) {
-		return it.Next()
+		return it.Next(ctx)
 	}
 	it.result = result
 	return graph.NextLogOut(it, it.Result(), true)
@@ -140,7 +147,7 @@ func (it *Iterator) Result() graph.Value {
 	return iterator.Int64Quad(it.result)
 }
 
-func (it *Iterator) NextPath() bool {
+func (it *Iterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -153,7 +160,7 @@ func (it *Iterator) Size() (int64, bool) {
 	return int64(it.tree.Len()), true
 }
 
-func (it *Iterator) Contains(v graph.Value) bool {
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 	graph.ContainsLogIn(it, v)
 	if v == nil {
 		return graph.ContainsLogOut(it, v, false)
@@ -0,0 +1,384 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+// A persistent memstore keeps its data in a directory, as a snapshot of the
+// quads it holds and a write-ahead log of the deltas applied since. Both are
+// made of records, each being the varint length of a proto.LogDelta, the
+// CRC-32C of its encoding and the encoding itself. The snapshot starts with
+// the horizon at which it was taken.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/proto"
+	"github.com/google/cayley/quad"
+)
+
+const (
+	snapshotFile = "snapshot"
+	walFile      = "wal"
+
+	// DefaultSnapshotDeltas is the number of deltas written to the log after
+	// which a new snapshot is taken.
+	DefaultSnapshotDeltas = 100000
+
+	// maxRecordSize bounds the size of a record, so that a corrupted length
+	// is not allocated.
+	maxRecordSize = 1 << 28
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errChecksum = errors.New("checksum mismatch")
+)
+
+// diskLog holds the write-ahead log of a persistent memstore.
+type diskLog struct {
+	dir    string
+	nosync bool
+	every  int
+
+	// mu serializes the writes, so that the log is in the order in which
+	// the deltas are applied.
+	mu  sync.Mutex
+	wal *os.File
+	n   int
+}
+
+// isPersistent returns whether the memstore keeps its data at the path, which
+// is the case for directories, and for paths which do not exist yet. Files are
+// quad files, which are loaded into an in-memory store.
+func isPersistent(path string, _ graph.Options) bool {
+	if path == "" || strings.Contains(path, "://") {
+		return false
+	}
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	return err == nil && fi.IsDir()
+}
+
+func createNewMemStore(path string, _ graph.Options) error {
+	if f, err := os.Open(path); err == nil {
+		names, _ := f.Readdirnames(1)
+		f.Close()
+		if len(names) > 0 {
+			return graph.ErrDatabaseExists
+		}
+	}
+	return os.MkdirAll(path, 0700)
+}
+
+func newQuadStoreForPath(path string, opts graph.Options) (graph.QuadStore, error) {
+	if !isPersistent(path, opts) {
+		return newQuadStore(), nil
+	}
+	return openQuadStore(path, opts)
+}
+
+// openQuadStore recovers a persistent memstore from its snapshot and its log.
+func openQuadStore(path string, opts graph.Options) (*QuadStore, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("memstore: %s is not initialized", path)
+	}
+	nosync, _, err := opts.BoolKey("nosync")
+	if err != nil {
+		return nil, err
+	}
+	every, ok, err := opts.IntKey("snapshot_deltas")
+	if err != nil {
+		return nil, err
+	} else if !ok || every <= 0 {
+		every = DefaultSnapshotDeltas
+	}
+	qs := newQuadStore()
+	horizon, err := qs.readSnapshot(filepath.Join(path, snapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	n, err := qs.replayLog(filepath.Join(path, walFile), horizon)
+	if err != nil {
+		return nil, err
+	}
+	wal, err := os.OpenFile(filepath.Join(path, walFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	qs.disk = &diskLog{dir: path, nosync: nosync, every: every, wal: wal, n: n}
+	return qs, nil
+}
+
+// readSnapshot loads the quads of the snapshot, and returns its horizon.
+func (qs *QuadStore) readSnapshot(path string) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var horizon int64
+	if err := binary.Read(r, binary.LittleEndian, &horizon); err != nil {
+		return 0, fmt.Errorf("memstore: could not read snapshot: %v", err)
+	}
+	var p proto.LogDelta
+	for {
+		_, err := readRecord(r, &p)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("memstore: could not read snapshot: %v", err)
+		}
+		if err := qs.AddDelta(protoToDelta(&p)); err != nil {
+			return 0, err
+		}
+	}
+	qs.log[0].ID = horizon
+	return horizon, nil
+}
+
+// replayLog applies the deltas of the log which are past the horizon, and
+// returns the number of deltas in the log. A last record which was not fully
+// written, as the process stopped while appending it, is cut off the log. Any
+// other invalid record fails the recovery, as the deltas after it would be
+// lost.
+func (qs *QuadStore) replayLog(path string, horizon int64) (int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var (
+		p   proto.LogDelta
+		off int64
+		n   int
+	)
+	for {
+		sz, err := readRecord(r, &p)
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			glog.Warningf("memstore: truncating the log at a partial record at offset %d", off)
+			if err := f.Truncate(off); err != nil {
+				return 0, err
+			}
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("memstore: invalid record in the log at offset %d: %v", off, err)
+		}
+		off += int64(sz)
+		n++
+		if int64(p.ID) <= horizon {
+			continue
+		}
+		switch graph.Procedure(p.Action) {
+		case graph.Add:
+			err = qs.AddDelta(protoToDelta(&p))
+			if err == graph.ErrQuadExists {
+				err = nil
+			}
+		case graph.Delete:
+			err = qs.RemoveDelta(protoToDelta(&p))
+			if err == graph.ErrQuadNotExist {
+				err = nil
+			}
+		default:
+			err = fmt.Errorf("memstore: invalid action in the log: %d", p.Action)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// writeLog appends the deltas to the log, which must be held by qs.disk.mu.
+func (qs *QuadStore) writeLog(deltas []graph.Delta) error {
+	var buf bytes.Buffer
+	for i := range deltas {
+		d := &deltas[i]
+		err := writeRecord(&buf, logDelta(d.ID.Int(), d.Quad, d.Action, d.Timestamp))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := qs.disk.wal.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if !qs.disk.nosync {
+		if err := qs.disk.wal.Sync(); err != nil {
+			return err
+		}
+	}
+	qs.disk.n += len(deltas)
+	return nil
+}
+
+// writeSnapshot writes the quads of the store to a new snapshot, which then
+// replaces the log. It must be called with qs.disk.mu held.
+func (qs *QuadStore) writeSnapshot() error {
+	path := filepath.Join(qs.disk.dir, snapshotFile)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = qs.dumpSnapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := qs.disk.wal.Truncate(0); err != nil {
+		return err
+	}
+	qs.disk.n = 0
+	return nil
+}
+
+func (qs *QuadStore) dumpSnapshot(w io.Writer) error {
+	qs.logmu.RLock()
+	defer qs.logmu.RUnlock()
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.LittleEndian, qs.horizon()); err != nil {
+		return err
+	}
+	for i := 1; i < len(qs.log); i++ {
+		e := &qs.log[i]
+		if e.Action != graph.Add || e.DeletedBy != 0 {
+			continue
+		}
+		if err := writeRecord(bw, logDelta(e.ID, e.Quad, e.Action, e.Timestamp)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// closeLog takes a last snapshot, if there were writes since the previous
+// one, and closes the log.
+func (qs *QuadStore) closeLog() error {
+	qs.disk.mu.Lock()
+	defer qs.disk.mu.Unlock()
+	var err error
+	if qs.disk.n > 0 {
+		err = qs.writeSnapshot()
+	}
+	if cerr := qs.disk.wal.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func logDelta(id int64, q quad.Quad, action graph.Procedure, ts time.Time) *proto.LogDelta {
+	return &proto.LogDelta{
+		ID:        uint64(id),
+		Quad:      proto.MakeQuad(q),
+		Action:    int32(action),
+		Timestamp: ts.UnixNano(),
+	}
+}
+
+func protoToDelta(p *proto.LogDelta) graph.Delta {
+	return graph.Delta{
+		ID:        graph.NewSequentialKey(int64(p.ID)),
+		Quad:      p.Quad.ToNative(),
+		Action:    graph.Procedure(p.Action),
+		Timestamp: time.Unix(0, p.Timestamp),
+	}
+}
+
+func writeRecord(w io.Writer, p *proto.LogDelta) error {
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	var hdr [binary.MaxVarintLen64 + 4]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(data)))
+	binary.LittleEndian.PutUint32(hdr[n:], crc32.Checksum(data, crcTable))
+	if _, err := w.Write(hdr[:n+4]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readRecord reads a record written by writeRecord, and returns its size. It
+// returns io.EOF only if there is no record left, and io.ErrUnexpectedEOF if
+// the last record is incomplete.
+func readRecord(r *bufio.Reader, p *proto.LogDelta) (int, error) {
+	if _, err := r.Peek(1); err == io.EOF {
+		return 0, io.EOF
+	}
+	sz, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, err
+	} else if sz > maxRecordSize {
+		return 0, fmt.Errorf("record of %d bytes", sz)
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	data := make([]byte, sz)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(sum[:]) {
+		return 0, errChecksum
+	}
+	p.Reset()
+	if err := p.Unmarshal(data); err != nil {
+		return 0, err
+	}
+	var hdr [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(hdr[:], sz) + len(sum) + len(data), nil
+}
@@ -16,10 +16,12 @@ package memstore
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
@@ -31,13 +33,12 @@ const QuadStoreType = "memstore"
 
 func init() {
 	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
-		NewFunc: func(string, graph.Options) (graph.QuadStore, error) {
-			return newQuadStore(), nil
-		},
+		NewFunc:           newQuadStoreForPath,
 		NewForRequestFunc: nil,
 		UpgradeFunc:       nil,
-		InitFunc:          nil,
+		InitFunc:          createNewMemStore,
 		IsPersistent:      false,
+		IsPersistentFunc:  isPersistent,
 	})
 }
 
@@ -161,6 +162,8 @@ type QuadStore struct {
 	size       int64
 
 	index QuadDirectionIndex
+	// disk is the log of a persistent store, and nil for in-memory ones.
+	disk *diskLog
 	// vip_index map[string]map[int64]map[string]map[int64]*b.Tree
 }
 
@@ -179,6 +182,10 @@ func newQuadStore() *QuadStore {
 }
 
 func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	if qs.disk != nil {
+		qs.disk.mu.Lock()
+		defer qs.disk.mu.Unlock()
+	}
 	// Precheck the whole transaction
 	for _, d := range deltas {
 		switch d.Action {
@@ -198,6 +205,11 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 			return errors.New("memstore: invalid action")
 		}
 	}
+	if qs.disk != nil {
+		if err := qs.writeLog(deltas); err != nil {
+			return err
+		}
+	}
 
 	for _, d := range deltas {
 		var err error
@@ -219,6 +231,15 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 			return err
 		}
 	}
+	if qs.disk != nil && qs.disk.n >= qs.disk.every {
+		// The snapshot is written before returning, so this write and the
+		// next ones wait for the whole store to be written, while the reads
+		// go on. The deltas are safe in the log, so a failed snapshot is
+		// retried with the next ones.
+		if err := qs.writeSnapshot(); err != nil {
+			glog.Errorf("memstore: could not write snapshot: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -250,7 +271,7 @@ func (qs *QuadStore) indexOf(t quad.Quad) (int64, bool) {
 	}
 
 	it := NewIterator(tree, qs, 0, nil)
-	for it.Next() {
+	for it.Next(context.TODO()) {
 		qs.logmu.RLock()
 		l := qs.log[it.result]
 		qs.logmu.RUnlock()
@@ -337,13 +358,48 @@ func (qs *QuadStore) QuadIterator(d quad.Direction, value graph.Value) graph.Ite
 	return &iterator.Null{}
 }
 
+// DeltasSince returns the deltas logged after the horizon, which are found by
+// a binary search as the log is sorted by ID.
+func (qs *QuadStore) DeltasSince(horizon *graph.PrimaryKey, limit int) ([]graph.Delta, error) {
+	var id int64
+	if horizon != nil {
+		id = horizon.Int()
+	}
+	qs.logmu.RLock()
+	defer qs.logmu.RUnlock()
+	// Skip the sentinel entry.
+	log := qs.log[1:]
+	i := sort.Search(len(log), func(i int) bool { return log[i].ID > id })
+	var deltas []graph.Delta
+	for ; i < len(log) && (limit <= 0 || len(deltas) < limit); i++ {
+		e := &log[i]
+		deltas = append(deltas, graph.Delta{
+			ID:        graph.NewSequentialKey(e.ID),
+			Quad:      e.Quad,
+			Action:    e.Action,
+			Timestamp: e.Timestamp,
+		})
+	}
+	return deltas, nil
+}
+
 func (qs *QuadStore) Horizon() graph.PrimaryKey {
 	qs.logmu.RLock()
-	id := qs.log[len(qs.log)-1].ID
+	id := qs.horizon()
 	qs.logmu.RUnlock()
 	return graph.NewSequentialKey(id)
 }
 
+// horizon returns the ID of the last delta, which must be held by qs.logmu.
+// The sentinel entry holds the horizon of the snapshot the store was loaded
+// from, as the deleted quads are not kept in snapshots.
+func (qs *QuadStore) horizon() int64 {
+	if id := qs.log[len(qs.log)-1].ID; id > qs.log[0].ID {
+		return id
+	}
+	return qs.log[0].ID
+}
+
 func (qs *QuadStore) Size() int64 {
 	qs.logmu.RLock()
 	size := qs.size
@@ -372,6 +428,8 @@ func (qs *QuadStore) ValueOf(name quad.Value) graph.Value {
 func (qs *QuadStore) NameOf(id graph.Value) quad.Value {
 	if id == nil {
 		return nil
+	} else if v, ok := id.(graph.PreFetchedValue); ok {
+		return v.NameOf()
 	}
 	qs.idmu.RLock()
 	v := qs.revIDMap[int64(id.(iterator.Int64Node))]
@@ -396,7 +454,14 @@ func (qs *QuadStore) NodesAllIterator() graph.Iterator {
 	return newNodesAllIterator(qs)
 }
 
-func (qs *QuadStore) Close() {}
+func (qs *QuadStore) Close() {
+	if qs.disk == nil {
+		return
+	}
+	if err := qs.closeLog(); err != nil {
+		glog.Errorf("memstore: could not close the log: %v", err)
+	}
+}
 
 func (qs *QuadStore) Type() string {
 	return QuadStoreType
@@ -15,13 +15,21 @@
 package memstore
 
 import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/graphtest"
 	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/proto"
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/writer"
 )
@@ -127,7 +135,7 @@ func TestIteratorsAndNextResultOrderA(t *testing.T) {
 	outerAnd.AddSubIterator(fixed)
 	outerAnd.AddSubIterator(hasa)
 
-	if !outerAnd.Next() {
+	if !outerAnd.Next(context.TODO()) {
 		t.Error("Expected one matching subtree")
 	}
 	val := outerAnd.Result()
@@ -141,7 +149,7 @@ func TestIteratorsAndNextResultOrderA(t *testing.T) {
 	)
 	for {
 		got = append(got, qs.NameOf(all.Result()).String())
-		if !outerAnd.NextPath() {
+		if !outerAnd.NextPath(context.TODO()) {
 			break
 		}
 	}
@@ -151,7 +159,7 @@ func TestIteratorsAndNextResultOrderA(t *testing.T) {
 		t.Errorf("Unexpected result, got:%q expect:%q", got, expect)
 	}
 
-	if outerAnd.Next() {
+	if outerAnd.Next(context.TODO()) {
 		t.Error("More than one possible top level output?")
 	}
 }
@@ -214,7 +222,7 @@ func TestRemoveQuad(t *testing.T) {
 	hasa := iterator.NewHasA(qs, innerAnd, quad.Object)
 
 	newIt, _ := hasa.Optimize()
-	if graph.Next(newIt) {
+	if graph.Next(context.TODO(), newIt) {
 		t.Error("E should not have any followers.")
 	}
 }
@@ -246,10 +254,182 @@ func TestTransaction(t *testing.T) {
 
 func TestEmpty(t *testing.T) {
 	qs := newQuadStore()
-	if qs.QuadsAllIterator().(graph.Nexter).Next() {
+	if qs.QuadsAllIterator().(graph.Nexter).Next(context.TODO()) {
 		t.Error("next quad in empty store")
 	}
-	if qs.NodesAllIterator().(graph.Nexter).Next() {
+	if qs.NodesAllIterator().(graph.Nexter).Next(context.TODO()) {
 		t.Error("next node in empty store")
 	}
 }
+
+func makePersistentStore(t testing.TB, dir string, opts graph.Options) *QuadStore {
+	qs, err := openQuadStore(dir, opts)
+	if err != nil {
+		t.Fatalf("Could not open the persistent store: %v", err)
+	}
+	return qs
+}
+
+func horizonOf(qs *QuadStore) int64 {
+	h := qs.Horizon()
+	return h.Int()
+}
+
+func TestPersistentAll(t *testing.T) {
+	graphtest.TestAll(t, func(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+		dir, err := ioutil.TempDir("", "cayley_test")
+		if err != nil {
+			t.Fatalf("Could not create working directory: %v", err)
+		}
+		qs := makePersistentStore(t, dir, graph.Options{"nosync": true})
+		return qs, nil, func() {
+			qs.Close()
+			os.RemoveAll(dir)
+		}
+	}, &graphtest.Config{
+		SkipNodeDelAfterQuadDel: true,
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if !isPersistent(filepath.Join(dir, "db"), nil) {
+		t.Error("Expected a new path to be persistent")
+	}
+	if err := createNewMemStore(filepath.Join(dir, "db"), nil); err != nil {
+		t.Fatalf("Could not init the store: %v", err)
+	}
+	if err := createNewMemStore(filepath.Join(dir, "db"), nil); err != nil {
+		t.Errorf("Could not init an empty directory: %v", err)
+	}
+	dir = filepath.Join(dir, "db")
+
+	for _, every := range []int{0, 3} {
+		os.Remove(filepath.Join(dir, snapshotFile))
+		os.Remove(filepath.Join(dir, walFile))
+		opts := graph.Options{"nosync": true}
+		if every > 0 {
+			opts["snapshot_deltas"] = float64(every)
+		}
+		qs := makePersistentStore(t, dir, opts)
+		w, _ := writer.NewSingleReplication(qs, nil)
+		if err := w.AddQuadSet(simpleGraph); err != nil {
+			t.Fatalf("Could not write quads: %v", err)
+		}
+		if err := w.RemoveQuad(quad.Make("E", "follows", "F", "")); err != nil {
+			t.Fatalf("Could not remove quad: %v", err)
+		}
+		horizon := horizonOf(qs)
+		size := qs.Size()
+
+		// Simulate a crash by not closing the store.
+		qs.disk.wal.Close()
+		qs = makePersistentStore(t, dir, opts)
+		if got := horizonOf(qs); got != horizon {
+			t.Errorf("Unexpected horizon after recovery, got:%d expected:%d", got, horizon)
+		}
+		if got := qs.Size(); got != size {
+			t.Errorf("Unexpected size after recovery, got:%d expected:%d", got, size)
+		}
+		if _, ok := qs.indexOf(quad.Make("E", "follows", "F", "")); ok {
+			t.Error("Removed quad was recovered")
+		}
+		if _, ok := qs.indexOf(quad.Make("G", "status", "cool", "status_graph")); !ok {
+			t.Error("Quad was not recovered")
+		}
+
+		// Writes after the recovery continue the log.
+		w, _ = writer.NewSingleReplication(qs, nil)
+		if err := w.AddQuad(quad.Make("E", "follows", "G", "")); err != nil {
+			t.Fatalf("Could not write quad: %v", err)
+		}
+		qs.Close()
+		qs = makePersistentStore(t, dir, opts)
+		if got := horizonOf(qs); got != horizon+1 {
+			t.Errorf("Unexpected horizon after reopening, got:%d expected:%d", got, horizon+1)
+		}
+		if got := qs.Size(); got != size+1 {
+			t.Errorf("Unexpected size after reopening, got:%d expected:%d", got, size+1)
+		}
+		qs.Close()
+	}
+}
+
+func TestPersistentTornLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	qs := makePersistentStore(t, dir, nil)
+	w, _ := writer.NewSingleReplication(qs, nil)
+	w.AddQuadSet(simpleGraph[:2])
+	qs.disk.wal.Write([]byte{0x20, 0x01})
+	qs.disk.wal.Close()
+
+	qs = makePersistentStore(t, dir, nil)
+	if got := qs.Size(); got != 2 {
+		t.Errorf("Unexpected size after recovery, got:%d expected:2", got)
+	}
+	w, _ = writer.NewSingleReplication(qs, nil)
+	w.AddQuad(simpleGraph[2])
+	qs.disk.wal.Close()
+
+	qs = makePersistentStore(t, dir, nil)
+	defer qs.Close()
+	if got := qs.Size(); got != 3 {
+		t.Errorf("Unexpected size after recovery, got:%d expected:3", got)
+	}
+}
+
+func TestPersistentCorruptLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	qs := makePersistentStore(t, dir, nil)
+	w, _ := writer.NewSingleReplication(qs, nil)
+	for _, q := range simpleGraph[:3] {
+		if err := w.AddQuad(q); err != nil {
+			t.Fatalf("Could not write quad: %v", err)
+		}
+	}
+	qs.disk.wal.Close()
+
+	// Flip the last byte of the second record.
+	path := filepath.Join(dir, walFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(bytes.NewReader(data))
+	var p proto.LogDelta
+	first, err := readRecord(r, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := readRecord(r, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[first+second-1] ^= 0xff
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if qs, err := openQuadStore(dir, nil); err == nil {
+		qs.Close()
+		t.Fatal("Expected an error recovering a log corrupted in the middle")
+	}
+	if got, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("The corrupted log was changed: %v", err)
+	}
+}
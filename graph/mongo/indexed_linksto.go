@@ -15,6 +15,7 @@
 package mongo
 
 import (
+	"golang.org/x/net/context"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
@@ -108,7 +109,7 @@ func (it *LinksTo) Optimize() (graph.Iterator, bool) {
 	return it, false
 }
 
-func (it *LinksTo) Next() bool {
+func (it *LinksTo) Next(ctx context.Context) bool {
 	var result struct {
 		ID      string  `bson:"_id"`
 		Added   []int64 `bson:"Added"`
@@ -118,6 +119,10 @@ func (it *LinksTo) Next() bool {
 next:
 	for {
 		it.runstats.Next += 1
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return graph.NextLogOut(it, nil, false)
+		}
 		if it.nextIt != nil && it.nextIt.Next(&result) {
 			it.runstats.ContainsNext += 1
 			if it.collection == "quads" && len(result.Added) <= len(result.Deleted) {
@@ -136,7 +141,7 @@ next:
 
 		}
 		// Subiterator is empty, get another one
-		if !graph.Next(it.primaryIt) {
+		if !graph.Next(ctx, it.primaryIt) {
 			// Possibly save error
 			it.err = it.primaryIt.Err()
 
@@ -174,8 +179,8 @@ func (it *LinksTo) Close() error {
 	return err
 }
 
-func (it *LinksTo) NextPath() bool {
-	ok := it.primaryIt.NextPath()
+func (it *LinksTo) NextPath(ctx context.Context) bool {
+	ok := it.primaryIt.NextPath(ctx)
 	if !ok {
 		it.err = it.primaryIt.Err()
 	}
@@ -192,7 +197,7 @@ func (it *LinksTo) Clone() graph.Iterator {
 	return m
 }
 
-func (it *LinksTo) Contains(val graph.Value) bool {
+func (it *LinksTo) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
 
@@ -204,7 +209,7 @@ func (it *LinksTo) Contains(val graph.Value) bool {
 	}
 
 	node := it.qs.QuadDirection(val, it.dir)
-	if it.primaryIt.Contains(node) {
+	if it.primaryIt.Contains(ctx, node) {
 		it.result = val
 		return graph.ContainsLogOut(it, val, true)
 	}
@@ -16,6 +16,7 @@ package mongo
 
 import (
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
@@ -37,6 +38,11 @@ type Iterator struct {
 	collection string
 	result     graph.Value
 	err        error
+
+	// limit and skip are passed to the query. They are only set for
+	// collections which are not filtered after being fetched.
+	limit int64
+	skip  int64
 }
 
 func NewIterator(qs *QuadStore, collection string, d quad.Direction, val graph.Value) *Iterator {
@@ -58,10 +64,19 @@ func NewIterator(qs *QuadStore, collection string, d quad.Direction, val graph.V
 }
 
 func (it *Iterator) makeMongoIterator() *mgo.Iter {
+	var q *mgo.Query
 	if it.isAll {
-		return it.qs.db.C(it.collection).Find(nil).Iter()
+		q = it.qs.db.C(it.collection).Find(nil)
+	} else {
+		q = it.qs.db.C(it.collection).Find(it.constraint)
+	}
+	if it.skip > 0 {
+		q = q.Skip(int(it.skip))
 	}
-	return it.qs.db.C(it.collection).Find(it.constraint).Iter()
+	if it.limit > 0 {
+		q = q.Limit(int(it.limit))
+	}
+	return q.Iter()
 }
 
 func NewAllIterator(qs *QuadStore, collection string) *Iterator {
@@ -98,8 +113,8 @@ func (it *Iterator) UID() uint64 {
 
 func (it *Iterator) Reset() {
 	it.Close()
-	it.iter = it.qs.db.C(it.collection).Find(it.constraint).Iter()
-
+	it.iter = it.makeMongoIterator()
+	it.err = nil
 }
 
 func (it *Iterator) Close() error {
@@ -130,16 +145,21 @@ func (it *Iterator) Clone() graph.Iterator {
 	} else {
 		m = NewIterator(it.qs, it.collection, it.dir, NodeHash(it.hash))
 	}
+	m.limit, m.skip = it.limit, it.skip
 	m.tags.CopyFrom(it)
 	return m
 }
 
-func (it *Iterator) Next() bool {
+func (it *Iterator) Next(ctx context.Context) bool {
 	var result struct {
 		ID      string  `bson:"_id"`
 		Added   []int64 `bson:"Added"`
 		Deleted []int64 `bson:"Deleted"`
 	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
 	if it.iter == nil {
 		it.iter = it.makeMongoIterator()
 	}
@@ -153,7 +173,7 @@ func (it *Iterator) Next() bool {
 		return false
 	}
 	if it.collection == "quads" && len(result.Added) <= len(result.Deleted) {
-		return it.Next()
+		return it.Next(ctx)
 	}
 	if it.collection == "quads" {
 		it.result = QuadHash(result.ID)
@@ -171,7 +191,7 @@ func (it *Iterator) Result() graph.Value {
 	return it.result
 }
 
-func (it *Iterator) NextPath() bool {
+func (it *Iterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
@@ -180,7 +200,7 @@ func (it *Iterator) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func (it *Iterator) Contains(v graph.Value) bool {
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 	graph.ContainsLogIn(it, v)
 	if it.isAll {
 		it.result = v
@@ -201,6 +221,15 @@ func (it *Iterator) Size() (int64, bool) {
 		if err != nil {
 			it.err = err
 		}
+		if it.skip > 0 {
+			it.size -= it.skip
+			if it.size < 0 {
+				it.size = 0
+			}
+		}
+		if it.limit > 0 && it.size > it.limit {
+			it.size = it.limit
+		}
 	}
 	return it.size, true
 }
@@ -468,6 +468,9 @@ func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
 }
 
 func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
+	if v, ok := v.(graph.PreFetchedValue); ok {
+		return v.NameOf()
+	}
 	hash := v.(NodeHash)
 	if hash == "" {
 		return nil
@@ -509,6 +512,37 @@ func (qs *QuadStore) Horizon() graph.PrimaryKey {
 	return graph.NewSequentialKey(log.LogID)
 }
 
+// DeltasSince returns the deltas logged after the horizon. The quads of the
+// log entries are read from the quads collection, by their key.
+func (qs *QuadStore) DeltasSince(horizon *graph.PrimaryKey, limit int) ([]graph.Delta, error) {
+	var id int64
+	if horizon != nil {
+		id = horizon.Int()
+	}
+	q := qs.db.C("log").Find(bson.M{"LogID": bson.M{"$gt": id}}).Sort("LogID")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var entries []MongoLogEntry
+	if err := q.All(&entries); err != nil {
+		return nil, err
+	}
+	deltas := make([]graph.Delta, 0, len(entries))
+	for _, e := range entries {
+		action := graph.Add
+		if e.Action == "Delete" {
+			action = graph.Delete
+		}
+		deltas = append(deltas, graph.Delta{
+			ID:        graph.NewSequentialKey(e.LogID),
+			Quad:      qs.Quad(QuadHash(e.Key)),
+			Action:    action,
+			Timestamp: time.Unix(0, e.Timestamp),
+		})
+	}
+	return deltas, nil
+}
+
 func (qs *QuadStore) FixedIterator() graph.FixedIterator {
 	return iterator.NewFixed(iterator.Identity)
 }
@@ -17,6 +17,7 @@ package mongo
 import (
 	"time"
 
+	"golang.org/x/net/context"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/golang/glog"
@@ -34,6 +35,10 @@ func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool)
 		return qs.optimizeAndIterator(it.(*iterator.And))
 	case graph.Comparison:
 		return qs.optimizeComparison(it.(*iterator.Comparison))
+	case graph.Limit:
+		return qs.optimizeLimit(it.(*iterator.Limit))
+	case graph.Skip:
+		return qs.optimizeSkip(it.(*iterator.Skip))
 	}
 	return it, false
 }
@@ -108,7 +113,7 @@ func (qs *QuadStore) optimizeLinksTo(it *iterator.LinksTo) (graph.Iterator, bool
 	if primary.Type() == graph.Fixed {
 		size, _ := primary.Size()
 		if size == 1 {
-			if !graph.Next(primary) {
+			if !graph.Next(context.TODO(), primary) {
 				panic("unexpected size during optimize")
 			}
 			val := primary.Result()
@@ -184,3 +189,49 @@ func (qs *QuadStore) optimizeComparison(it *iterator.Comparison) (graph.Iterator
 	}
 	return NewIteratorWithConstraints(qs, mit.collection, constraint), true
 }
+
+// limitSubIterator returns the Mongo iterator the limit or skip can be pushed
+// down to, if any. Deleted quads are filtered out after they are fetched, so
+// the quads collection can not be limited by the query itself.
+func limitSubIterator(it graph.Iterator) (*Iterator, bool) {
+	subs := it.SubIterators()
+	if len(subs) != 1 {
+		return nil, false
+	}
+	mit, ok := subs[0].(*Iterator)
+	if !ok || mit.collection == "quads" {
+		return nil, false
+	}
+	return mit, true
+}
+
+func (qs *QuadStore) optimizeLimit(it *iterator.Limit) (graph.Iterator, bool) {
+	mit, ok := limitSubIterator(it)
+	if !ok {
+		return it, false
+	}
+	if mit.limit == 0 || it.Limit() < mit.limit {
+		mit.limit = it.Limit()
+	}
+	mit.size = -1
+	mit.tags.CopyFrom(it)
+	return mit, true
+}
+
+func (qs *QuadStore) optimizeSkip(it *iterator.Skip) (graph.Iterator, bool) {
+	mit, ok := limitSubIterator(it)
+	if !ok {
+		return it, false
+	}
+	if mit.limit > 0 {
+		// Skipping inside an already limited query shrinks the window.
+		if it.Skip() >= mit.limit {
+			return iterator.NewNull(), true
+		}
+		mit.limit -= it.Skip()
+	}
+	mit.skip += it.Skip()
+	mit.size = -1
+	mit.tags.CopyFrom(it)
+	return mit, true
+}
@@ -20,16 +20,16 @@ import (
 	"github.com/google/cayley/quad"
 )
 
-type applyMorphism func(graph.QuadStore, graph.Iterator, *context) (graph.Iterator, *context)
+type applyMorphism func(graph.QuadStore, graph.Iterator, *pathContext) (graph.Iterator, *pathContext)
 
 type morphism struct {
 	Name     string
-	Reversal func(*context) (morphism, *context)
+	Reversal func(*pathContext) (morphism, *pathContext)
 	Apply    applyMorphism
 	tags     []string
 }
 
-// context allows a high-level change to the way paths are constructed. Some
+// pathContext allows a high-level change to the way paths are constructed. Some
 // functions may change the context, causing following chained calls to act
 // cdifferently.
 //
@@ -45,7 +45,7 @@ type morphism struct {
 // then yield a pointer to their own member context as the return value.
 //
 // For more examples, look at the morphisms which claim the individual fields.
-type context struct {
+type pathContext struct {
 	// Represents the path to the limiting set of labels that should be considered under traversal.
 	// inMorphism, outMorphism, et al should constrain edges by this set.
 	// A nil in this field represents all labels.
@@ -54,8 +54,8 @@ type context struct {
 	labelSet *Path
 }
 
-func (c context) copy() context {
-	return context{
+func (c pathContext) copy() pathContext {
+	return pathContext{
 		labelSet: c.labelSet,
 	}
 }
@@ -65,7 +65,7 @@ func (c context) copy() context {
 type Path struct {
 	stack       []morphism
 	qs          graph.QuadStore // Optionally. A nil qs is equivalent to a morphism.
-	baseContext context
+	baseContext pathContext
 }
 
 // IsMorphism returns whether this Path is a morphism.
@@ -255,6 +255,20 @@ func (p *Path) FollowReverse(path *Path) *Path {
 	return p
 }
 
+// FollowRecursive will repeatedly follow the given path from the current nodes,
+// returning every node that can be reached this way once. Nodes are visited
+// breadth-first, up to maxDepth steps away; a maxDepth of zero or less means
+// no limit. If depthTags are given, they are set to the number of steps in
+// which each node was reached.
+//
+// For example:
+//  // Will return all the nodes that "A" follows, directly or indirectly
+//  StartPath(qs, "A").FollowRecursive(StartMorphism().Out("follows"), 0)
+func (p *Path) FollowRecursive(path *Path, maxDepth int, depthTags ...string) *Path {
+	p.stack = append(p.stack, followRecursiveMorphism(path, maxDepth, depthTags))
+	return p
+}
+
 // Save will, from the current nodes in the path, retrieve the node
 // one linkage away (given by either a path or a predicate), add the given
 // tag, and propagate that to the result set.
@@ -343,6 +357,58 @@ func (p *Path) Back(tag string) *Path {
 	}
 }
 
+// Unique will remove the duplicate values from the result set.
+//
+// For example:
+//  // Will return every node followed by "A" or "B" only once
+//  StartPath(qs, "A", "B").Out("follows").Unique()
+func (p *Path) Unique() *Path {
+	p.stack = append(p.stack, uniqueMorphism())
+	return p
+}
+
+// Skip will omit a number of values from result set.
+//
+// For example:
+//  // Will return the nodes that "A" follows, except for the first two
+//  StartPath(qs, "A").Out("follows").Skip(2)
+func (p *Path) Skip(v int64) *Path {
+	p.stack = append(p.stack, skipMorphism(v))
+	return p
+}
+
+// Limit will limit a number of values in result set.
+//
+// For example:
+//  // Will return at most two of the nodes that "A" follows
+//  StartPath(qs, "A").Out("follows").Limit(2)
+func (p *Path) Limit(v int64) *Path {
+	p.stack = append(p.stack, limitMorphism(v))
+	return p
+}
+
+// Order will sort the result set by the values of the nodes, in ascending
+// order. Numbers, times and strings are compared by their native types.
+//
+// For example:
+//  // Will return the nodes that "A" follows, in order
+//  StartPath(qs, "A").Out("follows").Order()
+func (p *Path) Order() *Path {
+	return p.OrderBy("", false)
+}
+
+// OrderBy will sort the result set by the values of a tag, or by the values of
+// the nodes if the tag is empty, in ascending or descending order. Results
+// without a value for the tag come last.
+//
+// For example:
+//  // Will return the nodes that "A" follows, oldest first
+//  StartPath(qs, "A").Out("follows").Save("age", "age").OrderBy("age", true)
+func (p *Path) OrderBy(tag string, desc bool) *Path {
+	p.stack = append(p.stack, sortMorphism(tag, desc))
+	return p
+}
+
 // BuildIterator returns an iterator from this given Path.  Note that you must
 // call this with a full path (not a morphism), since a morphism does not have
 // the ability to fetch the underlying quads.  This function will panic if
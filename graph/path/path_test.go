@@ -21,6 +21,8 @@ import (
 	"sort"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/quad/cquads"
@@ -77,7 +79,7 @@ func runTopLevel(path *Path) []quad.Value {
 	var out []quad.Value
 	it := path.BuildIterator()
 	it, _ = it.Optimize()
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		v := path.qs.NameOf(it.Result())
 		out = append(out, v)
 	}
@@ -88,13 +90,13 @@ func runTag(path *Path, tag string) []quad.Value {
 	var out []quad.Value
 	it := path.BuildIterator()
 	it, _ = it.Optimize()
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		tags := make(map[string]graph.Value)
 		it.TagResults(tags)
 		if t, ok := tags[tag]; ok {
 			out = append(out, path.qs.NameOf(t))
 		}
-		for it.NextPath() {
+		for it.NextPath(context.TODO()) {
 			tags := make(map[string]graph.Value)
 			it.TagResults(tags)
 			if t, ok := tags[tag]; ok {
@@ -293,6 +295,50 @@ func testSet(qs graph.QuadStore) []test {
 			path:    StartPath(qs, vGreg).Tag("base").LabelContext(vSmartGraph).Out(vStatus).Tag("status").Back("base"),
 			expect:  []quad.Value{vGreg},
 		},
+		// Recursive tests
+		{
+			message: "use FollowRecursive",
+			path:    StartPath(qs, vCharlie).FollowRecursive(StartMorphism().Out(vFollows), 0),
+			expect:  []quad.Value{vBob, vDani, vFred, vGreg},
+		},
+		{
+			message: "use FollowRecursive with max depth",
+			path:    StartPath(qs, vCharlie).FollowRecursive(StartMorphism().Out(vFollows), 1),
+			expect:  []quad.Value{vBob, vDani},
+		},
+		{
+			message: "keep tags in FollowRecursive",
+			path:    StartPath(qs, vCharlie).Tag("start").FollowRecursive(StartMorphism().Out(vFollows), 0),
+			tag:     "start",
+			expect:  []quad.Value{vCharlie, vCharlie, vCharlie, vCharlie},
+		},
+		{
+			message: "tag depth in FollowRecursive",
+			path:    StartPath(qs, vCharlie).FollowRecursive(StartMorphism().Out(vFollows), 0, "depth"),
+			tag:     "depth",
+			expect:  []quad.Value{quad.Int(1), quad.Int(1), quad.Int(2), quad.Int(2)},
+		},
+		{
+			message: "use Unique",
+			path:    StartPath(qs, vAlice, vCharlie, vDani).Out(vFollows).Unique(),
+			expect:  []quad.Value{vBob, vDani, vGreg},
+		},
+		// Limit and Skip tests
+		{
+			message: "use Limit",
+			path:    StartPath(qs, vBob).Limit(1),
+			expect:  []quad.Value{vBob},
+		},
+		{
+			message: "use Limit greater than the result set",
+			path:    StartPath(qs, vBob, vCharlie).Out(vFollows).Limit(10),
+			expect:  []quad.Value{vBob, vFred, vDani},
+		},
+		{
+			message: "use Skip",
+			path:    StartPath(qs, vBob).Skip(1),
+			expect:  nil,
+		},
 		// Optional tests
 		{
 			message: "save limits top level",
@@ -329,3 +375,147 @@ func TestMorphisms(t *testing.T) {
 		}
 	}
 }
+
+func TestSkipLimit(t *testing.T) {
+	qs := makeTestStore(t)
+	follows := func() *Path {
+		return StartPath(qs, vBob, vCharlie).Out(vFollows)
+	}
+	all := runTopLevel(follows())
+	if len(all) != 3 {
+		t.Fatalf("Unexpected number of results: %v", all)
+	}
+	for skip := 0; skip <= len(all); skip++ {
+		got := runTopLevel(follows().Skip(int64(skip)).Limit(1))
+		if skip == len(all) {
+			if len(got) != 0 {
+				t.Errorf("Expected no results after skipping all, got: %v", got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, all[skip:skip+1]) {
+			t.Errorf("Failed to skip %d and limit 1, got: %v expected: %v", skip, got, all[skip:skip+1])
+		}
+	}
+}
+
+func TestShortestPaths(t *testing.T) {
+	qs := makeTestStore(t)
+	names := func(routes []Route) [][]quad.Value {
+		var out [][]quad.Value
+		for _, r := range routes {
+			var nodes []quad.Value
+			for i, n := range r.Nodes {
+				if i > 0 {
+					nodes = append(nodes, qs.NameOf(r.Predicates[i-1]))
+				}
+				nodes = append(nodes, qs.NameOf(n))
+			}
+			out = append(out, nodes)
+		}
+		return out
+	}
+	var tests = []struct {
+		message  string
+		from, to quad.Value
+		preds    []quad.Value
+		maxDepth int
+		k        int
+		expect   [][]quad.Value
+	}{
+		{
+			message: "find the shortest path",
+			from:    vCharlie, to: vGreg,
+			k: 1,
+			expect: [][]quad.Value{
+				{vCharlie, vFollows, vDani, vFollows, vGreg},
+			},
+		},
+		{
+			message: "find alternative paths",
+			from:    vCharlie, to: vGreg,
+			preds: []quad.Value{vFollows},
+			k:     5,
+			expect: [][]quad.Value{
+				{vCharlie, vFollows, vDani, vFollows, vGreg},
+				{vCharlie, vFollows, vBob, vFollows, vFred, vFollows, vGreg},
+				{vCharlie, vFollows, vDani, vFollows, vBob, vFollows, vFred, vFollows, vGreg},
+			},
+		},
+		{
+			message: "limit the length of paths",
+			from:    vCharlie, to: vGreg,
+			maxDepth: 3, k: 5,
+			expect: [][]quad.Value{
+				{vCharlie, vFollows, vDani, vFollows, vGreg},
+				{vCharlie, vFollows, vBob, vFollows, vFred, vFollows, vGreg},
+			},
+		},
+		{
+			message: "follow links in their direction only",
+			from:    vGreg, to: vCharlie,
+			k: 1,
+		},
+		{
+			message: "follow the given predicates only",
+			from:    vCharlie, to: vGreg,
+			preds: []quad.Value{vStatus},
+			k:     1,
+		},
+	}
+	for _, test := range tests {
+		routes, err := ShortestPaths(context.TODO(), qs, test.from, test.to, test.preds, test.maxDepth, test.k)
+		if err != nil {
+			t.Errorf("Failed to %s: %v", test.message, err)
+			continue
+		}
+		if got := names(routes); !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Failed to %s, got: %v expected: %v", test.message, got, test.expect)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	qs := makeTestStore(t)
+	follows := func() *Path {
+		return StartPath(qs, vAlice, vCharlie, vDani).Out(vFollows).Unique()
+	}
+	for _, test := range []struct {
+		message string
+		path    *Path
+		tag     string
+		expect  []quad.Value
+	}{
+		{
+			message: "use Order",
+			path:    follows().Order(),
+			expect:  []quad.Value{vBob, vDani, vGreg},
+		},
+		{
+			message: "use descending OrderBy",
+			path:    follows().OrderBy("", true),
+			expect:  []quad.Value{vGreg, vDani, vBob},
+		},
+		{
+			message: "use Order with Limit",
+			path:    follows().OrderBy("", true).Limit(1),
+			expect:  []quad.Value{vGreg},
+		},
+		{
+			message: "use OrderBy with a tag",
+			path:    StartPath(qs, vDani, vAlice, vCharlie).Tag("who").Out(vFollows).OrderBy("who", false),
+			tag:     "who",
+			expect:  []quad.Value{vAlice, vCharlie, vCharlie, vDani, vDani},
+		},
+	} {
+		var got []quad.Value
+		if test.tag == "" {
+			got = runTopLevel(test.path)
+		} else {
+			got = runTag(test.path, test.tag)
+		}
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Failed to %s, got: %v expected: %v", test.message, got, test.expect)
+		}
+	}
+}
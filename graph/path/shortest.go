@@ -0,0 +1,334 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package path
+
+// Defines shortest path queries between two nodes.
+//
+// A single shortest path is found with a bidirectional breadth-first search,
+// expanding outbound links from the source and inbound links from the
+// target, one level at a time. Alternative paths are found with Yen's
+// algorithm, which searches for detours from every node of the paths found
+// so far.
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+// Route is a path through the graph. Predicates[i] links Nodes[i] to
+// Nodes[i+1], so there is always one more node than there are predicates.
+type Route struct {
+	Nodes      []graph.Value
+	Predicates []graph.Value
+}
+
+// Len returns the number of links in the route.
+func (r Route) Len() int {
+	return len(r.Predicates)
+}
+
+// ShortestPath returns the shortest route from one node to another, following
+// links in their direction. If preds are given, only links with these
+// predicates are followed. Routes longer than maxDepth links are not
+// considered, unless maxDepth is zero or less. If no route exists, nil is
+// returned.
+func ShortestPath(ctx context.Context, qs graph.QuadStore, from, to quad.Value, preds []quad.Value, maxDepth int) (*Route, error) {
+	routes, err := ShortestPaths(ctx, qs, from, to, preds, maxDepth, 1)
+	if err != nil || len(routes) == 0 {
+		return nil, err
+	}
+	return &routes[0], nil
+}
+
+// ShortestPaths returns up to k shortest routes without loops from one node
+// to another, ordered by their length. The arguments are the same as for
+// ShortestPath.
+func ShortestPaths(ctx context.Context, qs graph.QuadStore, from, to quad.Value, preds []quad.Value, maxDepth, k int) ([]Route, error) {
+	s := &shortest{qs: qs}
+	if len(preds) != 0 {
+		s.preds = make(map[graph.Value]bool)
+		for _, p := range preds {
+			if v := qs.ValueOf(p); v != nil {
+				s.preds[graph.ToKey(v)] = true
+			}
+		}
+		if len(s.preds) == 0 {
+			return nil, nil
+		}
+	}
+	src, dst := qs.ValueOf(from), qs.ValueOf(to)
+	if src == nil || dst == nil || k <= 0 {
+		return nil, nil
+	}
+	first, err := s.search(ctx, src, dst, maxDepth, nil)
+	if err != nil || first == nil {
+		return nil, err
+	}
+	routes := []Route{*first}
+	var candidates []Route
+	for len(routes) < k {
+		prev := routes[len(routes)-1]
+		for i := 0; i < len(prev.Predicates); i++ {
+			root := Route{
+				Nodes:      prev.Nodes[:i+1],
+				Predicates: prev.Predicates[:i],
+			}
+			b := &bans{
+				nodes: make(map[graph.Value]bool),
+				links: make(map[link]bool),
+			}
+			// Do not take the links already taken by the known routes
+			// with the same root, and do not return to the root.
+			for _, r := range routes {
+				if r.Len() > i && root.isPrefixOf(r) {
+					b.links[s.linkOf(r, i)] = true
+				}
+			}
+			for _, n := range root.Nodes[:i] {
+				b.nodes[graph.ToKey(n)] = true
+			}
+			depth := 0
+			if maxDepth > 0 {
+				depth = maxDepth - i
+			}
+			spur, err := s.search(ctx, prev.Nodes[i], dst, depth, b)
+			if err != nil {
+				return routes, err
+			}
+			if spur == nil {
+				continue
+			}
+			r := root.join(*spur)
+			if !containsRoute(routes, r) && !containsRoute(candidates, r) {
+				candidates = append(candidates, r)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		best := 0
+		for i, c := range candidates {
+			if c.Len() < candidates[best].Len() {
+				best = i
+			}
+		}
+		routes = append(routes, candidates[best])
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+	return routes, nil
+}
+
+// link identifies a single link between two nodes, independent of the label
+// of the quad it comes from.
+type link struct {
+	from, pred, to graph.Value
+}
+
+// bans are the nodes and links a search must not use.
+type bans struct {
+	nodes map[graph.Value]bool
+	links map[link]bool
+}
+
+// step records the link by which a node was reached during the search. For
+// the backward search, node is the next node towards the target.
+type step struct {
+	node  graph.Value
+	pred  graph.Value
+	depth int
+}
+
+type shortest struct {
+	qs    graph.QuadStore
+	preds map[graph.Value]bool
+}
+
+func (s *shortest) linkOf(r Route, i int) link {
+	return link{
+		from: graph.ToKey(r.Nodes[i]),
+		pred: graph.ToKey(r.Predicates[i]),
+		to:   graph.ToKey(r.Nodes[i+1]),
+	}
+}
+
+// search runs a bidirectional breadth-first search from src to dst.
+func (s *shortest) search(ctx context.Context, src, dst graph.Value, maxDepth int, b *bans) (*Route, error) {
+	srcKey, dstKey := graph.ToKey(src), graph.ToKey(dst)
+	if srcKey == dstKey {
+		return &Route{Nodes: []graph.Value{src}}, nil
+	}
+	fwd := map[graph.Value]step{srcKey: {node: src}}
+	bwd := map[graph.Value]step{dstKey: {node: dst}}
+	fwdFrontier := []graph.Value{src}
+	bwdFrontier := []graph.Value{dst}
+	// values keeps the original values of the keys seen so far.
+	values := map[graph.Value]graph.Value{srcKey: src, dstKey: dst}
+	fwdDepth, bwdDepth := 0, 0
+	for len(fwdFrontier) != 0 && len(bwdFrontier) != 0 {
+		if maxDepth > 0 && fwdDepth+bwdDepth >= maxDepth {
+			return nil, nil
+		}
+		var (
+			meet []graph.Value
+			err  error
+		)
+		if len(fwdFrontier) <= len(bwdFrontier) {
+			fwdDepth++
+			fwdFrontier, meet, err = s.expand(ctx, fwdFrontier, quad.Subject, quad.Object, fwdDepth, fwd, bwd, values, b)
+		} else {
+			bwdDepth++
+			bwdFrontier, meet, err = s.expand(ctx, bwdFrontier, quad.Object, quad.Subject, bwdDepth, bwd, fwd, values, b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(meet) == 0 {
+			continue
+		}
+		best := meet[0]
+		for _, m := range meet[1:] {
+			if fwd[m].depth+bwd[m].depth < fwd[best].depth+bwd[best].depth {
+				best = m
+			}
+		}
+		return s.route(best, fwd, bwd, values), nil
+	}
+	return nil, nil
+}
+
+// expand follows the links from one level of the search, in the direction
+// from -> to. It returns the next level, and the nodes that were already
+// reached by the search from the other side.
+func (s *shortest) expand(ctx context.Context, frontier []graph.Value, from, to quad.Direction, depth int,
+	seen, other map[graph.Value]step, values map[graph.Value]graph.Value, b *bans) ([]graph.Value, []graph.Value, error) {
+	var next, meet []graph.Value
+	for _, n := range frontier {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		nKey := graph.ToKey(n)
+		it := s.qs.QuadIterator(from, n)
+		for graph.Next(ctx, it) {
+			q := it.Result()
+			p := s.qs.QuadDirection(q, quad.Predicate)
+			pKey := graph.ToKey(p)
+			if s.preds != nil && !s.preds[pKey] {
+				continue
+			}
+			v := s.qs.QuadDirection(q, to)
+			vKey := graph.ToKey(v)
+			if _, ok := seen[vKey]; ok {
+				continue
+			}
+			if b != nil {
+				if b.nodes[vKey] {
+					continue
+				}
+				l := link{from: nKey, pred: pKey, to: vKey}
+				if from == quad.Object {
+					l = link{from: vKey, pred: pKey, to: nKey}
+				}
+				if b.links[l] {
+					continue
+				}
+			}
+			seen[vKey] = step{node: n, pred: p, depth: depth}
+			values[vKey] = v
+			next = append(next, v)
+			if _, ok := other[vKey]; ok {
+				meet = append(meet, vKey)
+			}
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return next, meet, nil
+}
+
+// route builds the route through the node where both searches met.
+func (s *shortest) route(meet graph.Value, fwd, bwd map[graph.Value]step, values map[graph.Value]graph.Value) *Route {
+	var r Route
+	// Walk back to the source.
+	for k := meet; ; {
+		st := fwd[k]
+		r.Nodes = append(r.Nodes, values[k])
+		if st.pred == nil {
+			break
+		}
+		r.Predicates = append(r.Predicates, st.pred)
+		k = graph.ToKey(st.node)
+	}
+	for i, j := 0, len(r.Nodes)-1; i < j; i, j = i+1, j-1 {
+		r.Nodes[i], r.Nodes[j] = r.Nodes[j], r.Nodes[i]
+	}
+	for i, j := 0, len(r.Predicates)-1; i < j; i, j = i+1, j-1 {
+		r.Predicates[i], r.Predicates[j] = r.Predicates[j], r.Predicates[i]
+	}
+	// And forward to the target.
+	for k := meet; ; {
+		st := bwd[k]
+		if st.pred == nil {
+			break
+		}
+		r.Predicates = append(r.Predicates, st.pred)
+		r.Nodes = append(r.Nodes, st.node)
+		k = graph.ToKey(st.node)
+	}
+	return &r
+}
+
+func (r Route) isPrefixOf(o Route) bool {
+	if len(r.Nodes) > len(o.Nodes) || len(r.Predicates) > len(o.Predicates) {
+		return false
+	}
+	for i, n := range r.Nodes {
+		if graph.ToKey(n) != graph.ToKey(o.Nodes[i]) {
+			return false
+		}
+	}
+	for i, p := range r.Predicates {
+		if graph.ToKey(p) != graph.ToKey(o.Predicates[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// join appends a route starting at the last node of r.
+func (r Route) join(o Route) Route {
+	out := Route{
+		Nodes:      make([]graph.Value, 0, len(r.Nodes)+len(o.Nodes)-1),
+		Predicates: make([]graph.Value, 0, len(r.Predicates)+len(o.Predicates)),
+	}
+	out.Nodes = append(out.Nodes, r.Nodes...)
+	out.Nodes = append(out.Nodes, o.Nodes[1:]...)
+	out.Predicates = append(out.Predicates, r.Predicates...)
+	out.Predicates = append(out.Predicates, o.Predicates...)
+	return out
+}
+
+func containsRoute(routes []Route, r Route) bool {
+	for _, o := range routes {
+		if o.Len() == r.Len() && r.isPrefixOf(o) {
+			return true
+		}
+	}
+	return false
+}
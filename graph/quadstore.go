@@ -26,6 +26,8 @@ import (
 	"fmt"
 	"io"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/quad"
 )
 
@@ -70,6 +72,26 @@ func ToKey(v Value) Value {
 	return v
 }
 
+// PreFetchedValue is an optional interface for Value, indicating that the
+// value was not loaded from a QuadStore, but was computed in place (e.g. by
+// an iterator). QuadStores return the wrapped value from NameOf.
+type PreFetchedValue interface {
+	Value
+	NameOf() quad.Value
+}
+
+// PreFetched wraps a quad.Value so it can be passed around as a Value.
+func PreFetched(v quad.Value) PreFetchedValue {
+	return fetchedValue{Val: v}
+}
+
+type fetchedValue struct {
+	Val quad.Value
+}
+
+func (v fetchedValue) IsNode() bool       { return true }
+func (v fetchedValue) NameOf() quad.Value { return v.Val }
+
 type QuadStore interface {
 	// The only way in is through building a transaction, which
 	// is done by a replication strategy.
@@ -130,6 +152,23 @@ type QuadStore interface {
 	Type() string
 }
 
+// MetricsReporter is an optional interface for QuadStores which report
+// metrics of their backend, such as cache statistics. The metrics are keyed
+// by their name, optionally followed by labels in the Prometheus format, as
+// in `cache_hits{cache="ids"}`.
+type MetricsReporter interface {
+	Metrics() map[string]float64
+}
+
+// DeltaLog is an optional interface for QuadStores which keep a log of the
+// deltas they applied, so that the changes to the store can be followed.
+type DeltaLog interface {
+	// DeltasSince returns, in order, at most limit deltas logged after the
+	// given horizon, or from the start of the log if it is nil. A limit of
+	// zero or less returns all of them.
+	DeltasSince(horizon *PrimaryKey, limit int) ([]Delta, error)
+}
+
 type Options map[string]interface{}
 
 func (d Options) IntKey(key string) (int, bool, error) {
@@ -189,6 +228,9 @@ type QuadStoreRegistration struct {
 	UpgradeFunc       UpgradeStoreFunc
 	InitFunc          InitStoreFunc
 	IsPersistent      bool
+	// IsPersistentFunc, if set, tells whether the store keeps its data at the
+	// path, for the stores which are only persistent for some paths.
+	IsPersistentFunc func(string, Options) bool
 }
 
 var storeRegistry = make(map[string]QuadStoreRegistration)
@@ -241,6 +283,16 @@ func IsPersistent(name string) bool {
 	return storeRegistry[name].IsPersistent
 }
 
+// IsPersistentAt returns whether the quad store keeps its data at the path,
+// which for most stores does not depend on the path.
+func IsPersistentAt(name, dbpath string, opts Options) bool {
+	r := storeRegistry[name]
+	if r.IsPersistentFunc != nil {
+		return r.IsPersistentFunc(dbpath, opts)
+	}
+	return r.IsPersistent
+}
+
 func QuadStores() []string {
 	t := make([]string, 0, len(storeRegistry))
 	for n := range storeRegistry {
@@ -271,7 +323,7 @@ type iteratorReader struct {
 }
 
 func (r *iteratorReader) ReadQuad() (quad.Quad, error) {
-	if r.it.Next() {
+	if r.it.Next(context.TODO()) {
 		return r.qs.Quad(r.it.Result()), nil
 	}
 	if err := r.it.Err(); err != nil {
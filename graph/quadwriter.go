@@ -53,6 +53,27 @@ type IgnoreOpts struct {
 	IgnoreDup, IgnoreMissing bool
 }
 
+// IgnoreOptsFrom returns the options of a writer, which ignore the duplicated
+// and missing quads if the flags or the ignore_duplicate and ignore_missing
+// options are set.
+func IgnoreOptsFrom(opts Options) (IgnoreOpts, error) {
+	var (
+		o   IgnoreOpts
+		err error
+	)
+	if *IgnoreMissing {
+		o.IgnoreMissing = true
+	} else if o.IgnoreMissing, _, err = opts.BoolKey("ignore_missing"); err != nil {
+		return o, err
+	}
+	if *IgnoreDup {
+		o.IgnoreDup = true
+	} else if o.IgnoreDup, _, err = opts.BoolKey("ignore_duplicate"); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
 func (h *Handle) Close() {
 	h.QuadStore.Close()
 	h.QuadWriter.Close()
@@ -0,0 +1,278 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+// pageSize is the number of members an iterator reads from its sorted set at
+// a time.
+var pageSize = 100
+
+// Iterator iterates over the members of a sorted set, which are either the
+// quads with a node in a direction, or all the nodes or quads of the store.
+// The members are read a page at a time with ZRANGE, from the offset the last
+// page ended at, so the members written during the iteration may be skipped
+// or returned twice.
+type Iterator struct {
+	uid    uint64
+	tags   graph.Tagger
+	qs     *QuadStore
+	dir    quad.Direction
+	hash   NodeHash
+	key    string
+	isAll  bool
+	nodes  bool
+	size   int64
+	offset int
+	buf    []string
+	done   bool
+	result graph.Value
+	err    error
+}
+
+func NewIterator(qs *QuadStore, d quad.Direction, val graph.Value) *Iterator {
+	h := val.(NodeHash)
+	return &Iterator{
+		uid:  iterator.NextUID(),
+		qs:   qs,
+		dir:  d,
+		hash: h,
+		key:  qs.indexKey(d, h),
+		size: -1,
+	}
+}
+
+// NewAllIterator returns an iterator over all the nodes or all the quads of
+// the store, with the key of the sorted set which lists them.
+func NewAllIterator(qs *QuadStore, key string) *Iterator {
+	return &Iterator{
+		uid:   iterator.NextUID(),
+		qs:    qs,
+		dir:   quad.Any,
+		key:   qs.key(key),
+		isAll: true,
+		nodes: key == nodesKey,
+		size:  -1,
+	}
+}
+
+func (it *Iterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *Iterator) Reset() {
+	it.offset = 0
+	it.buf = nil
+	it.done = false
+	it.result = nil
+	it.err = nil
+}
+
+func (it *Iterator) Close() error {
+	it.buf = nil
+	it.done = true
+	return nil
+}
+
+func (it *Iterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Iterator) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+}
+
+func (it *Iterator) Clone() graph.Iterator {
+	m := &Iterator{
+		uid:   iterator.NextUID(),
+		qs:    it.qs,
+		dir:   it.dir,
+		hash:  it.hash,
+		key:   it.key,
+		isAll: it.isAll,
+		nodes: it.nodes,
+		size:  -1,
+	}
+	m.tags.CopyFrom(it)
+	return m
+}
+
+func (it *Iterator) token(member string) graph.Value {
+	if it.nodes {
+		return NodeHash(member)
+	}
+	return QuadHash(member)
+}
+
+// nextPage reads the members of the next page into the buffer.
+func (it *Iterator) nextPage() error {
+	c := it.qs.pool.Get()
+	defer c.Close()
+	buf, err := redis.Strings(c.Do("ZRANGE", it.key, it.offset, it.offset+pageSize-1))
+	if err != nil {
+		return err
+	}
+	it.buf = buf
+	it.offset += len(buf)
+	if len(buf) < pageSize {
+		it.done = true
+	}
+	return nil
+}
+
+func (it *Iterator) Next(ctx context.Context) bool {
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	if len(it.buf) == 0 {
+		if it.done {
+			it.result = nil
+			return false
+		}
+		if err := it.nextPage(); err != nil {
+			glog.Errorln("Error Nexting Iterator: ", err)
+			it.err = err
+			it.done = true
+			it.result = nil
+			return false
+		}
+		if len(it.buf) == 0 {
+			it.result = nil
+			return false
+		}
+	}
+	it.result = it.token(it.buf[0])
+	it.buf = it.buf[1:]
+	return true
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Result() graph.Value {
+	return it.result
+}
+
+func (it *Iterator) NextPath(ctx context.Context) bool {
+	return false
+}
+
+// SubIterators returns no subiterators for a Redis iterator.
+func (it *Iterator) SubIterators() []graph.Iterator {
+	return nil
+}
+
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
+	graph.ContainsLogIn(it, v)
+	var member string
+	switch v := v.(type) {
+	case NodeHash:
+		if !it.nodes {
+			return graph.ContainsLogOut(it, v, false)
+		}
+		member = string(v)
+	case QuadHash:
+		if it.nodes || (!it.isAll && v.Get(it.dir) != it.hash) {
+			return graph.ContainsLogOut(it, v, false)
+		}
+		member = string(v)
+	default:
+		return graph.ContainsLogOut(it, v, false)
+	}
+	c := it.qs.pool.Get()
+	defer c.Close()
+	r, err := c.Do("ZSCORE", it.key, member)
+	if err != nil {
+		it.err = err
+		return graph.ContainsLogOut(it, v, false)
+	} else if r == nil {
+		return graph.ContainsLogOut(it, v, false)
+	}
+	it.result = v
+	return graph.ContainsLogOut(it, v, true)
+}
+
+func (it *Iterator) Size() (int64, bool) {
+	if it.size == -1 {
+		c := it.qs.pool.Get()
+		defer c.Close()
+		n, err := redis.Int64(c.Do("ZCARD", it.key))
+		if err != nil {
+			it.err = err
+			return 0, false
+		}
+		it.size = n
+	}
+	return it.size, true
+}
+
+var redisType graph.Type
+
+func init() {
+	redisType = graph.RegisterIterator("redis")
+}
+
+func Type() graph.Type { return redisType }
+
+func (it *Iterator) Type() graph.Type {
+	if it.isAll {
+		return graph.All
+	}
+	return redisType
+}
+
+func (it *Iterator) Sorted() bool                     { return true }
+func (it *Iterator) Optimize() (graph.Iterator, bool) { return it, false }
+
+func (it *Iterator) Describe() graph.Description {
+	size, _ := it.Size()
+	return graph.Description{
+		UID:       it.UID(),
+		Name:      fmt.Sprintf("%x", string(it.hash)),
+		Type:      it.Type(),
+		Tags:      it.tags.Tags(),
+		Size:      size,
+		Direction: it.dir,
+	}
+}
+
+func (it *Iterator) Stats() graph.IteratorStats {
+	size, _ := it.Size()
+	return graph.IteratorStats{
+		ContainsCost: 1,
+		NextCost:     2,
+		Size:         size,
+	}
+}
+
+var _ graph.Nexter = &Iterator{}
@@ -0,0 +1,546 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis is a quad store backed by a Redis server.
+//
+// The values of the nodes are kept in a hash per node, along with the number
+// of quads they are in. The quads are indexed in a sorted set per direction
+// and node, and all of the nodes and quads are listed in sorted sets of their
+// own. All the members of the sorted sets have the same score, so that they
+// are sorted by key. The deltas are logged in a sorted set, scored by their
+// ID.
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/proto"
+	"github.com/google/cayley/internal/lru"
+	"github.com/google/cayley/quad"
+)
+
+func init() {
+	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
+		NewFunc:           newQuadStore,
+		NewForRequestFunc: nil,
+		UpgradeFunc:       nil,
+		InitFunc:          createNewRedis,
+		IsPersistent:      true,
+	})
+}
+
+const (
+	QuadStoreType    = "redis"
+	DefaultKeyPrefix = "cayley:"
+
+	latestDataVersion = 1
+
+	// maxRetries is the number of times the deltas are applied again when
+	// another client writes to the store at the same time.
+	maxRetries = 10
+)
+
+const (
+	versionKey = "version"
+	sizeKey    = "size"
+	horizonKey = "horizon"
+	logKey     = "log"
+	nodesKey   = "nodes"
+	quadsKey   = "quads"
+	nodePrefix = "node:"
+	// The index of the quads with a node in a direction is kept at
+	// "index:" followed by the prefix of the direction, ":" and the node.
+	indexPrefix = "index:"
+)
+
+var errConflict = errors.New("redis: quads were written by another client during the write")
+
+// NodeHash is the hash of a node value.
+type NodeHash string
+
+func (NodeHash) IsNode() bool { return true }
+
+// QuadHash is the hashes of the subject, predicate, object and label of a
+// quad, with no label hash for the quads without one.
+type QuadHash string
+
+func (QuadHash) IsNode() bool { return false }
+
+func (h QuadHash) Get(d quad.Direction) NodeHash {
+	var offset int
+	switch d {
+	case quad.Subject:
+		offset = 0
+	case quad.Predicate:
+		offset = quad.HashSize
+	case quad.Object:
+		offset = quad.HashSize * 2
+	case quad.Label:
+		offset = quad.HashSize * 3
+		if len(h) == offset { // no label
+			return ""
+		}
+	}
+	return NodeHash(h[offset : offset+quad.HashSize])
+}
+
+type QuadStore struct {
+	pool   *redis.Pool
+	prefix string
+	ids    *lru.Cache
+}
+
+func hashOf(v quad.Value) NodeHash {
+	if v == nil {
+		return ""
+	}
+	return NodeHash(quad.HashOf(v))
+}
+
+func quadHashOf(q quad.Quad) QuadHash {
+	return QuadHash(hashOf(q.Subject) + hashOf(q.Predicate) + hashOf(q.Object) + hashOf(q.Label))
+}
+
+func newPool(addr string, options graph.Options) (*redis.Pool, error) {
+	var opts []redis.DialOption
+	db, ok, err := options.IntKey("database")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		opts = append(opts, redis.DialDatabase(db))
+	}
+	pass, ok, err := options.StringKey("password")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		opts = append(opts, redis.DialPassword(pass))
+	}
+	return &redis.Pool{
+		MaxIdle:     8,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, opts...)
+		},
+	}, nil
+}
+
+func keyPrefix(options graph.Options) (string, error) {
+	prefix, ok, err := options.StringKey("key_prefix")
+	if err != nil {
+		return "", err
+	} else if !ok {
+		prefix = DefaultKeyPrefix
+	}
+	return prefix, nil
+}
+
+func createNewRedis(addr string, options graph.Options) error {
+	prefix, err := keyPrefix(options)
+	if err != nil {
+		return err
+	}
+	pool, err := newPool(addr, options)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	c := pool.Get()
+	defer c.Close()
+	ok, err := redis.Bool(c.Do("SETNX", prefix+versionKey, latestDataVersion))
+	if err != nil {
+		glog.Errorf("Error: could not create database: %v", err)
+		return err
+	} else if !ok {
+		return graph.ErrDatabaseExists
+	}
+	return nil
+}
+
+func newQuadStore(addr string, options graph.Options) (graph.QuadStore, error) {
+	prefix, err := keyPrefix(options)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newPool(addr, options)
+	if err != nil {
+		return nil, err
+	}
+	c := pool.Get()
+	vers, err := redis.Int64(c.Do("GET", prefix+versionKey))
+	c.Close()
+	if err == redis.ErrNil {
+		pool.Close()
+		return nil, errors.New("redis: quadstore has not been initialised")
+	} else if err != nil {
+		glog.Errorln("Error, could not read version info! ", err)
+		pool.Close()
+		return nil, err
+	} else if vers != latestDataVersion {
+		pool.Close()
+		return nil, fmt.Errorf("redis: data version is out of date (%d vs %d)", vers, latestDataVersion)
+	}
+	return &QuadStore{
+		pool:   pool,
+		prefix: prefix,
+		ids:    lru.New(1 << 16),
+	}, nil
+}
+
+func (qs *QuadStore) key(k string) string { return qs.prefix + k }
+
+func (qs *QuadStore) nodeKey(h NodeHash) string { return qs.prefix + nodePrefix + string(h) }
+
+func (qs *QuadStore) indexKey(d quad.Direction, h NodeHash) string {
+	return qs.prefix + indexPrefix + string(d.Prefix()) + ":" + string(h)
+}
+
+func (qs *QuadStore) getInt64(key string) int64 {
+	c := qs.pool.Get()
+	defer c.Close()
+	n, err := redis.Int64(c.Do("GET", qs.key(key)))
+	if err != nil && err != redis.ErrNil {
+		glog.Errorf("Error: could not read %s: %v", key, err)
+	}
+	return n
+}
+
+func (qs *QuadStore) Size() int64 {
+	return qs.getInt64(sizeKey)
+}
+
+func (qs *QuadStore) Horizon() graph.PrimaryKey {
+	return graph.NewSequentialKey(qs.getInt64(horizonKey))
+}
+
+func deltaToProto(delta *graph.Delta) proto.LogDelta {
+	var newd proto.LogDelta
+	newd.ID = uint64(delta.ID.Int())
+	newd.Action = int32(delta.Action)
+	newd.Timestamp = delta.Timestamp.UnixNano()
+	newd.Quad = proto.MakeQuad(delta.Quad)
+	return newd
+}
+
+func protoToDelta(p *proto.LogDelta) graph.Delta {
+	return graph.Delta{
+		ID:        graph.NewSequentialKey(int64(p.ID)),
+		Quad:      p.Quad.ToNative(),
+		Action:    graph.Procedure(p.Action),
+		Timestamp: time.Unix(0, p.Timestamp),
+	}
+}
+
+// DeltasSince returns the deltas logged after the horizon, in the order of
+// their scores.
+func (qs *QuadStore) DeltasSince(horizon *graph.PrimaryKey, limit int) ([]graph.Delta, error) {
+	min := "-inf"
+	if horizon != nil {
+		min = "(" + strconv.FormatInt(horizon.Int(), 10)
+	}
+	args := []interface{}{qs.key(logKey), min, "+inf"}
+	if limit > 0 {
+		args = append(args, "LIMIT", 0, limit)
+	}
+	c := qs.pool.Get()
+	defer c.Close()
+	data, err := redis.ByteSlices(c.Do("ZRANGEBYSCORE", args...))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]graph.Delta, 0, len(data))
+	for _, b := range data {
+		var p proto.LogDelta
+		if err := p.Unmarshal(b); err != nil {
+			return nil, err
+		}
+		out = append(out, protoToDelta(&p))
+	}
+	return out, nil
+}
+
+// ApplyDeltas applies the deltas in a MULTI/EXEC transaction, which is made
+// again when another client wrote quads since the store was read.
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	c := qs.pool.Get()
+	defer c.Close()
+	for i := 0; i < maxRetries; i++ {
+		err := qs.applyDeltas(c, deltas, ignoreOpts)
+		if err != errConflict {
+			return err
+		}
+		glog.V(2).Infof("retrying the deltas after a conflict")
+	}
+	return errConflict
+}
+
+func (qs *QuadStore) applyDeltas(c redis.Conn, deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	if _, err := c.Do("WATCH", qs.key(quadsKey), qs.key(horizonKey)); err != nil {
+		return err
+	}
+
+	// Check which of the quads are in the store.
+	var keys []QuadHash
+	for i := range deltas {
+		d := &deltas[i]
+		if d.Action != graph.Add && d.Action != graph.Delete {
+			return errors.New("redis: invalid action")
+		}
+		keys = append(keys, quadHashOf(d.Quad))
+	}
+	for _, k := range keys {
+		c.Send("ZSCORE", qs.key(quadsKey), string(k))
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	stored := make(map[QuadHash]bool)
+	for _, k := range keys {
+		v, err := c.Receive()
+		if err != nil {
+			glog.Error("could not access DB to prepare index: ", err)
+			return err
+		}
+		stored[k] = v != nil
+	}
+
+	var (
+		applied    []int
+		live       = make(map[QuadHash]bool)
+		quads      = make(map[QuadHash]quad.Quad)
+		resizeMap  = make(map[quad.Value]int64)
+		sizeChange int64
+		horizon    int64
+	)
+	for k, v := range stored {
+		live[k] = v
+	}
+	for i := range deltas {
+		d := &deltas[i]
+		k := keys[i]
+		isAdd := d.Action == graph.Add
+		if isAdd && live[k] {
+			if ignoreOpts.IgnoreDup {
+				continue
+			}
+			return graph.ErrQuadExists
+		}
+		if !isAdd && !live[k] {
+			if ignoreOpts.IgnoreMissing {
+				continue
+			}
+			return graph.ErrQuadNotExist
+		}
+		live[k] = isAdd
+		quads[k] = d.Quad
+		applied = append(applied, i)
+
+		delta := int64(1)
+		if !isAdd {
+			delta = -1
+		}
+		resizeMap[d.Quad.Subject] += delta
+		resizeMap[d.Quad.Predicate] += delta
+		resizeMap[d.Quad.Object] += delta
+		if d.Quad.Label != nil {
+			resizeMap[d.Quad.Label] += delta
+		}
+		sizeChange += delta
+		horizon = d.ID.Int()
+	}
+	if len(applied) == 0 {
+		_, err := c.Do("UNWATCH")
+		return err
+	}
+
+	// Read the sizes of the nodes, to know which ones are left with no quads.
+	var nodes []quad.Value
+	for v, n := range resizeMap {
+		if n != 0 {
+			nodes = append(nodes, v)
+			c.Send("HGET", qs.nodeKey(hashOf(v)), "size")
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	sizes := make([]int64, len(nodes))
+	for i := range nodes {
+		n, err := redis.Int64(c.Receive())
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		sizes[i] = n + resizeMap[nodes[i]]
+	}
+
+	c.Send("MULTI")
+	for _, i := range applied {
+		p := deltaToProto(&deltas[i])
+		data, err := p.Marshal()
+		if err != nil {
+			c.Do("DISCARD")
+			return err
+		}
+		c.Send("ZADD", qs.key(logKey), p.ID, data)
+	}
+	for k, q := range quads {
+		if live[k] == stored[k] {
+			continue
+		}
+		cmd := "ZREM"
+		if live[k] {
+			cmd = "ZADD"
+		}
+		qs.sendQuadIndex(c, cmd, k, q)
+	}
+	for i, v := range nodes {
+		h := hashOf(v)
+		if sizes[i] <= 0 {
+			c.Send("DEL", qs.nodeKey(h))
+			c.Send("ZREM", qs.key(nodesKey), string(h))
+			continue
+		}
+		pv := proto.MakeValue(v)
+		data, err := pv.Marshal()
+		if err != nil {
+			c.Do("DISCARD")
+			return err
+		}
+		c.Send("HMSET", qs.nodeKey(h), "value", data, "size", sizes[i])
+		c.Send("ZADD", qs.key(nodesKey), 0, string(h))
+	}
+	c.Send("INCRBY", qs.key(sizeKey), sizeChange)
+	c.Send("SET", qs.key(horizonKey), horizon)
+	r, err := c.Do("EXEC")
+	if err != nil {
+		glog.Error("could not write to DB for quadset: ", err)
+		return err
+	} else if r == nil {
+		return errConflict
+	}
+	return nil
+}
+
+// sendQuadIndex sends the command to add or remove the quad to or from the
+// sorted sets it is listed in.
+func (qs *QuadStore) sendQuadIndex(c redis.Conn, cmd string, k QuadHash, q quad.Quad) {
+	args := func(key string) []interface{} {
+		if cmd == "ZADD" {
+			return []interface{}{key, 0, string(k)}
+		}
+		return []interface{}{key, string(k)}
+	}
+	c.Send(cmd, args(qs.key(quadsKey))...)
+	for _, d := range []quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label} {
+		if h := k.Get(d); h != "" {
+			c.Send(cmd, args(qs.indexKey(d, h))...)
+		}
+	}
+}
+
+func (qs *QuadStore) Quad(val graph.Value) quad.Quad {
+	h := val.(QuadHash)
+	return quad.Quad{
+		Subject:   qs.NameOf(h.Get(quad.Subject)),
+		Predicate: qs.NameOf(h.Get(quad.Predicate)),
+		Object:    qs.NameOf(h.Get(quad.Object)),
+		Label:     qs.NameOf(h.Get(quad.Label)),
+	}
+}
+
+func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
+	return hashOf(s)
+}
+
+func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
+	if v, ok := v.(graph.PreFetchedValue); ok {
+		return v.NameOf()
+	}
+	hash := v.(NodeHash)
+	if hash == "" {
+		return nil
+	}
+	if val, ok := qs.ids.Get(string(hash)); ok {
+		return val.(quad.Value)
+	}
+	c := qs.pool.Get()
+	defer c.Close()
+	data, err := redis.Bytes(c.Do("HGET", qs.nodeKey(hash), "value"))
+	if err != nil {
+		if err != redis.ErrNil {
+			glog.Errorf("Error: could not retrieve node %x: %v", string(hash), err)
+		}
+		return nil
+	}
+	var p proto.Value
+	if err := p.Unmarshal(data); err != nil {
+		glog.Errorf("Error: could not decode node %x: %v", string(hash), err)
+		return nil
+	}
+	qv := p.ToNative()
+	qs.ids.Put(string(hash), qv)
+	return qv
+}
+
+// SizeOf returns the number of quads the node is in.
+func (qs *QuadStore) SizeOf(v graph.Value) int64 {
+	hash := v.(NodeHash)
+	if hash == "" {
+		return 0
+	}
+	c := qs.pool.Get()
+	defer c.Close()
+	n, err := redis.Int64(c.Do("HGET", qs.nodeKey(hash), "size"))
+	if err != nil && err != redis.ErrNil {
+		glog.Errorf("Error: could not read the size of node %x: %v", string(hash), err)
+	}
+	return n
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Value) graph.Iterator {
+	return NewIterator(qs, d, val)
+}
+
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	return NewAllIterator(qs, nodesKey)
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return NewAllIterator(qs, quadsKey)
+}
+
+func (qs *QuadStore) QuadDirection(val graph.Value, d quad.Direction) graph.Value {
+	return val.(QuadHash).Get(d)
+}
+
+func (qs *QuadStore) FixedIterator() graph.FixedIterator {
+	return iterator.NewFixed(iterator.Identity)
+}
+
+func (qs *QuadStore) Close() {
+	if err := qs.pool.Close(); err != nil {
+		glog.Errorf("could not close redis pool: %v", err)
+	}
+}
+
+func (qs *QuadStore) Type() string {
+	return QuadStoreType
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/graphtest"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+var redisAddr = flag.String("redis", "", "address of a Redis server to test against, instead of an in-process one")
+
+var _ graphtest.ValueSizer = (*QuadStore)(nil)
+
+var dbs int64
+
+// redisServer returns the address of a Redis server, and options with a key
+// prefix of their own, so that the stores of the tests do not share keys.
+func redisServer(t testing.TB) (string, graph.Options, func()) {
+	opts := graph.Options{
+		"key_prefix": fmt.Sprintf("cayley_test:%d:%d:", time.Now().UnixNano(), atomic.AddInt64(&dbs, 1)),
+	}
+	if *redisAddr != "" {
+		return *redisAddr, opts, func() {}
+	}
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Addr(), opts, s.Close
+}
+
+func makeRedis(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	addr, opts, closer := redisServer(t)
+	if err := createNewRedis(addr, opts); err != nil {
+		closer()
+		t.Fatal("Failed to create Redis database.", err)
+	}
+	qs, err := newQuadStore(addr, opts)
+	if err != nil {
+		closer()
+		t.Fatal("Failed to create Redis QuadStore.", err)
+	}
+	return qs, nil, func() {
+		qs.Close()
+		closer()
+	}
+}
+
+func TestCreateDatabase(t *testing.T) {
+	addr, opts, closer := redisServer(t)
+	defer closer()
+
+	if _, err := newQuadStore(addr, opts); err == nil {
+		t.Errorf("Created a QuadStore without init")
+	}
+	if err := createNewRedis(addr, opts); err != nil {
+		t.Fatal("Failed to create Redis database.", err)
+	}
+	if err := createNewRedis(addr, opts); err != graph.ErrDatabaseExists {
+		t.Errorf("Unexpected error on second init, got:%v expect:%v", err, graph.ErrDatabaseExists)
+	}
+
+	qs, err := newQuadStore(addr, opts)
+	if qs == nil || err != nil {
+		t.Fatal("Failed to create Redis QuadStore.", err)
+	}
+	if s := qs.Size(); s != 0 {
+		t.Errorf("Unexpected size, got:%d expected:0", s)
+	}
+	qs.Close()
+}
+
+func TestRedisAll(t *testing.T) {
+	graphtest.TestAll(t, makeRedis, nil)
+}
+
+func TestIteratorPages(t *testing.T) {
+	qs, opts, closer := makeRedis(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	quads := graphtest.IteratedQuads(t, qs, qs.QuadsAllIterator())
+	names := graphtest.IteratedRawStrings(t, qs, qs.NodesAllIterator())
+	b := qs.ValueOf(quad.Raw("B"))
+	quadsOfB := graphtest.IteratedQuads(t, qs, qs.QuadIterator(quad.Subject, b))
+
+	defer func(n int) { pageSize = n }(pageSize)
+	pageSize = 2
+	if got := graphtest.IteratedQuads(t, qs, qs.QuadsAllIterator()); !reflect.DeepEqual(got, quads) {
+		t.Errorf("Unexpected quads with small pages, got:%v expect:%v", got, quads)
+	}
+	if got := graphtest.IteratedRawStrings(t, qs, qs.NodesAllIterator()); !reflect.DeepEqual(got, names) {
+		t.Errorf("Unexpected nodes with small pages, got:%v expect:%v", got, names)
+	}
+	if got := graphtest.IteratedQuads(t, qs, qs.QuadIterator(quad.Subject, b)); !reflect.DeepEqual(got, quadsOfB) {
+		t.Errorf("Unexpected quads of B with small pages, got:%v expect:%v", got, quadsOfB)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	qs, opts, closer := makeRedis(t)
+	defer closer()
+
+	graphtest.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+
+	// With an linksto-fixed pair
+	fixed := qs.FixedIterator()
+	fixed.Add(qs.ValueOf(quad.Raw("F")))
+	fixed.Tagger().Add("internal")
+	lto := iterator.NewLinksTo(qs, fixed, quad.Object)
+
+	oldIt := lto.Clone()
+	newIt, ok := lto.Optimize()
+	if !ok {
+		t.Errorf("Failed to optimize iterator")
+	}
+	if newIt.Type() != Type() {
+		t.Errorf("Optimized iterator type does not match original, got:%v expect:%v", newIt.Type(), Type())
+	}
+
+	newQuads := graphtest.IteratedQuads(t, qs, newIt)
+	oldQuads := graphtest.IteratedQuads(t, qs, oldIt)
+	if !reflect.DeepEqual(newQuads, oldQuads) {
+		t.Errorf("Optimized iteration does not match original")
+	}
+
+	graph.Next(context.TODO(), oldIt)
+	oldResults := make(map[string]graph.Value)
+	oldIt.TagResults(oldResults)
+	graph.Next(context.TODO(), newIt)
+	newResults := make(map[string]graph.Value)
+	newIt.TagResults(newResults)
+	if !reflect.DeepEqual(newResults, oldResults) {
+		t.Errorf("Discordant tag results, new:%v old:%v", newResults, oldResults)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"database/sql"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
@@ -123,8 +124,12 @@ func (it *AllIterator) SubIterators() []graph.Iterator {
 	return nil
 }
 
-func (it *AllIterator) Next() bool {
+func (it *AllIterator) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
 	if it.cursor == nil {
 		it.makeCursor()
 		if it.cursor == nil {
@@ -163,14 +168,16 @@ func (it *AllIterator) Next() bool {
 	return graph.NextLogOut(it, it.result, true)
 }
 
-func (it *AllIterator) Contains(v graph.Value) bool {
+func (it *AllIterator) Contains(ctx context.Context, v graph.Value) bool {
 	graph.ContainsLogIn(it, v)
 	it.result = v
 	return graph.ContainsLogOut(it, v, true)
 }
 
+// Size returns the number of quads, which is only exact when iterating over
+// them, and not over the nodes.
 func (it *AllIterator) Size() (int64, bool) {
-	return it.qs.Size(), true
+	return it.qs.Size(), it.table == "quads"
 }
 
 func (it *AllIterator) Result() graph.Value {
@@ -180,7 +187,7 @@ func (it *AllIterator) Result() graph.Value {
 	return it.result
 }
 
-func (it *AllIterator) NextPath() bool {
+func (it *AllIterator) NextPath(ctx context.Context) bool {
 	return false
 }
 
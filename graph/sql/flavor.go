@@ -0,0 +1,97 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cayley/graph"
+)
+
+// defaultFlavor is the flavor of the stores which do not set one.
+const defaultFlavor = "postgres"
+
+// flavor holds what differs between the SQL databases: the driver, the
+// schema, the errors and the few statements which are not standard SQL.
+// Queries are written with ? placeholders, which are converted by bindVars.
+type flavor interface {
+	// driver returns the name of the database/sql driver.
+	driver() string
+	// dataSource returns the data source name to connect to the address.
+	dataSource(addr string) string
+	// tables returns the statements creating the tables and their indexes.
+	tables(opts graph.Options) ([]string, error)
+	// tableExists returns whether the error is about creating a table which
+	// already exists.
+	tableExists(err error) bool
+	// duplicate returns whether the error is a violation of a unique key.
+	duplicate(err error) bool
+	// bindVars converts the n placeholders of the query to the ones of the
+	// flavor.
+	bindVars(query string, n int) string
+	// insert returns the statement inserting a row into the table. If ignore
+	// is set, it either does nothing for the rows with existing keys, or
+	// fails for them with an error which is a duplicate without aborting the
+	// transaction, and which is then ignored.
+	insert(table string, cols []string, ignore bool) string
+	// orderKeys returns the ORDER BY keys of the expressions, in the
+	// direction, with the NULL values last.
+	orderKeys(exprs []string, dir string) []string
+	// numeric returns the expression of the number held by a node, if any.
+	numeric() string
+	// sizeEstimate returns the query estimating the number of quads, or an
+	// empty string if there is none.
+	sizeEstimate() string
+}
+
+var flavors = make(map[string]flavor)
+
+func registerFlavor(name string, f flavor) {
+	if _, ok := flavors[name]; ok {
+		panic("already registered SQL flavor " + name)
+	}
+	flavors[name] = f
+}
+
+// flavorOf returns the flavor set by the flavor option.
+func flavorOf(opts graph.Options) (flavor, error) {
+	name, ok, err := opts.StringKey("flavor")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		name = defaultFlavor
+	}
+	f, ok := flavors[name]
+	if !ok {
+		return nil, fmt.Errorf("sql: unknown flavor %q", name)
+	}
+	return f, nil
+}
+
+// placeholders returns n comma-separated placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nullsLast returns ORDER BY keys with the NULL values last, for the SQL
+// databases which put them first when ordering in ascending order.
+func nullsLast(exprs []string, dir string) []string {
+	keys := make([]string, 0, 2*len(exprs))
+	for _, e := range exprs {
+		keys = append(keys, e+" IS NULL", e+" "+dir)
+	}
+	return keys
+}
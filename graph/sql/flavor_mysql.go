@@ -0,0 +1,134 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+func init() {
+	registerFlavor("mysql", mySQL{})
+}
+
+// mySQL is the flavor of MySQL, from version 5.7.
+type mySQL struct{}
+
+func (mySQL) driver() string { return "mysql" }
+
+// dataSource makes the driver return times, and the server take the quoted
+// tag names of the iterators as identifiers.
+func (mySQL) dataSource(addr string) string {
+	var params []string
+	if !strings.Contains(addr, "parseTime") {
+		params = append(params, "parseTime=true")
+	}
+	if !strings.Contains(addr, "sql_mode") {
+		params = append(params, "sql_mode='ANSI_QUOTES,STRICT_ALL_TABLES'")
+	}
+	if len(params) == 0 {
+		return addr
+	}
+	sep := "?"
+	if strings.Contains(addr, "?") {
+		sep = "&"
+	}
+	return addr + sep + strings.Join(params, "&")
+}
+
+func (mySQL) tables(graph.Options) ([]string, error) {
+	// MySQL has no partial indexes, so the quads without a label are made
+	// unique by a column where their label is empty instead of NULL.
+	return []string{
+		fmt.Sprintf(`CREATE TABLE nodes (
+	hash VARBINARY(%d) PRIMARY KEY,
+	value BLOB,
+	value_string TEXT,
+	datatype TEXT,
+	language TEXT,
+	iri BOOLEAN,
+	bnode BOOLEAN,
+	value_int BIGINT,
+	value_bool BOOLEAN,
+	value_float DOUBLE PRECISION,
+	value_time DATETIME(6)
+);`, quad.HashSize),
+		fmt.Sprintf(`CREATE TABLE quads (
+	horizon BIGINT AUTO_INCREMENT PRIMARY KEY,
+	subject_hash VARBINARY(%[1]d) NOT NULL,
+	predicate_hash VARBINARY(%[1]d) NOT NULL,
+	object_hash VARBINARY(%[1]d) NOT NULL,
+	label_hash VARBINARY(%[1]d),
+	label_key VARBINARY(%[1]d) AS (COALESCE(label_hash, '')) STORED,
+	id BIGINT,
+	ts DATETIME(6),
+	UNIQUE INDEX spol_unique (subject_hash, predicate_hash, object_hash, label_key),
+	INDEX spo_index (subject_hash),
+	INDEX pos_index (predicate_hash),
+	INDEX osp_index (object_hash),
+	CONSTRAINT subject_hash_fk FOREIGN KEY (subject_hash) REFERENCES nodes (hash),
+	CONSTRAINT predicate_hash_fk FOREIGN KEY (predicate_hash) REFERENCES nodes (hash),
+	CONSTRAINT object_hash_fk FOREIGN KEY (object_hash) REFERENCES nodes (hash),
+	CONSTRAINT label_hash_fk FOREIGN KEY (label_hash) REFERENCES nodes (hash)
+);`, quad.HashSize),
+	}, nil
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/error-messages-server.html
+const (
+	errTableExists = 1050
+	errDupEntry    = 1062
+)
+
+func (mySQL) tableExists(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == errTableExists
+}
+
+func (mySQL) duplicate(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == errDupEntry
+}
+
+func (mySQL) bindVars(query string, _ int) string { return query }
+
+// insert ignores the existing nodes with an update which changes nothing, as
+// INSERT IGNORE would also turn other errors, such as the violations of foreign
+// keys, into warnings. The existing quads fail as duplicates, which only aborts
+// the statement, and are then ignored.
+func (mySQL) insert(table string, cols []string, ignore bool) string {
+	q := `INSERT INTO ` + table + `(` + strings.Join(cols, ", ") + `) VALUES (` + placeholders(len(cols)) + `)`
+	if ignore && table == "nodes" {
+		return q + ` ON DUPLICATE KEY UPDATE hash=hash;`
+	}
+	return q + `;`
+}
+
+func (mySQL) orderKeys(exprs []string, dir string) []string {
+	return nullsLast(exprs, dir)
+}
+
+func (mySQL) numeric() string {
+	return "COALESCE(value_int, value_float)"
+}
+
+func (mySQL) sizeEstimate() string {
+	return "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'quads';"
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/lib/pq"
+
+	"github.com/google/cayley/graph"
+)
+
+const defaultFillFactor = 50
+
+func init() {
+	registerFlavor("postgres", postgres{})
+}
+
+// postgres is the flavor of PostgreSQL, from version 9.5.
+type postgres struct{}
+
+func (postgres) driver() string { return "postgres" }
+
+func (postgres) dataSource(addr string) string { return addr }
+
+const nodesTableStatement = `CREATE TABLE nodes (
+	hash BYTEA PRIMARY KEY,
+	value BYTEA,
+	value_string TEXT,
+	datatype TEXT,
+	language TEXT,
+	iri BOOLEAN,
+	bnode BOOLEAN,
+	value_int BIGINT,
+	value_bool BOOLEAN,
+	value_float double precision,
+	value_time timestamp with time zone
+);`
+
+const quadsTableStatement = `CREATE TABLE quads (
+	horizon BIGSERIAL PRIMARY KEY,
+	subject_hash BYTEA NOT NULL,
+	predicate_hash BYTEA NOT NULL,
+	object_hash BYTEA NOT NULL,
+	label_hash BYTEA,
+	id BIGINT,
+	ts timestamp
+);`
+
+const quadsUniqueIndex = `
+	CREATE UNIQUE INDEX spol_unique ON quads (subject_hash, predicate_hash, object_hash, label_hash) WHERE label_hash IS NOT NULL;
+	CREATE UNIQUE INDEX spo_unique ON quads (subject_hash, predicate_hash, object_hash) WHERE label_hash IS NULL;
+	`
+
+const quadsForeignIndex = `
+	ALTER TABLE quads ADD CONSTRAINT subject_hash_fk FOREIGN KEY (subject_hash) REFERENCES nodes (hash);
+	ALTER TABLE quads ADD CONSTRAINT predicate_hash_fk FOREIGN KEY (predicate_hash) REFERENCES nodes (hash);
+	ALTER TABLE quads ADD CONSTRAINT object_hash_fk FOREIGN KEY (object_hash) REFERENCES nodes (hash);
+	ALTER TABLE quads ADD CONSTRAINT label_hash_fk FOREIGN KEY (label_hash) REFERENCES nodes (hash);
+	`
+
+func quadsSecondaryIndexes(factor int) string {
+	return fmt.Sprintf(`
+	CREATE INDEX spo_index ON quads (subject_hash) WITH (FILLFACTOR = %d);
+	CREATE INDEX pos_index ON quads (predicate_hash) WITH (FILLFACTOR = %d);
+	CREATE INDEX osp_index ON quads (object_hash) WITH (FILLFACTOR = %d);
+	`, factor, factor, factor)
+}
+
+func (postgres) tables(opts graph.Options) ([]string, error) {
+	factor, factorOk, err := opts.IntKey("db_fill_factor")
+	if err != nil {
+		return nil, err
+	} else if !factorOk {
+		factor = defaultFillFactor
+	}
+	return []string{
+		nodesTableStatement,
+		quadsTableStatement,
+		quadsUniqueIndex + quadsForeignIndex + quadsSecondaryIndexes(factor),
+	}, nil
+}
+
+func (postgres) tableExists(err error) bool {
+	pe, ok := err.(*pq.Error)
+	return ok && pe.Code == "42P07"
+}
+
+func (postgres) duplicate(err error) bool {
+	pe, ok := err.(*pq.Error)
+	return ok && pe.Code == "23505"
+}
+
+func (postgres) bindVars(query string, n int) string {
+	return convertToPostgres(query, n)
+}
+
+func convertToPostgres(query string, n int) string {
+	for i := 1; i <= n; i++ {
+		query = strings.Replace(query, "?", fmt.Sprintf("$%d", i), 1)
+	}
+	return query
+}
+
+func (postgres) insert(table string, cols []string, ignore bool) string {
+	q := `INSERT INTO ` + table + `(` + strings.Join(cols, ", ") + `) VALUES (` + placeholders(len(cols)) + `)`
+	if ignore {
+		return q + ` ON CONFLICT DO NOTHING;`
+	}
+	return q + `;`
+}
+
+func (postgres) orderKeys(exprs []string, dir string) []string {
+	keys := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		keys = append(keys, e+" "+dir+" NULLS LAST")
+	}
+	return keys
+}
+
+func (postgres) numeric() string {
+	return "COALESCE(value_int::double precision, value_float)"
+}
+
+func (postgres) sizeEstimate() string {
+	return "SELECT reltuples::BIGINT AS estimate FROM pg_class WHERE relname='quads';"
+}
+
+func (postgres) copyFrom(tx *sql.Tx, in []graph.Delta, opts graph.IgnoreOpts) error {
+	panic("broken")
+	stmt, err := tx.Prepare(pq.CopyIn("quads", "subject", "predicate", "object", "label", "id", "ts", "subject_hash", "predicate_hash", "object_hash", "label_hash"))
+	if err != nil {
+		glog.Errorf("couldn't prepare COPY statement: %v", err)
+		return err
+	}
+	for _, d := range in {
+		s, p, o, l, err := marshalQuadDirections(d.Quad)
+		if err != nil {
+			glog.Errorf("couldn't marshal quads: %v", err)
+			return err
+		}
+		_, err = stmt.Exec(
+			s,
+			p,
+			o,
+			l,
+			d.ID.Int(),
+			d.Timestamp,
+			hashOf(d.Quad.Subject),
+			hashOf(d.Quad.Predicate),
+			hashOf(d.Quad.Object),
+			hashOf(d.Quad.Label),
+		)
+		if err != nil {
+			err = convInsertError(postgres{}, err)
+			glog.Errorf("couldn't execute COPY statement: %v", err)
+			return err
+		}
+	}
+	//if _, err = stmt.Exec(); err != nil {
+	//	glog.Errorf("couldn't execute COPY statement 2: %v", err)
+	//	return err
+	//}
+	if err = stmt.Close(); err != nil {
+		glog.Errorf("couldn't close COPY statement: %v", err)
+		err = convInsertError(postgres{}, err)
+		return err
+	}
+	return nil
+}
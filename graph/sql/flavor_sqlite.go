@@ -0,0 +1,106 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/google/cayley/graph"
+)
+
+func init() {
+	registerFlavor("sqlite", sqlite{})
+}
+
+// sqlite is the flavor of SQLite, which keeps the database in a single file.
+type sqlite struct{}
+
+func (sqlite) driver() string { return "sqlite3" }
+
+// dataSource waits for the locks held by other connections, as the iterators
+// keep reading while the nodes are looked up.
+func (sqlite) dataSource(addr string) string {
+	if strings.Contains(addr, "_busy_timeout") {
+		return addr
+	}
+	if strings.Contains(addr, "?") {
+		return addr + "&_busy_timeout=5000"
+	}
+	return addr + "?_busy_timeout=5000"
+}
+
+func (sqlite) tables(graph.Options) ([]string, error) {
+	return []string{
+		`CREATE TABLE nodes (
+	hash BLOB PRIMARY KEY,
+	value BLOB,
+	value_string TEXT,
+	datatype TEXT,
+	language TEXT,
+	iri BOOLEAN,
+	bnode BOOLEAN,
+	value_int BIGINT,
+	value_bool BOOLEAN,
+	value_float DOUBLE PRECISION,
+	value_time DATETIME
+);`,
+		`CREATE TABLE quads (
+	horizon INTEGER PRIMARY KEY AUTOINCREMENT,
+	subject_hash BLOB NOT NULL REFERENCES nodes (hash),
+	predicate_hash BLOB NOT NULL REFERENCES nodes (hash),
+	object_hash BLOB NOT NULL REFERENCES nodes (hash),
+	label_hash BLOB REFERENCES nodes (hash),
+	id BIGINT,
+	ts DATETIME
+);`,
+		`CREATE UNIQUE INDEX spol_unique ON quads (subject_hash, predicate_hash, object_hash, label_hash) WHERE label_hash IS NOT NULL;`,
+		`CREATE UNIQUE INDEX spo_unique ON quads (subject_hash, predicate_hash, object_hash) WHERE label_hash IS NULL;`,
+		`CREATE INDEX spo_index ON quads (subject_hash);`,
+		`CREATE INDEX pos_index ON quads (predicate_hash);`,
+		`CREATE INDEX osp_index ON quads (object_hash);`,
+	}, nil
+}
+
+func (sqlite) tableExists(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && e.Code == sqlite3.ErrError && strings.Contains(e.Error(), "already exists")
+}
+
+func (sqlite) duplicate(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && (e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
+func (sqlite) bindVars(query string, _ int) string { return query }
+
+func (sqlite) insert(table string, cols []string, ignore bool) string {
+	q := `INSERT `
+	if ignore {
+		q += `OR IGNORE `
+	}
+	return q + `INTO ` + table + `(` + strings.Join(cols, ", ") + `) VALUES (` + placeholders(len(cols)) + `);`
+}
+
+func (sqlite) orderKeys(exprs []string, dir string) []string {
+	return nullsLast(exprs, dir)
+}
+
+func (sqlite) numeric() string {
+	return "COALESCE(value_int, value_float)"
+}
+
+func (sqlite) sizeEstimate() string { return "" }
@@ -21,6 +21,7 @@ import (
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
+	"golang.org/x/net/context"
 )
 
 func intersect(a sqlIterator, b sqlIterator, qs *QuadStore) (*SQLIterator, error) {
@@ -143,6 +144,12 @@ func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool)
 		return qs.optimizeHasA(it.(*iterator.HasA))
 	case graph.And:
 		return qs.optimizeAnd(it.(*iterator.And))
+	case graph.Limit:
+		return qs.optimizeLimit(it.(*iterator.Limit))
+	case graph.Skip:
+		return qs.optimizeSkip(it.(*iterator.Skip))
+	case graph.Sort:
+		return qs.optimizeSort(it.(*iterator.Sort))
 	}
 	return it, false
 }
@@ -160,7 +167,7 @@ func (qs *QuadStore) optimizeLinksTo(it *iterator.LinksTo) (graph.Iterator, bool
 			return iterator.NewNull(), true
 		}
 		if size == 1 {
-			if !graph.Next(primary) {
+			if !graph.Next(context.TODO(), primary) {
 				panic("sql: unexpected size during optimize")
 			}
 			val := primary.Result()
@@ -174,7 +181,7 @@ func (qs *QuadStore) optimizeLinksTo(it *iterator.LinksTo) (graph.Iterator, bool
 			return newIt, true
 		} else if size > 1 {
 			var vals []NodeHash
-			for graph.Next(primary) {
+			for graph.Next(context.TODO(), primary) {
 				vals = append(vals, primary.Result().(NodeHash))
 			}
 			lsql := &SQLLinkIterator{
@@ -281,7 +288,7 @@ func (qs *QuadStore) optimizeAnd(it *iterator.And) (graph.Iterator, bool) {
 				continue
 			}
 			changed = true
-			for graph.Next(subit) {
+			for graph.Next(context.TODO(), subit) {
 				nodeit.fixedSet = append(nodeit.fixedSet, qs.NameOf(subit.Result()))
 			}
 		}
@@ -323,3 +330,52 @@ func (qs *QuadStore) optimizeHasA(it *iterator.HasA) (graph.Iterator, bool) {
 	}
 	return it, false
 }
+
+func (qs *QuadStore) optimizeLimit(it *iterator.Limit) (graph.Iterator, bool) {
+	subs := it.SubIterators()
+	if len(subs) != 1 || subs[0].Type() != sqlType {
+		return it, false
+	}
+	p := subs[0].(*SQLIterator)
+	if p.limit == 0 || it.Limit() < p.limit {
+		p.limit = it.Limit()
+	}
+	p.Tagger().CopyFrom(it)
+	return p, true
+}
+
+func (qs *QuadStore) optimizeSkip(it *iterator.Skip) (graph.Iterator, bool) {
+	subs := it.SubIterators()
+	if len(subs) != 1 || subs[0].Type() != sqlType {
+		return it, false
+	}
+	p := subs[0].(*SQLIterator)
+	if p.limit > 0 {
+		// Skipping inside an already limited query shrinks the window.
+		if it.Skip() >= p.limit {
+			return iterator.NewNull(), true
+		}
+		p.limit -= it.Skip()
+	}
+	p.skip += it.Skip()
+	p.Tagger().CopyFrom(it)
+	return p, true
+}
+
+func (qs *QuadStore) optimizeSort(it *iterator.Sort) (graph.Iterator, bool) {
+	subs := it.SubIterators()
+	if len(subs) != 1 || subs[0].Type() != sqlType {
+		return it, false
+	}
+	p := subs[0].(*SQLIterator)
+	// Ordering has to happen before the rows are limited, and only once.
+	if p.sorted || p.limit > 0 || p.skip > 0 {
+		return it, false
+	}
+	if _, ok := p.orderColumn(it.By()); !ok {
+		return it, false
+	}
+	p.sorted, p.orderBy, p.desc = true, it.By(), it.Descending()
+	p.Tagger().CopyFrom(it)
+	return p, true
+}
@@ -15,9 +15,16 @@
 package sql
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/graphtest"
+	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
 )
 
@@ -68,6 +75,81 @@ func TestBuildLinksTo(t *testing.T) {
 	t.Log(s, v)
 }
 
+func TestBuildLimitSkip(t *testing.T) {
+	var qs *QuadStore
+	a := NewSQLLinkIterator(nil, quad.Subject, quad.Raw("Foo"))
+	it, changed := iterator.NewLimit(qs, iterator.NewSkip(qs, a, 5), 10).Optimize()
+	if !changed {
+		t.Fatal("Failed to push Limit and Skip into the SQL iterator")
+	}
+	s, _ := it.(*SQLIterator).buildSQL(true, nil)
+	if !strings.HasSuffix(s, " LIMIT 10 OFFSET 5;") {
+		t.Errorf("Unexpected query: %s", s)
+	}
+	t.Log(s)
+}
+
+func TestBuildSort(t *testing.T) {
+	var qs *QuadStore
+	a := NewSQLLinkIterator(nil, quad.Subject, quad.Raw("Foo"))
+	nodes, err := hasa(a.sql, quad.Object, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, changed := iterator.NewLimit(qs, iterator.NewSort(qs, nodes, "", true), 10).Optimize()
+	if !changed {
+		t.Fatal("Failed to push Sort and Limit into the SQL iterator")
+	}
+	col := nodes.sql.tableID().column()
+	s, _ := it.(*SQLIterator).buildSQL(true, nil)
+	if !strings.Contains(s, " ORDER BY (SELECT COALESCE(value_int::double precision, value_float) FROM nodes WHERE nodes.hash = "+col+") DESC NULLS LAST") ||
+		!strings.HasSuffix(s, " DESC NULLS LAST LIMIT 10;") {
+		t.Errorf("Unexpected query: %s", s)
+	}
+	t.Log(s)
+
+	// Links are not nodes, so they cannot be ordered by the backend.
+	b := NewSQLLinkIterator(nil, quad.Subject, quad.Raw("Foo"))
+	if _, changed := iterator.NewSort(qs, b, "", false).Optimize(); changed {
+		t.Error("Unexpected push down of Sort of links")
+	}
+}
+
+func TestSQLiteSortSkip(t *testing.T) {
+	qs, opts, closer := makeSQLite(t)
+	defer closer()
+	var quads []quad.Quad
+	for _, age := range []int{30, 10, 40, 20} {
+		quads = append(quads, quad.Quad{
+			Subject:   quad.IRI(fmt.Sprintf("p%d", age)),
+			Predicate: quad.IRI("age"),
+			Object:    quad.Int(age),
+		})
+	}
+	graphtest.MakeWriter(t, qs, opts, quads...)
+
+	a := NewSQLLinkIterator(qs.(*QuadStore), quad.Predicate, quad.IRI("age"))
+	nodes, err := hasa(a.sql, quad.Object, qs.(*QuadStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, changed := iterator.NewLimit(qs, iterator.NewSkip(qs, iterator.NewSort(qs, nodes, "", true), 1), 2).Optimize()
+	if !changed {
+		t.Fatal("Failed to push Sort, Skip and Limit into the SQL iterator")
+	}
+	var got []quad.Value
+	for graph.Next(context.TODO(), it) {
+		got = append(got, qs.NameOf(it.Result()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []quad.Value{quad.Int(30), quad.Int(20)}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Unexpected results, got:%v expected:%v", got, expect)
+	}
+}
+
 func TestInterestingQuery(t *testing.T) {
 	if *postgres_path == "" {
 		t.SkipNow()
@@ -117,7 +199,7 @@ func TestInterestingQuery(t *testing.T) {
 	s, v := it8.sql.buildSQL(true, nil)
 	it8.Tagger().Add("id")
 	t.Log(s, v)
-	for graph.Next(it8) {
+	for graph.Next(context.TODO(), it8) {
 		t.Log(it8.Result())
 		out := make(map[string]graph.Value)
 		it8.TagResults(out)
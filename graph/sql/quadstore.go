@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -20,8 +19,6 @@ import (
 
 const QuadStoreType = "sql"
 
-const defaultFillFactor = 50
-
 func init() {
 	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
 		NewFunc:           newQuadStore,
@@ -96,7 +93,7 @@ func (q QuadHashes) Get(d quad.Direction) NodeHash {
 
 type QuadStore struct {
 	db           *sql.DB
-	sqlFlavor    string
+	flavor       flavor
 	size         int64
 	ids          *lru.Cache
 	sizes        *lru.Cache
@@ -104,9 +101,8 @@ type QuadStore struct {
 	useEstimates bool
 }
 
-func connectSQLTables(addr string, _ graph.Options) (*sql.DB, error) {
-	// TODO(barakmich): Parse options for more friendly addr, other SQLs.
-	conn, err := sql.Open("postgres", addr)
+func connectSQLTables(fl flavor, addr string) (*sql.DB, error) {
+	conn, err := sql.Open(fl.driver(), fl.dataSource(addr))
 	if err != nil {
 		glog.Errorf("Couldn't open database at %s: %#v", addr, err)
 		return nil, err
@@ -135,6 +131,15 @@ var nodesColumns = []string{
 	"value_time",
 }
 
+var quadInsertColumns = []string{
+	"subject_hash",
+	"predicate_hash",
+	"object_hash",
+	"label_hash",
+	"id",
+	"ts",
+}
+
 var nodeInsertColumns = [][]string{
 	{"value"},
 	{"value_string", "iri"},
@@ -148,101 +153,45 @@ var nodeInsertColumns = [][]string{
 	{"value_time"},
 }
 
-const nodesTableStatement = `CREATE TABLE nodes (
-	hash BYTEA PRIMARY KEY,
-	value BYTEA,
-	value_string TEXT,
-	datatype TEXT,
-	language TEXT,
-	iri BOOLEAN,
-	bnode BOOLEAN,
-	value_int BIGINT,
-	value_bool BOOLEAN,
-	value_float double precision,
-	value_time timestamp with time zone
-);`
-
-const quadsUniqueIndex = `
-	CREATE UNIQUE INDEX spol_unique ON quads (subject_hash, predicate_hash, object_hash, label_hash) WHERE label_hash IS NOT NULL;
-	CREATE UNIQUE INDEX spo_unique ON quads (subject_hash, predicate_hash, object_hash) WHERE label_hash IS NULL;
-	`
-
-const quadsForeignIndex = `
-	ALTER TABLE quads ADD CONSTRAINT subject_hash_fk FOREIGN KEY (subject_hash) REFERENCES nodes (hash);
-	ALTER TABLE quads ADD CONSTRAINT predicate_hash_fk FOREIGN KEY (predicate_hash) REFERENCES nodes (hash);
-	ALTER TABLE quads ADD CONSTRAINT object_hash_fk FOREIGN KEY (object_hash) REFERENCES nodes (hash);
-	ALTER TABLE quads ADD CONSTRAINT label_hash_fk FOREIGN KEY (label_hash) REFERENCES nodes (hash);
-	`
-
-func quadsSecondaryIndexes(factor int) string {
-	return fmt.Sprintf(`
-	CREATE INDEX spo_index ON quads (subject_hash) WITH (FILLFACTOR = %d);
-	CREATE INDEX pos_index ON quads (predicate_hash) WITH (FILLFACTOR = %d);
-	CREATE INDEX osp_index ON quads (object_hash) WITH (FILLFACTOR = %d);
-	`, factor, factor, factor)
-}
-
 func createSQLTables(addr string, options graph.Options) error {
-	conn, err := connectSQLTables(addr, options)
+	fl, err := flavorOf(options)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	tx, err := conn.Begin()
+	tables, err := fl.tables(options)
 	if err != nil {
-		glog.Errorf("Couldn't begin creation transaction: %s", err)
 		return err
 	}
-
-	table, err := tx.Exec(nodesTableStatement)
+	conn, err := connectSQLTables(fl, addr)
 	if err != nil {
-		tx.Rollback()
-		errd := err.(*pq.Error)
-		if errd.Code == "42P07" {
-			return graph.ErrDatabaseExists
-		}
-		glog.Errorf("Cannot create nodes table: %v", table)
 		return err
 	}
-	table, err = tx.Exec(`
-	CREATE TABLE quads (
-		horizon BIGSERIAL PRIMARY KEY,
-		subject_hash BYTEA NOT NULL,
-		predicate_hash BYTEA NOT NULL,
-		object_hash BYTEA NOT NULL,
-		label_hash BYTEA,
-		id BIGINT,
-		ts timestamp
-	);`)
+	defer conn.Close()
+	tx, err := conn.Begin()
 	if err != nil {
-		tx.Rollback()
-		errd := err.(*pq.Error)
-		if errd.Code == "42P07" {
-			return graph.ErrDatabaseExists
-		}
-		glog.Errorf("Cannot create quad table: %v", table)
+		glog.Errorf("Couldn't begin creation transaction: %s", err)
 		return err
 	}
-	factor, factorOk, err := options.IntKey("db_fill_factor")
-	if !factorOk {
-		factor = defaultFillFactor
-	}
-	spoIndexes := quadsSecondaryIndexes(factor)
-
-	var index sql.Result
-	index, err = tx.Exec(quadsUniqueIndex + quadsForeignIndex + spoIndexes)
-	if err != nil {
-		glog.Errorf("Cannot create indices: %v", index)
-		tx.Rollback()
-		return err
+	for _, stmt := range tables {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			if fl.tableExists(err) {
+				return graph.ErrDatabaseExists
+			}
+			glog.Errorf("Cannot create tables: %v", err)
+			return err
+		}
 	}
-	tx.Commit()
-	return nil
+	return tx.Commit()
 }
 
 func newQuadStore(addr string, options graph.Options) (graph.QuadStore, error) {
 	var qs QuadStore
-	conn, err := connectSQLTables(addr, options)
+	fl, err := flavorOf(options)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connectSQLTables(fl, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +200,7 @@ func newQuadStore(addr string, options graph.Options) (graph.QuadStore, error) {
 		return nil, err
 	}
 	qs.db = conn
-	qs.sqlFlavor = "postgres"
+	qs.flavor = fl
 	qs.size = -1
 	qs.sizes = lru.New(1024)
 	qs.ids = lru.New(1024)
@@ -271,14 +220,9 @@ func newQuadStore(addr string, options graph.Options) (graph.QuadStore, error) {
 	return &qs, nil
 }
 
-func convInsertError(err error) error {
-	if err == nil {
-		return err
-	}
-	if pe, ok := err.(*pq.Error); ok {
-		if pe.Code == "23505" {
-			return graph.ErrQuadExists
-		}
+func convInsertError(fl flavor, err error) error {
+	if err != nil && fl.duplicate(err) {
+		return graph.ErrQuadExists
 	}
 	return err
 }
@@ -303,49 +247,6 @@ func marshalQuadDirections(q quad.Quad) (s, p, o, l []byte, err error) {
 	return
 }
 
-func (qs *QuadStore) copyFrom(tx *sql.Tx, in []graph.Delta, opts graph.IgnoreOpts) error {
-	panic("broken")
-	stmt, err := tx.Prepare(pq.CopyIn("quads", "subject", "predicate", "object", "label", "id", "ts", "subject_hash", "predicate_hash", "object_hash", "label_hash"))
-	if err != nil {
-		glog.Errorf("couldn't prepare COPY statement: %v", err)
-		return err
-	}
-	for _, d := range in {
-		s, p, o, l, err := marshalQuadDirections(d.Quad)
-		if err != nil {
-			glog.Errorf("couldn't marshal quads: %v", err)
-			return err
-		}
-		_, err = stmt.Exec(
-			s,
-			p,
-			o,
-			l,
-			d.ID.Int(),
-			d.Timestamp,
-			hashOf(d.Quad.Subject),
-			hashOf(d.Quad.Predicate),
-			hashOf(d.Quad.Object),
-			hashOf(d.Quad.Label),
-		)
-		if err != nil {
-			err = convInsertError(err)
-			glog.Errorf("couldn't execute COPY statement: %v", err)
-			return err
-		}
-	}
-	//if _, err = stmt.Exec(); err != nil {
-	//	glog.Errorf("couldn't execute COPY statement 2: %v", err)
-	//	return err
-	//}
-	if err = stmt.Close(); err != nil {
-		glog.Errorf("couldn't close COPY statement: %v", err)
-		err = convInsertError(err)
-		return err
-	}
-	return nil
-}
-
 func escapeNullByte(s string) string {
 	return strings.Replace(s, "\u0000", `\x00`, -1)
 }
@@ -398,22 +299,18 @@ func nodeValues(h NodeHash, v quad.Value) (int, []interface{}, error) {
 	return nodeKey, values, nil
 }
 
-func (qs *QuadStore) runTxPostgres(tx *sql.Tx, in []graph.Delta, opts graph.IgnoreOpts) error {
+func (qs *QuadStore) runTx(tx *sql.Tx, in []graph.Delta, opts graph.IgnoreOpts) error {
 	//allAdds := true
 	//for _, d := range in {
 	//	if d.Action != graph.Add {
 	//		allAdds = false
 	//	}
 	//}
-	//if allAdds && !opts.IgnoreDup {
-	//	return qs.copyFrom(tx, in, opts)
+	//if pg, ok := qs.flavor.(postgres); ok && allAdds && !opts.IgnoreDup {
+	//	return pg.copyFrom(tx, in, opts)
 	//}
 
-	end := ";"
-	if opts.IgnoreDup {
-		end = " ON CONFLICT DO NOTHING;"
-	}
-
+	fl := qs.flavor
 	var (
 		insertQuad  *sql.Stmt
 		insertValue map[int]*sql.Stmt     // prepared statements for each value type
@@ -428,7 +325,7 @@ func (qs *QuadStore) runTxPostgres(tx *sql.Tx, in []graph.Delta, opts graph.Igno
 		switch d.Action {
 		case graph.Add:
 			if insertQuad == nil {
-				insertQuad, err = tx.Prepare(`INSERT INTO quads(subject_hash, predicate_hash, object_hash, label_hash, id, ts) VALUES ($1, $2, $3, $4, $5, $6)` + end)
+				insertQuad, err = tx.Prepare(fl.bindVars(fl.insert("quads", quadInsertColumns, opts.IgnoreDup), len(quadInsertColumns)))
 				if err != nil {
 					return err
 				}
@@ -463,22 +360,18 @@ func (qs *QuadStore) runTxPostgres(tx *sql.Tx, in []graph.Delta, opts graph.Igno
 				}
 				stmt, ok := insertValue[nodeKey]
 				if !ok {
-					var ph = make([]string, len(values)-1)
-					for i := range ph {
-						ph[i] = "$" + strconv.FormatInt(int64(i)+2, 10)
-					}
-					stmt, err = tx.Prepare(`INSERT INTO nodes(hash, ` +
-						strings.Join(nodeInsertColumns[nodeKey], ", ") +
-						`) VALUES ($1, ` +
-						strings.Join(ph, ", ") +
-						`) ON CONFLICT DO NOTHING;`)
+					cols := append([]string{"hash"}, nodeInsertColumns[nodeKey]...)
+					stmt, err = tx.Prepare(fl.bindVars(fl.insert("nodes", cols, true), len(cols)))
 					if err != nil {
 						return err
 					}
 					insertValue[nodeKey] = stmt
 				}
 				_, err = stmt.Exec(values...)
-				err = convInsertError(err)
+				err = convInsertError(fl, err)
+				if err == graph.ErrQuadExists {
+					err = nil
+				}
 				if err != nil {
 					glog.Errorf("couldn't exec INSERT statement: %v", err)
 					return err
@@ -490,18 +383,21 @@ func (qs *QuadStore) runTxPostgres(tx *sql.Tx, in []graph.Delta, opts graph.Igno
 				d.ID.Int(),
 				d.Timestamp,
 			)
-			err = convInsertError(err)
+			err = convInsertError(fl, err)
+			if err == graph.ErrQuadExists && opts.IgnoreDup {
+				err = nil
+			}
 			if err != nil {
 				glog.Errorf("couldn't exec INSERT statement: %v", err)
 				return err
 			}
 		case graph.Delete:
 			if deleteQuad == nil {
-				deleteQuad, err = tx.Prepare(`DELETE FROM quads WHERE subject_hash=$1 and predicate_hash=$2 and object_hash=$3 and label_hash=$4;`)
+				deleteQuad, err = tx.Prepare(fl.bindVars(`DELETE FROM quads WHERE subject_hash=? and predicate_hash=? and object_hash=? and label_hash=?;`, 4))
 				if err != nil {
 					return err
 				}
-				deleteTriple, err = tx.Prepare(`DELETE FROM quads WHERE subject_hash=$1 and predicate_hash=$2 and object_hash=$3 and label_hash is null;`)
+				deleteTriple, err = tx.Prepare(fl.bindVars(`DELETE FROM quads WHERE subject_hash=? and predicate_hash=? and object_hash=? and label_hash is null;`, 3))
 				if err != nil {
 					return err
 				}
@@ -538,15 +434,10 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 		glog.Errorf("couldn't begin write transaction: %v", err)
 		return err
 	}
-	switch qs.sqlFlavor {
-	case "postgres":
-		err = qs.runTxPostgres(tx, in, opts)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-	default:
-		panic("no support for flavor: " + qs.sqlFlavor)
+	err = qs.runTx(tx, in, opts)
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 	return tx.Commit()
 }
@@ -581,6 +472,8 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 	if v == nil {
 		glog.V(2).Info("NameOf was nil")
 		return nil
+	} else if v, ok := v.(graph.PreFetchedValue); ok {
+		return v.NameOf()
 	}
 	hash := v.(NodeHash)
 	if !hash.Valid() {
@@ -601,8 +494,8 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 		value_bool,
 		value_float,
 		value_time
-	FROM nodes WHERE hash = $1 LIMIT 1;`
-	c := qs.db.QueryRow(query, hash.toSQL())
+	FROM nodes WHERE hash = ? LIMIT 1;`
+	c := qs.db.QueryRow(qs.flavor.bindVars(query, 1), hash.toSQL())
 	var (
 		data   []byte
 		str    sql.NullString
@@ -671,6 +564,27 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 	return val
 }
 
+// Metrics reports the lookups of the caches of node IDs and of sizes, and
+// the rate of the ones answered by the cache.
+func (qs *QuadStore) Metrics() map[string]float64 {
+	m := make(map[string]float64)
+	for _, c := range []struct {
+		name  string
+		cache *lru.Cache
+	}{
+		{"ids", qs.ids},
+		{"sizes", qs.sizes},
+	} {
+		hits, misses := c.cache.Stats()
+		m[`sql_cache_hits_total{cache="`+c.name+`"}`] = float64(hits)
+		m[`sql_cache_misses_total{cache="`+c.name+`"}`] = float64(misses)
+		if hits+misses > 0 {
+			m[`sql_cache_hit_rate{cache="`+c.name+`"}`] = float64(hits) / float64(hits+misses)
+		}
+	}
+	return m
+}
+
 func (qs *QuadStore) Size() int64 {
 	if qs.size != -1 {
 		return qs.size
@@ -678,11 +592,8 @@ func (qs *QuadStore) Size() int64 {
 
 	query := "SELECT COUNT(*) FROM quads;"
 	if qs.useEstimates {
-		switch qs.sqlFlavor {
-		case "postgres":
-			query = "SELECT reltuples::BIGINT AS estimate FROM pg_class WHERE relname='quads';"
-		default:
-			panic("no estimate support for flavor: " + qs.sqlFlavor)
+		if q := qs.flavor.sizeEstimate(); q != "" {
+			query = q
 		}
 	}
 
@@ -740,7 +651,7 @@ func (qs *QuadStore) sizeForIterator(isAll bool, dir quad.Direction, hash NodeHa
 	var size int64
 	glog.V(4).Infoln("sql: getting size for select %s, %v", dir.String(), hash)
 	err = qs.db.QueryRow(
-		fmt.Sprintf("SELECT count(*) FROM quads WHERE %s_hash = $1;", dir.String()), hash.toSQL()).Scan(&size)
+		qs.flavor.bindVars(fmt.Sprintf("SELECT count(*) FROM quads WHERE %s_hash = ?;", dir.String()), 1), hash.toSQL()).Scan(&size)
 	if err != nil {
 		glog.Errorln("Error getting size from SQL database: %v", err)
 		return 0
@@ -1,6 +1,9 @@
 package sql
 
 import (
+	"io/ioutil"
+	"os"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/graphtest"
 	"github.com/google/cayley/internal/dock"
@@ -51,8 +54,103 @@ func TestPostgresAll(t *testing.T) {
 	})
 }
 
+func makeSQLite(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	tmpFile, err := ioutil.TempFile("", "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working file: %v", err)
+	}
+	tmpFile.Close()
+	addr := tmpFile.Name()
+	opts := graph.Options{"flavor": "sqlite"}
+	if err := createSQLTables(addr, opts); err != nil {
+		os.Remove(addr)
+		t.Fatal(err)
+	}
+	qs, err := newQuadStore(addr, opts)
+	if err != nil {
+		os.Remove(addr)
+		t.Fatal(err)
+	}
+	return qs, nil, func() {
+		qs.Close()
+		os.Remove(addr)
+	}
+}
+
+func TestSQLiteAll(t *testing.T) {
+	graphtest.TestAll(t, makeSQLite, &graphtest.Config{
+		SkipNodeDelAfterQuadDel: true,
+	})
+}
+
+func TestSQLiteInitExisting(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "cayley_test")
+	if err != nil {
+		t.Fatalf("Could not create working file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	opts := graph.Options{"flavor": "sqlite"}
+	if err := createSQLTables(tmpFile.Name(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := createSQLTables(tmpFile.Name(), opts); err != graph.ErrDatabaseExists {
+		t.Errorf("Unexpected error for an existing database, got:%v expected:%v", err, graph.ErrDatabaseExists)
+	}
+}
+
+// mysqlAddr is the data source name of a MySQL database, whose tables are
+// dropped by the tests. The MySQL tests are skipped if it is not set.
+var mysqlAddr = os.Getenv("CAYLEY_MYSQL_ADDR")
+
+func makeMySQL(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	if mysqlAddr == "" {
+		t.Skip("CAYLEY_MYSQL_ADDR is not set")
+	}
+	opts := graph.Options{"flavor": "mysql"}
+	conn, err := connectSQLTables(mySQL{}, mysqlAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`DROP TABLE IF EXISTS quads, nodes;`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := createSQLTables(mysqlAddr, opts); err != nil {
+		t.Fatal(err)
+	}
+	qs, err := newQuadStore(mysqlAddr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qs, nil, func() {
+		qs.Close()
+	}
+}
+
+func TestMySQLAll(t *testing.T) {
+	graphtest.TestAll(t, makeMySQL, &graphtest.Config{
+		TimeInMcs:               true,
+		TimeRound:               true,
+		SkipNodeDelAfterQuadDel: true,
+	})
+}
+
+func TestMySQLZeroRune(t *testing.T) {
+	testZeroRune(t, makeMySQL)
+}
+
 func TestZeroRune(t *testing.T) {
-	qs, opts, closer := makePostgres(t)
+	testZeroRune(t, makePostgres)
+}
+
+func TestSQLiteZeroRune(t *testing.T) {
+	testZeroRune(t, makeSQLite)
+}
+
+func testZeroRune(t *testing.T, gen graphtest.DatabaseFunc) {
+	qs, opts, closer := gen(t)
 	defer closer()
 
 	w := graphtest.MakeWriter(t, qs, opts)
@@ -17,12 +17,14 @@ package sql
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/golang/glog"
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
+	"golang.org/x/net/context"
 )
 
 var sqlType graph.Type
@@ -40,14 +42,19 @@ type tagDir struct {
 	justLocal bool
 }
 
-func (t tagDir) String() string {
+// column returns the column holding the node hashes of the tag.
+func (t tagDir) column() string {
 	if t.dir == quad.Any {
 		if t.justLocal {
-			return fmt.Sprintf("%s.__execd as \"%s\"", t.table, t.tag)
+			return fmt.Sprintf("%s.__execd", t.table)
 		}
-		return fmt.Sprintf("%s.\"%s\" as \"%s\"", t.table, t.tag, t.tag)
+		return fmt.Sprintf("%s.\"%s\"", t.table, t.tag)
 	}
-	return fmt.Sprintf("%s.%s_hash as \"%s\"", t.table, t.dir, t.tag)
+	return fmt.Sprintf("%s.%s_hash", t.table, t.dir)
+}
+
+func (t tagDir) String() string {
+	return fmt.Sprintf("%s as \"%s\"", t.column(), t.tag)
 }
 
 type tableDef struct {
@@ -84,6 +91,16 @@ type SQLIterator struct {
 
 	sql sqlIterator
 
+	// limit and skip are applied to the query as LIMIT and OFFSET.
+	limit int64
+	skip  int64
+
+	// sorted orders the results by the values of the orderBy tag, or of the
+	// results if it is empty.
+	sorted  bool
+	orderBy string
+	desc    bool
+
 	result      map[string]graph.Value
 	resultIndex int
 	resultList  [][]NodeHash
@@ -93,9 +110,14 @@ type SQLIterator struct {
 
 func (it *SQLIterator) Clone() graph.Iterator {
 	m := &SQLIterator{
-		uid: iterator.NextUID(),
-		qs:  it.qs,
-		sql: it.sql.sqlClone(),
+		uid:     iterator.NextUID(),
+		qs:      it.qs,
+		sql:     it.sql.sqlClone(),
+		limit:   it.limit,
+		skip:    it.skip,
+		sorted:  it.sorted,
+		orderBy: it.orderBy,
+		desc:    it.desc,
 	}
 	return m
 }
@@ -160,19 +182,51 @@ func (it *SQLIterator) Sorted() bool                     { return false }
 func (it *SQLIterator) Optimize() (graph.Iterator, bool) { return it, false }
 
 func (it *SQLIterator) Size() (int64, bool) {
-	return it.sql.Size(it.qs)
+	size, exact := it.sql.Size(it.qs)
+	if it.skip > 0 {
+		size -= it.skip
+		if size < 0 {
+			size = 0
+		}
+	}
+	if it.limit > 0 && size > it.limit {
+		size = it.limit
+	}
+	return size, exact
 }
 
 func (it *SQLIterator) Describe() graph.Description {
 	size, _ := it.Size()
 	return graph.Description{
 		UID:  it.UID(),
-		Name: it.sql.Describe(),
+		Name: it.describe(),
 		Type: it.Type(),
 		Size: size,
 	}
 }
 
+func (it *SQLIterator) describe() string {
+	s := it.sql.Describe()
+	if it.sorted {
+		by := it.orderBy
+		if by == "" {
+			by = "result"
+		}
+		dir := "ASC"
+		if it.desc {
+			dir = "DESC"
+		}
+		s += fmt.Sprintf(" ORDER BY %s %s", by, dir)
+	}
+	if it.skip > 0 {
+		s += fmt.Sprintf(" OFFSET %d", it.skip)
+	}
+	if it.limit > 0 {
+		s += fmt.Sprintf(" LIMIT %d", it.limit)
+	}
+	return s
+}
+
 func (it *SQLIterator) Stats() graph.IteratorStats {
 	size, _ := it.Size()
 	return graph.IteratorStats{
@@ -182,7 +236,7 @@ func (it *SQLIterator) Stats() graph.IteratorStats {
 	}
 }
 
-func (it *SQLIterator) NextPath() bool {
+func (it *SQLIterator) NextPath(ctx context.Context) bool {
 	it.resultIndex += 1
 	if it.resultIndex >= len(it.resultList) {
 		return false
@@ -191,9 +245,13 @@ func (it *SQLIterator) NextPath() bool {
 	return true
 }
 
-func (it *SQLIterator) Next() bool {
+func (it *SQLIterator) Next(ctx context.Context) bool {
 	var err error
 	graph.NextLogIn(it)
+	if err = ctx.Err(); err != nil {
+		it.err = err
+		return graph.NextLogOut(it, nil, false)
+	}
 	if it.cursor == nil {
 		err = it.makeCursor(true, nil)
 		if err != nil {
@@ -235,6 +293,11 @@ func (it *SQLIterator) Next() bool {
 	it.resultNext = nil
 	it.resultIndex = 0
 	for {
+		if err = ctx.Err(); err != nil {
+			it.err = err
+			it.cursor.Close()
+			return graph.NextLogOut(it, nil, false)
+		}
 		if !it.cursor.Next() {
 			glog.V(4).Infoln("sql: No next")
 			err := it.cursor.Err()
@@ -267,11 +330,15 @@ func (it *SQLIterator) Next() bool {
 	return graph.NextLogOut(it, it.Result(), true)
 }
 
-func (it *SQLIterator) Contains(v graph.Value) bool {
+func (it *SQLIterator) Contains(ctx context.Context, v graph.Value) bool {
 	var err error
 	if ok, res := it.sql.quickContains(v); ok {
 		return res
 	}
+	if err = ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
 	err = it.makeCursor(false, v)
 	if err != nil {
 		glog.Errorf("Couldn't make query: %v", err)
@@ -290,6 +357,11 @@ func (it *SQLIterator) Contains(v graph.Value) bool {
 	}
 	it.resultList = nil
 	for {
+		if err = ctx.Err(); err != nil {
+			it.err = err
+			it.cursor.Close()
+			return false
+		}
 		if !it.cursor.Next() {
 			glog.V(4).Infoln("sql: No next")
 			err := it.cursor.Err()
@@ -336,14 +408,87 @@ func (it *SQLIterator) buildResult(i int) {
 	it.result = it.sql.buildResult(it.resultList[i], it.cols)
 }
 
+// orderColumn returns the column holding the hashes of the nodes of the given
+// tag, or of the results if it is empty.
+func (it *SQLIterator) orderColumn(by string) (string, bool) {
+	if by == "" {
+		if it.sql.Type() == link {
+			return "", false
+		}
+		return it.sql.tableID().column(), true
+	}
+	for _, t := range it.sql.getTags() {
+		if t.tag == by {
+			return t.column(), true
+		}
+	}
+	return "", false
+}
+
+// orderClause returns the ORDER BY clause sorting the results by the values of
+// their nodes: numbers first, then times, then strings, as the Sort iterator
+// does.
+func (it *SQLIterator) orderClause() string {
+	col, ok := it.orderColumn(it.orderBy)
+	if !ok {
+		return ""
+	}
+	dir := "ASC"
+	if it.desc {
+		dir = "DESC"
+	}
+	fl := it.flavor()
+	var values []string
+	for _, v := range []string{
+		fl.numeric(),
+		"value_time",
+		"value_string",
+	} {
+		values = append(values, fmt.Sprintf("(SELECT %s FROM nodes WHERE nodes.hash = %s)", v, col))
+	}
+	return " ORDER BY " + strings.Join(fl.orderKeys(values, dir), ", ")
+}
+
+// flavor returns the flavor of the quad store, or the default one for the
+// iterators which are built without a quad store.
+func (it *SQLIterator) flavor() flavor {
+	if it.qs == nil {
+		return flavors[defaultFlavor]
+	}
+	return it.qs.flavor
+}
+
+// buildSQL builds the query for the underlying sqlIterator, ordering the rows
+// and restricting them to the limit and offset when iterating.
+func (it *SQLIterator) buildSQL(next bool, value graph.Value) (string, sqlArgs) {
+	q, values := it.sql.buildSQL(next, value)
+	if next && (it.sorted || it.limit > 0 || it.skip > 0) {
+		q = strings.TrimSuffix(q, ";")
+		if it.sorted {
+			q += it.orderClause()
+		}
+		if it.limit > 0 {
+			q += fmt.Sprintf(" LIMIT %d", it.limit)
+		} else if it.skip > 0 {
+			// Not all the flavors accept an OFFSET without a LIMIT.
+			q += fmt.Sprintf(" LIMIT %d", int64(math.MaxInt64))
+		}
+		if it.skip > 0 {
+			q += fmt.Sprintf(" OFFSET %d", it.skip)
+		}
+		q += ";"
+	}
+	return q, values
+}
+
 func (it *SQLIterator) makeCursor(next bool, value graph.Value) error {
 	if it.cursor != nil {
 		it.cursor.Close()
 	}
 	var q string
 	var values sqlArgs
-	q, values = it.sql.buildSQL(next, value)
-	q = convertToPostgres(q, values)
+	q, values = it.buildSQL(next, value)
+	q = it.qs.flavor.bindVars(q, len(values))
 	cursor, err := it.qs.db.Query(q, values...)
 	if err != nil {
 		glog.Errorf("Couldn't get cursor from SQL database: %v", err)
@@ -354,13 +499,6 @@ func (it *SQLIterator) makeCursor(next bool, value graph.Value) error {
 	return nil
 }
 
-func convertToPostgres(query string, values sqlArgs) string {
-	for i := 1; i <= len(values); i++ {
-		query = strings.Replace(query, "?", fmt.Sprintf("$%d", i), 1)
-	}
-	return query
-}
-
 func NewSQLLinkIterator(qs *QuadStore, d quad.Direction, v quad.Value) *SQLIterator {
 	return newSQLLinkIterator(qs, d, NodeHash(hashOf(v)))
 }
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
@@ -42,14 +44,14 @@ func TestSQLLinkIteration(t *testing.T) {
 		t.Fatal(err)
 	}
 	it := NewSQLLinkIterator(qs, quad.Object, quad.Raw("Humphrey Bogart"))
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		fmt.Println(it.Result())
 	}
 	it = NewSQLLinkIterator(qs, quad.Subject, quad.Raw("/en/casablanca_1942"))
 	s, v := it.sql.buildSQL(true, nil)
 	t.Log(s, v)
 	c := 0
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		fmt.Println(it.Result())
 		c += 1
 	}
@@ -81,7 +83,7 @@ func TestSQLNodeIteration(t *testing.T) {
 	s, v := it.sql.buildSQL(true, nil)
 	t.Log(s, v)
 	c := 0
-	for graph.Next(it) {
+	for graph.Next(context.TODO(), it) {
 		t.Log(it.Result())
 		c += 1
 	}
@@ -25,6 +25,8 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/internal"
 	"github.com/google/cayley/internal/config"
@@ -33,10 +35,12 @@ import (
 	"github.com/google/cayley/query/gremlin"
 
 	// Load all supported backends.
+	_ "github.com/google/cayley/graph/badger"
 	_ "github.com/google/cayley/graph/bolt"
 	_ "github.com/google/cayley/graph/leveldb"
 	_ "github.com/google/cayley/graph/memstore"
 	_ "github.com/google/cayley/graph/mongo"
+	_ "github.com/google/cayley/graph/redis"
 	_ "github.com/google/cayley/graph/sql"
 
 	// Load writer registry
@@ -444,7 +448,7 @@ func prepare(t testing.TB) {
 	switch *backend {
 	case "memstore":
 		cfg.DatabasePath = "../data/30kmoviedata.nq.gz"
-	case "leveldb", "bolt":
+	case "leveldb", "bolt", "badger":
 		cfg.DatabasePath = "/tmp/cayley_test_" + *backend
 		cfg.DatabaseOptions = map[string]interface{}{
 			"nosync": true, // It's a test. If we need to load, do it fast.
@@ -560,7 +564,7 @@ func checkQueries(t *testing.T) {
 			t.Fatalf("Failed to parse benchmark gremlin %s: %v", test.message, err)
 		}
 		c := make(chan interface{}, 5)
-		go ses.Execute(test.query, c, 100)
+		go ses.Execute(context.TODO(), test.query, c, 100)
 		var (
 			got      []interface{}
 			timedOut bool
@@ -628,7 +632,7 @@ func runBench(n int, b *testing.B) {
 		// Do the parsing we know works.
 		ses.Parse(benchmarkQueries[n].query)
 		b.StartTimer()
-		go ses.Execute(benchmarkQueries[n].query, c, 100)
+		go ses.Execute(context.TODO(), benchmarkQueries[n].query, c, 100)
 		for _ = range c {
 		}
 		b.StopTimer()
@@ -31,10 +31,27 @@ type Config struct {
 	ReplicationOptions         map[string]interface{}
 	ListenHost                 string
 	ListenPort                 string
+	TLSCertFile                string
+	TLSKeyFile                 string
+	AllowedOrigins             []string
+	ReadTimeout                time.Duration
+	WriteTimeout               time.Duration
+	IdleTimeout                time.Duration
 	ReadOnly                   bool
 	Timeout                    time.Duration
 	LoadSize                   int
 	RequiresHTTPRequestContext bool
+	Auth                       []Credential
+	StoredQueries              string
+}
+
+// Credential grants a role to the HTTP clients which present either its
+// bearer token, or its user and password with basic authentication.
+type Credential struct {
+	Token    string `json:"token,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role"`
 }
 
 type config struct {
@@ -45,10 +62,18 @@ type config struct {
 	ReplicationOptions         map[string]interface{} `json:"replication_options"`
 	ListenHost                 string                 `json:"listen_host"`
 	ListenPort                 string                 `json:"listen_port"`
+	TLSCertFile                string                 `json:"tls_cert"`
+	TLSKeyFile                 string                 `json:"tls_key"`
+	AllowedOrigins             []string               `json:"allowed_origins"`
+	ReadTimeout                duration               `json:"read_timeout"`
+	WriteTimeout               duration               `json:"write_timeout"`
+	IdleTimeout                duration               `json:"idle_timeout"`
 	ReadOnly                   bool                   `json:"read_only"`
 	Timeout                    duration               `json:"timeout"`
 	LoadSize                   int                    `json:"load_size"`
 	RequiresHTTPRequestContext bool                   `json:"http_request_context"`
+	Auth                       []Credential           `json:"auth"`
+	StoredQueries              string                 `json:"stored_queries"`
 }
 
 func (c *Config) UnmarshalJSON(data []byte) error {
@@ -65,10 +90,18 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		ReplicationOptions:         t.ReplicationOptions,
 		ListenHost:                 t.ListenHost,
 		ListenPort:                 t.ListenPort,
+		TLSCertFile:                t.TLSCertFile,
+		TLSKeyFile:                 t.TLSKeyFile,
+		AllowedOrigins:             t.AllowedOrigins,
+		ReadTimeout:                time.Duration(t.ReadTimeout),
+		WriteTimeout:               time.Duration(t.WriteTimeout),
+		IdleTimeout:                time.Duration(t.IdleTimeout),
 		ReadOnly:                   t.ReadOnly,
 		Timeout:                    time.Duration(t.Timeout),
 		LoadSize:                   t.LoadSize,
 		RequiresHTTPRequestContext: t.RequiresHTTPRequestContext,
+		Auth:                       t.Auth,
+		StoredQueries:              t.StoredQueries,
 	}
 	return nil
 }
@@ -82,9 +115,17 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 		ReplicationOptions: c.ReplicationOptions,
 		ListenHost:         c.ListenHost,
 		ListenPort:         c.ListenPort,
+		TLSCertFile:        c.TLSCertFile,
+		TLSKeyFile:         c.TLSKeyFile,
+		AllowedOrigins:     c.AllowedOrigins,
+		ReadTimeout:        duration(c.ReadTimeout),
+		WriteTimeout:       duration(c.WriteTimeout),
+		IdleTimeout:        duration(c.IdleTimeout),
 		ReadOnly:           c.ReadOnly,
 		Timeout:            duration(c.Timeout),
 		LoadSize:           c.LoadSize,
+		Auth:               c.Auth,
+		StoredQueries:      c.StoredQueries,
 	})
 }
 
@@ -102,6 +143,9 @@ func (d *duration) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 	text := string(data)
+	if s, err := strconv.Unquote(text); err == nil {
+		text = s
+	}
 	t, err := time.ParseDuration(text)
 	if err == nil {
 		*d = duration(t)
@@ -22,13 +22,20 @@ import (
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/internal/config"
+	"github.com/google/cayley/internal/metrics"
 	"github.com/google/cayley/quad"
 )
 
 var ErrNotPersistent = errors.New("database type is not persistent")
 
+// IsPersistent returns whether the configured database keeps its data, so that
+// it does not need to be loaded on every start.
+func IsPersistent(cfg *config.Config) bool {
+	return graph.IsPersistentAt(cfg.DatabaseType, cfg.DatabasePath, cfg.DatabaseOptions)
+}
+
 func Init(cfg *config.Config) error {
-	if !graph.IsPersistent(cfg.DatabaseType) {
+	if !IsPersistent(cfg) {
 		return fmt.Errorf("ignoring unproductive database initialization request: %v", ErrNotPersistent)
 	}
 
@@ -64,7 +71,76 @@ func OpenQuadWriter(qs graph.QuadStore, cfg *config.Config) (graph.QuadWriter, e
 		return nil, err
 	}
 
-	return w, nil
+	return &countingWriter{QuadWriter: w, name: cfg.ReplicationType}, nil
+}
+
+var (
+	quadsWritten = metrics.NewCounter("cayley_quads_written_total",
+		"Number of quads written, by replication method.", "writer")
+	quadsDeleted = metrics.NewCounter("cayley_quads_deleted_total",
+		"Number of quads deleted, by replication method.", "writer")
+)
+
+// countingWriter counts the quads written and deleted by a QuadWriter.
+type countingWriter struct {
+	graph.QuadWriter
+	name string
+}
+
+func (w *countingWriter) WriteQuad(q quad.Quad) error {
+	err := w.QuadWriter.WriteQuad(q)
+	if err == nil {
+		quadsWritten.Inc(w.name)
+	}
+	return err
+}
+
+func (w *countingWriter) AddQuad(q quad.Quad) error {
+	err := w.QuadWriter.AddQuad(q)
+	if err == nil {
+		quadsWritten.Inc(w.name)
+	}
+	return err
+}
+
+func (w *countingWriter) WriteQuads(quads []quad.Quad) (int, error) {
+	n, err := w.QuadWriter.WriteQuads(quads)
+	quadsWritten.Add(float64(n), w.name)
+	return n, err
+}
+
+func (w *countingWriter) AddQuadSet(quads []quad.Quad) error {
+	err := w.QuadWriter.AddQuadSet(quads)
+	if err == nil {
+		quadsWritten.Add(float64(len(quads)), w.name)
+	}
+	return err
+}
+
+func (w *countingWriter) RemoveQuad(q quad.Quad) error {
+	err := w.QuadWriter.RemoveQuad(q)
+	if err == nil {
+		quadsDeleted.Inc(w.name)
+	}
+	return err
+}
+
+func (w *countingWriter) ApplyTransaction(t *graph.Transaction) error {
+	if err := w.QuadWriter.ApplyTransaction(t); err != nil {
+		return err
+	}
+	var added, deleted int
+	for i := range t.Deltas {
+		switch t.Deltas[i].Action {
+		case graph.Add:
+			added++
+		case graph.Delete:
+			deleted++
+		}
+	}
+	quadsWritten.Add(float64(added), w.name)
+	quadsDeleted.Add(float64(deleted), w.name)
+	return nil
 }
 
 type batchLogger struct {
@@ -15,6 +15,7 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -24,14 +25,18 @@ import (
 	"time"
 
 	"github.com/peterh/liner"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/internal/config"
 	"github.com/google/cayley/quad/cquads"
 	"github.com/google/cayley/query"
+	"github.com/google/cayley/query/datalog"
+	"github.com/google/cayley/query/graphql"
 	"github.com/google/cayley/query/gremlin"
 	"github.com/google/cayley/query/mql"
 	"github.com/google/cayley/query/sexp"
+	"github.com/google/cayley/query/sparql"
 )
 
 func trace(s string) (string, time.Time) {
@@ -44,7 +49,7 @@ func un(s string, startTime time.Time) {
 	fmt.Printf(s, float64(endTime.UnixNano()-startTime.UnixNano())/float64(1E6))
 }
 
-func Run(query string, ses query.Session) {
+func Run(ctx context.Context, query string, ses query.Session) {
 	nResults := 0
 	startTrace, startTime := trace("Elapsed time: %g ms\n\n")
 	defer func() {
@@ -54,7 +59,7 @@ func Run(query string, ses query.Session) {
 	}()
 	fmt.Printf("\n")
 	c := make(chan interface{}, 5)
-	go ses.Execute(query, c, 100)
+	go ses.Execute(ctx, query, c, 100)
 	for res := range c {
 		fmt.Print(ses.Format(res))
 		nResults++
@@ -82,6 +87,12 @@ func Repl(h *graph.Handle, queryLanguage string, cfg *config.Config) error {
 		ses = sexp.NewSession(h.QuadStore)
 	case "mql":
 		ses = mql.NewSession(h.QuadStore)
+	case "sparql":
+		ses = sparql.NewSession(h.QuadStore)
+	case "graphql":
+		ses = graphql.NewSession(h.QuadStore)
+	case "datalog":
+		ses = datalog.NewSession(h.QuadStore)
 	case "gremlin":
 		fallthrough
 	default:
@@ -145,6 +156,10 @@ func Repl(h *graph.Handle, queryLanguage string, cfg *config.Config) error {
 				fmt.Printf("Debug set to %t\n", debug)
 				continue
 
+			case ":explain":
+				explain(ses, args, cfg)
+				continue
+
 			case ":a":
 				quad, err := cquads.Parse(args)
 				if err != nil {
@@ -184,7 +199,9 @@ func Repl(h *graph.Handle, queryLanguage string, cfg *config.Config) error {
 		result, err := ses.Parse(code)
 		switch result {
 		case query.Parsed:
-			Run(code, ses)
+			ctx, cancel := queryContext(cfg)
+			Run(ctx, code, ses)
+			cancel()
 			code = ""
 		case query.ParseFail:
 			fmt.Println("Error: ", err)
@@ -194,6 +211,47 @@ func Repl(h *graph.Handle, queryLanguage string, cfg *config.Config) error {
 	}
 }
 
+// explain prints the iterator trees of the query in args, which is run and
+// timed when it starts with "analyze".
+func explain(ses query.Session, args string, cfg *config.Config) {
+	code := strings.TrimSpace(args)
+	analyze := false
+	if cmd, rest := splitLine(code); cmd == "analyze" {
+		analyze, code = true, strings.TrimSpace(rest)
+	}
+	switch result, err := ses.Parse(code); result {
+	case query.Parsed:
+	case query.ParseMore:
+		fmt.Println("Error: incomplete query")
+		return
+	default:
+		fmt.Println("Error: ", err)
+		return
+	}
+	ctx, cancel := queryContext(cfg)
+	defer cancel()
+	exp, err := query.Explain(ctx, ses, code, analyze)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return
+	}
+	b, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return
+	}
+	fmt.Printf("%s\n", b)
+}
+
+// queryContext returns a context for a single query that is cancelled
+// once the configured query timeout elapses.
+func queryContext(cfg *config.Config) (context.Context, context.CancelFunc) {
+	if cfg.Timeout > 0 {
+		return context.WithTimeout(context.Background(), cfg.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
 // Splits a line into a command and its arguments
 // e.g. ":a b c d ." will be split into ":a" and " b c d ."
 func splitLine(line string) (string, string) {
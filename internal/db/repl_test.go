@@ -34,6 +34,11 @@ var testSplitLines = []struct {
 		expectedCommand:   ":debug",
 		expectedArguments: " t",
 	},
+	{
+		line:              `:explain analyze g.V("alice").All()`,
+		expectedCommand:   ":explain",
+		expectedArguments: ` analyze g.V("alice").All()`,
+	},
 	{
 		line: "",
 		// expectedCommand is nil
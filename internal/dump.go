@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
 
 	// Register all supported formats for encoding
@@ -40,7 +41,8 @@ func Dump(qs graph.QuadStore, outFile, typ string) error {
 		defer gzip.Close()
 		w = gzip
 	}
-	qr := graph.NewQuadReader(qs) //TODO: add possible support for exporting specific queries only
+	qr := graph.NewQuadReader(qs)
+	defer qr.Close()
 
 	if typ == "quad" { // compatibility
 		typ = "nquads"
@@ -51,13 +53,8 @@ func Dump(qs graph.QuadStore, outFile, typ string) error {
 	} else if format.Writer == nil {
 		return fmt.Errorf("format %q: encoding is not supported", typ)
 	}
-	qw := format.Writer(w)
-	count, err := quad.Copy(qw, qr)
+	count, err := WriteQuads(w, qr, format)
 	if err != nil {
-		qw.Close()
-		return err
-	}
-	if err = qw.Close(); err != nil {
 		return err
 	}
 	if outFile != "-" {
@@ -65,3 +62,30 @@ func Dump(qs graph.QuadStore, outFile, typ string) error {
 	}
 	return nil
 }
+
+// WriteQuads encodes all the quads of the reader in the format, and returns
+// the number of quads written.
+func WriteQuads(w io.Writer, qr quad.Reader, format *quad.Format) (int, error) {
+	qw := format.Writer(w)
+	count, err := quad.Copy(qw, qr)
+	if err != nil {
+		qw.Close()
+		return count, err
+	}
+	return count, qw.Close()
+}
+
+// NewSubgraphReader returns a reader of the quads which have one of the nodes
+// as their subject or object.
+func NewSubgraphReader(qs graph.QuadStore, nodes []graph.Value) quad.ReadCloser {
+	or := iterator.NewOr()
+	for _, d := range []quad.Direction{quad.Subject, quad.Object} {
+		fixed := qs.FixedIterator()
+		for _, v := range nodes {
+			fixed.Add(v)
+		}
+		or.AddSubIterator(iterator.NewLinksTo(qs, fixed, d))
+	}
+	it, _ := iterator.NewUnique(or).Optimize()
+	return graph.NewQuadReaderWithIterator(qs, it)
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+
+	_ "github.com/google/cayley/graph/memstore"
+	_ "github.com/google/cayley/writer"
+)
+
+func TestSubgraphReader(t *testing.T) {
+	qs, _ := graph.NewQuadStore("memstore", "", nil)
+	w, _ := graph.NewQuadWriter("single", qs, nil)
+	w.AddQuadSet([]quad.Quad{
+		quad.Make("alice", "follows", "bob", ""),
+		quad.Make("bob", "follows", "bob", ""),
+		quad.Make("bob", "status", "cool", ""),
+		quad.Make("charlie", "follows", "dani", ""),
+	})
+	qr := NewSubgraphReader(qs, []graph.Value{qs.ValueOf(quad.Raw("bob"))})
+	defer qr.Close()
+	var got []string
+	for {
+		q, err := qr.ReadQuad()
+		if err != nil {
+			break
+		}
+		got = append(got, q.NQuad())
+	}
+	sort.Strings(got)
+	expect := []string{
+		`alice follows bob .`,
+		`bob follows bob .`,
+		`bob status cool .`,
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Unexpected quads, got: %q expected: %q", got, expect)
+	}
+}
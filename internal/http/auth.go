@@ -0,0 +1,127 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/google/cayley/internal/config"
+)
+
+// Role is the level of access granted to a client.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleRead
+	RoleWrite
+	RoleAdmin
+)
+
+var roleNames = map[string]Role{
+	"read":  RoleRead,
+	"write": RoleWrite,
+	"admin": RoleAdmin,
+}
+
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// ErrUnauthenticated is returned by an Authenticator when the credentials of a
+// request are missing or invalid.
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Authenticator returns the role of the client sending a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Role, error)
+}
+
+// staticAuth authenticates clients with the credentials of the config file.
+type staticAuth struct {
+	creds []config.Credential
+	roles []Role
+}
+
+// NewStaticAuth returns an Authenticator accepting the given bearer tokens and
+// basic credentials.
+func NewStaticAuth(creds []config.Credential) (Authenticator, error) {
+	a := &staticAuth{creds: creds}
+	for i, c := range creds {
+		role, ok := roleNames[c.Role]
+		if !ok {
+			return nil, fmt.Errorf("auth: unknown role %q", c.Role)
+		}
+		if (c.Token == "") == (c.User == "") {
+			return nil, fmt.Errorf("auth: credential %d must have either a token or a user", i)
+		}
+		a.roles = append(a.roles, role)
+	}
+	return a, nil
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (a *staticAuth) Authenticate(r *http.Request) (Role, error) {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token := strings.TrimSpace(h[len("Bearer "):])
+		for i, c := range a.creds {
+			if c.Token != "" && secureEqual(c.Token, token) {
+				return a.roles[i], nil
+			}
+		}
+		return RoleNone, ErrUnauthenticated
+	}
+	if user, pass, ok := r.BasicAuth(); ok {
+		for i, c := range a.creds {
+			if c.User != "" && secureEqual(c.User, user) && secureEqual(c.Password, pass) {
+				return a.roles[i], nil
+			}
+		}
+	}
+	return RoleNone, ErrUnauthenticated
+}
+
+// authorize only calls the handler for the clients which have at least the
+// given role. All the clients are allowed when authentication is disabled.
+func (api *API) authorize(role Role, handler ResponseHandler) ResponseHandler {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
+		if api.auth == nil {
+			return handler(w, r, params)
+		}
+		got, err := api.auth.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="cayley"`)
+			return jsonResponse(w, http.StatusUnauthorized, err)
+		}
+		if got < role {
+			return jsonResponse(w, http.StatusForbidden, fmt.Sprintf("%s access is required.", role))
+		}
+		return handler(w, r, params)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/cayley/internal/config"
+)
+
+var testCredentials = []config.Credential{
+	{Token: "reader-token", Role: "read"},
+	{Token: "admin-token", Role: "admin"},
+	{User: "loader", Password: "l0ad3r", Role: "write"},
+}
+
+const (
+	writeBody      = `[{"subject": "alice", "predicate": "follows", "object": "greg"}]`
+	namedQueryBody = `{"lang": "gremlin", "query": "g.V().All()"}`
+)
+
+func bearer(token string) string {
+	return "Bearer " + token
+}
+
+func basic(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+var authTests = []struct {
+	message       string
+	method, path  string
+	body          string
+	authorization string
+	code          int
+}{
+	{
+		message: "read without credentials",
+		method:  "GET", path: "/api/v1/quads",
+		code: 401,
+	},
+	{
+		message: "read with a wrong token",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: bearer("wrong-token"),
+		code:          401,
+	},
+	{
+		message: "read with a wrong password",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: basic("loader", "wrong"),
+		code:          401,
+	},
+	{
+		message: "read with an unknown user",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: basic("nobody", "l0ad3r"),
+		code:          401,
+	},
+	{
+		message: "read with a token as basic credentials",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: basic("", "reader-token"),
+		code:          401,
+	},
+	{
+		message: "read with a bearer token",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: bearer("reader-token"),
+		code:          200,
+	},
+	{
+		message: "query with a bearer token",
+		method:  "POST", path: "/api/v1/query/gremlin",
+		body:          followsBobGremlin,
+		authorization: bearer("reader-token"),
+		code:          200,
+	},
+	{
+		message: "read with basic credentials of a writer",
+		method:  "GET", path: "/api/v1/quads",
+		authorization: basic("loader", "l0ad3r"),
+		code:          200,
+	},
+	{
+		message: "write with basic credentials",
+		method:  "POST", path: "/api/v1/write",
+		body:          writeBody,
+		authorization: basic("loader", "l0ad3r"),
+		code:          200,
+	},
+	{
+		message: "write with a read token",
+		method:  "POST", path: "/api/v1/write",
+		body:          writeBody,
+		authorization: bearer("reader-token"),
+		code:          403,
+	},
+	{
+		message: "write without credentials",
+		method:  "POST", path: "/api/v1/write",
+		body: writeBody,
+		code: 401,
+	},
+	{
+		message: "store a query with a read token",
+		method:  "PUT", path: "/api/v1/query/named/all",
+		body:          namedQueryBody,
+		authorization: bearer("reader-token"),
+		code:          403,
+	},
+	{
+		message: "store a query with basic credentials of a writer",
+		method:  "PUT", path: "/api/v1/query/named/all",
+		body:          namedQueryBody,
+		authorization: basic("loader", "l0ad3r"),
+		code:          403,
+	},
+	{
+		message: "store a query with an admin token",
+		method:  "PUT", path: "/api/v1/query/named/all",
+		body:          namedQueryBody,
+		authorization: bearer("admin-token"),
+		code:          200,
+	},
+}
+
+func TestAuth(t *testing.T) {
+	for _, test := range authTests {
+		_, r := makeTestAPI(t, &config.Config{Auth: testCredentials}, readTestData(t))
+		var headers []string
+		if test.authorization != "" {
+			headers = []string{"Authorization", test.authorization}
+		}
+		w := serve(r, test.method, test.path, test.body, headers...)
+		if w.Code != test.code {
+			t.Errorf("Failed to %s, got status:%d expect:%d (%s)", test.message, w.Code, test.code, w.Body)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); (challenge != "") != (test.code == 401) {
+			t.Errorf("Failed to %s, unexpected challenge: %q", test.message, challenge)
+		}
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	_, r := makeTestAPI(t, nil, readTestData(t))
+	if w := serve(r, "POST", "/api/v1/write", writeBody); w.Code != 200 {
+		t.Errorf("Unexpected status without auth: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestStaticAuthConfig(t *testing.T) {
+	for _, creds := range [][]config.Credential{
+		{{Token: "token", Role: "root"}},
+		{{Token: "token", User: "user", Password: "pass", Role: "read"}},
+		{{Password: "pass", Role: "read"}},
+	} {
+		if _, err := NewStaticAuth(creds); err == nil {
+			t.Errorf("Expected an error for the credentials %+v", creds)
+		}
+	}
+}
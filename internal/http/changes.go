@@ -0,0 +1,241 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+)
+
+const (
+	// maxChangesPage is the maximal number of changes of a response.
+	maxChangesPage = 1000
+	// changesPollInterval is the interval at which the delta log is read
+	// while waiting for changes. The writes made through the API wake the
+	// waiting requests at once, so it only delays the changes made by other
+	// processes sharing the quad store.
+	changesPollInterval = time.Second
+)
+
+// writeNotifier wakes the requests waiting for changes when quads are written
+// through the API.
+type writeNotifier struct {
+	mu      sync.Mutex
+	written chan struct{}
+}
+
+// wait returns a channel which is closed by the next write.
+func (n *writeNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.written == nil {
+		n.written = make(chan struct{})
+	}
+	return n.written
+}
+
+func (n *writeNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.written != nil {
+		close(n.written)
+		n.written = nil
+	}
+}
+
+// notifyingWriter is a QuadWriter which notifies its writes, whether they
+// succeed or not.
+type notifyingWriter struct {
+	graph.QuadWriter
+	n *writeNotifier
+}
+
+func (w notifyingWriter) WriteQuad(q quad.Quad) error {
+	defer w.n.notify()
+	return w.QuadWriter.WriteQuad(q)
+}
+
+func (w notifyingWriter) WriteQuads(buf []quad.Quad) (int, error) {
+	defer w.n.notify()
+	return w.QuadWriter.WriteQuads(buf)
+}
+
+func (w notifyingWriter) AddQuad(q quad.Quad) error {
+	defer w.n.notify()
+	return w.QuadWriter.AddQuad(q)
+}
+
+func (w notifyingWriter) AddQuadSet(quads []quad.Quad) error {
+	defer w.n.notify()
+	return w.QuadWriter.AddQuadSet(quads)
+}
+
+func (w notifyingWriter) RemoveQuad(q quad.Quad) error {
+	defer w.n.notify()
+	return w.QuadWriter.RemoveQuad(q)
+}
+
+func (w notifyingWriter) ApplyTransaction(tx *graph.Transaction) error {
+	defer w.n.notify()
+	return w.QuadWriter.ApplyTransaction(tx)
+}
+
+// change is a delta of the log, with the action names of transactions.
+type change struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Quad      quad.Quad `json:"quad"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// changesResult is a page of changes. Horizon is the ID of the last change,
+// from which the next page starts.
+type changesResult struct {
+	Result  []change `json:"result"`
+	Horizon int64    `json:"horizon"`
+}
+
+// nextChanges returns the changes logged after the horizon. If there is none,
+// the log is read again after every write, and at least every poll interval,
+// until there are some or the context is done.
+func nextChanges(ctx context.Context, dl graph.DeltaLog, writes *writeNotifier, horizon int64, limit int) ([]change, error) {
+	for {
+		// Wait for the writes from before the log is read, so that none is
+		// missed.
+		written := writes.wait()
+		key := graph.NewSequentialKey(horizon)
+		deltas, err := dl.DeltasSince(&key, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(deltas) > 0 {
+			changes := make([]change, 0, len(deltas))
+			for i := range deltas {
+				d := &deltas[i]
+				action := "add"
+				if d.Action == graph.Delete {
+					action = "delete"
+				}
+				changes = append(changes, change{
+					ID:        d.ID.Int(),
+					Action:    action,
+					Quad:      d.Quad,
+					Timestamp: d.Timestamp,
+				})
+			}
+			return changes, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-written:
+		case <-time.After(changesPollInterval):
+		}
+	}
+}
+
+// ServeV1Changes returns the changes to the quad store after a horizon, so that
+// clients can follow the writes. A request either waits for changes for a
+// while, or streams them as they are logged until the client goes away.
+func (api *API) ServeV1Changes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	dl, ok := h.QuadStore.(graph.DeltaLog)
+	if !ok {
+		return jsonResponse(w, 400, "The quad store does not keep a log of changes.")
+	}
+	params := r.URL.Query()
+	var horizon int64
+	s := params.Get("since")
+	if s == "" {
+		// Set by the browsers which reconnect to an event stream.
+		s = r.Header.Get("Last-Event-ID")
+	}
+	if s != "" {
+		horizon, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || horizon < 0 {
+			return jsonResponse(w, 400, "Invalid since.")
+		}
+	}
+	limit := maxChangesPage
+	if s := params.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return jsonResponse(w, 400, "Invalid limit.")
+		}
+		if limit > maxChangesPage {
+			limit = maxChangesPage
+		}
+	}
+	var wait time.Duration
+	if s := params.Get("wait"); s != "" {
+		wait, err = time.ParseDuration(s)
+		if err != nil {
+			return jsonResponse(w, 400, err)
+		}
+	}
+
+	if ct := streamType(r); ct != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cancelOnClose(w, ctx, cancel)
+		rw := newResultWriter(w, ct)
+		for ctx.Err() == nil {
+			changes, err := nextChanges(ctx, dl, &api.writes, horizon, limit)
+			if err != nil {
+				rw.writeError(err)
+				break
+			}
+			for _, c := range changes {
+				if err := rw.writeID("change", strconv.FormatInt(c.ID, 10), c); err != nil {
+					return 200
+				}
+				horizon = c.ID
+			}
+		}
+		return 200
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	cancelOnClose(w, ctx, cancel)
+	changes, err := nextChanges(ctx, dl, &api.writes, horizon, limit)
+	if err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	if n := len(changes); n > 0 {
+		horizon = changes[n-1].ID
+	} else {
+		changes = []change{}
+	}
+	bytes, err := json.MarshalIndent(changesResult{Result: changes, Horizon: horizon}, "", " ")
+	if err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+	return 200
+}
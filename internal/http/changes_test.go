@@ -0,0 +1,164 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/quad"
+)
+
+// The quad written by writeBody.
+var writtenQuad = quad.Make("alice", "follows", "greg", "")
+
+func getChanges(t *testing.T, r *httprouter.Router, path string, headers ...string) changesResult {
+	return decodeChanges(t, path, serve(r, "GET", path, "", headers...))
+}
+
+func decodeChanges(t *testing.T, path string, w *httptest.ResponseRecorder) changesResult {
+	if w.Code != 200 {
+		t.Fatalf("Unexpected status for %q: %d %s", path, w.Code, w.Body)
+	}
+	var res changesResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Failed to decode the changes of %q: %v", path, err)
+	}
+	return res
+}
+
+func TestChangesLongPoll(t *testing.T) {
+	data := readTestData(t)
+	_, r := makeTestAPI(t, nil, data)
+
+	res := getChanges(t, r, "/api/v1/changes")
+	if len(res.Result) != len(data) || res.Horizon != res.Result[len(data)-1].ID {
+		t.Fatalf("Unexpected changes of the initial quads: %d changes, horizon %d", len(res.Result), res.Horizon)
+	}
+	horizon := res.Horizon
+
+	// Nothing is written while waiting.
+	res = getChanges(t, r, fmt.Sprintf("/api/v1/changes?since=%d&wait=50ms", horizon))
+	if len(res.Result) != 0 || res.Horizon != horizon {
+		t.Errorf("Unexpected changes without writes: %+v", res)
+	}
+
+	path := fmt.Sprintf("/api/v1/changes?since=%d&wait=1m", horizon)
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- serve(r, "GET", path, "")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	written := time.Now()
+	if w := serve(r, "POST", "/api/v1/write", writeBody); w.Code != 200 {
+		t.Fatalf("Failed to write: %d %s", w.Code, w.Body)
+	}
+	select {
+	case w := <-done:
+		res = decodeChanges(t, path, w)
+	case <-time.After(10 * time.Second):
+		t.Fatal("The change was not sent to the waiting request.")
+	}
+	if d := time.Since(written); d >= changesPollInterval/2 {
+		t.Errorf("The waiting request was not woken by the write, it took %v", d)
+	}
+	if len(res.Result) != 1 || res.Horizon != horizon+1 {
+		t.Fatalf("Unexpected changes after the write: %+v", res)
+	}
+	if c := res.Result[0]; c.ID != horizon+1 || c.Action != "add" || c.Quad != writtenQuad {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+}
+
+// readEvent reads the ID and the data of the next server-sent event.
+func readEvent(t *testing.T, sc *bufio.Scanner) (int64, change) {
+	var (
+		id int64
+		c  change
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			return id, c
+		case strings.HasPrefix(line, "id: "):
+			var err error
+			if id, err = strconv.ParseInt(line[len("id: "):], 10, 64); err != nil {
+				t.Fatalf("Invalid event ID: %q", line)
+			}
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &c); err != nil {
+				t.Fatalf("Failed to decode the change %q: %v", line, err)
+			}
+		case line != "event: change":
+			t.Fatalf("Unexpected line in event stream: %q", line)
+		}
+	}
+	t.Fatalf("Stream ended early: %v", sc.Err())
+	return 0, c
+}
+
+func TestChangesResume(t *testing.T) {
+	data := readTestData(t)
+	_, r := makeTestAPI(t, nil, data)
+	horizon := getChanges(t, r, "/api/v1/changes").Horizon
+
+	// A long poll resumes from the last event too.
+	res := getChanges(t, r, "/api/v1/changes", "Last-Event-ID", strconv.FormatInt(horizon-3, 10))
+	if len(res.Result) != 3 || res.Result[0].ID != horizon-2 || res.Horizon != horizon {
+		t.Errorf("Unexpected changes after the last event: %+v", res)
+	}
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", srv.URL+"/api/v1/changes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", contentTypeSSE)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(horizon-2, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	for _, expect := range []int64{horizon - 1, horizon} {
+		if id, c := readEvent(t, sc); id != expect || c.ID != expect {
+			t.Fatalf("Unexpected event %d, expect:%d (%+v)", id, expect, c)
+		}
+	}
+
+	// The stream goes on with the changes written after it started.
+	if w := serve(r, "POST", "/api/v1/write", writeBody); w.Code != 200 {
+		t.Fatalf("Failed to write: %d %s", w.Code, w.Body)
+	}
+	if id, c := readEvent(t, sc); id != horizon+1 || c.Quad != writtenQuad {
+		t.Errorf("Unexpected event %d after the write: %+v", id, c)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+var corsTests = []struct {
+	message   string
+	origins   []string
+	method    string
+	headers   []string
+	allowed   string // The expected Access-Control-Allow-Origin header.
+	preflight bool
+}{
+	{
+		message: "ignore cross-origin requests without allowed origins",
+		method:  "GET",
+		headers: []string{"Origin", "https://example.com"},
+	},
+	{
+		message: "allow a listed origin",
+		origins: []string{"https://other.com", "https://example.com/"},
+		method:  "GET",
+		headers: []string{"Origin", "https://example.com"},
+		allowed: "https://example.com",
+	},
+	{
+		message: "refuse an origin which is not listed",
+		origins: []string{"https://example.com"},
+		method:  "POST",
+		headers: []string{"Origin", "https://evil.com"},
+	},
+	{
+		message: "allow any origin",
+		origins: []string{"*"},
+		method:  "POST",
+		headers: []string{"Origin", "https://evil.com"},
+		allowed: "https://evil.com",
+	},
+	{
+		message: "serve same-origin requests",
+		origins: []string{"*"},
+		method:  "GET",
+	},
+	{
+		message:   "answer a preflight request",
+		origins:   []string{"https://example.com"},
+		method:    "OPTIONS",
+		headers:   []string{"Origin", "https://example.com", "Access-Control-Request-Method", "PUT"},
+		allowed:   "https://example.com",
+		preflight: true,
+	},
+	{
+		message: "refuse a preflight request from an origin which is not listed",
+		origins: []string{"https://example.com"},
+		method:  "OPTIONS",
+		headers: []string{"Origin", "https://evil.com", "Access-Control-Request-Method", "PUT"},
+	},
+	{
+		message: "serve an OPTIONS request which is not a preflight request",
+		origins: []string{"https://example.com"},
+		method:  "OPTIONS",
+		headers: []string{"Origin", "https://example.com"},
+		allowed: "https://example.com",
+	},
+}
+
+func TestAllowOrigins(t *testing.T) {
+	for _, test := range corsTests {
+		served := false
+		h := allowOrigins(test.origins, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = true
+		}))
+		w := serve(h, test.method, "/api/v1/quads", "", test.headers...)
+		hdr := w.Header()
+		if got := hdr.Get("Access-Control-Allow-Origin"); got != test.allowed {
+			t.Errorf("Failed to %s, got allowed origin:%q expect:%q", test.message, got, test.allowed)
+		}
+		if test.allowed != "" && hdr.Get("Vary") != "Origin" {
+			t.Errorf("Failed to %s, the response does not vary by origin", test.message)
+		}
+		if served == test.preflight {
+			t.Errorf("Failed to %s, got served:%v expect:%v", test.message, served, !test.preflight)
+		}
+		if test.preflight {
+			if w.Code != http.StatusNoContent {
+				t.Errorf("Failed to %s, got status:%d", test.message, w.Code)
+			}
+			if hdr.Get("Access-Control-Allow-Methods") == "" || hdr.Get("Access-Control-Allow-Headers") == "" {
+				t.Errorf("Failed to %s, missing headers: %v", test.message, hdr)
+			}
+		}
+	}
+}
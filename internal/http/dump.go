@@ -0,0 +1,85 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/internal"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query/gremlin"
+)
+
+// acceptsGzip returns whether the client accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if i := strings.Index(enc, ";"); i >= 0 {
+			enc = enc[:i]
+		}
+		if strings.TrimSpace(enc) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeV1Dump writes the quads of the database, in the format accepted by the
+// client. When a Gremlin query is given, only the quads having one of the
+// nodes it results in as their subject or object are written.
+func (api *API) ServeV1Dump(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	format, contentType := quadFormat(r)
+	if format == nil {
+		return jsonResponse(w, http.StatusNotAcceptable, "No acceptable quad format.")
+	}
+	var qr quad.ReadCloser
+	if q := r.URL.Query().Get("query"); q != "" {
+		ctx, cancel := api.queryContext(w)
+		ses := gremlin.NewSession(h.QuadStore, api.config.Timeout, false)
+		nodes, err := ses.Nodes(ctx, q)
+		cancel()
+		if err != nil {
+			return jsonResponse(w, 400, err)
+		}
+		qr = internal.NewSubgraphReader(h.QuadStore, nodes)
+	} else {
+		qr = graph.NewQuadReader(h.QuadStore)
+	}
+	defer qr.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	if _, err = internal.WriteQuads(out, qr, format); err != nil {
+		// The status is sent already.
+		glog.Errorf("failed to dump quads: %v", err)
+	}
+	return 200
+}
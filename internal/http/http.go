@@ -20,10 +20,13 @@ import (
 	"html/template"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/internal/config"
@@ -80,7 +83,9 @@ func LogRequest(handler ResponseHandler) httprouter.Handle {
 		}
 		glog.Infof("Started %s %s for %s", req.Method, req.URL.Path, addr)
 		code := handler(w, req, params)
-		glog.Infof("Completed %v %s %s in %v", code, http.StatusText(code), req.URL.Path, time.Since(start))
+		d := time.Since(start)
+		observeRequest(req, params, code, d)
+		glog.Infof("Completed %v %s %s in %v", code, http.StatusText(code), req.URL.Path, d)
 
 	}
 }
@@ -106,13 +111,19 @@ func (h *TemplateRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 }
 
 type API struct {
-	config *config.Config
-	handle *graph.Handle
+	config  *config.Config
+	handle  *graph.Handle
+	auth    Authenticator
+	queries *storedQueries
+	writes  writeNotifier
 }
 
 func (api *API) GetHandleForRequest(r *http.Request) (*graph.Handle, error) {
 	if !api.config.RequiresHTTPRequestContext {
-		return api.handle, nil
+		return &graph.Handle{
+			QuadStore:  api.handle.QuadStore,
+			QuadWriter: notifyingWriter{api.handle.QuadWriter, &api.writes},
+		}, nil
 	}
 
 	opts := make(graph.Options)
@@ -126,16 +137,26 @@ func (api *API) GetHandleForRequest(r *http.Request) (*graph.Handle, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
+	return &graph.Handle{QuadStore: qs, QuadWriter: notifyingWriter{qw, &api.writes}}, nil
 }
 
 func (api *API) APIv1(r *httprouter.Router) {
-	r.POST("/api/v1/query/:query_lang", LogRequest(api.ServeV1Query))
-	r.POST("/api/v1/shape/:query_lang", LogRequest(api.ServeV1Shape))
-	r.POST("/api/v1/write", LogRequest(api.ServeV1Write))
-	r.POST("/api/v1/write/file/nquad", LogRequest(api.ServeV1WriteNQuad))
+	r.POST("/api/v1/query/:query_lang", LogRequest(api.authorize(RoleRead, api.ServeV1Query)))
+	r.POST("/api/v1/query/:query_lang/:name", LogRequest(api.authorize(RoleRead, api.ServeV1NamedQuery)))
+	r.GET("/api/v1/query/named", LogRequest(api.authorize(RoleRead, api.ServeV1ListNamedQueries)))
+	r.PUT("/api/v1/query/named/:name", LogRequest(api.authorize(RoleAdmin, api.ServeV1PutNamedQuery)))
+	r.DELETE("/api/v1/query/named/:name", LogRequest(api.authorize(RoleAdmin, api.ServeV1DeleteNamedQuery)))
+	r.POST("/api/v1/shape/:query_lang", LogRequest(api.authorize(RoleRead, api.ServeV1Shape)))
+	r.POST("/api/v1/explain/:query_lang", LogRequest(api.authorize(RoleRead, api.ServeV1Explain)))
+	r.POST("/api/v1/write", LogRequest(api.authorize(RoleWrite, api.ServeV1Write)))
+	r.POST("/api/v1/write/file/nquad", LogRequest(api.authorize(RoleWrite, api.ServeV1WriteNQuad)))
 	//TODO(barakmich): /write/text/nquad, which reads from request.body instead of HTML5 file form?
-	r.POST("/api/v1/delete", LogRequest(api.ServeV1Delete))
+	r.POST("/api/v1/delete", LogRequest(api.authorize(RoleWrite, api.ServeV1Delete)))
+	r.POST("/api/v1/tx", LogRequest(api.authorize(RoleWrite, api.ServeV1Tx)))
+	r.GET("/api/v1/quads", LogRequest(api.authorize(RoleRead, api.ServeV1Quads)))
+	r.GET("/api/v1/dump", LogRequest(api.authorize(RoleRead, api.ServeV1Dump)))
+	r.GET("/api/v1/changes", LogRequest(api.authorize(RoleRead, api.ServeV1Changes)))
+	r.GET("/metrics", LogRequest(api.authorize(RoleAdmin, api.ServeMetrics)))
 }
 
 func SetupRoutes(handle *graph.Handle, cfg *config.Config) {
@@ -149,6 +170,18 @@ func SetupRoutes(handle *graph.Handle, cfg *config.Config) {
 	root := &TemplateRequestHandler{templates: templates}
 	docs := &DocRequestHandler{assets: assets}
 	api := &API{config: cfg, handle: handle}
+	if len(cfg.Auth) > 0 {
+		auth, err := NewStaticAuth(cfg.Auth)
+		if err != nil {
+			glog.Fatalln(err)
+		}
+		api.auth = auth
+	}
+	queries, err := loadStoredQueries(cfg.StoredQueries)
+	if err != nil {
+		glog.Fatalln(err)
+	}
+	api.queries = queries
 	api.APIv1(r)
 
 	//m.Use(martini.Static("static", martini.StaticOptions{Prefix: "/static", SkipLogging: true}))
@@ -160,12 +193,62 @@ func SetupRoutes(handle *graph.Handle, cfg *config.Config) {
 	http.Handle("/", r)
 }
 
-func Serve(handle *graph.Handle, cfg *config.Config) {
+// Serve runs the HTTP server until the process is interrupted or terminated.
+// The requests in flight are then drained before Serve returns, so that the
+// database can be closed cleanly; a second signal stops draining them.
+func Serve(handle *graph.Handle, cfg *config.Config) error {
 	SetupRoutes(handle, cfg)
-	glog.Infof("Cayley now listening on %s:%s\n", cfg.ListenHost, cfg.ListenPort)
-	fmt.Printf("Cayley now listening on %s:%s\n", cfg.ListenHost, cfg.ListenPort)
-	err := http.ListenAndServe(fmt.Sprintf("%s:%s", cfg.ListenHost, cfg.ListenPort), nil)
-	if err != nil {
-		glog.Fatal("ListenAndServe: ", err)
+	if cfg.WriteTimeout > 0 {
+		glog.Warningf("The write timeout of %v cuts off streamed results and the change feed.", cfg.WriteTimeout)
+	}
+	srv := &http.Server{
+		Addr:         fmt.Sprintf("%s:%s", cfg.ListenHost, cfg.ListenPort),
+		Handler:      allowOrigins(cfg.AllowedOrigins, http.DefaultServeMux),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan error, 1)
+	go func() {
+		select {
+		case <-sig:
+		case <-stop:
+			return
+		}
+		glog.Infoln("Shutting down, draining the requests in flight.")
+		fmt.Println("Shutting down, draining the requests in flight.")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-sig:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		done <- srv.Shutdown(ctx)
+	}()
+
+	scheme := "http"
+	if cfg.TLSCertFile != "" {
+		scheme = "https"
+	}
+	glog.Infof("Cayley now listening on %s://%s\n", scheme, srv.Addr)
+	fmt.Printf("Cayley now listening on %s://%s\n", scheme, srv.Addr)
+	var err error
+	if cfg.TLSCertFile != "" {
+		err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
 	}
+	return <-done
 }
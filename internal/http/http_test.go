@@ -0,0 +1,110 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/internal/config"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/quad/cquads"
+
+	_ "github.com/google/cayley/graph/memstore"
+	_ "github.com/google/cayley/writer"
+)
+
+// readTestData reads the quads of data/testdata.nq.
+func readTestData(t testing.TB) []quad.Quad {
+	f, err := os.Open("../../data/testdata.nq")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+	quads, err := quad.ReadAll(cquads.NewDecoder(f))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	return quads
+}
+
+// makeTestAPI returns an API over a memstore holding the quads, and a router
+// serving its routes. A nil config is the default one.
+func makeTestAPI(t testing.TB, cfg *config.Config, quads []quad.Quad) (*API, *httprouter.Router) {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Minute
+	}
+	if cfg.ReplicationType == "" {
+		cfg.ReplicationType = "single"
+	}
+	qs, err := graph.NewQuadStore("memstore", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the quad store: %v", err)
+	}
+	qw, err := graph.NewQuadWriter(cfg.ReplicationType, qs, cfg.ReplicationOptions)
+	if err != nil {
+		t.Fatalf("Failed to create the quad writer: %v", err)
+	}
+	if len(quads) > 0 {
+		if _, err := qw.WriteQuads(quads); err != nil {
+			t.Fatalf("Failed to write the quads: %v", err)
+		}
+	}
+	queries, err := loadStoredQueries(cfg.StoredQueries)
+	if err != nil {
+		t.Fatalf("Failed to load the stored queries: %v", err)
+	}
+	api := &API{
+		config:  cfg,
+		handle:  &graph.Handle{QuadStore: qs, QuadWriter: qw},
+		queries: queries,
+	}
+	if len(cfg.Auth) > 0 {
+		api.auth, err = NewStaticAuth(cfg.Auth)
+		if err != nil {
+			t.Fatalf("Failed to set up auth: %v", err)
+		}
+	}
+	r := httprouter.New()
+	api.APIv1(r)
+	return api, r
+}
+
+// serve sends a request to the handler, with the headers given as pairs of
+// names and values, and returns the response.
+func serve(h http.Handler, method, url, body string, headers ...string) *httptest.ResponseRecorder {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, url, rd)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
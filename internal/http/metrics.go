@@ -0,0 +1,97 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/internal/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("cayley_http_requests_total",
+		"Number of HTTP API requests, by route, query language and status code.", "route", "lang", "code")
+	requestDuration = metrics.NewHistogram("cayley_http_request_duration_seconds",
+		"Latency of HTTP API requests, by route and query language.", metrics.DefaultBuckets, "route", "lang")
+	queryTimeouts = metrics.NewCounter("cayley_query_timeouts_total",
+		"Number of queries which timed out, by query language.", "lang")
+)
+
+// observeRequest records a request answered by an API handler. The route is
+// the path of the request without its query language and stored query name,
+// and the language is only kept if it is known, so that the number of series
+// is bounded.
+func observeRequest(r *http.Request, params httprouter.Params, code int, d time.Duration) {
+	route := r.URL.Path
+	if name := params.ByName("name"); name != "" {
+		route = strings.TrimSuffix(route, "/"+name)
+	}
+	lang := params.ByName("query_lang")
+	if lang != "" {
+		route = strings.TrimSuffix(route, "/"+lang)
+		if _, ok := languages[lang]; !ok && lang != namedLang {
+			lang = "unknown"
+		}
+	}
+	requestsTotal.Inc(route, lang, strconv.Itoa(code))
+	requestDuration.Observe(d.Seconds(), route, lang)
+}
+
+// ServeMetrics writes the metrics of the server and of its quad store, in the
+// Prometheus text format.
+func (api *API) ServeMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.Write(w)
+	metrics.WriteGauge(w, "cayley_quadstore_size", "Number of quads in the quad store.", float64(h.QuadStore.Size()))
+	if mr, ok := h.QuadStore.(graph.MetricsReporter); ok {
+		writeBackendMetrics(w, mr.Metrics())
+	}
+	return 200
+}
+
+// writeBackendMetrics writes the metrics of a quad store backend, grouped by
+// name as the text format requires.
+func writeBackendMetrics(w http.ResponseWriter, m map[string]float64) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	name := func(k string) string {
+		if i := strings.Index(k, "{"); i >= 0 {
+			return k[:i]
+		}
+		return k
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ni, nj := name(keys[i]), name(keys[j]); ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		w.Write([]byte("cayley_" + k + " " + strconv.FormatFloat(m[k], 'g', -1, 64) + "\n"))
+	}
+}
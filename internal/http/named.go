@@ -0,0 +1,332 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query"
+)
+
+// namedLang is the query language of the URL under which the stored queries
+// are run.
+const namedLang = "named"
+
+// StoredQuery is a query stored under a name, which is run with the values of
+// its parameters.
+type StoredQuery struct {
+	Lang   string   `json:"lang"`
+	Query  string   `json:"query"`
+	Params []string `json:"params,omitempty"`
+}
+
+// storedQuery is a stored query along with its compiled form, which is
+// prepared by the first call which runs it.
+type storedQuery struct {
+	StoredQuery
+
+	once     sync.Once
+	prepared interface{}
+	err      error
+}
+
+func (q *storedQuery) prepare(p query.Preparer) (interface{}, error) {
+	q.once.Do(func() {
+		q.prepared, q.err = p.Prepare(q.Query)
+	})
+	return q.prepared, q.err
+}
+
+// storedQueries holds the stored queries by name. They are saved to the file
+// at path, if it is set.
+type storedQueries struct {
+	path string
+
+	mu      sync.RWMutex
+	queries map[string]*storedQuery
+}
+
+// loadStoredQueries reads the stored queries from a JSON file, which may not
+// exist yet.
+func loadStoredQueries(path string) (*storedQueries, error) {
+	s := &storedQueries{path: path, queries: make(map[string]*storedQuery)}
+	if path == "" {
+		return s, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var queries map[string]StoredQuery
+	if err := json.NewDecoder(f).Decode(&queries); err != nil {
+		return nil, fmt.Errorf("could not parse stored queries %q: %v", path, err)
+	}
+	for name, q := range queries {
+		s.queries[name] = &storedQuery{StoredQuery: q}
+	}
+	return s, nil
+}
+
+func (s *storedQueries) get(name string) (*storedQuery, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, ok := s.queries[name]
+	return q, ok
+}
+
+func (s *storedQueries) list() map[string]StoredQuery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]StoredQuery, len(s.queries))
+	for name, q := range s.queries {
+		out[name] = q.StoredQuery
+	}
+	return out
+}
+
+// put stores the query, or deletes the query with the name if q is nil, and
+// saves the stored queries.
+func (s *storedQueries) put(name string, q *storedQuery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.queries[name]
+	if q != nil {
+		s.queries[name] = q
+	} else {
+		delete(s.queries, name)
+	}
+	if err := s.save(); err != nil {
+		if ok {
+			s.queries[name] = old
+		} else {
+			delete(s.queries, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the stored queries to their file, which must be held by s.mu.
+// The file is replaced at once, so that it is never left half written.
+func (s *storedQueries) save() error {
+	if s.path == "" {
+		return nil
+	}
+	queries := make(map[string]StoredQuery, len(s.queries))
+	for name, q := range s.queries {
+		queries[name] = q.StoredQuery
+	}
+	data, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// validName returns whether s can name a stored query or a parameter: it must
+// only hold letters, digits, dashes and underscores.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '-' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeParams reads the values of the declared parameters from a JSON object.
+// Strings are taken as they would be written in a query, and numbers and
+// booleans are typed values.
+func decodeParams(r io.Reader, declared []string) (map[string]quad.Value, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil && err != io.EOF {
+		return nil, err
+	}
+	params := make(map[string]quad.Value, len(declared))
+	for _, name := range declared {
+		v, ok := raw[name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %s", name)
+		}
+		switch v := v.(type) {
+		case string:
+			params[name] = quad.Raw(v)
+		case bool:
+			params[name] = quad.Bool(v)
+		case json.Number:
+			if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				params[name] = quad.Int(i)
+			} else if f, err := v.Float64(); err == nil {
+				params[name] = quad.Float(f)
+			} else {
+				return nil, fmt.Errorf("parameter %s: %v", name, err)
+			}
+		default:
+			return nil, fmt.Errorf("parameter %s: unsupported value %v", name, v)
+		}
+	}
+	if len(raw) > len(params) {
+		for name := range raw {
+			if _, ok := params[name]; !ok {
+				return nil, fmt.Errorf("unknown parameter %s", name)
+			}
+		}
+	}
+	return params, nil
+}
+
+// ServeV1NamedQuery runs a stored query, with the values of its parameters in
+// the JSON object of the request body.
+func (api *API) ServeV1NamedQuery(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
+	if params.ByName("query_lang") != namedLang {
+		return jsonResponse(w, 404, "Not found.")
+	}
+	name := params.ByName("name")
+	sq, ok := api.queries.get(name)
+	if !ok {
+		return jsonResponse(w, 404, fmt.Sprintf("No stored query named %s.", name))
+	}
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	ses := api.newSession(h, sq.Lang)
+	if ses == nil {
+		return jsonResponse(w, 400, fmt.Sprintf("Unknown query language %s.", sq.Lang))
+	}
+	p, ok := ses.(query.Preparer)
+	if !ok {
+		return jsonResponse(w, 400, fmt.Sprintf("Query language %s has no stored queries.", sq.Lang))
+	}
+	vals, err := decodeParams(r.Body, sq.Params)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	meta, err := parseMeta(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	start := time.Now()
+	prepared, err := sq.prepare(p)
+	if err == nil {
+		err = p.Bind(prepared, vals)
+	}
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	if meta != nil {
+		meta.ParseTime = time.Since(start)
+	}
+	return api.runQuery(w, r, sq.Lang, "", ses, meta)
+}
+
+// ServeV1ListNamedQueries lists the stored queries.
+func (api *API) ServeV1ListNamedQueries(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	bytes, err := WrapResult(api.queries.list())
+	if err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	w.Write(bytes)
+	return 200
+}
+
+// ServeV1PutNamedQuery stores the query of the request body under a name,
+// replacing the query previously stored under it.
+func (api *API) ServeV1PutNamedQuery(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
+	name := params.ByName("name")
+	if !validName(name) {
+		return jsonResponse(w, 400, "Invalid query name.")
+	}
+	var sq StoredQuery
+	if err := json.NewDecoder(r.Body).Decode(&sq); err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	ses := api.newSession(h, sq.Lang)
+	if ses == nil {
+		return jsonResponse(w, 400, fmt.Sprintf("Unknown query language %s.", sq.Lang))
+	}
+	p, ok := ses.(query.Preparer)
+	if !ok {
+		return jsonResponse(w, 400, fmt.Sprintf("Query language %s has no stored queries.", sq.Lang))
+	}
+	seen := make(map[string]bool, len(sq.Params))
+	for _, param := range sq.Params {
+		if !validName(param) || seen[param] {
+			return jsonResponse(w, 400, fmt.Sprintf("Invalid parameter %s.", param))
+		}
+		seen[param] = true
+	}
+	q := &storedQuery{StoredQuery: sq}
+	if _, err := q.prepare(p); err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	if err := api.queries.put(name, q); err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	fmt.Fprintf(w, "{\"result\": \"Successfully stored query %s.\"}", name)
+	return 200
+}
+
+// ServeV1DeleteNamedQuery deletes a stored query.
+func (api *API) ServeV1DeleteNamedQuery(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
+	name := params.ByName("name")
+	if _, ok := api.queries.get(name); !ok {
+		return jsonResponse(w, 404, fmt.Sprintf("No stored query named %s.", name))
+	}
+	if err := api.queries.put(name, nil); err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	fmt.Fprintf(w, "{\"result\": \"Successfully deleted query %s.\"}", name)
+	return 200
+}
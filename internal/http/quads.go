@@ -0,0 +1,223 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+
+	// Register the formats quads can be read in.
+	_ "github.com/google/cayley/quad/cquads"
+	_ "github.com/google/cayley/quad/json"
+	_ "github.com/google/cayley/quad/jsonld"
+	_ "github.com/google/cayley/quad/pquads"
+)
+
+const (
+	// defaultQuadFormat is used when the client accepts any format.
+	defaultQuadFormat = "application/n-quads"
+	// maxQuadsPage is the maximal number of quads of a page.
+	maxQuadsPage = 10000
+	// nextTokenHeader holds the token of the next page of quads.
+	nextTokenHeader = "Cayley-Next-Token"
+)
+
+// quadFormat returns the registered format which can write quads, chosen by
+// the format parameter or by the Accept header, and its content type.
+func quadFormat(r *http.Request) (*quad.Format, string) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		f := quad.FormatByName(name)
+		if f == nil || f.Writer == nil {
+			return nil, ""
+		}
+		if len(f.Mime) == 0 {
+			return f, defaultQuadFormat
+		}
+		return f, f.Mime[0]
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		accept = "*/*"
+	}
+	for _, s := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		if mt == "*/*" || mt == "application/*" {
+			mt = defaultQuadFormat
+		}
+		if f := quad.FormatByMime(mt); f != nil && f.Writer != nil {
+			return f, mt
+		}
+	}
+	return nil, ""
+}
+
+func encodePageToken(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token")
+	}
+	offset, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid page token")
+	}
+	return offset, nil
+}
+
+// quadsIterator returns an iterator over the quads matching the values of
+// the s, p, o and l parameters.
+func quadsIterator(qs graph.QuadStore, r *http.Request) (graph.Iterator, error) {
+	var its []graph.Iterator
+	for _, d := range []struct {
+		param string
+		dir   quad.Direction
+	}{
+		{"s", quad.Subject},
+		{"p", quad.Predicate},
+		{"o", quad.Object},
+		{"l", quad.Label},
+	} {
+		s := r.URL.Query().Get(d.param)
+		if s == "" {
+			continue
+		}
+		v, err := quad.Raw(s).Parse()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s value %q: %v", d.dir, s, err)
+		}
+		val := qs.ValueOf(v)
+		if val == nil {
+			return iterator.NewNull(), nil
+		}
+		its = append(its, qs.QuadIterator(d.dir, val))
+	}
+	switch len(its) {
+	case 0:
+		return qs.QuadsAllIterator(), nil
+	case 1:
+		return its[0], nil
+	}
+	and := iterator.NewAnd(qs)
+	for _, it := range its {
+		and.AddSubIterator(it)
+	}
+	return and, nil
+}
+
+// ServeV1Quads writes the quads matching the bound directions, in the format
+// accepted by the client. With a limit, the quads are paginated, and the token
+// of the next page, if any, is returned in a header.
+func (api *API) ServeV1Quads(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	format, contentType := quadFormat(r)
+	if format == nil {
+		return jsonResponse(w, http.StatusNotAcceptable, "No acceptable quad format.")
+	}
+	var limit, offset int64
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || limit <= 0 || limit > maxQuadsPage {
+			return jsonResponse(w, 400, fmt.Sprintf("limit must be between 1 and %d", maxQuadsPage))
+		}
+	}
+	if s := r.URL.Query().Get("token"); s != "" {
+		if offset, err = decodePageToken(s); err != nil {
+			return jsonResponse(w, 400, err)
+		}
+	}
+	it, err := quadsIterator(h.QuadStore, r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	if offset > 0 {
+		it = iterator.NewSkip(h.QuadStore, it, offset)
+	}
+	if limit > 0 {
+		// Read one more quad, to know whether there is a next page.
+		it = iterator.NewLimit(h.QuadStore, it, limit+1)
+	}
+	it, _ = it.Optimize()
+	defer it.Close()
+
+	ctx, cancel := api.queryContext(w)
+	defer cancel()
+
+	var page []quad.Quad
+	if limit > 0 {
+		for graph.Next(ctx, it) {
+			page = append(page, h.QuadStore.Quad(it.Result()))
+		}
+		if err = it.Err(); err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return jsonResponse(w, 500, err)
+		}
+		if int64(len(page)) > limit {
+			page = page[:limit]
+			w.Header().Set(nextTokenHeader, encodePageToken(offset+limit))
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	qw := format.Writer(w)
+	if limit > 0 {
+		_, err = quad.Copy(qw, quad.NewReader(page))
+	} else {
+		err = copyQuads(ctx, qw, h.QuadStore, it)
+	}
+	if err == nil {
+		err = qw.Close()
+	}
+	if err != nil {
+		// The status is sent already.
+		glog.Errorf("failed to write quads: %v", err)
+	}
+	return 200
+}
+
+// copyQuads writes the quads of the iterator until it is done or the context
+// is cancelled.
+func copyQuads(ctx context.Context, qw quad.Writer, qs graph.QuadStore, it graph.Iterator) error {
+	for graph.Next(ctx, it) {
+		if err := qw.WriteQuad(qs.Quad(it.Result())); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
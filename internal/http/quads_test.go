@@ -0,0 +1,185 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/quad/cquads"
+)
+
+// sortedNQuads returns the sorted lines of the quads in N-Quads.
+func sortedNQuads(quads []quad.Quad) []string {
+	out := []string{}
+	for _, q := range quads {
+		out = append(out, q.NQuad())
+	}
+	sort.Strings(out)
+	return out
+}
+
+// readNQuads reads the quads of a response.
+func readNQuads(t *testing.T, body string) []quad.Quad {
+	quads, err := quad.ReadAll(cquads.NewDecoder(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("Failed to read the quads of the response: %v\n%s", err, body)
+	}
+	return quads
+}
+
+// filterQuads returns the quads matching the values of the directions.
+func filterQuads(quads []quad.Quad, vals map[quad.Direction]quad.Value) []quad.Quad {
+	var out []quad.Quad
+	for _, q := range quads {
+		ok := true
+		for d, v := range vals {
+			if q.Get(d) != v {
+				ok = false
+			}
+		}
+		if ok {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func TestQuadsFilters(t *testing.T) {
+	data := readTestData(t)
+	_, r := makeTestAPI(t, nil, data)
+	for _, c := range []struct {
+		params url.Values
+		vals   map[quad.Direction]quad.Value
+	}{
+		{},
+		{
+			params: url.Values{"s": {"<bob>"}},
+			vals:   map[quad.Direction]quad.Value{quad.Subject: quad.IRI("bob")},
+		},
+		{
+			params: url.Values{"p": {"<follows>"}, "o": {"<fred>"}},
+			vals:   map[quad.Direction]quad.Value{quad.Predicate: quad.IRI("follows"), quad.Object: quad.IRI("fred")},
+		},
+		{
+			params: url.Values{"o": {`"cool_person"`}},
+			vals:   map[quad.Direction]quad.Value{quad.Object: quad.String("cool_person")},
+		},
+		{
+			params: url.Values{"l": {"<smart_graph>"}},
+			vals:   map[quad.Direction]quad.Value{quad.Label: quad.IRI("smart_graph")},
+		},
+		{
+			// A value which is not in the store.
+			params: url.Values{"s": {"<nobody>"}},
+			vals:   map[quad.Direction]quad.Value{quad.Subject: quad.IRI("nobody")},
+		},
+	} {
+		w := serve(r, "GET", "/api/v1/quads?"+c.params.Encode(), "", "Accept", "application/n-quads")
+		if w.Code != 200 {
+			t.Errorf("Unexpected status for %v: %d %s", c.params, w.Code, w.Body)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/n-quads" {
+			t.Errorf("Unexpected content type for %v: %q", c.params, ct)
+		}
+		got := sortedNQuads(readNQuads(t, w.Body.String()))
+		expect := sortedNQuads(filterQuads(data, c.vals))
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Unexpected quads for %v, got:%q expect:%q", c.params, got, expect)
+		}
+	}
+}
+
+func TestQuadsPages(t *testing.T) {
+	data := readTestData(t)
+	_, r := makeTestAPI(t, nil, data)
+
+	for _, params := range []string{"", "&p=%3Cfollows%3E"} {
+		var (
+			all   []quad.Quad
+			token string
+			pages int
+		)
+		for {
+			path := "/api/v1/quads?limit=4" + params
+			if token != "" {
+				path += "&token=" + url.QueryEscape(token)
+			}
+			w := serve(r, "GET", path, "")
+			if w.Code != 200 {
+				t.Fatalf("Unexpected status for %q: %d %s", path, w.Code, w.Body)
+			}
+			page := readNQuads(t, w.Body.String())
+			if len(page) > 4 {
+				t.Errorf("Page %d of %q has %d quads", pages, params, len(page))
+			}
+			all = append(all, page...)
+			pages++
+			if token = w.Header().Get(nextTokenHeader); token == "" {
+				break
+			}
+			if pages > len(data) {
+				t.Fatalf("Too many pages for %q", params)
+			}
+		}
+		var expect []quad.Quad
+		if params == "" {
+			expect = data
+		} else {
+			expect = filterQuads(data, map[quad.Direction]quad.Value{quad.Predicate: quad.IRI("follows")})
+		}
+		if got, exp := sortedNQuads(all), sortedNQuads(expect); !reflect.DeepEqual(got, exp) {
+			t.Errorf("Unexpected quads of the pages of %q, got:%q expect:%q", params, got, exp)
+		}
+		if n := (len(expect) + 3) / 4; pages != n {
+			t.Errorf("Unexpected number of pages for %q, got:%d expect:%d", params, pages, n)
+		}
+	}
+}
+
+func TestQuadsErrors(t *testing.T) {
+	_, r := makeTestAPI(t, nil, readTestData(t))
+	for _, c := range []struct {
+		path   string
+		accept string
+		code   int
+	}{
+		{path: "/api/v1/quads?s=%3Cbob", code: 400},
+		{path: "/api/v1/quads?o=%22unterminated", code: 400},
+		{path: "/api/v1/quads?p=follows", code: 400},
+		{path: "/api/v1/quads?limit=0", code: 400},
+		{path: "/api/v1/quads?limit=-1", code: 400},
+		{path: "/api/v1/quads?limit=many", code: 400},
+		{path: "/api/v1/quads?limit=100000", code: 400},
+		{path: "/api/v1/quads?limit=2&token=!!", code: 400},
+		{path: "/api/v1/quads?limit=2&token=" + encodePageToken(-1), code: 400},
+		{path: "/api/v1/quads?format=unknown", code: 406},
+		{path: "/api/v1/quads", accept: "text/html", code: 406},
+	} {
+		var headers []string
+		if c.accept != "" {
+			headers = []string{"Accept", c.accept}
+		}
+		w := serve(r, "GET", c.path, "", headers...)
+		if w.Code != c.code {
+			t.Errorf("Unexpected status for %q, got:%d expect:%d (%s)", c.path, w.Code, c.code, w.Body)
+		}
+	}
+}
@@ -19,41 +19,154 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
 
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/internal/config"
 	"github.com/google/cayley/query"
+	"github.com/google/cayley/query/datalog"
+	"github.com/google/cayley/query/graphql"
 	"github.com/google/cayley/query/gremlin"
 	"github.com/google/cayley/query/mql"
+	"github.com/google/cayley/query/sparql"
 )
 
+// resultLimit is the maximal number of results of a query which is not
+// streamed, for the sessions which limit their results.
+const resultLimit = 100
+
 type SuccessQueryWrapper struct {
 	Result interface{} `json:"result"`
+	Meta   *QueryMeta  `json:"meta,omitempty"`
 }
 
 type ErrorQueryWrapper struct {
-	Error string `json:"error"`
+	Error string     `json:"error"`
+	Meta  *QueryMeta `json:"meta,omitempty"`
+}
+
+// QueryMeta describes how a query ran. Times are in nanoseconds.
+type QueryMeta struct {
+	ParseTime     time.Duration `json:"parse_time"`
+	ExecutionTime time.Duration `json:"execution_time"`
+	// Count is the number of results found by the query.
+	Count int `json:"count"`
+	// Limited is set when the session stopped the query at the limit of
+	// results with more left, and TimedOut when it stopped at the query
+	// timeout.
+	Limited  bool         `json:"limited"`
+	TimedOut bool         `json:"timed_out"`
+	Stats    *query.Stats `json:"stats,omitempty"`
 }
 
 func WrapErrResult(err error) ([]byte, error) {
+	return wrapErrResultMeta(err, nil)
+}
+
+func wrapErrResultMeta(err error, meta *QueryMeta) ([]byte, error) {
 	var wrap ErrorQueryWrapper
 	wrap.Error = err.Error()
+	wrap.Meta = meta
 	return json.MarshalIndent(wrap, "", " ")
 }
 
 func WrapResult(result interface{}) ([]byte, error) {
+	return wrapResultMeta(result, nil)
+}
+
+func wrapResultMeta(result interface{}, meta *QueryMeta) ([]byte, error) {
 	var wrap SuccessQueryWrapper
 	wrap.Result = result
+	wrap.Meta = meta
 	return json.MarshalIndent(wrap, "", " ")
 }
 
-func Run(q string, ses query.HTTP) (interface{}, error) {
+func Run(ctx context.Context, q string, ses query.HTTP) (interface{}, error) {
+	return runMeta(ctx, q, ses, nil)
+}
+
+// runMeta runs the query as Run does, and fills the metadata of the query if
+// it is not nil.
+func runMeta(ctx context.Context, q string, ses query.HTTP, meta *QueryMeta) (interface{}, error) {
+	var stats func() *query.Stats
+	if meta != nil {
+		ctx, stats = query.WithStats(ctx)
+	}
+	start := time.Now()
+	st, _ := ses.(query.Streamer)
+	n := 0
 	c := make(chan interface{}, 5)
-	go ses.Execute(q, c, 100)
+	go ses.Execute(ctx, q, c, resultLimit)
 	for res := range c {
+		if st == nil {
+			n++
+		} else if _, ok := st.StreamResult(res); ok {
+			n++
+		}
 		ses.Collate(res)
 	}
-	return ses.Results()
+	var (
+		out interface{}
+		err = ctx.Err()
+	)
+	if err != nil {
+		ses.Clear()
+	} else {
+		out, err = ses.Results()
+	}
+	if meta != nil {
+		meta.ExecutionTime = time.Since(start)
+		meta.Count = n
+		if l, ok := ses.(query.Limiter); ok {
+			meta.Limited = l.Limited()
+		}
+		meta.TimedOut = isTimeout(err)
+		meta.Stats = stats()
+	}
+	return out, err
+}
+
+// isTimeout returns whether a query failed because it timed out.
+func isTimeout(err error) bool {
+	return err == context.DeadlineExceeded || err == gremlin.ErrKillTimeout
+}
+
+// queryContext returns a context for a single query. It is cancelled when
+// the configured query timeout elapses or the client goes away, whichever
+// happens first. The returned cancel function must be called once the query
+// is done.
+func (api *API) queryContext(w http.ResponseWriter) (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if api.config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), api.config.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	cancelOnClose(w, ctx, cancel)
+	return ctx, cancel
+}
+
+// cancelOnClose cancels the context when the client of the response goes away.
+func cancelOnClose(w http.ResponseWriter, ctx context.Context, cancel context.CancelFunc) {
+	cn, ok := w.(http.CloseNotifier)
+	if !ok {
+		return
+	}
+	closed := cn.CloseNotify()
+	go func() {
+		select {
+		case <-closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 }
 
 func GetQueryShape(q string, ses query.HTTP) ([]byte, error) {
@@ -64,16 +177,55 @@ func GetQueryShape(q string, ses query.HTTP) ([]byte, error) {
 	return json.Marshal(s)
 }
 
+// languages holds the constructors of the sessions of the query languages
+// served over HTTP.
+var languages = map[string]func(qs graph.QuadStore, cfg *config.Config) query.HTTP{
+	"gremlin": func(qs graph.QuadStore, cfg *config.Config) query.HTTP {
+		return gremlin.NewSession(qs, cfg.Timeout, false)
+	},
+	"mql": func(qs graph.QuadStore, _ *config.Config) query.HTTP {
+		return mql.NewSession(qs)
+	},
+	"sparql": func(qs graph.QuadStore, _ *config.Config) query.HTTP {
+		return sparql.NewSession(qs)
+	},
+	"graphql": func(qs graph.QuadStore, _ *config.Config) query.HTTP {
+		return graphql.NewSession(qs)
+	},
+	"datalog": func(qs graph.QuadStore, _ *config.Config) query.HTTP {
+		return datalog.NewSession(qs)
+	},
+}
+
+// newSession returns a session of the query language, or nil if there is no
+// such language.
+func (api *API) newSession(h *graph.Handle, lang string) query.HTTP {
+	newSes, ok := languages[lang]
+	if !ok {
+		return nil
+	}
+	return newSes(h.QuadStore, api.config)
+}
+
+// parseMeta returns the metadata to fill for the request, which is nil unless
+// the meta parameter is set.
+func parseMeta(r *http.Request) (*QueryMeta, error) {
+	s := r.URL.Query().Get("meta")
+	if s == "" {
+		return nil, nil
+	}
+	ok, err := strconv.ParseBool(s)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &QueryMeta{}, nil
+}
+
 // TODO(barakmich): Turn this into proper middleware.
 func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
 	h, err := api.GetHandleForRequest(r)
-	var ses query.HTTP
-	switch params.ByName("query_lang") {
-	case "gremlin":
-		ses = gremlin.NewSession(h.QuadStore, api.config.Timeout, false)
-	case "mql":
-		ses = mql.NewSession(h.QuadStore)
-	default:
+	ses := api.newSession(h, params.ByName("query_lang"))
+	if ses == nil {
 		return jsonResponse(w, 400, "Need a query language.")
 	}
 	bodyBytes, err := ioutil.ReadAll(r.Body)
@@ -81,27 +233,18 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		return jsonResponse(w, 400, err)
 	}
 	code := string(bodyBytes)
+	meta, err := parseMeta(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	start := time.Now()
 	result, err := ses.Parse(code)
+	if meta != nil {
+		meta.ParseTime = time.Since(start)
+	}
 	switch result {
 	case query.Parsed:
-		var output interface{}
-		var bytes []byte
-		var err error
-		output, err = Run(code, ses)
-		if err != nil {
-			bytes, err = WrapErrResult(err)
-			http.Error(w, string(bytes), 400)
-			ses = nil
-			return 400
-		}
-		bytes, err = WrapResult(output)
-		if err != nil {
-			ses = nil
-			return jsonResponse(w, 400, err)
-		}
-		fmt.Fprint(w, string(bytes))
-		ses = nil
-		return 200
+		return api.runQuery(w, r, params.ByName("query_lang"), code, ses, meta)
 	case query.ParseFail:
 		ses = nil
 		return jsonResponse(w, 400, err)
@@ -111,15 +254,41 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 	}
 }
 
+// runQuery runs a parsed query and writes its results, streamed if the
+// request accepts a stream.
+func (api *API) runQuery(w http.ResponseWriter, r *http.Request, lang, code string, ses query.HTTP, meta *QueryMeta) int {
+	if ct := streamType(r); ct != "" {
+		ctx, cancel := api.queryContext(w)
+		err := streamResults(ctx, code, ses, newResultWriter(w, ct), -1)
+		cancel()
+		if isTimeout(err) {
+			queryTimeouts.Inc(lang)
+		}
+		return 200
+	}
+	ctx, cancel := api.queryContext(w)
+	output, err := runMeta(ctx, code, ses, meta)
+	cancel()
+	if isTimeout(err) {
+		queryTimeouts.Inc(lang)
+	}
+	if err != nil {
+		bytes, _ := wrapErrResultMeta(err, meta)
+		http.Error(w, string(bytes), 400)
+		return 400
+	}
+	bytes, err := wrapResultMeta(output, meta)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	fmt.Fprint(w, string(bytes))
+	return 200
+}
+
 func (api *API) ServeV1Shape(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
 	h, err := api.GetHandleForRequest(r)
-	var ses query.HTTP
-	switch params.ByName("query_lang") {
-	case "gremlin":
-		ses = gremlin.NewSession(h.QuadStore, api.config.Timeout, false)
-	case "mql":
-		ses = mql.NewSession(h.QuadStore)
-	default:
+	ses := api.newSession(h, params.ByName("query_lang"))
+	if ses == nil {
 		return jsonResponse(w, 400, "Need a query language.")
 	}
 	bodyBytes, err := ioutil.ReadAll(r.Body)
@@ -144,3 +313,51 @@ func (api *API) ServeV1Shape(w http.ResponseWriter, r *http.Request, params http
 		return jsonResponse(w, 500, "Incomplete data?")
 	}
 }
+
+// ServeV1Explain describes the iterator trees of a query, before and after
+// they are optimized. With the analyze parameter, the query is run, and the
+// calls made to every iterator are counted and timed.
+func (api *API) ServeV1Explain(w http.ResponseWriter, r *http.Request, params httprouter.Params) int {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	ses := api.newSession(h, params.ByName("query_lang"))
+	if ses == nil {
+		return jsonResponse(w, 400, "Need a query language.")
+	}
+	analyze := false
+	if s := r.URL.Query().Get("analyze"); s != "" {
+		analyze, err = strconv.ParseBool(s)
+		if err != nil {
+			return jsonResponse(w, 400, err)
+		}
+	}
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	code := string(bodyBytes)
+	result, err := ses.Parse(code)
+	switch result {
+	case query.Parsed:
+		ctx, cancel := api.queryContext(w)
+		exp, err := query.Explain(ctx, ses, code, analyze)
+		cancel()
+		if err != nil {
+			bytes, _ := WrapErrResult(err)
+			http.Error(w, string(bytes), 400)
+			return 400
+		}
+		bytes, err := WrapResult(exp)
+		if err != nil {
+			return jsonResponse(w, 400, err)
+		}
+		fmt.Fprint(w, string(bytes))
+		return 200
+	case query.ParseFail:
+		return jsonResponse(w, 400, err)
+	default:
+		return jsonResponse(w, 500, "Incomplete data?")
+	}
+}
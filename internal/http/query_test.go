@@ -0,0 +1,117 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/cayley/internal/config"
+	"github.com/google/cayley/quad"
+)
+
+// chainQuads returns a chain of n quads, where every node follows the next.
+func chainQuads(n int) []quad.Quad {
+	quads := make([]quad.Quad, 0, n)
+	for i := 0; i < n; i++ {
+		quads = append(quads, quad.Quad{
+			Subject:   quad.IRI(fmt.Sprintf("n%d", i)),
+			Predicate: quad.IRI("follows"),
+			Object:    quad.IRI(fmt.Sprintf("n%d", i+1)),
+		})
+	}
+	return quads
+}
+
+type metaResponse struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error"`
+	Meta   *QueryMeta  `json:"meta"`
+}
+
+func TestQueryMeta(t *testing.T) {
+	_, r := makeTestAPI(t, nil, chainQuads(resultLimit+50))
+	for _, c := range []struct {
+		lang, query string
+		count       int
+		limited     bool
+		stats       bool
+	}{
+		{lang: "gremlin", query: `g.V("<n0>").Out("<follows>").All()`, count: 1, stats: true},
+		// Gremlin does not limit its results: all the nodes of the chain and
+		// the predicate are found.
+		{lang: "gremlin", query: `g.V().All()`, count: resultLimit + 52, stats: true},
+		{lang: "datalog", query: `?- follows(X, <n1>).`, count: 1},
+		{lang: "datalog", query: `?- follows(X, Y).`, count: resultLimit, limited: true},
+	} {
+		w := serve(r, "POST", "/api/v1/query/"+c.lang+"?meta=1", c.query)
+		if w.Code != 200 {
+			t.Errorf("Unexpected status for %q: %d %s", c.query, w.Code, w.Body)
+			continue
+		}
+		var resp metaResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode the response to %q: %v", c.query, err)
+		}
+		m := resp.Meta
+		if m == nil {
+			t.Errorf("No metadata for %q: %s", c.query, w.Body)
+			continue
+		}
+		if m.Count != c.count || m.Limited != c.limited || m.TimedOut {
+			t.Errorf("Unexpected metadata for %q, got count:%d limited:%v timed_out:%v, expect count:%d limited:%v",
+				c.query, m.Count, m.Limited, m.TimedOut, c.count, c.limited)
+		}
+		if n := len(resp.Result.([]interface{})); n != c.count {
+			t.Errorf("Count of %q does not match the %d results: %d", c.query, n, m.Count)
+		}
+		if m.ParseTime <= 0 || m.ExecutionTime <= 0 {
+			t.Errorf("Unexpected times for %q: parse:%v execution:%v", c.query, m.ParseTime, m.ExecutionTime)
+		}
+		if c.stats && (m.Stats == nil || m.Stats.Iterators == 0 || m.Stats.Next == 0) {
+			t.Errorf("Unexpected stats for %q: %+v", c.query, m.Stats)
+		}
+	}
+
+	// Without the parameter, there is no metadata.
+	w := serve(r, "POST", "/api/v1/query/gremlin", `g.V("<n0>").All()`)
+	var resp metaResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Meta != nil {
+		t.Errorf("Unexpected metadata without the meta parameter: %+v", resp.Meta)
+	}
+}
+
+func TestQueryMetaTimeout(t *testing.T) {
+	_, r := makeTestAPI(t, &config.Config{Timeout: 50 * time.Millisecond}, nil)
+	w := serve(r, "POST", "/api/v1/query/gremlin?meta=true", endlessGremlin)
+	if w.Code != 400 {
+		t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+	}
+	var resp metaResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode the response: %v", err)
+	}
+	if resp.Error == "" || resp.Meta == nil || !resp.Meta.TimedOut || resp.Meta.Limited {
+		t.Errorf("Expected a timed out query, got: %s", w.Body)
+	}
+	if resp.Meta != nil && resp.Meta.ExecutionTime < 50*time.Millisecond {
+		t.Errorf("Unexpected execution time: %v", resp.Meta.ExecutionTime)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/query"
+)
+
+const (
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeSSE    = "text/event-stream"
+)
+
+// streamType returns the streaming content type accepted by the client, or
+// an empty string if the results should be sent as a single document.
+func streamType(r *http.Request) string {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case contentTypeNDJSON, contentTypeSSE:
+			return mt
+		}
+	}
+	return ""
+}
+
+// resultWriter writes the results of a query one at a time, and flushes them
+// to the client.
+type resultWriter struct {
+	w     io.Writer
+	flush func()
+	sse   bool
+}
+
+func newResultWriter(w http.ResponseWriter, contentType string) *resultWriter {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	rw := &resultWriter{w: w, flush: func() {}, sse: contentType == contentTypeSSE}
+	if f, ok := w.(http.Flusher); ok {
+		rw.flush = f.Flush
+	}
+	return rw
+}
+
+// write sends a JSON value, as a line of NDJSON or as a server-sent event of
+// the given type.
+func (rw *resultWriter) write(event string, v interface{}) error {
+	return rw.writeID(event, "", v)
+}
+
+// writeID sends a JSON value as write does, along with the ID of the event if
+// it is a server-sent event, which the client sends back when it reconnects.
+func (rw *resultWriter) writeID(event, id string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if rw.sse && id != "" {
+		_, err = fmt.Fprintf(rw.w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, b)
+	} else if rw.sse {
+		_, err = fmt.Fprintf(rw.w, "event: %s\ndata: %s\n\n", event, b)
+	} else {
+		_, err = fmt.Fprintf(rw.w, "%s\n", b)
+	}
+	if err != nil {
+		return err
+	}
+	rw.flush()
+	return nil
+}
+
+func (rw *resultWriter) writeResult(v interface{}) error {
+	return rw.write("result", SuccessQueryWrapper{Result: v})
+}
+
+func (rw *resultWriter) writeError(err error) error {
+	return rw.write("error", ErrorQueryWrapper{Error: err.Error()})
+}
+
+// streamResults runs the query and writes every result as soon as it is found,
+// for the sessions which can output results on their own; the results of the
+// other sessions are collated and written at once. An error ends the stream,
+// and is returned; the query stops when the results cannot be written, such as
+// when the client goes away.
+func streamResults(ctx context.Context, q string, ses query.HTTP, rw *resultWriter, limit int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c := make(chan interface{}, 5)
+	go ses.Execute(ctx, q, c, limit)
+	st, stream := ses.(query.Streamer)
+	var werr error
+	for res := range c {
+		if werr != nil {
+			continue
+		}
+		if !stream {
+			ses.Collate(res)
+			continue
+		}
+		if v, ok := st.StreamResult(res); ok {
+			if werr = rw.writeResult(v); werr != nil {
+				cancel()
+			}
+		}
+	}
+	if werr != nil {
+		ses.Clear()
+		return werr
+	}
+	if err := ctx.Err(); err != nil {
+		ses.Clear()
+		rw.writeError(err)
+		return err
+	}
+	out, err := ses.Results()
+	if err != nil {
+		rw.writeError(err)
+		return err
+	}
+	if !stream {
+		rw.writeResult(out)
+	}
+	if rw.sse {
+		rw.write("end", struct{}{})
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/internal/config"
+)
+
+const (
+	followsBobGremlin = `g.V("<bob>").In("<follows>").All()`
+	charlieMQL        = `[{"id": "<charlie>", "<follows>": [{"id": null}]}]`
+	endlessGremlin    = `for (var i = 0; ; i++) { g.Emit(i) }`
+)
+
+// event is a server-sent event.
+type event struct {
+	name string
+	data string
+}
+
+func parseEvents(t *testing.T, body string) []event {
+	var out []event
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var ev event
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.name = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				ev.data = line[len("data: "):]
+			case strings.HasPrefix(line, "id: "):
+			default:
+				t.Fatalf("Unexpected line in event stream: %q", line)
+			}
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// gremlinIDs returns the sorted ids of the results of a Gremlin query.
+func gremlinIDs(t *testing.T, results []json.RawMessage) []string {
+	var ids []string
+	for _, data := range results {
+		var r struct {
+			Result map[string]string `json:"result"`
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("Failed to decode result %s: %v", data, err)
+		}
+		ids = append(ids, r.Result["id"])
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// jsonEqual returns whether two JSON documents hold the same values.
+func jsonEqual(t *testing.T, a, b string) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatalf("Failed to decode %s: %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatalf("Failed to decode %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+var followsBob = []string{"<alice>", "<charlie>", "<dani>"}
+
+func TestStreamNDJSON(t *testing.T) {
+	_, r := makeTestAPI(t, nil, readTestData(t))
+
+	w := serve(r, "POST", "/api/v1/query/gremlin", followsBobGremlin, "Accept", contentTypeNDJSON)
+	if w.Code != 200 {
+		t.Fatalf("Unexpected status: %d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeNDJSON {
+		t.Errorf("Unexpected content type, got:%q expect:%q", ct, contentTypeNDJSON)
+	}
+	var lines []json.RawMessage
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		lines = append(lines, json.RawMessage(line))
+	}
+	if got := gremlinIDs(t, lines); !reflect.DeepEqual(got, followsBob) {
+		t.Errorf("Unexpected results, got:%v expect:%v", got, followsBob)
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	_, r := makeTestAPI(t, nil, readTestData(t))
+
+	w := serve(r, "POST", "/api/v1/query/gremlin", followsBobGremlin, "Accept", contentTypeSSE)
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeSSE {
+		t.Errorf("Unexpected content type, got:%q expect:%q", ct, contentTypeSSE)
+	}
+	events := parseEvents(t, w.Body.String())
+	if n := len(events); n == 0 || events[n-1].name != "end" {
+		t.Fatalf("Expected the stream to end with an end event, got:%v", events)
+	}
+	var results []json.RawMessage
+	for _, ev := range events[:len(events)-1] {
+		if ev.name != "result" {
+			t.Fatalf("Unexpected event: %v", ev)
+		}
+		results = append(results, json.RawMessage(ev.data))
+	}
+	if got := gremlinIDs(t, results); !reflect.DeepEqual(got, followsBob) {
+		t.Errorf("Unexpected results, got:%v expect:%v", got, followsBob)
+	}
+
+	// A query which does not end before the timeout.
+	_, r = makeTestAPI(t, &config.Config{Timeout: 50 * time.Millisecond}, nil)
+	w = serve(r, "POST", "/api/v1/query/gremlin", endlessGremlin, "Accept", contentTypeSSE)
+	events = parseEvents(t, w.Body.String())
+	if n := len(events); n == 0 || events[n-1].name != "error" {
+		t.Errorf("Expected the stream to end with an error event, got:%v", events)
+	}
+}
+
+// TestStreamCollate checks that the results of MQL, which are built from all
+// the paths, are collated and sent as a single result.
+func TestStreamCollate(t *testing.T) {
+	_, r := makeTestAPI(t, nil, readTestData(t))
+
+	expect := `{"result":[{"<follows>":[{"id":"<bob>"},{"id":"<dani>"}],"id":"<charlie>"}]}`
+	w := serve(r, "POST", "/api/v1/query/mql", charlieMQL, "Accept", contentTypeNDJSON)
+	if got := strings.TrimSpace(w.Body.String()); !jsonEqual(t, got, expect) {
+		t.Errorf("Unexpected NDJSON, got:%s expect:%s", got, expect)
+	}
+
+	w = serve(r, "POST", "/api/v1/query/mql", charlieMQL, "Accept", contentTypeSSE)
+	events := parseEvents(t, w.Body.String())
+	if len(events) != 2 || events[0].name != "result" || events[1].name != "end" {
+		t.Fatalf("Expected a result and an end event, got:%v", events)
+	}
+	if !jsonEqual(t, events[0].data, expect) {
+		t.Errorf("Unexpected result event, got:%s expect:%s", events[0].data, expect)
+	}
+}
+
+func TestStreamNegotiation(t *testing.T) {
+	for _, c := range []struct {
+		accept string
+		expect string
+	}{
+		{accept: "", expect: ""},
+		{accept: "application/json", expect: ""},
+		{accept: "*/*", expect: ""},
+		{accept: "text/html, application/x-ndjson;q=0.9", expect: contentTypeNDJSON},
+		{accept: "text/event-stream", expect: contentTypeSSE},
+		{accept: "text/event-stream; charset=utf-8, application/x-ndjson", expect: contentTypeSSE},
+		{accept: "invalid/;;, application/x-ndjson", expect: contentTypeNDJSON},
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/query/gremlin", nil)
+		req.Header.Set("Accept", c.accept)
+		if got := streamType(req); got != c.expect {
+			t.Errorf("Unexpected stream type for %q, got:%q expect:%q", c.accept, got, c.expect)
+		}
+	}
+
+	_, r := makeTestAPI(t, nil, readTestData(t))
+	w := serve(r, "POST", "/api/v1/query/gremlin", followsBobGremlin, "Accept", "application/json")
+	var doc struct {
+		Result []map[string]string `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a single JSON document, got:%s (%v)", w.Body, err)
+	}
+	if len(doc.Result) != len(followsBob) {
+		t.Errorf("Unexpected results: %v", doc.Result)
+	}
+}
+
+// TestStreamCancel checks that a query stops once the client of its stream
+// goes away.
+func TestStreamCancel(t *testing.T) {
+	_, r := makeTestAPI(t, nil, nil)
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.ServeHTTP(w, req)
+		close(done)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("POST", srv.URL+"/api/v1/query/gremlin", strings.NewReader(endlessGremlin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", contentTypeNDJSON)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	for i := 0; i < 10; i++ {
+		if !sc.Scan() {
+			t.Fatalf("Stream ended early: %v", sc.Err())
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The query did not stop after the client went away.")
+	}
+}
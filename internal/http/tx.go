@@ -0,0 +1,191 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/quad/cquads"
+)
+
+// contentTypePatch is the type of N-Quads patches, where every line is an
+// N-Quad prefixed with A to add it or D to delete it.
+const contentTypePatch = "application/rdf-patch"
+
+// txOp is an operation of a JSON transaction document.
+type txOp struct {
+	Action string    `json:"action"`
+	Quad   quad.Quad `json:"quad"`
+}
+
+// TxResult reports the quads changed by a transaction. Ignored quads are the
+// ones which were added or deleted twice by the transaction, or both added
+// and deleted by it.
+//
+// Unless the writer ignores duplicated or missing quads, adding a quad which
+// exists or deleting one which does not fails the whole transaction, so all
+// the other quads are added or removed. When the writer ignores them, they are
+// counted as ignored too, which is known from a read of the store made before
+// the transaction is applied; the counts may be off if the quads are written
+// concurrently.
+type TxResult struct {
+	Result  string `json:"result"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Ignored int    `json:"ignored"`
+}
+
+// readTx reads the operations of a transaction, and returns the number of
+// operations along with the transaction.
+func readTx(r *http.Request) (*graph.Transaction, int, error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt == contentTypePatch {
+		return readPatch(r.Body)
+	}
+	var ops []txOp
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		return nil, 0, err
+	}
+	tx := graph.NewTransaction()
+	for i, op := range ops {
+		if !op.Quad.IsValid() {
+			return nil, 0, fmt.Errorf("invalid quad at index %d. %s", i, op.Quad)
+		}
+		switch op.Action {
+		case "add":
+			tx.AddQuad(op.Quad)
+		case "delete":
+			tx.RemoveQuad(op.Quad)
+		default:
+			return nil, 0, fmt.Errorf("invalid action %q at index %d", op.Action, i)
+		}
+	}
+	return tx, len(ops), nil
+}
+
+func readPatch(r io.Reader) (*graph.Transaction, int, error) {
+	tx := graph.NewTransaction()
+	n, line := 0, 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line++
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		if len(s) < 2 || (s[0] != 'A' && s[0] != 'D') || (s[1] != ' ' && s[1] != '\t') {
+			return nil, 0, fmt.Errorf("line %d: expected an A or D operation", line)
+		}
+		q, err := cquads.Parse(s[2:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		if s[0] == 'A' {
+			tx.AddQuad(q)
+		} else {
+			tx.RemoveQuad(q)
+		}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+	return tx, n, nil
+}
+
+// quadExists returns whether the quad is in the store.
+func quadExists(ctx context.Context, qs graph.QuadStore, q quad.Quad) bool {
+	and := iterator.NewAnd(qs)
+	for _, d := range []quad.Direction{quad.Subject, quad.Predicate, quad.Object} {
+		v := qs.ValueOf(q.Get(d))
+		if v == nil {
+			and.Close()
+			return false
+		}
+		and.AddSubIterator(qs.QuadIterator(d, v))
+	}
+	it, _ := and.Optimize()
+	defer it.Close()
+	label := quad.StringOf(q.Label)
+	for graph.Next(ctx, it) {
+		if quad.StringOf(qs.NameOf(qs.QuadDirection(it.Result(), quad.Label))) == label {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeV1Tx applies a document of added and deleted quads as a single
+// transaction, so that either all of it or none of it is written.
+func (api *API) ServeV1Tx(w http.ResponseWriter, r *http.Request, _ httprouter.Params) int {
+	if api.config.ReadOnly {
+		return jsonResponse(w, 400, "Database is read-only.")
+	}
+	tx, n, err := readTx(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	ignore, err := graph.IgnoreOptsFrom(api.config.ReplicationOptions)
+	if err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	res := TxResult{
+		Result:  "Successfully applied the transaction.",
+		Ignored: n - len(tx.Deltas),
+	}
+	ctx, cancel := api.queryContext(w)
+	defer cancel()
+	for i := range tx.Deltas {
+		d := &tx.Deltas[i]
+		switch {
+		case d.Action == graph.Add && !ignore.IgnoreDup:
+			// The transaction fails if the quad exists.
+			res.Added++
+		case d.Action == graph.Delete && !ignore.IgnoreMissing:
+			res.Removed++
+		case d.Action == graph.Add && !quadExists(ctx, h.QuadStore, d.Quad):
+			res.Added++
+		case d.Action == graph.Delete && quadExists(ctx, h.QuadStore, d.Quad):
+			res.Removed++
+		default:
+			res.Ignored++
+		}
+	}
+	if err = h.QuadWriter.ApplyTransaction(tx); err != nil {
+		return jsonResponse(w, 400, err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return jsonResponse(w, 500, err)
+	}
+	w.Write(data)
+	return 200
+}
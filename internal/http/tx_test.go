@@ -0,0 +1,202 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/internal/config"
+	"github.com/google/cayley/quad"
+)
+
+// storeQuads returns the sorted N-Quads of all the quads of the store.
+func storeQuads(api *API) []string {
+	var quads []quad.Quad
+	qs := api.handle.QuadStore
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	for graph.Next(context.TODO(), it) {
+		quads = append(quads, qs.Quad(it.Result()))
+	}
+	return sortedNQuads(quads)
+}
+
+var txTests = []struct {
+	message     string
+	contentType string
+	body        string
+	ignore      bool
+	code        int
+	expect      TxResult
+	// added and deleted are the quads changed by the transaction.
+	added, deleted []quad.Quad
+}{
+	{
+		message: "apply a JSON transaction",
+		body: `[
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "greg"}},
+			{"action": "delete", "quad": {"subject": "alice", "predicate": "follows", "object": "bob"}},
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "greg"}}
+		]`,
+		code:    200,
+		expect:  TxResult{Added: 1, Removed: 1, Ignored: 1},
+		added:   []quad.Quad{quad.Make("alice", "follows", "greg", "")},
+		deleted: []quad.Quad{quad.Make("alice", "follows", "bob", "")},
+	},
+	{
+		message:     "apply a patch",
+		contentType: contentTypePatch,
+		body: `# A comment.
+A <alice> <follows> <greg> .
+D <alice> <follows> <bob> .
+
+A <emily> <follows> <bob> <smart_graph> .
+D <emily> <follows> <bob> <smart_graph> .
+`,
+		code:    200,
+		expect:  TxResult{Added: 1, Removed: 1, Ignored: 2},
+		added:   []quad.Quad{iriQuad("alice", "follows", "greg")},
+		deleted: []quad.Quad{iriQuad("alice", "follows", "bob")},
+	},
+	{
+		message: "roll back a JSON transaction adding an existing quad",
+		body: `[
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "greg"}},
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "bob"}}
+		]`,
+		code: 400,
+	},
+	{
+		message:     "roll back a patch deleting a missing quad",
+		contentType: contentTypePatch,
+		body: `A <alice> <follows> <greg> .
+D <alice> <follows> <fred> .
+`,
+		code: 400,
+	},
+	{
+		message: "reject a JSON transaction with an invalid quad",
+		body: `[
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "greg"}},
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows"}}
+		]`,
+		code: 400,
+	},
+	{
+		message: "reject a JSON transaction with an invalid action",
+		body: `[
+			{"action": "add", "quad": {"subject": "alice", "predicate": "follows", "object": "greg"}},
+			{"action": "update", "quad": {"subject": "alice", "predicate": "follows", "object": "bob"}}
+		]`,
+		code: 400,
+	},
+	{
+		message:     "reject a patch with an invalid line",
+		contentType: contentTypePatch,
+		body: `A <alice> <follows> <greg> .
+X <alice> <follows> <bob> .
+`,
+		code: 400,
+	},
+	{
+		message:     "reject a patch with an invalid quad",
+		contentType: contentTypePatch,
+		body: `A <alice> <follows> <greg> .
+D <alice> <follows .
+`,
+		code: 400,
+	},
+	{
+		message:     "ignore existing and missing quads",
+		contentType: contentTypePatch,
+		body: `A <alice> <follows> <greg> .
+A <alice> <follows> <bob> .
+D <alice> <follows> <fred> .
+D <bob> <follows> <fred> .
+`,
+		ignore:  true,
+		code:    200,
+		expect:  TxResult{Added: 1, Removed: 1, Ignored: 2},
+		added:   []quad.Quad{iriQuad("alice", "follows", "greg")},
+		deleted: []quad.Quad{iriQuad("bob", "follows", "fred")},
+	},
+}
+
+// iriQuad returns a quad of IRIs without a label.
+func iriQuad(s, p, o string) quad.Quad {
+	return quad.Quad{Subject: quad.IRI(s), Predicate: quad.IRI(p), Object: quad.IRI(o)}
+}
+
+func TestTx(t *testing.T) {
+	// The values of JSON quads are raw strings.
+	data := append(readTestData(t), quad.Make("alice", "follows", "bob", ""))
+	for _, test := range txTests {
+		cfg := &config.Config{}
+		if test.ignore {
+			cfg.ReplicationOptions = graph.Options{"ignore_duplicate": true, "ignore_missing": true}
+		}
+		api, r := makeTestAPI(t, cfg, data)
+		before := storeQuads(api)
+
+		var headers []string
+		if test.contentType != "" {
+			headers = []string{"Content-Type", test.contentType}
+		}
+		w := serve(r, "POST", "/api/v1/tx", test.body, headers...)
+		if w.Code != test.code {
+			t.Errorf("Failed to %s, got status:%d expect:%d (%s)", test.message, w.Code, test.code, w.Body)
+			continue
+		}
+		expect := before
+		if test.code == 200 {
+			var res TxResult
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Errorf("Failed to %s, cannot decode the result: %v", test.message, err)
+				continue
+			}
+			test.expect.Result = res.Result
+			if res != test.expect {
+				t.Errorf("Failed to %s, got:%+v expect:%+v", test.message, res, test.expect)
+			}
+			expect = applyChanges(data, test.added, test.deleted)
+		}
+		if got := storeQuads(api); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to %s, unexpected quads in the store, got:%q expect:%q", test.message, got, expect)
+		}
+	}
+}
+
+// applyChanges returns the sorted N-Quads of the quads, with some of them
+// added and deleted.
+func applyChanges(quads, added, deleted []quad.Quad) []string {
+	var out []quad.Quad
+	for _, q := range quads {
+		keep := true
+		for _, d := range deleted {
+			if q == d {
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, q)
+		}
+	}
+	return sortedNQuads(append(out, added...))
+}
@@ -16,6 +16,7 @@ package lru
 
 import (
 	"container/list"
+	"sync/atomic"
 )
 
 // TODO(kortschak) Reimplement without container/list.
@@ -25,6 +26,8 @@ type Cache struct {
 	cache    map[string]*list.Element
 	priority *list.List
 	maxSize  int
+
+	hits, misses int64
 }
 
 type kv struct {
@@ -41,7 +44,8 @@ func New(size int) *Cache {
 }
 
 func (lru *Cache) Put(key string, value interface{}) {
-	if _, ok := lru.Get(key); ok {
+	if element, ok := lru.cache[key]; ok {
+		lru.priority.MoveToFront(element)
 		return
 	}
 	if len(lru.cache) == lru.maxSize {
@@ -54,11 +58,19 @@ func (lru *Cache) Put(key string, value interface{}) {
 func (lru *Cache) Get(key string) (interface{}, bool) {
 	if element, ok := lru.cache[key]; ok {
 		lru.priority.MoveToFront(element)
+		atomic.AddInt64(&lru.hits, 1)
 		return element.Value.(kv).value, true
 	}
+	atomic.AddInt64(&lru.misses, 1)
 	return nil, false
 }
 
+// Stats returns the number of lookups which found a value, and of the ones
+// which did not.
+func (lru *Cache) Stats() (hits, misses int64) {
+	return atomic.LoadInt64(&lru.hits), atomic.LoadInt64(&lru.misses)
+}
+
 func (lru *Cache) removeOldest() {
 	last := lru.priority.Remove(lru.priority.Back())
 	delete(lru.cache, last.(kv).key)
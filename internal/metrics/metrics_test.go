@@ -0,0 +1,65 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := &Counter{vec: newVec("test_total", "A test counter.", []string{"route"}), vals: make(map[string]float64)}
+	c.Inc("/b")
+	c.Add(2, "/a")
+	c.Inc("/a")
+	if v := c.Value("/a"); v != 3 {
+		t.Errorf("Unexpected value, got: %v expected: 3", v)
+	}
+	var buf bytes.Buffer
+	c.write(&buf)
+	expect := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{route="/a"} 3
+test_total{route="/b"} 1
+`
+	if got := buf.String(); got != expect {
+		t.Errorf("Unexpected output, got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := &Histogram{
+		vec:     newVec("test_seconds", "A test histogram.", []string{"lang"}),
+		buckets: []float64{.1, 1},
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+	}
+	h.Observe(.05, `a"b`)
+	h.Observe(.5, `a"b`)
+	h.Observe(5, `a"b`)
+	var buf bytes.Buffer
+	h.write(&buf)
+	expect := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{lang="a\"b",le="0.1"} 1
+test_seconds_bucket{lang="a\"b",le="1"} 2
+test_seconds_bucket{lang="a\"b",le="+Inf"} 3
+test_seconds_sum{lang="a\"b"} 5.55
+test_seconds_count{lang="a\"b"} 3
+`
+	if got := buf.String(); got != expect {
+		t.Errorf("Unexpected output, got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datalog
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad/cquads"
+	"github.com/google/cayley/query"
+
+	_ "github.com/google/cayley/graph/memstore"
+	_ "github.com/google/cayley/writer"
+)
+
+func makeTestSession(t testing.TB) *Session {
+	f, err := os.Open("../../data/testdata.nq")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+	qs, _ := graph.NewQuadStore("memstore", "", nil)
+	w, _ := graph.NewQuadWriter("single", qs, nil)
+	dec := cquads.NewDecoder(f)
+	for q, err := dec.ReadQuad(); err == nil; q, err = dec.ReadQuad() {
+		w.WriteQuad(q)
+	}
+	return NewSession(qs)
+}
+
+func runQuery(ses *Session, input string, limit int) (interface{}, error) {
+	c := make(chan interface{}, 5)
+	go ses.Execute(context.TODO(), input, c, limit)
+	for res := range c {
+		ses.Collate(res)
+	}
+	return ses.Results()
+}
+
+// rows formats the results as sorted strings, so they are easy to compare.
+func rows(res interface{}) []string {
+	out := []string{}
+	list, _ := res.([]interface{})
+	for _, r := range list {
+		obj := r.(map[string]string)
+		var keys []string
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s := ""
+		for _, k := range keys {
+			s += fmt.Sprintf("%s=%s ", k, obj[k])
+		}
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+const reach = `
+	reach(X, Y) :- follows(X, Y).
+	reach(X, Y) :- follows(X, Z), reach(Z, Y).
+`
+
+var testQueries = []struct {
+	message string
+	query   string
+	expect  []string
+}{
+	{
+		message: "query the quads",
+		query:   `?- follows(X, <fred>).`,
+		expect:  []string{"X=<bob> ", "X=<emily> "},
+	},
+	{
+		message: "join atoms",
+		query:   `?- follows(<charlie>, X), status(X, "cool_person").`,
+		expect:  []string{"X=<bob> ", "X=<dani> "},
+	},
+	{
+		message: "use a recursive rule",
+		query:   reach + `?- reach(charlie, Y).`,
+		expect:  []string{"Y=<bob> ", "Y=<dani> ", "Y=<fred> ", "Y=<greg> "},
+	},
+	{
+		message: "use a recursive rule backwards",
+		query:   reach + `?- reach(X, greg).`,
+		expect:  []string{"X=<alice> ", "X=<bob> ", "X=<charlie> ", "X=<dani> ", "X=<emily> ", "X=<fred> "},
+	},
+	{
+		message: "use a left recursive rule",
+		query: `
+			reach(X, Y) :- follows(X, Y).
+			reach(X, Y) :- reach(X, Z), follows(Z, Y).
+			?- reach(alice, Y).
+		`,
+		expect: []string{"Y=<bob> ", "Y=<fred> ", "Y=<greg> "},
+	},
+	{
+		message: "use mutually recursive rules",
+		query: `
+			odd(X, Y) :- follows(X, Y).
+			odd(X, Y) :- even(X, Z), follows(Z, Y).
+			even(X, Y) :- odd(X, Z), follows(Z, Y).
+			?- even(alice, Y).
+		`,
+		expect: []string{"Y=<fred> "},
+	},
+	{
+		message: "use facts",
+		query: `
+			likes(alice, fred).
+			likes(alice, "cake").
+			?- likes(alice, X), follows(X, Y).
+		`,
+		expect: []string{"X=<fred> Y=<greg> "},
+	},
+	{
+		message: "filter with comparisons",
+		query: `
+			cool(X) :- status(X, "cool_person").
+			?- cool(X), follows(X, Y), Y != bob.
+		`,
+		expect: []string{"X=<bob> Y=<fred> ", "X=<dani> Y=<greg> "},
+	},
+	{
+		message: "use labels",
+		query:   `?- status(X, S, <smart_graph>).`,
+		expect:  []string{`S="smart_person" X=<emily> `, `S="smart_person" X=<greg> `},
+	},
+	{
+		message: "hide anonymous variables",
+		query:   `?- follows(X, _), status(X, _).`,
+		expect:  []string{"X=<bob> ", "X=<dani> ", "X=<emily> "},
+	},
+	{
+		message: "answer ground queries",
+		query:   reach + `?- reach(alice, greg).`,
+		expect:  []string{""},
+	},
+	{
+		message: "answer false ground queries",
+		query:   reach + `?- reach(greg, alice).`,
+		expect:  []string{},
+	},
+}
+
+func TestQueries(t *testing.T) {
+	for _, test := range testQueries {
+		ses := makeTestSession(t)
+		res, err := runQuery(ses, test.query, -1)
+		if err != nil {
+			t.Errorf("Failed to %s: %v", test.message, err)
+			continue
+		}
+		if got := rows(res); !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Failed to %s, got: %q expected: %q", test.message, got, test.expect)
+		}
+	}
+}
+
+func TestSessionRules(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, input := range []string{
+		`reach(X, Y) :- follows(X, Y).`,
+		`reach(X, Y) :- follows(X, Z), reach(Z, Y).`,
+	} {
+		if _, err := runQuery(ses, input, -1); err != nil {
+			t.Fatalf("Failed to declare %q: %v", input, err)
+		}
+	}
+	res, err := runQuery(ses, `?- reach(X, fred).`, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"X=<alice> ", "X=<bob> ", "X=<charlie> ", "X=<dani> ", "X=<emily> "}
+	if got := rows(res); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Unexpected results, got: %q expected: %q", got, expect)
+	}
+	res, err = runQuery(ses, `?- reach(X, fred).`, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows(res); len(got) != 2 {
+		t.Errorf("Unexpected results with a limit, got: %q", got)
+	}
+	if !ses.Limited() {
+		t.Errorf("Expected the query to be limited")
+	}
+	// The limit is not reached when there are no more results.
+	res, err = runQuery(ses, `?- reach(X, fred).`, len(expect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows(res); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Unexpected results at the limit, got: %q expected: %q", got, expect)
+	}
+	if ses.Limited() {
+		t.Errorf("Unexpected limited query with as many results as the limit")
+	}
+}
+
+func TestParse(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, test := range []struct {
+		input  string
+		expect query.ParseResult
+	}{
+		{`reach(X, Y) :- follows(X, Y).`, query.Parsed},
+		{`reach(X, Y) :-`, query.ParseMore},
+		{`?- reach(X, Y)`, query.ParseMore},
+		{`reach(X, Y) :- .`, query.ParseFail},
+	} {
+		if got, _ := ses.Parse(test.input); got != test.expect {
+			t.Errorf("Unexpected parse result for %q, got: %v expected: %v", test.input, got, test.expect)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, input := range []string{
+		`?- follows(X, Y)`,
+		`reach(X, Y) :- follows(X, Z).`,
+		`friend(X) :- follows(X, Y), Z != Y.`,
+		`likes(alice, X).`,
+		`?- follows(X).`,
+		`reach(X) :- follows(X, Y). ?- reach(X, Y).`,
+		`reach(X) :- follows(X, Y). reach(X, Y) :- follows(X, Y).`,
+		`?- follows(X, Y). ?- follows(Y, X).`,
+		`<follows>(X, Y) :- follows(Y, X).`,
+	} {
+		if _, err := runQuery(makeTestSession(t), input, -1); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datalog
+
+// Evaluates Datalog programs bottom-up.
+//
+// Atoms of the quad store relations are compiled to iterator trees, with the
+// arguments already bound by the previous atoms of the rule as fixed
+// iterators. The derived relations are materialized, and computed with
+// semi-naive evaluation: every round only joins the tuples found by the
+// previous round with the others, until no new tuple is found.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query"
+)
+
+type tuple []quad.Value
+
+// binding maps the variables of a rule to their values.
+type binding map[string]quad.Value
+
+func valueKey(v quad.Value) string {
+	return v.String()
+}
+
+func tupleKey(t tuple) string {
+	keys := make([]string, len(t))
+	for i, v := range t {
+		keys[i] = valueKey(v)
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// version selects the tuples of a derived relation used by an atom.
+type version int
+
+const (
+	// All the tuples.
+	versionFull version = iota
+	// The tuples found before the last round.
+	versionOld
+	// The tuples found by the last round.
+	versionDelta
+)
+
+// relation is a materialized derived relation.
+type relation struct {
+	arity  int
+	tuples []tuple
+	seen   map[string]bool
+	// index maps the values of each column to the positions of the tuples.
+	index []map[string][]int
+	// delta is the position of the first tuple found by the last round.
+	delta int
+	added []tuple
+}
+
+func newRelation(arity int) *relation {
+	r := &relation{
+		arity: arity,
+		seen:  make(map[string]bool),
+		index: make([]map[string][]int, arity),
+	}
+	for i := range r.index {
+		r.index[i] = make(map[string][]int)
+	}
+	return r
+}
+
+// add adds a tuple to the ones found by the current round.
+func (r *relation) add(t tuple) {
+	k := tupleKey(t)
+	if r.seen[k] {
+		return
+	}
+	r.seen[k] = true
+	r.added = append(r.added, t)
+}
+
+// commit ends a round, and reports whether it found new tuples.
+func (r *relation) commit() bool {
+	r.delta = len(r.tuples)
+	for _, t := range r.added {
+		for i, v := range t {
+			k := valueKey(v)
+			r.index[i][k] = append(r.index[i][k], len(r.tuples))
+		}
+		r.tuples = append(r.tuples, t)
+	}
+	r.added = nil
+	return len(r.tuples) > r.delta
+}
+
+// scan calls fn for the tuples of a version of the relation which match the
+// non-nil values of pattern.
+func (r *relation) scan(ver version, pattern tuple, fn func(tuple) error) error {
+	lo, hi := 0, len(r.tuples)
+	switch ver {
+	case versionOld:
+		hi = r.delta
+	case versionDelta:
+		lo = r.delta
+	}
+	var positions []int
+	indexed := false
+	for i, v := range pattern {
+		if v != nil {
+			positions, indexed = r.index[i][valueKey(v)], true
+			break
+		}
+	}
+	if !indexed {
+		positions = make([]int, 0, hi-lo)
+		for i := lo; i < hi; i++ {
+			positions = append(positions, i)
+		}
+	}
+next:
+	for _, p := range positions {
+		if p < lo || p >= hi {
+			continue
+		}
+		t := r.tuples[p]
+		for i, v := range pattern {
+			if v != nil && valueKey(v) != valueKey(t[i]) {
+				continue next
+			}
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literal is an atom of a rule, with the version of the relation it uses.
+type literal struct {
+	a   *atom
+	ver version
+}
+
+type evaluator struct {
+	qs    graph.QuadStore
+	rules map[string][]*rule
+	rels  map[string]*relation
+}
+
+func newEvaluator(qs graph.QuadStore, rules map[string][]*rule) *evaluator {
+	return &evaluator{
+		qs:    qs,
+		rules: rules,
+		rels:  make(map[string]*relation),
+	}
+}
+
+func (e *evaluator) isDerived(a *atom) bool {
+	return len(e.rules[a.name()]) > 0
+}
+
+// prepare creates the relations used by the atoms, and checks the arity of
+// every atom they depend on.
+func (e *evaluator) prepare(atoms []atom) error {
+	for i := range atoms {
+		a := &atoms[i]
+		name := a.name()
+		if !e.isDerived(a) {
+			if len(a.args) != 2 && len(a.args) != 3 {
+				return fmt.Errorf("%v at char %d: relations of the quad store take a subject, an object and an optional label", a, a.pos)
+			}
+			continue
+		}
+		arity := len(e.rules[name][0].head.args)
+		if len(a.args) != arity {
+			return fmt.Errorf("%v at char %d: %s takes %d arguments", a, a.pos, name, arity)
+		}
+		if _, ok := e.rels[name]; ok {
+			continue
+		}
+		e.rels[name] = newRelation(arity)
+		for _, r := range e.rules[name] {
+			if err := e.prepare(r.body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run computes the relations created by prepare, and returns the number of
+// rounds it took.
+func (e *evaluator) run(ctx context.Context) (int, error) {
+	var rules []*rule
+	for name := range e.rels {
+		rules = append(rules, e.rules[name]...)
+	}
+	// The first round only uses the rules which do not depend on derived
+	// relations, as they are all empty.
+	for _, r := range rules {
+		derived := false
+		for i := range r.body {
+			derived = derived || e.isDerived(&r.body[i])
+		}
+		if derived {
+			continue
+		}
+		if err := e.apply(ctx, r, -1); err != nil {
+			return 0, err
+		}
+	}
+	rounds := 1
+	for e.commit() {
+		rounds++
+		for _, r := range rules {
+			for i := range r.body {
+				a := &r.body[i]
+				if !e.isDerived(a) {
+					continue
+				}
+				if rel := e.rels[a.name()]; rel.delta == len(rel.tuples) {
+					continue
+				}
+				if err := e.apply(ctx, r, i); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return rounds, nil
+}
+
+func (e *evaluator) commit() bool {
+	changed := false
+	for _, rel := range e.rels {
+		if rel.commit() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// apply adds the tuples derived by a rule. If delta is a valid position, the
+// atom at that position only uses the tuples found by the last round, the
+// previous atoms use the older tuples and the next ones use all of them, so
+// that every combination including a new tuple is joined exactly once.
+func (e *evaluator) apply(ctx context.Context, r *rule, delta int) error {
+	var lits []literal
+	if delta >= 0 {
+		lits = append(lits, literal{a: &r.body[delta], ver: versionDelta})
+	}
+	for i := range r.body {
+		switch {
+		case i == delta:
+		case i < delta:
+			lits = append(lits, literal{a: &r.body[i], ver: versionOld})
+		default:
+			lits = append(lits, literal{a: &r.body[i], ver: versionFull})
+		}
+	}
+	rel := e.rels[r.head.name()]
+	return e.solve(ctx, lits, r.comps, binding{}, func(b binding) error {
+		t := make(tuple, len(r.head.args))
+		for i, tm := range r.head.args {
+			t[i] = b.value(tm)
+		}
+		rel.add(t)
+		return nil
+	})
+}
+
+// solve calls emit for every solution of the literals and the comparisons
+// which extends b.
+func (e *evaluator) solve(ctx context.Context, lits []literal, comps []comparison, b binding, emit func(binding) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(lits) == 0 {
+		for _, c := range comps {
+			if !c.holds(b) {
+				return nil
+			}
+		}
+		return emit(b)
+	}
+	next := func(b binding) error {
+		return e.solve(ctx, lits[1:], comps, b, emit)
+	}
+	if e.isDerived(lits[0].a) {
+		return e.matchRelation(lits[0], b, next)
+	}
+	return e.matchQuads(ctx, lits[0].a, b, next)
+}
+
+func (e *evaluator) matchRelation(l literal, b binding, fn func(binding) error) error {
+	pattern := make(tuple, len(l.a.args))
+	for i, tm := range l.a.args {
+		pattern[i] = b.value(tm)
+	}
+	return e.rels[l.a.name()].scan(l.ver, pattern, func(t tuple) error {
+		if nb, ok := b.extend(l.a.args, t); ok {
+			return fn(nb)
+		}
+		return nil
+	})
+}
+
+// quadDirections are the directions of the arguments of the quad store
+// relations.
+var quadDirections = []quad.Direction{quad.Subject, quad.Object, quad.Label}
+
+// buildIterator builds the iterator tree for the quads matching an atom of a
+// quad store relation. The unbound arguments are tagged with their positions.
+func (e *evaluator) buildIterator(a *atom, b binding) graph.Iterator {
+	root := -1
+	and := iterator.NewAnd(e.qs)
+	and.AddSubIterator(iterator.NewLinksTo(e.qs, e.fixed(a.pred), quad.Predicate))
+	for i, tm := range a.args {
+		var it graph.Iterator
+		if v := b.value(tm); v != nil {
+			it = e.fixed(v)
+		} else if root < 0 {
+			root = i
+			continue
+		} else {
+			it = e.qs.NodesAllIterator()
+			it.Tagger().Add(strconv.Itoa(i))
+		}
+		and.AddSubIterator(iterator.NewLinksTo(e.qs, it, quadDirections[i]))
+	}
+	if root < 0 {
+		return iterator.NewHasA(e.qs, and, quad.Subject)
+	}
+	it := iterator.NewHasA(e.qs, and, quadDirections[root])
+	it.Tagger().Add(strconv.Itoa(root))
+	return it
+}
+
+func (e *evaluator) fixed(v quad.Value) graph.Iterator {
+	val := e.qs.ValueOf(v)
+	if val == nil {
+		return iterator.NewNull()
+	}
+	fixed := e.qs.FixedIterator()
+	fixed.Add(val)
+	return fixed
+}
+
+func (e *evaluator) matchQuads(ctx context.Context, a *atom, b binding, fn func(binding) error) error {
+	it := query.Optimize(ctx, e.buildIterator(a, b))
+	defer it.Close()
+	found := func() error {
+		tags := make(map[string]graph.Value)
+		it.TagResults(tags)
+		t := make(tuple, len(a.args))
+		for i := range t {
+			if v, ok := tags[strconv.Itoa(i)]; ok {
+				t[i] = e.qs.NameOf(v)
+			} else {
+				t[i] = b.value(a.args[i])
+			}
+		}
+		if nb, ok := b.extend(a.args, t); ok {
+			return fn(nb)
+		}
+		return nil
+	}
+	for graph.Next(ctx, it) {
+		if err := found(); err != nil {
+			return err
+		}
+		for it.NextPath(ctx) {
+			if err := found(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// value returns the value of a term, or nil for an unbound variable.
+func (b binding) value(t term) quad.Value {
+	if t.isVar() {
+		return b[t.name]
+	}
+	return t.val
+}
+
+// extend binds the variables of the arguments to the values of the tuple. It
+// fails if a variable is bound to a different value.
+func (b binding) extend(args []term, t tuple) (binding, bool) {
+	nb := make(binding, len(b)+len(args))
+	for k, v := range b {
+		nb[k] = v
+	}
+	for i, tm := range args {
+		if t[i] == nil {
+			return nil, false
+		}
+		if !tm.isVar() {
+			continue
+		}
+		if v, ok := nb[tm.name]; ok {
+			if valueKey(v) != valueKey(t[i]) {
+				return nil, false
+			}
+			continue
+		}
+		nb[tm.name] = t[i]
+	}
+	return nb, true
+}
+
+var compareOps = map[string]iterator.Operator{
+	"<":  iterator.CompareLT,
+	"<=": iterator.CompareLTE,
+	">":  iterator.CompareGT,
+	">=": iterator.CompareGTE,
+}
+
+// holds checks whether the comparison is true for the values of b. Values of
+// different types are never ordered.
+func (c *comparison) holds(b binding) bool {
+	x, y := b.value(c.a), b.value(c.b)
+	switch c.op {
+	case "=":
+		return valueKey(x) == valueKey(y)
+	case "!=":
+		return valueKey(x) != valueKey(y)
+	}
+	op := compareOps[c.op]
+	switch x := x.(type) {
+	case quad.Int:
+		switch y := y.(type) {
+		case quad.Int:
+			return iterator.RunIntOp(x, op, y)
+		case quad.Float:
+			return iterator.RunFloatOp(quad.Float(x), op, y)
+		}
+	case quad.Float:
+		switch y := y.(type) {
+		case quad.Int:
+			return iterator.RunFloatOp(x, op, quad.Float(y))
+		case quad.Float:
+			return iterator.RunFloatOp(x, op, y)
+		}
+	case quad.String:
+		if y, ok := y.(quad.String); ok {
+			return iterator.RunStrOp(string(x), op, string(y))
+		}
+	case quad.IRI:
+		if y, ok := y.(quad.IRI); ok {
+			return iterator.RunStrOp(string(x), op, string(y))
+		}
+	case quad.Time:
+		if y, ok := y.(quad.Time); ok {
+			return iterator.RunTimeOp(time.Time(x), op, time.Time(y))
+		}
+	}
+	return false
+}
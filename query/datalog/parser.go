@@ -0,0 +1,475 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datalog
+
+// Parses Datalog programs: rules, facts and queries, each ending with a
+// period.
+//
+//   ancestor(X, Y) :- parent(X, Y).
+//   ancestor(X, Y) :- parent(X, Z), ancestor(Z, Y).
+//   ?- ancestor(X, <bob>).
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/cayley/quad"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokName
+	tokVar
+	tokIRI
+	tokBlank
+	tokString
+	tokInt
+	tokFloat
+	tokPunct
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of input"
+	case tokIRI:
+		return "<" + t.val + ">"
+	case tokBlank:
+		return "_:" + t.val
+	case tokString:
+		return strconv.Quote(t.val)
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+func (t token) is(s string) bool {
+	return t.typ == tokPunct && t.val == s
+}
+
+// operators lists the punctuation, longest first.
+var operators = []string{":-", "?-", "!=", "<=", ">=", "(", ")", ",", ".", "=", "<", ">"}
+
+func isNameChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lex splits the input into tokens. Comments start with % and run to the end
+// of the line. The last token is always tokEOF.
+func lex(input string) ([]token, error) {
+	var toks []token
+	s := []rune(input)
+	for i := 0; ; {
+		for i < len(s) && (unicode.IsSpace(s[i]) || s[i] == '%') {
+			if s[i] == '%' {
+				for i < len(s) && s[i] != '\n' {
+					i++
+				}
+				continue
+			}
+			i++
+		}
+		if i >= len(s) {
+			return append(toks, token{typ: tokEOF, pos: i}), nil
+		}
+		start := i
+		r := s[i]
+		switch {
+		case r == '<':
+			// Either an IRI or a comparison operator.
+			j := i + 1
+			for j < len(s) && s[j] != '>' && !unicode.IsSpace(s[j]) && !strings.ContainsRune(`<"{}|^`+"`\\", s[j]) {
+				j++
+			}
+			if j < len(s) && s[j] == '>' {
+				toks = append(toks, token{typ: tokIRI, val: string(s[i+1 : j]), pos: start})
+				i = j + 1
+				continue
+			}
+		case r == '_' && i+1 < len(s) && s[i+1] == ':':
+			j := i + 2
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			toks = append(toks, token{typ: tokBlank, val: string(s[i+2 : j]), pos: start})
+			i = j
+			continue
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			typ := tokName
+			if r == '_' || unicode.IsUpper(r) {
+				typ = tokVar
+			}
+			toks = append(toks, token{typ: typ, val: string(s[i:j]), pos: start})
+			i = j
+			continue
+		case r == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' && s[j] != '\n' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) || s[j] != '"' {
+				return nil, fmt.Errorf("unterminated string at char %d", start)
+			}
+			val, err := strconv.Unquote(string(s[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at char %d: %v", start, err)
+			}
+			toks = append(toks, token{typ: tokString, val: val, pos: start})
+			i = j + 1
+			continue
+		case unicode.IsDigit(r) || r == '-' && i+1 < len(s) && unicode.IsDigit(s[i+1]):
+			j := i + 1
+			typ := tokInt
+			for j < len(s) && (unicode.IsDigit(s[j]) || s[j] == '.' && typ == tokInt && j+1 < len(s) && unicode.IsDigit(s[j+1])) {
+				if s[j] == '.' {
+					typ = tokFloat
+				}
+				j++
+			}
+			toks = append(toks, token{typ: typ, val: string(s[i:j]), pos: start})
+			i = j
+			continue
+		}
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(string(s[i:]), op) {
+				toks = append(toks, token{typ: tokPunct, val: op, pos: start})
+				i += len([]rune(op))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected character %q at char %d", r, start)
+		}
+	}
+}
+
+// term is either a variable or a constant.
+type term struct {
+	name string
+	val  quad.Value
+}
+
+func (t term) isVar() bool { return t.name != "" }
+
+func (t term) String() string {
+	if t.isVar() {
+		return t.name
+	}
+	return t.val.String()
+}
+
+// atom is a relation applied to terms. Relations which are not defined by
+// rules are the predicates of the quads, with the subject, the object and
+// the optional label as their arguments.
+type atom struct {
+	pred quad.Value
+	args []term
+	pos  int
+}
+
+// name returns the name of the relation.
+func (a *atom) name() string {
+	if iri, ok := a.pred.(quad.IRI); ok && isIdent(string(iri)) {
+		return string(iri)
+	}
+	return a.pred.String()
+}
+
+func (a *atom) String() string {
+	args := make([]string, len(a.args))
+	for i, t := range a.args {
+		args[i] = t.String()
+	}
+	return fmt.Sprintf("%s(%s)", a.name(), strings.Join(args, ", "))
+}
+
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !isNameChar(r) || i == 0 && !unicode.IsLower(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// comparison is a built-in predicate comparing two terms.
+type comparison struct {
+	op   string
+	a, b term
+	pos  int
+}
+
+// rule derives the tuples of the head from the solutions of the body. Facts
+// are rules without a body.
+type rule struct {
+	head  atom
+	body  []atom
+	comps []comparison
+}
+
+// program is a parsed input: a list of rules and an optional query.
+type program struct {
+	rules []*rule
+	query *rule
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	anon int
+}
+
+func parse(input string) (*program, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	prog := &program{}
+	for p.peek().typ != tokEOF {
+		if p.peek().is("?-") {
+			pos := p.next().pos
+			if prog.query != nil {
+				return nil, fmt.Errorf("only one query is allowed at char %d", pos)
+			}
+			q := &rule{}
+			if err := p.parseBody(q); err != nil {
+				return nil, err
+			}
+			prog.query = q
+		} else {
+			r, err := p.parseRule()
+			if err != nil {
+				return nil, err
+			}
+			prog.rules = append(prog.rules, r)
+		}
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range prog.rules {
+		if err := r.check(); err != nil {
+			return nil, err
+		}
+	}
+	if prog.query != nil {
+		if err := prog.query.check(); err != nil {
+			return nil, err
+		}
+	}
+	return prog, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(s string) error {
+	if t := p.peek(); !t.is(s) {
+		return fmt.Errorf("expected %q, got %v at char %d", s, t, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseRule() (*rule, error) {
+	if t := p.peek(); t.typ != tokName {
+		return nil, fmt.Errorf("expected the name of a relation, got %v at char %d", t, t.pos)
+	}
+	head, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	r := &rule{head: *head}
+	if p.peek().is(":-") {
+		p.next()
+		if err := p.parseBody(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// parseBody parses a comma separated list of atoms and comparisons.
+func (p *parser) parseBody(r *rule) error {
+	for {
+		if t := p.peek(); (t.typ == tokName || t.typ == tokIRI) && p.toks[p.pos+1].is("(") {
+			a, err := p.parseAtom()
+			if err != nil {
+				return err
+			}
+			r.body = append(r.body, *a)
+		} else {
+			c, err := p.parseComparison()
+			if err != nil {
+				return err
+			}
+			r.comps = append(r.comps, *c)
+		}
+		if !p.peek().is(",") {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseAtom() (*atom, error) {
+	t := p.next()
+	a := &atom{pred: quad.IRI(t.val), pos: t.pos}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.peek().is(")") {
+		if len(a.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		tm, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		a.args = append(a.args, tm)
+	}
+	p.next()
+	return a, nil
+}
+
+func (p *parser) parseComparison() (*comparison, error) {
+	pos := p.peek().pos
+	a, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op.val {
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("expected a comparison, got %v at char %d", op, op.pos)
+	}
+	b, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	return &comparison{op: op.val, a: a, b: b, pos: pos}, nil
+}
+
+// parseTerm parses a variable or a constant. Names starting with a lower case
+// letter are IRIs, so that bob and <bob> are the same node.
+func (p *parser) parseTerm() (term, error) {
+	t := p.next()
+	switch t.typ {
+	case tokVar:
+		if t.val == "_" {
+			// Every anonymous variable is a different one.
+			p.anon++
+			return term{name: fmt.Sprintf("_#%d", p.anon)}, nil
+		}
+		return term{name: t.val}, nil
+	case tokName, tokIRI:
+		return term{val: quad.IRI(t.val)}, nil
+	case tokBlank:
+		return term{val: quad.BNode(t.val)}, nil
+	case tokString:
+		return term{val: quad.String(t.val)}, nil
+	case tokInt:
+		n, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid integer %q at char %d", t.val, t.pos)
+		}
+		return term{val: quad.Int(n)}, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid number %q at char %d", t.val, t.pos)
+		}
+		return term{val: quad.Float(f)}, nil
+	}
+	return term{}, fmt.Errorf("expected a term, got %v at char %d", t, t.pos)
+}
+
+// check verifies that the rule is safe: every variable of the head and of the
+// comparisons has to appear in an atom of the body.
+func (r *rule) check() error {
+	bound := make(map[string]bool)
+	for _, a := range r.body {
+		for _, t := range a.args {
+			if t.isVar() {
+				bound[t.name] = true
+			}
+		}
+	}
+	for _, t := range r.head.args {
+		if t.isVar() && !bound[t.name] {
+			return fmt.Errorf("variable %s of %v does not appear in the body of the rule at char %d", t.name, &r.head, r.head.pos)
+		}
+	}
+	for _, c := range r.comps {
+		for _, t := range []term{c.a, c.b} {
+			if t.isVar() && !bound[t.name] {
+				return fmt.Errorf("variable %s of the comparison at char %d does not appear in an atom", t.name, c.pos)
+			}
+		}
+	}
+	return nil
+}
+
+// vars returns the named variables of the body, in order of appearance.
+func (r *rule) vars() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, a := range r.body {
+		for _, t := range a.args {
+			if t.isVar() && !isAnonymous(t.name) && !seen[t.name] {
+				seen[t.name] = true
+				out = append(out, t.name)
+			}
+		}
+	}
+	return out
+}
+
+// isAnonymous checks whether the variable is hidden from the results, as
+// variables starting with an underscore are.
+func isAnonymous(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datalog
+
+// Defines a running session of the Datalog query language. The rules declared
+// in a session are kept for the following queries.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query"
+)
+
+// errLimit stops the query once enough results are sent.
+var errLimit = errors.New("limit reached")
+
+type Session struct {
+	qs    graph.QuadStore
+	debug bool
+	rules map[string][]*rule
+
+	vars       []string
+	err        error
+	limited    bool
+	dataOutput []interface{}
+}
+
+func NewSession(qs graph.QuadStore) *Session {
+	return &Session{
+		qs:    qs,
+		rules: make(map[string][]*rule),
+	}
+}
+
+func (s *Session) Debug(ok bool) {
+	s.debug = ok
+}
+
+// Parse waits for the input to end with a period.
+func (s *Session) Parse(input string) (query.ParseResult, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return query.ParseFail, err
+	}
+	if len(toks) < 2 || !toks[len(toks)-2].is(".") {
+		return query.ParseMore, nil
+	}
+	if _, err := parse(input); err != nil {
+		return query.ParseFail, err
+	}
+	return query.Parsed, nil
+}
+
+// ShapeOf returns the shape of the first atom of the query which is a relation
+// of the quad store.
+func (s *Session) ShapeOf(input string) (interface{}, error) {
+	prog, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if prog.query == nil {
+		return nil, fmt.Errorf("no query to describe")
+	}
+	rules, err := s.withRules(prog.rules)
+	if err != nil {
+		return nil, err
+	}
+	e := newEvaluator(s.qs, rules)
+	for i := range prog.query.body {
+		a := &prog.query.body[i]
+		if e.isDerived(a) {
+			continue
+		}
+		output := make(map[string]interface{})
+		iterator.OutputQueryShapeForIterator(e.buildIterator(a, binding{}), s.qs, output)
+		return output, nil
+	}
+	return nil, fmt.Errorf("the query only uses derived relations")
+}
+
+// withRules returns the rules of the session with the new ones added, after
+// checking that the relations keep the same arity.
+func (s *Session) withRules(add []*rule) (map[string][]*rule, error) {
+	rules := make(map[string][]*rule, len(s.rules))
+	for name, rs := range s.rules {
+		rules[name] = rs
+	}
+	for _, r := range add {
+		name := r.head.name()
+		if rs := rules[name]; len(rs) > 0 && len(rs[0].head.args) != len(r.head.args) {
+			return nil, fmt.Errorf("%v at char %d: %s takes %d arguments", &r.head, r.head.pos, name, len(rs[0].head.args))
+		}
+		rules[name] = append(rules[name][:len(rules[name]):len(rules[name])], r)
+	}
+	return rules, nil
+}
+
+// Execute adds the rules of the input to the session, and runs its query, if
+// any. Every solution of the query is sent as a map of its variables to
+// their values.
+func (s *Session) Execute(ctx context.Context, input string, out chan interface{}, limit int) {
+	defer close(out)
+	s.err = nil
+	s.limited = false
+	prog, err := parse(input)
+	if err != nil {
+		s.err = err
+		return
+	}
+	rules, err := s.withRules(prog.rules)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.rules = rules
+	q := prog.query
+	if q == nil {
+		return
+	}
+	s.vars = q.vars()
+	e := newEvaluator(s.qs, s.rules)
+	if s.err = e.prepare(q.body); s.err != nil {
+		return
+	}
+	rounds, err := e.run(ctx)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if s.debug {
+		var names []string
+		for name := range e.rels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s/%d: %d tuples\n", name, e.rels[name].arity, len(e.rels[name].tuples))
+		}
+		fmt.Printf("evaluated in %d rounds\n", rounds)
+	}
+	lits := make([]literal, len(q.body))
+	for i := range q.body {
+		lits[i] = literal{a: &q.body[i], ver: versionFull}
+	}
+	seen := make(map[string]bool)
+	n := 0
+	err = e.solve(ctx, lits, q.comps, binding{}, func(b binding) error {
+		row := make(map[string]quad.Value, len(s.vars))
+		t := make(tuple, len(s.vars))
+		for i, name := range s.vars {
+			row[name], t[i] = b[name], b[name]
+		}
+		k := tupleKey(t)
+		if seen[k] {
+			return nil
+		}
+		seen[k] = true
+		// The query only stops at a solution past the limit, so that it is
+		// known to be limited.
+		if limit > 0 && n >= limit {
+			return errLimit
+		}
+		n++
+		select {
+		case out <- row:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err == errLimit {
+		s.limited = true
+	} else {
+		s.err = err
+	}
+}
+
+// Limited returns whether the last query stopped at the limit of results.
+func (s *Session) Limited() bool {
+	return s.limited
+}
+
+func (s *Session) Format(result interface{}) string {
+	r, ok := result.(map[string]quad.Value)
+	if !ok {
+		return ""
+	}
+	out := fmt.Sprintln("****")
+	for _, name := range s.vars {
+		out += fmt.Sprintf("%s : %v\n", name, r[name])
+	}
+	return out
+}
+
+// StreamResult returns the output of a single result, as an object mapping
+// the variables of the query to their values.
+func (s *Session) StreamResult(result interface{}) (interface{}, bool) {
+	r, ok := result.(map[string]quad.Value)
+	if !ok {
+		return nil, false
+	}
+	obj := make(map[string]string, len(r))
+	for name, v := range r {
+		obj[name] = v.String()
+	}
+	return obj, true
+}
+
+// Collate adds a result to the output, as an object mapping the variables of
+// the query to their values.
+func (s *Session) Collate(result interface{}) {
+	if obj, ok := s.StreamResult(result); ok {
+		s.dataOutput = append(s.dataOutput, obj)
+	}
+}
+
+func (s *Session) Results() (interface{}, error) {
+	defer s.Clear()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.dataOutput, nil
+}
+
+func (s *Session) Clear() {
+	s.dataOutput = nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+// Explains the iterator trees run by the queries of any language.
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+)
+
+// MaxExplainedIterators is the maximal number of iterator trees described
+// for a query. Languages such as Datalog build a tree for every step of their
+// evaluation.
+var MaxExplainedIterators = 100
+
+// Explanation holds the plans of the iterator trees used by a query.
+type Explanation struct {
+	Iterators []Plan `json:"iterators"`
+	// Omitted is the number of trees over MaxExplainedIterators.
+	Omitted  int  `json:"omitted,omitempty"`
+	Analyzed bool `json:"analyzed"`
+}
+
+// Plan describes an iterator tree as it was built by the query language, and
+// as it was after Optimize, which includes the optimizations of the quad
+// store.
+type Plan struct {
+	Before graph.IteratorPlan `json:"before"`
+	After  graph.IteratorPlan `json:"after"`
+}
+
+type explainKey struct{}
+
+type explainer struct {
+	analyze bool
+	profile *graph.Profile
+
+	mu      sync.Mutex
+	plans   []Plan
+	its     []graph.Iterator
+	omitted int
+}
+
+// Optimize optimizes an iterator tree before a query runs it. When the query
+// is explained, the tree is described before and after being optimized; unless
+// the query is analyzed, an empty iterator is returned instead, so that the
+// query does not read the data. The tree is also recorded for the statistics of
+// the query, if they are collected.
+func Optimize(ctx context.Context, it graph.Iterator) graph.Iterator {
+	e, _ := ctx.Value(explainKey{}).(*explainer)
+	if e == nil {
+		it, _ = it.Optimize()
+		if c, ok := ctx.Value(statsKey{}).(*statsCollector); ok {
+			c.collect(it)
+		}
+		return it
+	}
+	before := graph.DumpPlan(it, nil)
+	it, _ = it.Optimize()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.plans) >= MaxExplainedIterators {
+		e.omitted++
+		if !e.analyze {
+			it.Close()
+			return iterator.NewNull()
+		}
+		return it
+	}
+	e.plans = append(e.plans, Plan{Before: before})
+	if !e.analyze {
+		e.plans[len(e.plans)-1].After = graph.DumpPlan(it, nil)
+		it.Close()
+		return iterator.NewNull()
+	}
+	e.its = append(e.its, it)
+	e.profile.Add(it)
+	return it
+}
+
+// Explain runs the query in the session, and returns the plans of the
+// iterator trees it used. When analyze is set, the plans hold the calls made
+// to each iterator and the time they took; otherwise no data is read, so the
+// trees which depend on the results of others may be missing.
+func Explain(ctx context.Context, ses Executor, q string, analyze bool) (*Explanation, error) {
+	e := &explainer{analyze: analyze}
+	if analyze {
+		e.profile = graph.StartProfile()
+	}
+	ctx = context.WithValue(ctx, explainKey{}, e)
+	c := make(chan interface{}, 5)
+	go ses.Execute(ctx, q, c, 100)
+	hs, _ := ses.(HTTP)
+	for res := range c {
+		if hs != nil {
+			hs.Collate(res)
+		}
+	}
+	var err error
+	if hs != nil {
+		_, err = hs.Results()
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if analyze {
+		e.profile.Stop()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, it := range e.its {
+		e.plans[i].After = graph.DumpPlan(it, e.profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Explanation{
+		Iterators: e.plans,
+		Omitted:   e.omitted,
+		Analyzed:  analyze,
+	}, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad/cquads"
+
+	_ "github.com/google/cayley/graph/memstore"
+	_ "github.com/google/cayley/writer"
+)
+
+func makeTestSession(t testing.TB) *Session {
+	f, err := os.Open("../../data/testdata.nq")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+	qs, _ := graph.NewQuadStore("memstore", "", nil)
+	w, _ := graph.NewQuadWriter("single", qs, nil)
+	dec := cquads.NewDecoder(f)
+	for q, err := dec.ReadQuad(); err == nil; q, err = dec.ReadQuad() {
+		w.WriteQuad(q)
+	}
+	return NewSession(qs)
+}
+
+func runQuery(ses *Session, query string) (interface{}, error) {
+	c := make(chan interface{}, 5)
+	go ses.Execute(context.TODO(), query, c, -1)
+	for res := range c {
+		ses.Collate(res)
+	}
+	return ses.Results()
+}
+
+// normalize converts the results to their JSON form, with sorted lists, as
+// the order of the nodes is not defined.
+func normalize(t testing.TB, v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return sortLists(out)
+}
+
+func sortLists(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		keys := make([]string, len(v))
+		for i := range v {
+			v[i] = sortLists(v[i])
+			b, _ := json.Marshal(v[i])
+			keys[i] = string(b)
+		}
+		sort.Sort(byKey{keys: keys, vals: v})
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = sortLists(v[k])
+		}
+	}
+	return v
+}
+
+type byKey struct {
+	keys []string
+	vals []interface{}
+}
+
+func (s byKey) Len() int           { return len(s.keys) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.vals[i], s.vals[j] = s.vals[j], s.vals[i]
+}
+
+var testQueries = []struct {
+	message string
+	query   string
+	expect  string
+}{
+	{
+		message: "select a node",
+		query:   `{ nodes(id: "<bob>") { id status } }`,
+		expect:  `{"nodes": [{"id": "<bob>", "status": "cool_person"}]}`,
+	},
+	{
+		message: "filter nodes by value",
+		query:   `{ nodes(status: "cool_person") { id } }`,
+		expect:  `{"nodes": [{"id": "<bob>"}, {"id": "<dani>"}, {"id": "<greg>"}]}`,
+	},
+	{
+		message: "use IRIs as names",
+		query:   `{ nodes(<status>: ["smart_person"]) { id } }`,
+		expect:  `{"nodes": [{"id": "<emily>"}, {"id": "<greg>"}]}`,
+	},
+	{
+		message: "follow predicates",
+		query: `
+			query {
+				nodes(id: "<dani>") {
+					id
+					follows {
+						id
+						status
+					}
+				}
+			}
+		`,
+		expect: `{"nodes": [{"id": "<dani>", "follows": [
+			{"id": "<bob>", "status": "cool_person"},
+			{"id": "<greg>", "status": ["cool_person", "smart_person"]}
+		]}]}`,
+	},
+	{
+		message: "follow reversed predicates",
+		query:   `{ nodes(id: "<fred>") { followers: follows @rev { id } } }`,
+		expect:  `{"nodes": [{"followers": [{"id": "<bob>"}, {"id": "<emily>"}]}]}`,
+	},
+	{
+		message: "filter the values of a field",
+		query:   `{ nodes(id: "<charlie>") { follows(status: "cool_person", id: "<dani>") { id } } }`,
+		expect:  `{"nodes": [{"follows": {"id": "<dani>"}}]}`,
+	},
+	{
+		message: "return nulls for missing values",
+		query:   `{ nodes(id: "<alice>") { id status } }`,
+		expect:  `{"nodes": [{"id": "<alice>", "status": null}]}`,
+	},
+	{
+		message: "use several top level fields",
+		query:   `{ a: nodes(id: "<alice>") { follows } b: nodes(id: "<emily>") }`,
+		expect:  `{"a": [{"follows": "<bob>"}], "b": ["<emily>"]}`,
+	},
+	{
+		message: "return an empty list when nothing matches",
+		query:   `{ nodes(id: "<nobody>") { id } }`,
+		expect:  `{"nodes": []}`,
+	},
+}
+
+func TestGraphQL(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, test := range testQueries {
+		res, err := runQuery(ses, test.query)
+		if err != nil {
+			t.Errorf("Failed to %s: %v", test.message, err)
+			continue
+		}
+		var expect interface{}
+		if err := json.Unmarshal([]byte(test.expect), &expect); err != nil {
+			t.Fatalf("Invalid expectation for %s: %v", test.message, err)
+		}
+		got := normalize(t, res)
+		if expect = sortLists(expect); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to %s, got: %v expected: %v", test.message, got, expect)
+		}
+	}
+}
+
+func TestFirstOffset(t *testing.T) {
+	ses := makeTestSession(t)
+	res, err := runQuery(ses, `{ nodes(id: "<bob>") { follows @rev { id } } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := res.(map[string]interface{})["nodes"].([]interface{})[0].(map[string]interface{})["follows"].([]interface{})
+	if len(all) != 3 {
+		t.Fatalf("Unexpected followers: %v", all)
+	}
+	for offset := range all {
+		res, err := runQuery(ses, `{ nodes(id: "<bob>") { follows(first: 1, offset: `+strconv.Itoa(offset)+`) @rev { id } } }`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := res.(map[string]interface{})["nodes"].([]interface{})[0].(map[string]interface{})["follows"]
+		if !reflect.DeepEqual(got, all[offset]) {
+			t.Errorf("Unexpected result for offset %d, got: %v expected: %v", offset, got, all[offset])
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, query := range []string{
+		`{ nodes { id }`,
+		`{ nodes(id: $id) { id } }`,
+		`query Q($id: String) { nodes(id: $id) { id } }`,
+		`{ nodes(first: "1") { id } }`,
+		`{ nodes @skip { id } }`,
+		`{ nodes { ...fields } }`,
+	} {
+		if _, err := runQuery(ses, query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+// Parses the subset of GraphQL used to query the graph: a single query made of
+// fields with arguments, aliases and directives. As an extension, IRIs in
+// angle brackets may be used as field and argument names, since most
+// predicates are not valid GraphQL names.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/cayley/quad"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokName
+	tokIRI
+	tokString
+	tokInt
+	tokFloat
+	tokPunct
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of query"
+	case tokIRI:
+		return "<" + t.val + ">"
+	case tokString:
+		return strconv.Quote(t.val)
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+func (t token) is(s string) bool {
+	return (t.typ == tokPunct || t.typ == tokName) && t.val == s
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r >= '0' && r <= '9'
+}
+
+const punctuation = "!$():=@[]{}|"
+
+// lex splits the input into tokens. Commas are insignificant in GraphQL, so
+// they are skipped with the white space. The last token is always tokEOF.
+func lex(input string) ([]token, error) {
+	var toks []token
+	s := []rune(input)
+	for i := 0; ; {
+		for i < len(s) && (unicode.IsSpace(s[i]) || s[i] == ',' || s[i] == '#') {
+			if s[i] == '#' {
+				for i < len(s) && s[i] != '\n' {
+					i++
+				}
+				continue
+			}
+			i++
+		}
+		if i >= len(s) {
+			return append(toks, token{typ: tokEOF, pos: i}), nil
+		}
+		start := i
+		switch r := s[i]; {
+		case isNameStart(r):
+			for i < len(s) && isNameChar(s[i]) {
+				i++
+			}
+			toks = append(toks, token{typ: tokName, val: string(s[start:i]), pos: start})
+		case r == '<':
+			for i < len(s) && s[i] != '>' {
+				i++
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated IRI at char %d", start)
+			}
+			i++
+			toks = append(toks, token{typ: tokIRI, val: string(s[start+1 : i-1]), pos: start})
+		case r == '"':
+			val, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at char %d", err, start)
+			}
+			i += n
+			toks = append(toks, token{typ: tokString, val: val, pos: start})
+		case r == '-' || r >= '0' && r <= '9':
+			i++
+			typ := tokInt
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || strings.ContainsRune(".eE+-", s[i])) {
+				if !(s[i] >= '0' && s[i] <= '9') {
+					typ = tokFloat
+				}
+				i++
+			}
+			toks = append(toks, token{typ: typ, val: string(s[start:i]), pos: start})
+		case r == '.' && i+2 < len(s) && s[i+1] == '.' && s[i+2] == '.':
+			return nil, fmt.Errorf("fragments are not supported at char %d", start)
+		case strings.ContainsRune(punctuation, r):
+			i++
+			toks = append(toks, token{typ: tokPunct, val: string(r), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at char %d", r, start)
+		}
+	}
+}
+
+// lexString reads a quoted string from the start of s, and returns its
+// unescaped value and the number of runes read.
+func lexString(s []rune) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			i++
+		case '"':
+			val, err := strconv.Unquote(string(s[:i+1]))
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid string: %v", err)
+			}
+			return val, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// field is a field of a selection set. Every field but id is a predicate,
+// linking the node of the parent to the nodes of the field.
+type field struct {
+	alias string
+	name  string
+	pred  quad.Value
+	args  []argument
+	rev   bool
+	sel   []*field
+}
+
+// key returns the name of the field in the results.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name string
+	pred quad.Value
+	vals []quad.Value
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses a query, and returns its top level fields.
+func parse(input string) ([]*field, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().is("query") {
+		p.next()
+		if p.peek().typ == tokName {
+			p.next()
+		}
+		if p.peek().is("(") {
+			return nil, p.errorf("variables are not supported")
+		}
+	}
+	sel, err := p.parseSelection()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.errorf("unexpected %v", t)
+	}
+	return sel, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at char %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *parser) expect(s string) error {
+	if !p.peek().is(s) {
+		return p.errorf("expected %q, got %v", s, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseSelection() ([]*field, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var out []*field
+	for !p.peek().is("}") {
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	p.next()
+	if len(out) == 0 {
+		return nil, p.errorf("empty selection")
+	}
+	return out, nil
+}
+
+// parseName parses a field or an argument name, and the predicate it stands
+// for.
+func (p *parser) parseName() (string, quad.Value, error) {
+	switch t := p.next(); t.typ {
+	case tokName:
+		return t.val, quad.IRI(t.val), nil
+	case tokIRI:
+		return t.String(), quad.IRI(t.val), nil
+	default:
+		p.pos--
+		return "", nil, p.errorf("expected name, got %v", t)
+	}
+}
+
+func (p *parser) parseField() (*field, error) {
+	name, pred, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	f := &field{name: name, pred: pred}
+	if p.peek().is(":") {
+		p.next()
+		f.alias = name
+		if f.name, f.pred, err = p.parseName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().is("(") {
+		if f.args, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+	for p.peek().is("@") {
+		p.next()
+		switch t := p.next(); {
+		case t.is("rev"):
+			f.rev = true
+		default:
+			return nil, fmt.Errorf("unknown directive %v at char %d", t, t.pos)
+		}
+	}
+	if p.peek().is("{") {
+		if f.sel, err = p.parseSelection(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) parseArguments() ([]argument, error) {
+	p.next()
+	var out []argument
+	for !p.peek().is(")") {
+		name, pred, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		var vals []quad.Value
+		if p.peek().is("[") {
+			p.next()
+			for !p.peek().is("]") {
+				v, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				vals = append(vals, v)
+			}
+			p.next()
+		} else {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			vals = []quad.Value{v}
+		}
+		out = append(out, argument{name: name, pred: pred, vals: vals})
+	}
+	p.next()
+	return out, nil
+}
+
+// parseValue parses an argument value. Strings are converted in the same way
+// as the values of the results are: "<iri>" and "_:bnode" are IRIs and blank
+// nodes, and everything else is a string.
+func (p *parser) parseValue() (quad.Value, error) {
+	t := p.next()
+	switch t.typ {
+	case tokString:
+		return parseString(t.val), nil
+	case tokIRI:
+		return quad.IRI(t.val), nil
+	case tokInt:
+		n, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q at char %d", t.val, t.pos)
+		}
+		return quad.Int(n), nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at char %d", t.val, t.pos)
+		}
+		return quad.Float(f), nil
+	case tokName:
+		switch t.val {
+		case "true":
+			return quad.Bool(true), nil
+		case "false":
+			return quad.Bool(false), nil
+		}
+	case tokPunct:
+		if t.val == "$" {
+			return nil, fmt.Errorf("variables are not supported at char %d", t.pos)
+		}
+	}
+	return nil, fmt.Errorf("unexpected %v at char %d", t, t.pos)
+}
+
+func parseString(s string) quad.Value {
+	switch {
+	case len(s) > 2 && s[0] == '<' && s[len(s)-1] == '>':
+		return quad.IRI(s[1 : len(s)-1])
+	case strings.HasPrefix(s, "_:"):
+		return quad.BNode(s[2:])
+	}
+	return quad.String(s)
+}
@@ -0,0 +1,317 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+// Defines a running session of the GraphQL query language.
+//
+// Every top level field of a query selects a set of nodes, and every field
+// of a selection set follows a predicate from the node of its parent. Each
+// selection set is resolved with one path traversal per parent node, so that
+// the arguments of a field apply to the nodes of each parent separately.
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/graph/path"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query"
+)
+
+// Names of the fields and arguments with a special meaning.
+const (
+	idField   = "id"
+	firstArg  = "first"
+	offsetArg = "offset"
+)
+
+type Session struct {
+	qs    graph.QuadStore
+	debug bool
+
+	fields     []*field
+	err        error
+	dataOutput map[string][]interface{}
+}
+
+// result is a single node selected by a top level field.
+type result struct {
+	key string
+	val interface{}
+}
+
+func NewSession(qs graph.QuadStore) *Session {
+	return &Session{qs: qs}
+}
+
+func (s *Session) Debug(ok bool) {
+	s.debug = ok
+}
+
+func (s *Session) Parse(input string) (query.ParseResult, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return query.ParseFail, err
+	}
+	depth := 0
+	for _, t := range toks {
+		switch {
+		case t.is("{"):
+			depth++
+		case t.is("}"):
+			depth--
+		}
+	}
+	if depth > 0 {
+		return query.ParseMore, nil
+	}
+	if _, err := parse(input); err != nil {
+		return query.ParseFail, err
+	}
+	return query.Parsed, nil
+}
+
+func (s *Session) ShapeOf(input string) (interface{}, error) {
+	fields, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+	p, err := buildPath(path.StartPath(s.qs), fields[0])
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]interface{})
+	iterator.OutputQueryShapeForIterator(p.BuildIterator(), s.qs, output)
+	return output, nil
+}
+
+func (s *Session) Execute(ctx context.Context, input string, out chan interface{}, _ int) {
+	defer close(out)
+	s.err = nil
+	s.fields, s.err = parse(input)
+	if s.err != nil {
+		return
+	}
+	for _, f := range s.fields {
+		p, err := buildPath(path.StartPath(s.qs), f)
+		if err != nil {
+			s.err = err
+			return
+		}
+		vals, err := s.values(ctx, p)
+		if err != nil {
+			s.err = err
+			return
+		}
+		for _, v := range vals {
+			var val interface{}
+			if len(f.sel) == 0 {
+				val = toJSON(s.qs.NameOf(v))
+			} else if val, err = s.object(ctx, v, f.sel); err != nil {
+				s.err = err
+				return
+			}
+			select {
+			case out <- result{key: f.key(), val: val}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// buildPath adds the constraints of the arguments of a field to a path.
+func buildPath(p *path.Path, f *field) (*path.Path, error) {
+	var first, offset int64
+	for _, a := range f.args {
+		switch a.name {
+		case idField:
+			p = p.Is(a.vals...)
+		case firstArg, offsetArg:
+			var n quad.Int
+			ok := len(a.vals) == 1
+			if ok {
+				n, ok = a.vals[0].(quad.Int)
+			}
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%s of %s must be a non-negative integer", a.name, f.key())
+			}
+			if a.name == firstArg {
+				first = int64(n)
+			} else {
+				offset = int64(n)
+			}
+		default:
+			p = p.Has(a.pred, a.vals...)
+		}
+	}
+	p = p.Unique()
+	if offset > 0 {
+		p = p.Skip(offset)
+	}
+	if first > 0 {
+		p = p.Limit(first)
+	}
+	return p, nil
+}
+
+// values returns the nodes of a path.
+func (s *Session) values(ctx context.Context, p *path.Path) ([]graph.Value, error) {
+	it := query.Optimize(ctx, p.BuildIterator())
+	defer it.Close()
+	if s.debug {
+		b, err := json.MarshalIndent(it.Describe(), "", "  ")
+		if err != nil {
+			fmt.Printf("failed to format description: %v", err)
+		} else {
+			fmt.Printf("%s", b)
+		}
+	}
+	var out []graph.Value
+	for graph.Next(ctx, it) {
+		// Some stores return values for nodes they do not have, which
+		// have no names.
+		if v := it.Result(); s.qs.NameOf(v) != nil {
+			out = append(out, v)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return out, ctx.Err()
+}
+
+// object resolves a selection set for a node.
+func (s *Session) object(ctx context.Context, v graph.Value, sel []*field) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(sel))
+	for _, f := range sel {
+		if f.name == idField {
+			obj[f.key()] = toJSON(s.qs.NameOf(v))
+			continue
+		}
+		p := path.StartPath(s.qs, s.qs.NameOf(v))
+		if f.rev {
+			p = p.In(f.pred)
+		} else {
+			p = p.Out(f.pred)
+		}
+		p, err := buildPath(p, f)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := s.values(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, fv := range vals {
+			if len(f.sel) == 0 {
+				out = append(out, toJSON(s.qs.NameOf(fv)))
+				continue
+			}
+			o, err := s.object(ctx, fv, f.sel)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, o)
+		}
+		// As the graph has no schema, a field is a single value when there
+		// is only one, and a list otherwise.
+		switch len(out) {
+		case 0:
+			obj[f.key()] = nil
+		case 1:
+			obj[f.key()] = out[0]
+		default:
+			obj[f.key()] = out
+		}
+	}
+	return obj, nil
+}
+
+// toJSON converts a value to its JSON equivalent. Strings, numbers, booleans
+// and times are converted to their native types, while the other values keep
+// their N-Quads form, so that IRIs can be told apart from strings.
+func toJSON(v quad.Value) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case quad.String:
+		return string(v)
+	case quad.Int:
+		return int64(v)
+	case quad.Float:
+		return float64(v)
+	case quad.Bool:
+		return bool(v)
+	case quad.Time:
+		return time.Time(v)
+	}
+	return v.String()
+}
+
+func (s *Session) Format(res interface{}) string {
+	r := res.(result)
+	b, err := json.MarshalIndent(r.val, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error: %v\n", err)
+	}
+	return fmt.Sprintf("****\n%s: %s\n", r.key, b)
+}
+
+// StreamResult returns the output of a single result, as an object with the
+// node under the key of its top level field.
+func (s *Session) StreamResult(res interface{}) (interface{}, bool) {
+	r, ok := res.(result)
+	if !ok {
+		return nil, false
+	}
+	return map[string]interface{}{r.key: r.val}, true
+}
+
+func (s *Session) Collate(res interface{}) {
+	r := res.(result)
+	if s.dataOutput == nil {
+		s.dataOutput = make(map[string][]interface{})
+	}
+	s.dataOutput[r.key] = append(s.dataOutput[r.key], r.val)
+}
+
+// Results returns an object with a list of nodes for every top level field
+// of the query.
+func (s *Session) Results() (interface{}, error) {
+	defer s.Clear()
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make(map[string]interface{}, len(s.fields))
+	for _, f := range s.fields {
+		list := s.dataOutput[f.key()]
+		if list == nil {
+			list = []interface{}{}
+		}
+		out[f.key()] = list
+	}
+	return out, nil
+}
+
+func (s *Session) Clear() {
+	s.dataOutput = nil
+}
@@ -21,7 +21,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/robertkrimen/otto"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
@@ -40,7 +42,7 @@ type worker struct {
 	count int
 	limit int
 
-	kill <-chan struct{}
+	ctx context.Context
 }
 
 type graphObject struct {
@@ -78,6 +80,63 @@ func (g *graphObject) Emit(call otto.FunctionCall) otto.Value {
 	return otto.NullValue()
 }
 
+// ShortestPath sends the shortest paths between two nodes as results. Every
+// node of a path is a separate result, tagged with the number of the path, the
+// number of the step and the predicate that led to the node.
+func (g *graphObject) ShortestPath(call otto.FunctionCall) otto.Value {
+	args := exportArgs(call.ArgumentList)
+	if len(args) < 2 || g.wk.wantShape() {
+		return otto.NullValue()
+	}
+	from, ok1 := toQuadValue(args[0])
+	to, ok2 := toQuadValue(args[1])
+	if !ok1 || !ok2 {
+		return otto.NullValue()
+	}
+	var preds []quad.Value
+	if len(args) > 2 {
+		switch v := args[2].(type) {
+		case nil:
+		case []interface{}:
+			preds = toQuadValues(v)
+		case []string:
+			for _, p := range v {
+				preds = append(preds, quad.Raw(p))
+			}
+		default:
+			preds = toQuadValues([]interface{}{v})
+		}
+	}
+	maxDepth, k := 0, 1
+	if len(args) > 3 {
+		maxDepth = toInt(args[3])
+	}
+	if len(args) > 4 {
+		k = toInt(args[4])
+	}
+	routes, err := path.ShortestPaths(g.wk.ctx, g.wk.qs, from, to, preds, maxDepth, k)
+	if err != nil {
+		glog.Error(err)
+		return otto.NullValue()
+	}
+	for i, r := range routes {
+		for j, n := range r.Nodes {
+			tags := map[string]graph.Value{
+				"path":       graph.PreFetched(quad.Int(i)),
+				"step":       graph.PreFetched(quad.Int(j)),
+				TopResultTag: n,
+			}
+			if j > 0 {
+				tags["predicate"] = r.Predicates[j-1]
+			}
+			if !g.wk.send(&Result{actualResults: tags}) {
+				return otto.NullValue()
+			}
+		}
+	}
+	return otto.NullValue()
+}
+
 func oneStringType(fnc func(s string) quad.Value) func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
 		args := toStrings(exportArgs(call.ArgumentList))
@@ -142,6 +201,7 @@ func newWorker(qs graph.QuadStore) *worker {
 		qs:    qs,
 		env:   env,
 		limit: -1,
+		ctx:   context.Background(),
 	}
 	env.Set("graph", &graphObject{wk: wk})
 	env.Run("g = graph")
@@ -23,6 +23,7 @@ import (
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
 	"github.com/google/cayley/quad"
+	"github.com/google/cayley/query"
 )
 
 const TopResultTag = "id"
@@ -133,6 +134,185 @@ func (p *pathObject) ForEach(call otto.FunctionCall) otto.Value {
 	return otto.NullValue()
 }
 
+// Count returns the number of results of the query, as ToArray would return
+// them, without sending them.
+func (p *pathObject) Count(call otto.FunctionCall) otto.Value {
+	it := p.buildIteratorTree()
+	if p.wk.wantShape() {
+		iterator.OutputQueryShapeForIterator(it, p.wk.qs, p.wk.shape)
+		return otto.NullValue()
+	}
+	val, err := call.Otto.ToValue(p.wk.countIterator(it))
+	if err != nil {
+		glog.Error(err)
+		return otto.NullValue()
+	}
+	return val
+}
+
+// tagArg returns the tag given as the only argument of a final, or the tag of
+// the results.
+func tagArg(call otto.FunctionCall) string {
+	args := exportArgs(call.ArgumentList)
+	if len(args) == 0 {
+		return TopResultTag
+	}
+	if tags := toStrings(args); len(tags) == 1 {
+		return tags[0]
+	}
+	return ""
+}
+
+// GroupCount returns an object mapping the values of a tag to the number of
+// paths they appear on.
+func (p *pathObject) GroupCount(call otto.FunctionCall) otto.Value {
+	tag := tagArg(call)
+	if tag == "" {
+		return otto.NullValue()
+	}
+	it := p.buildIteratorTree()
+	it.Tagger().Add(TopResultTag)
+	if p.wk.wantShape() {
+		iterator.OutputQueryShapeForIterator(it, p.wk.qs, p.wk.shape)
+		return otto.NullValue()
+	}
+	counts := make(map[string]interface{})
+	p.wk.runIteratorWithTags(it, func(tags map[string]graph.Value) {
+		if v, ok := tags[tag]; ok {
+			name := quad.StringOf(p.wk.qs.NameOf(v))
+			n, _ := counts[name].(int64)
+			counts[name] = n + 1
+		}
+	})
+	val, err := call.Otto.ToValue(counts)
+	if err != nil {
+		glog.Error(err)
+		return otto.NullValue()
+	}
+	return val
+}
+
+// aggregate applies fn to the numeric values of a tag, for every path of the
+// query. Values which are not numbers are skipped.
+func (p *pathObject) aggregate(call otto.FunctionCall, fn func(vals []quad.Value) interface{}) otto.Value {
+	tag := tagArg(call)
+	if tag == "" {
+		return otto.NullValue()
+	}
+	it := p.buildIteratorTree()
+	it.Tagger().Add(TopResultTag)
+	if p.wk.wantShape() {
+		iterator.OutputQueryShapeForIterator(it, p.wk.qs, p.wk.shape)
+		return otto.NullValue()
+	}
+	var vals []quad.Value
+	p.wk.runIteratorWithTags(it, func(tags map[string]graph.Value) {
+		v, ok := tags[tag]
+		if !ok {
+			return
+		}
+		switch qv := p.wk.qs.NameOf(v).(type) {
+		case quad.Int, quad.Float:
+			vals = append(vals, qv)
+		}
+	})
+	res := fn(vals)
+	if res == nil {
+		return otto.NullValue()
+	}
+	val, err := call.Otto.ToValue(res)
+	if err != nil {
+		glog.Error(err)
+		return otto.NullValue()
+	}
+	return val
+}
+
+func (p *pathObject) Sum(call otto.FunctionCall) otto.Value {
+	return p.aggregate(call, sumOf)
+}
+func (p *pathObject) Min(call otto.FunctionCall) otto.Value {
+	return p.aggregate(call, func(vals []quad.Value) interface{} {
+		return extremumOf(vals, func(a, b float64) bool { return a < b })
+	})
+}
+func (p *pathObject) Max(call otto.FunctionCall) otto.Value {
+	return p.aggregate(call, func(vals []quad.Value) interface{} {
+		return extremumOf(vals, func(a, b float64) bool { return a > b })
+	})
+}
+func (p *pathObject) Avg(call otto.FunctionCall) otto.Value {
+	return p.aggregate(call, func(vals []quad.Value) interface{} {
+		if len(vals) == 0 {
+			return nil
+		}
+		var sum float64
+		for _, v := range vals {
+			sum += toFloat(v)
+		}
+		return sum / float64(len(vals))
+	})
+}
+
+func toFloat(v quad.Value) float64 {
+	switch v := v.(type) {
+	case quad.Int:
+		return float64(v)
+	case quad.Float:
+		return float64(v)
+	}
+	return 0
+}
+
+// toNumber converts a numeric value to its native type.
+func toNumber(v quad.Value) interface{} {
+	switch v := v.(type) {
+	case quad.Int:
+		return int64(v)
+	case quad.Float:
+		return float64(v)
+	}
+	return nil
+}
+
+// sumOf returns the sum of the values, which is an integer if all of them
+// are.
+func sumOf(vals []quad.Value) interface{} {
+	var (
+		isum    int64
+		fsum    float64
+		isFloat bool
+	)
+	for _, v := range vals {
+		switch v := v.(type) {
+		case quad.Int:
+			isum += int64(v)
+		case quad.Float:
+			fsum += float64(v)
+			isFloat = true
+		}
+	}
+	if isFloat {
+		return fsum + float64(isum)
+	}
+	return isum
+}
+
+// extremumOf returns the first value for which better returns true against
+// all the others, or nil if there are no values.
+func extremumOf(vals []quad.Value, better func(a, b float64) bool) interface{} {
+	if len(vals) == 0 {
+		return nil
+	}
+	best := vals[0]
+	for _, v := range vals[1:] {
+		if better(toFloat(v), toFloat(best)) {
+			best = v
+		}
+	}
+	return toNumber(best)
+}
+
 func (wk *worker) tagsToValueMap(m map[string]graph.Value) map[string]string {
 	outputMap := make(map[string]string)
 	for k, v := range m {
@@ -144,14 +324,14 @@ func (wk *worker) tagsToValueMap(m map[string]graph.Value) map[string]string {
 func (wk *worker) runIteratorToArray(it graph.Iterator, limit int) []map[string]string {
 	output := make([]map[string]string, 0)
 	n := 0
-	it, _ = it.Optimize()
+	it = query.Optimize(wk.ctx, it)
 	for {
 		select {
-		case <-wk.kill:
+		case <-wk.ctx.Done():
 			return nil
 		default:
 		}
-		if !graph.Next(it) {
+		if !graph.Next(wk.ctx, it) {
 			break
 		}
 		tags := make(map[string]graph.Value)
@@ -161,9 +341,9 @@ func (wk *worker) runIteratorToArray(it graph.Iterator, limit int) []map[string]
 		if limit >= 0 && n >= limit {
 			break
 		}
-		for it.NextPath() {
+		for it.NextPath(wk.ctx) {
 			select {
-			case <-wk.kill:
+			case <-wk.ctx.Done():
 				return nil
 			default:
 			}
@@ -183,14 +363,14 @@ func (wk *worker) runIteratorToArray(it graph.Iterator, limit int) []map[string]
 func (wk *worker) runIteratorToArrayNoTags(it graph.Iterator, limit int) []string {
 	output := make([]string, 0)
 	n := 0
-	it, _ = it.Optimize()
+	it = query.Optimize(wk.ctx, it)
 	for {
 		select {
-		case <-wk.kill:
+		case <-wk.ctx.Done():
 			return nil
 		default:
 		}
-		if !graph.Next(it) {
+		if !graph.Next(wk.ctx, it) {
 			break
 		}
 		output = append(output, quad.StringOf(wk.qs.NameOf(it.Result())))
@@ -205,7 +385,7 @@ func (wk *worker) runIteratorToArrayNoTags(it graph.Iterator, limit int) []strin
 
 func (wk *worker) runIteratorWithCallback(it graph.Iterator, callback otto.Value, this otto.FunctionCall, limit int) {
 	n := 0
-	it, _ = it.Optimize()
+	it = query.Optimize(wk.ctx, it)
 	if glog.V(2) {
 		b, err := json.MarshalIndent(it.Describe(), "", "  ")
 		if err != nil {
@@ -216,11 +396,11 @@ func (wk *worker) runIteratorWithCallback(it graph.Iterator, callback otto.Value
 	}
 	for {
 		select {
-		case <-wk.kill:
+		case <-wk.ctx.Done():
 			return
 		default:
 		}
-		if !graph.Next(it) {
+		if !graph.Next(wk.ctx, it) {
 			break
 		}
 		tags := make(map[string]graph.Value)
@@ -231,9 +411,9 @@ func (wk *worker) runIteratorWithCallback(it graph.Iterator, callback otto.Value
 		if limit >= 0 && n >= limit {
 			break
 		}
-		for it.NextPath() {
+		for it.NextPath(wk.ctx) {
 			select {
-			case <-wk.kill:
+			case <-wk.ctx.Done():
 				return
 			default:
 			}
@@ -250,17 +430,72 @@ func (wk *worker) runIteratorWithCallback(it graph.Iterator, callback otto.Value
 	it.Close()
 }
 
+// countIterator returns the number of results of the iterator. The exact size
+// of a leaf iterator is used when it has one; the size of the other iterators
+// is only an estimate, made from the ones of their subiterators.
+func (wk *worker) countIterator(it graph.Iterator) int64 {
+	it = query.Optimize(wk.ctx, it)
+	defer it.Close()
+	if size, exact := it.Size(); exact && len(it.SubIterators()) == 0 {
+		return size
+	}
+	var n int64
+	for graph.Next(wk.ctx, it) {
+		n++
+	}
+	wk.checkIterator(it)
+	return n
+}
+
+// runIteratorWithTags calls fn with the tags of every path of the iterator.
+func (wk *worker) runIteratorWithTags(it graph.Iterator, fn func(map[string]graph.Value)) {
+	it = query.Optimize(wk.ctx, it)
+	defer it.Close()
+	for graph.Next(wk.ctx, it) {
+		tags := make(map[string]graph.Value)
+		it.TagResults(tags)
+		fn(tags)
+		for it.NextPath(wk.ctx) {
+			tags := make(map[string]graph.Value)
+			it.TagResults(tags)
+			fn(tags)
+		}
+	}
+	wk.checkIterator(it)
+}
+
+// iteratorError is raised when an iterator fails while a final reads its
+// results, so that the query fails instead of returning partial results.
+type iteratorError struct {
+	err error
+}
+
+// checkIterator stops the query if it was cancelled or if the iterator failed.
+// The errors are raised the same way as when the query is interrupted.
+func (wk *worker) checkIterator(it graph.Iterator) {
+	if err := wk.ctx.Err(); err != nil {
+		panic(ctxError(err))
+	}
+	if err := it.Err(); err != nil {
+		panic(iteratorError{err})
+	}
+}
+
 func (wk *worker) send(r *Result) bool {
 	if wk.limit >= 0 && wk.limit == wk.count {
 		return false
 	}
 	select {
-	case <-wk.kill:
+	case <-wk.ctx.Done():
 		return false
 	default:
 	}
 	if wk.results != nil {
-		wk.results <- r
+		select {
+		case wk.results <- r:
+		case <-wk.ctx.Done():
+			return false
+		}
 		wk.count++
 		if wk.limit >= 0 && wk.limit == wk.count {
 			return false
@@ -275,7 +510,7 @@ func (wk *worker) runIterator(it graph.Iterator) {
 		iterator.OutputQueryShapeForIterator(it, wk.qs, wk.shape)
 		return
 	}
-	it, _ = it.Optimize()
+	it = query.Optimize(wk.ctx, it)
 	if glog.V(2) {
 		b, err := json.MarshalIndent(it.Describe(), "", "  ")
 		if err != nil {
@@ -286,11 +521,11 @@ func (wk *worker) runIterator(it graph.Iterator) {
 	}
 	for {
 		select {
-		case <-wk.kill:
+		case <-wk.ctx.Done():
 			return
 		default:
 		}
-		if !graph.Next(it) {
+		if !graph.Next(wk.ctx, it) {
 			break
 		}
 		tags := make(map[string]graph.Value)
@@ -298,9 +533,9 @@ func (wk *worker) runIterator(it graph.Iterator) {
 		if !wk.send(&Result{actualResults: tags}) {
 			break
 		}
-		for it.NextPath() {
+		for it.NextPath(wk.ctx) {
 			select {
-			case <-wk.kill:
+			case <-wk.ctx.Done():
 				return
 			default:
 			}
@@ -15,15 +15,19 @@
 package gremlin
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"sort"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 	"github.com/google/cayley/quad/cquads"
+	"github.com/google/cayley/query"
 
 	_ "github.com/google/cayley/graph/memstore"
 	_ "github.com/google/cayley/writer"
@@ -163,6 +167,31 @@ var testQueries = []struct {
 		`,
 		expect: []string{"<alice>", "<charlie>", "<dani>"},
 	},
+	{
+		message: "show recursive morphism",
+		query: `
+			follows = g.M().Out("<follows>")
+			g.V("<charlie>").FollowRecursive(follows).All()
+		`,
+		expect: []string{"<bob>", "<dani>", "<fred>", "<greg>"},
+	},
+	{
+		message: "show recursive morphism with depth limit",
+		query: `
+			follows = g.M().Out("<follows>")
+			g.V("<charlie>").FollowRecursive(follows, 1).All()
+		`,
+		expect: []string{"<bob>", "<dani>"},
+	},
+	{
+		message: "show recursive morphism with depth tag",
+		query: `
+			follows = g.M().Out("<follows>")
+			g.V("<charlie>").FollowRecursive(follows, 0, "depth").Is("<greg>").All()
+		`,
+		tag:    "depth",
+		expect: []string{quad.Int(2).String()},
+	},
 
 	// Intersection tests.
 	{
@@ -313,6 +342,28 @@ var testQueries = []struct {
 		`,
 		expect: []string{"<dani>", "<fred>"},
 	},
+	{
+		message: "find the shortest path",
+		query: `
+			g.ShortestPath("<charlie>", "<greg>", "<follows>")
+		`,
+		expect: []string{"<charlie>", "<dani>", "<greg>"},
+	},
+	{
+		message: "find alternative shortest paths",
+		query: `
+			g.ShortestPath("<charlie>", "<greg>", ["<follows>"], 0, 2)
+		`,
+		expect: []string{"<charlie>", "<dani>", "<greg>", "<charlie>", "<bob>", "<fred>", "<greg>"},
+	},
+	{
+		message: "show the predicates of the shortest path",
+		query: `
+			g.ShortestPath("<charlie>", "<greg>", null, 2)
+		`,
+		tag:    "predicate",
+		expect: []string{"<follows>", "<follows>"},
+	},
 }
 
 func runQueryGetTag(rec func(), g []quad.Quad, query string, tag string) []string {
@@ -320,7 +371,7 @@ func runQueryGetTag(rec func(), g []quad.Quad, query string, tag string) []strin
 	c := make(chan interface{}, 1)
 	go func() {
 		defer rec()
-		js.Execute(query, c, -1)
+		js.Execute(context.TODO(), query, c, -1)
 	}()
 
 	var results []string
@@ -405,7 +456,7 @@ func TestIssue160(t *testing.T) {
 
 	ses := makeTestSession(issue160TestGraph)
 	c := make(chan interface{}, 5)
-	go ses.Execute(query, c, 100)
+	go ses.Execute(context.TODO(), query, c, 100)
 	var got []string
 	for res := range c {
 		func() {
@@ -422,3 +473,225 @@ func TestIssue160(t *testing.T) {
 		t.Errorf("Unexpected result, got: %q expected: %q", got, expect)
 	}
 }
+
+var testAggregates = []struct {
+	message string
+	query   string
+	expect  string
+}{
+	{
+		message: "count all nodes",
+		query: `
+			var n = 0
+			g.V().ForEach(function(d) { n++ })
+			g.Emit(n > 0 && g.V().Count() == n)
+		`,
+		expect: "true",
+	},
+	{
+		message: "count fixed nodes",
+		query:   `g.Emit(g.V("<alice>", "<bob>").Count())`,
+		expect:  "2",
+	},
+	{
+		message: "count a traversal",
+		query:   `g.Emit(g.V("<bob>").In("<follows>").Count())`,
+		expect:  "3",
+	},
+	{
+		message: "count the paths to every node",
+		query:   `g.Emit(g.V().Out("<follows>").GroupCount())`,
+		expect:  "map[<bob>:3 <dani>:1 <fred>:2 <greg>:2]",
+	},
+	{
+		message: "count the values of a tag",
+		query:   `g.Emit(g.V().Tag("person").Out("<follows>").Is("<bob>").GroupCount("person"))`,
+		expect:  "map[<alice>:1 <charlie>:1 <dani>:1]",
+	},
+	{
+		message: "sum integers and floats",
+		query:   `g.Emit(g.V().Out("<age>").Sum())`,
+		expect:  "117.5",
+	},
+	{
+		message: "sum integers",
+		query:   `g.Emit(g.V("<alice>", "<bob>").Out("<age>").Sum())`,
+		expect:  "55",
+	},
+	{
+		message: "find the minimum",
+		query:   `g.Emit(g.V().Out("<age>").Min())`,
+		expect:  "22.5",
+	},
+	{
+		message: "find the maximum of a tag",
+		query:   `g.Emit(g.V().Out("<age>").Tag("age").In("<age>").Max("age"))`,
+		expect:  "40",
+	},
+	{
+		message: "average",
+		query:   `g.Emit(g.V().Out("<age>").Avg())`,
+		expect:  "29.375",
+	},
+	{
+		message: "ignore values which are not numbers",
+		query:   `g.Emit(g.V().Out("<status>").Sum())`,
+		expect:  "0",
+	},
+	{
+		message: "return null without values",
+		query:   `g.Emit(g.V("<greg>").Out("<age>").Max() === null)`,
+		expect:  "true",
+	},
+}
+
+func TestAggregates(t *testing.T) {
+	data := append(loadGraph("../../data/testdata.nq", t),
+		quad.Quad{Subject: quad.IRI("alice"), Predicate: quad.IRI("age"), Object: quad.Int(30)},
+		quad.Quad{Subject: quad.IRI("bob"), Predicate: quad.IRI("age"), Object: quad.Int(25)},
+		quad.Quad{Subject: quad.IRI("fred"), Predicate: quad.IRI("age"), Object: quad.Int(40)},
+		quad.Quad{Subject: quad.IRI("dani"), Predicate: quad.IRI("age"), Object: quad.Float(22.5)},
+	)
+	for _, test := range testAggregates {
+		ses := makeTestSession(data)
+		c := make(chan interface{}, 5)
+		go ses.Execute(context.TODO(), test.query, c, -1)
+		var got []string
+		for res := range c {
+			if data := res.(*Result); !data.metaresult {
+				got = append(got, fmt.Sprint(data.val))
+			}
+		}
+		if len(got) != 1 || got[0] != test.expect {
+			t.Errorf("Failed to %s, got: %q expected: %q", test.message, got, test.expect)
+		}
+	}
+}
+
+var testOrder = []struct {
+	message string
+	query   string
+	expect  []string
+}{
+	{
+		message: "order nodes",
+		query: `
+			g.V("<alice>", "<charlie>", "<dani>").Out("<follows>").Order().ForEach(function(d) { g.Emit(d.id) })
+		`,
+		expect: []string{"<bob>", "<bob>", "<bob>", "<dani>", "<greg>"},
+	},
+	{
+		message: "order by a numeric tag",
+		query: `
+			g.V().Save("<age>", "age").OrderBy("age").ForEach(function(d) { g.Emit(d.id) })
+		`,
+		expect: []string{"<dani>", "<bob>", "<alice>", "<fred>"},
+	},
+	{
+		message: "order by a numeric tag in descending order",
+		query: `
+			g.V().Save("<age>", "age").OrderBy("age", "desc").ForEach(function(d) { g.Emit(d.id) })
+		`,
+		expect: []string{"<fred>", "<alice>", "<bob>", "<dani>"},
+	},
+}
+
+func TestOrderBy(t *testing.T) {
+	data := append(loadGraph("../../data/testdata.nq", t),
+		quad.Quad{Subject: quad.IRI("alice"), Predicate: quad.IRI("age"), Object: quad.Int(30)},
+		quad.Quad{Subject: quad.IRI("bob"), Predicate: quad.IRI("age"), Object: quad.Int(25)},
+		quad.Quad{Subject: quad.IRI("fred"), Predicate: quad.IRI("age"), Object: quad.Int(40)},
+		quad.Quad{Subject: quad.IRI("dani"), Predicate: quad.IRI("age"), Object: quad.Float(22.5)},
+	)
+	for _, test := range testOrder {
+		ses := makeTestSession(data)
+		c := make(chan interface{}, 5)
+		go ses.Execute(context.TODO(), test.query, c, -1)
+		var got []string
+		for res := range c {
+			if data := res.(*Result); !data.metaresult {
+				got = append(got, fmt.Sprint(data.val))
+			}
+		}
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Failed to %s, got: %q expected: %q", test.message, got, test.expect)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	data := loadGraph("../../data/testdata.nq", t)
+	const q = `g.V("<alice>").Out("<follows>").All()`
+
+	exp, err := query.Explain(context.TODO(), makeTestSession(data), q, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.Iterators) != 1 || exp.Analyzed {
+		t.Fatalf("Unexpected explanation: %+v", exp)
+	}
+	if plan := exp.Iterators[0]; len(plan.Before.SubIts) == 0 || plan.After.Next != 0 {
+		t.Errorf("Unexpected plan without analysis: %+v", plan)
+	}
+
+	exp, err = query.Explain(context.TODO(), makeTestSession(data), q, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.Iterators) != 1 || !exp.Analyzed {
+		t.Fatalf("Unexpected explanation: %+v", exp)
+	}
+	if after := exp.Iterators[0].After; after.Next == 0 || after.NextTime == 0 {
+		t.Errorf("Expected the calls to be counted and timed, got: %+v", after)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	data := loadGraph("../../data/testdata.nq", t)
+	ses := makeTestSession(data)
+	nodes, err := ses.Nodes(context.TODO(), `g.V("<alice>", "<charlie>").Out("<follows>").All()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range nodes {
+		got = append(got, quad.StringOf(ses.qs.NameOf(v)))
+	}
+	sort.Strings(got)
+	expect := []string{"<bob>", "<dani>"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Unexpected nodes, got: %v expected: %v", got, expect)
+	}
+}
+
+func TestPrepareBind(t *testing.T) {
+	data := loadGraph("../../data/testdata.nq", t)
+	prepared, err := makeTestSession(data).Prepare(`g.V(params.name).Out("<follows>").All()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		expect []string
+	}{
+		{name: "<alice>", expect: []string{"<bob>"}},
+		{name: "<charlie>", expect: []string{"<bob>", "<dani>"}},
+	} {
+		ses := makeTestSession(data)
+		if err := ses.Bind(prepared, map[string]quad.Value{"name": quad.Raw(test.name)}); err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := ses.Nodes(context.TODO(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range nodes {
+			got = append(got, quad.StringOf(ses.qs.NameOf(v)))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Unexpected nodes for %s, got: %v expected: %v", test.name, got, test.expect)
+		}
+	}
+}
@@ -23,8 +23,10 @@ import (
 	"github.com/robertkrimen/otto"
 	// Provide underscore JS library.
 	_ "github.com/robertkrimen/otto/underscore"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
 	"github.com/google/cayley/query"
 )
 
@@ -38,7 +40,6 @@ type Session struct {
 	persist *otto.Otto
 
 	timeout time.Duration
-	kill    chan struct{}
 
 	debug      bool
 	dataOutput []interface{}
@@ -88,12 +89,53 @@ func (s *Session) Parse(input string) (query.ParseResult, error) {
 	return query.Parsed, nil
 }
 
-func (s *Session) runUnsafe(input interface{}) (otto.Value, error) {
+// Prepare compiles the query to a script, which can be run by any session.
+func (s *Session) Prepare(input string) (interface{}, error) {
+	script, err := s.wk.env.Compile("", input)
+	if err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Bind makes the next call to Execute run the script returned by Prepare. The
+// values of the parameters are available to the script as the properties of
+// the params object.
+func (s *Session) Bind(prepared interface{}, params map[string]quad.Value) error {
+	script, ok := prepared.(*otto.Script)
+	if !ok {
+		return fmt.Errorf("gremlin: cannot bind %T", prepared)
+	}
+	obj := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		obj[k] = quadValue{v}
+	}
+	if err := s.wk.env.Set("params", obj); err != nil {
+		return err
+	}
+	s.script = script
+	return nil
+}
+
+// ctxError converts a context error to the error reported by the session.
+func ctxError(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrKillTimeout
+	}
+	return err
+}
+
+func (s *Session) runUnsafe(ctx context.Context, input interface{}) (otto.Value, error) {
 	wk := s.wk
 	defer func() {
 		if r := recover(); r != nil {
-			if r == ErrKillTimeout {
-				s.err = ErrKillTimeout
+			if r == ErrKillTimeout || r == context.Canceled {
+				s.err = r.(error)
+				wk.env = s.persist
+				return
+			}
+			if ierr, ok := r.(iteratorError); ok {
+				s.err = ierr.err
 				wk.env = s.persist
 				return
 			}
@@ -103,58 +145,106 @@ func (s *Session) runUnsafe(input interface{}) (otto.Value, error) {
 
 	// Use buffered chan to prevent blocking.
 	wk.env.Interrupt = make(chan func(), 1)
-	s.kill = make(chan struct{})
-	wk.kill = s.kill
+	var cancel context.CancelFunc
+	if s.timeout >= 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	wk.ctx = ctx
 
 	done := make(chan struct{})
 	defer close(done)
-	if s.timeout >= 0 {
-		go func() {
-			time.Sleep(s.timeout)
-			select {
-			case <-done:
-			default:
-				close(s.kill)
-				wk.Lock()
-				if wk.env != nil {
-					wk.env.Interrupt <- func() {
-						panic(ErrKillTimeout)
-					}
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err := ctxError(ctx.Err())
+			wk.Lock()
+			if wk.env != nil {
+				wk.env.Interrupt <- func() {
+					panic(err)
 				}
-				wk.Unlock()
 			}
-		}()
-	}
+			wk.Unlock()
+		}
+	}()
 
 	wk.Lock()
 	env := wk.env
 	wk.Unlock()
-	return env.Run(input)
+	v, err := env.Run(input)
+	if cerr := ctx.Err(); cerr != nil {
+		s.err = ctxError(cerr)
+	}
+	return v, err
 }
 
-func (s *Session) Execute(input string, out chan interface{}, _ int) {
+func (s *Session) Execute(ctx context.Context, input string, out chan interface{}, _ int) {
 	defer close(out)
 	s.err = nil
 	s.wk.results = out
 	var err error
 	var value otto.Value
 	if s.script == nil {
-		value, err = s.runUnsafe(input)
+		value, err = s.runUnsafe(ctx, input)
 	} else {
-		value, err = s.runUnsafe(s.script)
+		value, err = s.runUnsafe(ctx, s.script)
+	}
+	if err == nil {
+		err = s.err
 	}
-	out <- &Result{
+	select {
+	case out <- &Result{
 		metaresult: true,
 		err:        err,
 		val:        exportArgs([]otto.Value{value})[0],
+	}:
+	case <-ctx.Done():
 	}
 	s.wk.results = nil
+	s.wk.ctx = context.Background()
 	s.script = nil
 	s.wk.Lock()
 	s.wk.env = s.persist
 	s.wk.Unlock()
 }
 
+// Nodes runs the query and returns the distinct nodes it results in, such as
+// the nodes of a path ending with All.
+func (s *Session) Nodes(ctx context.Context, input string) ([]graph.Value, error) {
+	c := make(chan interface{}, 5)
+	go s.Execute(ctx, input, c, -1)
+	var (
+		nodes []graph.Value
+		err   error
+	)
+	seen := make(map[graph.Value]struct{})
+	for res := range c {
+		r := res.(*Result)
+		if r.metaresult {
+			if r.err != nil {
+				err = r.err
+			}
+			continue
+		}
+		v, ok := r.actualResults[TopResultTag]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[graph.ToKey(v)]; ok {
+			continue
+		}
+		seen[graph.ToKey(v)] = struct{}{}
+		nodes = append(nodes, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
 func (s *Session) Format(result interface{}) string {
 	data := result.(*Result)
 	if data.metaresult {
@@ -206,56 +296,47 @@ func (s *Session) Format(result interface{}) string {
 }
 
 // Web stuff
-func (s *Session) Collate(result interface{}) {
+// StreamResult returns the output of a single result, which is what Collate
+// adds to the results, and false if the result has no output.
+func (s *Session) StreamResult(result interface{}) (interface{}, bool) {
 	data := result.(*Result)
-	if !data.metaresult {
-		if data.val == nil {
-			obj := make(map[string]string)
-			tags := data.actualResults
-			var tagKeys []string
-			for k := range tags {
-				tagKeys = append(tagKeys, k)
-			}
-			sort.Strings(tagKeys)
-			for _, k := range tagKeys {
-				if name := s.qs.NameOf(tags[k]); name != nil {
-					obj[k] = name.String()
-				} else {
-					delete(obj, k)
-				}
-			}
-			if len(obj) != 0 {
-				s.dataOutput = append(s.dataOutput, obj)
-			}
+	if data.metaresult {
+		return nil, false
+	}
+	if data.val != nil {
+		return data.val, true
+	}
+	obj := make(map[string]string)
+	tags := data.actualResults
+	var tagKeys []string
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		if name := s.qs.NameOf(tags[k]); name != nil {
+			obj[k] = name.String()
 		} else {
-			s.dataOutput = append(s.dataOutput, data.val)
+			delete(obj, k)
 		}
 	}
+	if len(obj) == 0 {
+		return nil, false
+	}
+	return obj, true
 }
 
-func (s *Session) RawResult(result interface{}) map[string]graph.Value {
-	data, ok := result.(*Result)
-	if !ok || data.metaresult {
-		return nil
-	}
-	if data.val != nil {
-		//s.dataOutput = append(s.dataOutput, data.val)
-		return nil
+func (s *Session) Collate(result interface{}) {
+	if v, ok := s.StreamResult(result); ok {
+		s.dataOutput = append(s.dataOutput, v)
 	}
-	return data.actualResults
 }
-
 func (s *Session) Results() (interface{}, error) {
 	defer s.Clear()
 	if s.err != nil {
 		return nil, s.err
 	}
-	select {
-	case <-s.kill:
-		return nil, ErrKillTimeout
-	default:
-		return s.dataOutput, nil
-	}
+	return s.dataOutput, nil
 }
 
 func (s *Session) Clear() {
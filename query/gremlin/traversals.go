@@ -17,6 +17,8 @@ package gremlin
 // Adds special traversal functions to JS Gremlin objects. Most of these just build the chain of objects, and won't often need the session.
 
 import (
+	"strings"
+
 	"github.com/robertkrimen/otto"
 
 	"github.com/google/cayley/graph"
@@ -91,6 +93,28 @@ func (p *pathObject) Follow(call otto.FunctionCall) otto.Value {
 func (p *pathObject) FollowR(call otto.FunctionCall) otto.Value {
 	return p.follow(call, true)
 }
+func (p *pathObject) FollowRecursive(call otto.FunctionCall) otto.Value {
+	args := exportArgs(call.ArgumentList)
+	if len(args) == 0 {
+		return otto.NullValue()
+	}
+	ep, ok := args[0].(*path.Path)
+	if !ok {
+		return otto.NullValue()
+	}
+	var (
+		maxDepth int
+		tags     []string
+	)
+	if len(args) > 1 {
+		maxDepth = toInt(args[1])
+	}
+	if len(args) > 2 {
+		tags = toStrings(args[2:])
+	}
+	np := p.path.FollowRecursive(ep, maxDepth, tags...)
+	return outObj(call, p.clone(np))
+}
 func (p *pathObject) And(call otto.FunctionCall) otto.Value {
 	ep := exportAsPath(call.ArgumentList)
 	np := p.path.And(ep.path)
@@ -202,3 +226,31 @@ func (p *pathObject) Filter(call otto.FunctionCall) otto.Value {
 	}
 	return outObj(call, p.clone(np))
 }
+func (p *pathObject) Order(call otto.FunctionCall) otto.Value {
+	args := toStrings(exportArgs(call.ArgumentList))
+	return p.orderBy(call, "", args)
+}
+func (p *pathObject) OrderBy(call otto.FunctionCall) otto.Value {
+	args := toStrings(exportArgs(call.ArgumentList))
+	if len(args) == 0 {
+		return otto.NullValue()
+	}
+	return p.orderBy(call, args[0], args[1:])
+}
+
+// orderBy sorts the results by the given tag, in the direction of the first
+// argument, which is "asc" (the default) or "desc".
+func (p *pathObject) orderBy(call otto.FunctionCall, tag string, args []string) otto.Value {
+	desc := false
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return otto.NullValue()
+		}
+	}
+	np := p.path.OrderBy(tag, desc)
+	return outObj(call, p.clone(np))
+}
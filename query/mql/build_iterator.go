@@ -37,132 +37,212 @@ func (q *Query) buildResultIterator(path Path) graph.Iterator {
 	return all
 }
 
-func (q *Query) BuildIteratorTree(query interface{}) {
-	q.isRepeated = make(map[Path]bool)
-	q.queryStructure = make(map[Path]map[string]interface{})
-	q.queryResult = make(map[ResultPath]map[string]interface{})
-	q.queryResult[""] = make(map[string]interface{})
+type nodeKind int
+
+const (
+	fixedNode nodeKind = iota
+	resultNode
+	listNode
+	objectNode
+)
+
+// queryNode is a node of the structure of an MQL query, from which its
+// iterator tree is built.
+type queryNode struct {
+	kind nodeKind
+	path Path
+	// value is the value of a fixed node. If param is set, it is replaced by
+	// the value of the parameter with that name, when one is bound.
+	value string
+	param string
+	// elem is the single element of a list.
+	elem *queryNode
+	// links are the fields of an object.
+	links []queryLink
+}
+
+// queryLink is a field of an object, linking it to the node of its value.
+type queryLink struct {
+	key     string
+	pred    string
+	reverse bool
+	node    *queryNode
+}
+
+func (n *queryNode) optional() bool {
+	switch n.kind {
+	case resultNode:
+		return true
+	case listNode:
+		return n.elem.optional()
+	}
+	return false
+}
 
-	var isOptional bool
-	q.it, isOptional, q.err = q.buildIteratorTreeInternal(query, NewPath())
-	if isOptional {
-		q.err = errors.New("optional iterator at the top level")
+// preparedQuery is the structure of a decoded MQL query. It is only read once
+// built, so it is shared by all the sessions running the query.
+type preparedQuery struct {
+	root           *queryNode
+	isRepeated     map[Path]bool
+	queryStructure map[Path]map[string]interface{}
+}
+
+func prepareQuery(query interface{}) (*preparedQuery, error) {
+	p := &preparedQuery{
+		isRepeated:     make(map[Path]bool),
+		queryStructure: make(map[Path]map[string]interface{}),
 	}
+	root, err := p.compile(query, NewPath())
+	if err != nil {
+		return nil, err
+	}
+	if root.optional() {
+		return nil, errors.New("optional iterator at the top level")
+	}
+	p.root = root
+	return p, nil
 }
 
-func (q *Query) buildIteratorTreeInternal(query interface{}, path Path) (it graph.Iterator, optional bool, err error) {
-	err = nil
-	optional = false
+func (p *preparedQuery) compile(query interface{}, path Path) (*queryNode, error) {
+	n := &queryNode{path: path}
 	switch t := query.(type) {
 	case bool:
 		// for JSON booleans
 		// Treat the bool as a string and call it a day.
 		// Things which are really bool-like are special cases and will be dealt with separately.
+		n.value = "false"
 		if t {
-			it = q.buildFixed("true")
+			n.value = "true"
 		}
-		it = q.buildFixed("false")
 	case float64:
 		// for JSON numbers
 		// Damn you, Javascript, and your lack of integer values.
 		if math.Floor(t) == t {
 			// Treat it like an integer.
-			it = q.buildFixed(fmt.Sprintf("%0.f", t))
+			n.value = fmt.Sprintf("%0.f", t)
 		} else {
-			it = q.buildFixed(fmt.Sprintf("%f", t))
+			n.value = fmt.Sprintf("%f", t)
 		}
 	case string:
 		// for JSON strings
-		it = q.buildFixed(t)
+		n.value = t
+		if strings.HasPrefix(t, "$") {
+			n.param = t[1:]
+		}
 	case []interface{}:
 		// for JSON arrays
-		q.isRepeated[path] = true
+		p.isRepeated[path] = true
 		if len(t) == 0 {
-			it = q.buildResultIterator(path)
-			optional = true
+			n.kind = resultNode
 		} else if len(t) == 1 {
-			it, optional, err = q.buildIteratorTreeInternal(t[0], path)
+			elem, err := p.compile(t[0], path)
+			if err != nil {
+				return nil, err
+			}
+			n.kind, n.elem = listNode, elem
 		} else {
-			err = fmt.Errorf("multiple fields at location root %s", path.DisplayString())
+			return nil, fmt.Errorf("multiple fields at location root %s", path.DisplayString())
 		}
 	case map[string]interface{}:
 		// for JSON objects
-		it, err = q.buildIteratorTreeMapInternal(t, path)
+		n.kind = objectNode
+		outputStructure := make(map[string]interface{})
+		for key, subquery := range t {
+			outputStructure[key] = nil
+			pred := key
+			if strings.HasPrefix(pred, "@") {
+				i := strings.Index(pred, ":")
+				if i != -1 {
+					pred = pred[(i + 1):]
+				}
+			}
+			reverse := strings.HasPrefix(pred, "!")
+			pred = strings.TrimPrefix(pred, "!")
+			sub, err := p.compile(subquery, path.Follow(key))
+			if err != nil {
+				return nil, err
+			}
+			n.links = append(n.links, queryLink{key: key, pred: pred, reverse: reverse, node: sub})
+		}
+		p.queryStructure[path] = outputStructure
 	case nil:
-		it = q.buildResultIterator(path)
-		optional = true
+		n.kind = resultNode
 	default:
-		err = fmt.Errorf("Unknown JSON type: %T")
+		return nil, fmt.Errorf("Unknown JSON type: %T", t)
 	}
+	return n, nil
+}
+
+func (q *Query) BuildIteratorTree(query interface{}) {
+	p, err := prepareQuery(query)
 	if err != nil {
-		return nil, false, err
+		q.err = err
+		return
 	}
-	it.Tagger().Add(string(path))
-	return it, optional, nil
+	q.buildPrepared(p, nil)
 }
 
-func (q *Query) buildIteratorTreeMapInternal(query map[string]interface{}, path Path) (graph.Iterator, error) {
-	it := iterator.NewAnd(q.ses.qs)
-	it.AddSubIterator(q.ses.qs.NodesAllIterator())
-	var err error
-	err = nil
-	outputStructure := make(map[string]interface{})
-	for key, subquery := range query {
-		optional := false
-		outputStructure[key] = nil
-		reverse := false
-		pred := key
-		if strings.HasPrefix(pred, "@") {
-			i := strings.Index(pred, ":")
-			if i != -1 {
-				pred = pred[(i + 1):]
-			}
-		}
-		if strings.HasPrefix(pred, "!") {
-			reverse = true
-			pred = strings.TrimPrefix(pred, "!")
+// buildPrepared builds the iterator tree of a prepared query, with the values
+// of its parameters.
+func (q *Query) buildPrepared(p *preparedQuery, params map[string]quad.Value) {
+	q.isRepeated = p.isRepeated
+	q.queryStructure = p.queryStructure
+	q.queryResult = make(map[ResultPath]map[string]interface{})
+	q.queryResult[""] = make(map[string]interface{})
+	q.it = q.buildNode(p.root, params)
+}
+
+func (q *Query) buildNode(n *queryNode, params map[string]quad.Value) graph.Iterator {
+	var it graph.Iterator
+	switch n.kind {
+	case fixedNode:
+		value := n.value
+		if v, ok := params[n.param]; ok && n.param != "" {
+			value = quad.StringOf(v)
 		}
+		it = q.buildFixed(value)
+	case resultNode:
+		it = q.buildResultIterator(n.path)
+	case listNode:
+		it = q.buildNode(n.elem, params)
+	case objectNode:
+		it = q.buildObject(n, params)
+	}
+	it.Tagger().Add(string(n.path))
+	return it
+}
 
+func (q *Query) buildObject(n *queryNode, params map[string]quad.Value) graph.Iterator {
+	it := iterator.NewAnd(q.ses.qs)
+	it.AddSubIterator(q.ses.qs.NodesAllIterator())
+	for _, l := range n.links {
 		// Other special constructs here
 		var subit graph.Iterator
-		if key == "id" {
-			subit, optional, err = q.buildIteratorTreeInternal(subquery, path.Follow(key))
-			if err != nil {
-				return nil, err
-			}
+		if l.key == "id" {
+			subit = q.buildNode(l.node, params)
 		} else {
-			var builtIt graph.Iterator
-			builtIt, optional, err = q.buildIteratorTreeInternal(subquery, path.Follow(key))
-			if err != nil {
-				return nil, err
-			}
+			builtIt := q.buildNode(l.node, params)
 			subAnd := iterator.NewAnd(q.ses.qs)
 			predFixed := q.ses.qs.FixedIterator()
-			predFixed.Add(q.ses.qs.ValueOf(quad.Raw(pred)))
+			predFixed.Add(q.ses.qs.ValueOf(quad.Raw(l.pred)))
 			subAnd.AddSubIterator(iterator.NewLinksTo(q.ses.qs, predFixed, quad.Predicate))
-			if reverse {
+			if l.reverse {
 				lto := iterator.NewLinksTo(q.ses.qs, builtIt, quad.Subject)
 				subAnd.AddSubIterator(lto)
-				hasa := iterator.NewHasA(q.ses.qs, subAnd, quad.Object)
-				subit = hasa
+				subit = iterator.NewHasA(q.ses.qs, subAnd, quad.Object)
 			} else {
 				lto := iterator.NewLinksTo(q.ses.qs, builtIt, quad.Object)
 				subAnd.AddSubIterator(lto)
-				hasa := iterator.NewHasA(q.ses.qs, subAnd, quad.Subject)
-				subit = hasa
+				subit = iterator.NewHasA(q.ses.qs, subAnd, quad.Subject)
 			}
 		}
-		if optional {
+		if l.node.optional() {
 			it.AddSubIterator(iterator.NewOptional(subit))
 		} else {
 			it.AddSubIterator(subit)
 		}
 	}
-	if err != nil {
-		return nil, err
-	}
-	q.queryStructure[path] = outputStructure
-	return it, nil
+	return it
 }
 
 type byRecordLength []ResultPath
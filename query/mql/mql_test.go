@@ -19,6 +19,8 @@ import (
 	"reflect"
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	_ "github.com/google/cayley/graph/memstore"
 	"github.com/google/cayley/quad"
@@ -170,7 +172,7 @@ var testQueries = []struct {
 func runQuery(g []quad.Quad, query string) interface{} {
 	s := makeTestSession(g)
 	c := make(chan interface{}, 5)
-	go s.Execute(query, c, -1)
+	go s.Execute(context.TODO(), query, c, -1)
 	for result := range c {
 		s.Collate(result)
 	}
@@ -192,3 +194,73 @@ func TestMQL(t *testing.T) {
 		}
 	}
 }
+
+func TestPrepareBind(t *testing.T) {
+	ses := makeTestSession(nil)
+	byID, err := ses.Prepare(`[{"id": "$who", "follows": []}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byStatus, err := ses.Prepare(`[{"id": null, "status": "$status", "!follows": [{"id": "$who"}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		prepared interface{}
+		params   map[string]quad.Value
+		expect   string
+	}{
+		{
+			prepared: byID,
+			params:   map[string]quad.Value{"who": quad.Raw("A")},
+			expect:   `[{"id": "A", "follows": ["B"]}]`,
+		},
+		{
+			prepared: byID,
+			params:   map[string]quad.Value{"who": quad.Raw("C")},
+			expect:   `[{"id": "C", "follows": ["B", "D"]}]`,
+		},
+		{
+			prepared: byStatus,
+			params:   map[string]quad.Value{"status": quad.Raw("cool"), "who": quad.Raw("C")},
+			expect:   `[{"id": "B", "status": "cool", "!follows": [{"id": "C"}]}, {"id": "D", "status": "cool", "!follows": [{"id": "C"}]}]`,
+		},
+		{
+			prepared: byStatus,
+			params:   map[string]quad.Value{"status": quad.Raw("cool"), "who": quad.Raw("A")},
+			expect:   `[{"id": "B", "status": "cool", "!follows": [{"id": "A"}]}]`,
+		},
+	} {
+		s := makeTestSession(simpleGraph)
+		if err := s.Bind(test.prepared, test.params); err != nil {
+			t.Fatal(err)
+		}
+		c := make(chan interface{}, 5)
+		go s.Execute(context.TODO(), "", c, -1)
+		for result := range c {
+			s.Collate(result)
+		}
+		got, err := s.Results()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expect interface{}
+		json.Unmarshal([]byte(test.expect), &expect)
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Unexpected results for %v, got: %v expected: %s", test.params, got, test.expect)
+		}
+	}
+
+	if err := ses.Bind("[]", nil); err == nil {
+		t.Error("Expected an error binding a query which was not prepared")
+	}
+	for _, query := range []string{
+		`[{"id": "$who", "follows": ["A", "B"]}]`,
+		`null`,
+		`[{"id": `,
+	} {
+		if _, err := ses.Prepare(query); err == nil {
+			t.Errorf("Expected an error preparing %s", query)
+		}
+	}
+}
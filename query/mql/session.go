@@ -20,15 +20,19 @@ import (
 	"sort"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
 	"github.com/google/cayley/query"
 )
 
 type Session struct {
 	qs           graph.QuadStore
 	currentQuery *Query
+	bound        *preparedQuery
+	params       map[string]quad.Value
 	debug        bool
 }
 
@@ -50,6 +54,9 @@ func (s *Session) ShapeOf(query string) (interface{}, error) {
 	}
 	s.currentQuery = NewQuery(s)
 	s.currentQuery.BuildIteratorTree(mqlQuery)
+	if s.currentQuery.isError() {
+		return nil, s.currentQuery.err
+	}
 	output := make(map[string]interface{})
 	iterator.OutputQueryShapeForIterator(s.currentQuery.it, s.qs, output)
 	nodes := make([]iterator.Node, 0)
@@ -70,19 +77,50 @@ func (s *Session) Parse(input string) (query.ParseResult, error) {
 	return query.Parsed, nil
 }
 
-func (s *Session) Execute(input string, c chan interface{}, _ int) {
-	defer close(c)
+// Prepare decodes the query and builds its structure, which can then be bound
+// by any session.
+func (s *Session) Prepare(input string) (interface{}, error) {
 	var mqlQuery interface{}
-	err := json.Unmarshal([]byte(input), &mqlQuery)
+	if err := json.Unmarshal([]byte(input), &mqlQuery); err != nil {
+		return nil, err
+	}
+	p, err := prepareQuery(mqlQuery)
 	if err != nil {
-		return
+		return nil, err
+	}
+	return p, nil
+}
+
+// Bind makes the next call to Execute run the query returned by Prepare, in
+// which every string of the form "$name" is replaced by the value of the
+// parameter with that name.
+func (s *Session) Bind(prepared interface{}, params map[string]quad.Value) error {
+	p, ok := prepared.(*preparedQuery)
+	if !ok {
+		return fmt.Errorf("mql: cannot bind %T", prepared)
 	}
+	s.bound, s.params = p, params
+	return nil
+}
+
+func (s *Session) Execute(ctx context.Context, input string, c chan interface{}, _ int) {
+	defer close(c)
 	s.currentQuery = NewQuery(s)
-	s.currentQuery.BuildIteratorTree(mqlQuery)
+	if p := s.bound; p != nil {
+		s.currentQuery.buildPrepared(p, s.params)
+		s.bound, s.params = nil, nil
+	} else {
+		var mqlQuery interface{}
+		err := json.Unmarshal([]byte(input), &mqlQuery)
+		if err != nil {
+			return
+		}
+		s.currentQuery.BuildIteratorTree(mqlQuery)
+	}
 	if s.currentQuery.isError() {
 		return
 	}
-	it, _ := s.currentQuery.it.Optimize()
+	it := query.Optimize(ctx, s.currentQuery.it)
 	if glog.V(2) {
 		b, err := json.MarshalIndent(it.Describe(), "", "  ")
 		if err != nil {
@@ -91,16 +129,27 @@ func (s *Session) Execute(input string, c chan interface{}, _ int) {
 			glog.Infof("%s", b)
 		}
 	}
-	for graph.Next(it) {
+	for graph.Next(ctx, it) {
 		tags := make(map[string]graph.Value)
 		it.TagResults(tags)
-		c <- tags
-		for it.NextPath() == true {
+		select {
+		case c <- tags:
+		case <-ctx.Done():
+			return
+		}
+		for it.NextPath(ctx) == true {
 			tags := make(map[string]graph.Value)
 			it.TagResults(tags)
-			c <- tags
+			select {
+			case c <- tags:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
+	if err := it.Err(); err != nil {
+		s.currentQuery.err = err
+	}
 }
 
 func (s *Session) Format(result interface{}) string {
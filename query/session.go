@@ -16,6 +16,12 @@ package query
 
 // Defines the graph session interface general to all query languages.
 
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/quad"
+)
+
 type ParseResult int
 
 const (
@@ -24,10 +30,19 @@ const (
 	ParseFail
 )
 
+// Executor runs queries, as every session does.
+type Executor interface {
+	// Runs the query and returns individual results on the channel.
+	// Execution stops early when the context is cancelled.
+	Execute(context.Context, string, chan interface{}, int)
+}
+
 type Session interface {
 	// Return whether the string is a valid expression.
 	Parse(string) (ParseResult, error)
-	Execute(string, chan interface{}, int)
+	// Runs the query and returns individual results on the channel.
+	// Execution stops early when the context is cancelled.
+	Execute(context.Context, string, chan interface{}, int)
 	Format(interface{}) string
 	Debug(bool)
 }
@@ -36,9 +51,38 @@ type HTTP interface {
 	// Return whether the string is a valid expression.
 	Parse(string) (ParseResult, error)
 	// Runs the query and returns individual results on the channel.
-	Execute(string, chan interface{}, int)
+	// Execution stops early when the context is cancelled.
+	Execute(context.Context, string, chan interface{}, int)
 	ShapeOf(string) (interface{}, error)
 	Collate(interface{})
 	Results() (interface{}, error)
 	Clear()
 }
+
+// Streamer is implemented by the sessions which can output every result on
+// its own, so that results are sent as soon as they are found instead of
+// being collated.
+type Streamer interface {
+	// StreamResult returns the output of a single result of Execute, and
+	// false if the result has no output.
+	StreamResult(interface{}) (interface{}, bool)
+}
+
+// Limiter is implemented by the sessions which stop a query at the limit of
+// results passed to Execute.
+type Limiter interface {
+	// Limited returns whether the last query stopped at the limit, with
+	// results left.
+	Limited() bool
+}
+
+// Preparer is implemented by the sessions which can compile a query once, and
+// run it many times with different parameters.
+type Preparer interface {
+	// Prepare compiles the query. The compiled query may be bound by other
+	// sessions of the same language, concurrently.
+	Prepare(string) (interface{}, error)
+	// Bind makes the next call to Execute run the compiled query, with the
+	// given values of its parameters, instead of its input.
+	Bind(interface{}, map[string]quad.Value) error
+}
@@ -17,6 +17,8 @@ package sexp
 import (
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/quad"
 
@@ -69,7 +71,7 @@ func TestMemstoreBackedSexp(t *testing.T) {
 		if it.Type() != test.typ {
 			t.Errorf("Incorrect type for %s, got:%q expect %q", test.message, it.Type(), test.expect)
 		}
-		if !graph.Next(it) {
+		if !graph.Next(context.TODO(), it) {
 			t.Errorf("Failed to %s", test.message)
 		}
 		got := it.Result()
@@ -91,7 +93,7 @@ func TestTreeConstraintParse(t *testing.T) {
 	if it.Type() != graph.And {
 		t.Errorf("Odd iterator tree. Got: %#v", it.Describe())
 	}
-	if !graph.Next(it) {
+	if !graph.Next(context.TODO(), it) {
 		t.Error("Got no results")
 	}
 	out := it.Result()
@@ -109,7 +111,7 @@ func TestTreeConstraintTagParse(t *testing.T) {
 		"(:like\n" +
 		"($a (:is :good))))"
 	it := BuildIteratorTreeForQuery(qs, query)
-	if !graph.Next(it) {
+	if !graph.Next(context.TODO(), it) {
 		t.Error("Got no results")
 	}
 	tags := make(map[string]graph.Value)
@@ -139,14 +141,14 @@ func TestMultipleConstraintParse(t *testing.T) {
 	if it.Type() != graph.And {
 		t.Errorf("Odd iterator tree. Got: %#v", it.Describe())
 	}
-	if !graph.Next(it) {
+	if !graph.Next(context.TODO(), it) {
 		t.Error("Got no results")
 	}
 	out := it.Result()
 	if out != qs.ValueOf(quad.Raw("i")) {
 		t.Errorf("Got %d, expected %d", out, qs.ValueOf(quad.Raw("i")))
 	}
-	if graph.Next(it) {
+	if graph.Next(context.TODO(), it) {
 		t.Error("Too many results")
 	}
 }
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"sort"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/cayley/graph"
 	"github.com/google/cayley/query"
 )
@@ -67,12 +69,10 @@ func (s *Session) Parse(input string) (query.ParseResult, error) {
 	return query.ParseFail, errors.New("invalid syntax")
 }
 
-func (s *Session) Execute(input string, out chan interface{}, limit int) {
+func (s *Session) Execute(ctx context.Context, input string, out chan interface{}, limit int) {
+	defer close(out)
 	it := BuildIteratorTreeForQuery(s.qs, input)
-	newIt, changed := it.Optimize()
-	if changed {
-		it = newIt
-	}
+	it = query.Optimize(ctx, it)
 
 	if s.debug {
 		b, err := json.MarshalIndent(it.Describe(), "", "  ")
@@ -83,25 +83,32 @@ func (s *Session) Execute(input string, out chan interface{}, limit int) {
 		}
 	}
 	nResults := 0
-	for graph.Next(it) {
+	for graph.Next(ctx, it) {
 		tags := make(map[string]graph.Value)
 		it.TagResults(tags)
-		out <- &tags
+		select {
+		case out <- &tags:
+		case <-ctx.Done():
+			return
+		}
 		nResults++
 		if nResults > limit && limit != -1 {
 			break
 		}
-		for it.NextPath() == true {
+		for it.NextPath(ctx) == true {
 			tags := make(map[string]graph.Value)
 			it.TagResults(tags)
-			out <- &tags
+			select {
+			case out <- &tags:
+			case <-ctx.Done():
+				return
+			}
 			nResults++
 			if nResults > limit && limit != -1 {
 				break
 			}
 		}
 	}
-	close(out)
 }
 
 func (s *Session) Format(result interface{}) string {
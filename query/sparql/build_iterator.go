@@ -0,0 +1,397 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+// Compiles a parsed query to an iterator tree.
+//
+// A group of triple patterns is turned into a tree rooted at one of its
+// variables, in the same way MQL builds its queries: every variable is an
+// And of all the nodes and the constraints of the patterns it takes part in,
+// and the patterns are linked with HasA and LinksTo iterators. Every variable
+// is tagged with its name, so the results are the tags of the tree.
+//
+// Since the iterators can only express trees, the patterns must be
+// connected. A pattern that closes a cycle reaches a variable a second time,
+// so it is given an alias there, and the tree is wrapped in a check iterator
+// which only keeps the paths where the variable and its alias are the same
+// node. FILTER comparisons between two variables are checked in the same way.
+
+import (
+	"fmt"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+type builder struct {
+	qs      graph.QuadStore
+	filters map[string][]filter
+	// compared is the set of variables that FILTER compares to another one.
+	compared map[string]bool
+	// bound is the set of variables that are part of the tree.
+	bound map[string]bool
+	// checks are the comparisons between the variables of the cycles in the
+	// group being built.
+	checks  []check
+	aliases int
+}
+
+// scope keeps track of the patterns of a group that were added to the tree.
+type scope struct {
+	b      *builder
+	parent *scope
+	g      *group
+
+	visited   map[string]bool
+	triples   []bool
+	optionals []bool
+	unions    []bool
+}
+
+func buildIteratorTree(qs graph.QuadStore, q *Query) (graph.Iterator, error) {
+	b := &builder{
+		qs:       qs,
+		filters:  make(map[string][]filter),
+		compared: make(map[string]bool),
+		bound:    make(map[string]bool),
+	}
+	if err := b.addFilters(q.where, false); err != nil {
+		return nil, err
+	}
+	it, err := b.buildGroup(q.where, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name := range b.filters {
+		if !b.bound[name] {
+			return nil, fmt.Errorf("variable ?%s in FILTER is not used in any pattern", name)
+		}
+	}
+	for name := range b.compared {
+		if !b.bound[name] {
+			return nil, fmt.Errorf("variable ?%s in FILTER is not used in any pattern", name)
+		}
+	}
+	if q.offset > 0 {
+		it = iterator.NewSkip(qs, it, q.offset)
+	}
+	if q.limit > 0 {
+		it = iterator.NewLimit(qs, it, q.limit)
+	}
+	return it, nil
+}
+
+// addFilters collects the comparisons to a value of every variable. The
+// comparisons between two variables are checked on the tree of the group
+// instead, which is only done outside of OPTIONAL.
+func (b *builder) addFilters(g *group, optional bool) error {
+	for _, f := range g.filters {
+		if !f.isJoin() {
+			b.filters[f.name] = append(b.filters[f.name], f)
+			continue
+		}
+		if optional {
+			return fmt.Errorf("FILTER comparing two variables inside OPTIONAL is not supported: ?%s %v ?%s", f.name, f.op, f.other)
+		}
+		b.compared[f.name] = true
+		b.compared[f.other] = true
+	}
+	for _, o := range g.optionals {
+		if err := b.addFilters(o, true); err != nil {
+			return err
+		}
+	}
+	for _, u := range g.unions {
+		for _, br := range u {
+			if err := b.addFilters(br, optional); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *builder) newScope(g *group, parent *scope) *scope {
+	return &scope{
+		b:         b,
+		parent:    parent,
+		g:         g,
+		visited:   make(map[string]bool),
+		triples:   make([]bool, len(g.triples)),
+		optionals: make([]bool, len(g.optionals)),
+		unions:    make([]bool, len(g.unions)),
+	}
+}
+
+// buildGroup builds the tree for a group, rooted at its first variable.
+func (b *builder) buildGroup(g *group, parent *scope) (graph.Iterator, error) {
+	if len(g.unions) != 0 {
+		// An Or only follows the paths of the first alternative that
+		// contains a value, so UNION is distributed over the rest of the
+		// group instead of being a constraint in the tree.
+		or := iterator.NewOr()
+		for _, br := range g.unions[0] {
+			alt := &group{}
+			alt.merge(&group{
+				triples:   g.triples,
+				filters:   g.filters,
+				optionals: g.optionals,
+				unions:    g.unions[1:],
+			})
+			alt.merge(br)
+			it, err := b.buildGroup(alt, parent)
+			if err != nil {
+				return nil, err
+			}
+			or.AddSubIterator(it)
+		}
+		return or, nil
+	}
+	var root *term
+	for _, t := range g.triples {
+		for i := range t.dirs {
+			if t.dirs[i].isVar() {
+				root = &t.dirs[i]
+				break
+			}
+		}
+		if root != nil {
+			break
+		}
+	}
+	if root != nil {
+		n := len(b.checks)
+		s := b.newScope(g, parent)
+		it, err := s.buildNode(*root)
+		if err == nil {
+			err = s.complete()
+		}
+		if err != nil {
+			return nil, err
+		}
+		checks := append([]check(nil), b.checks[n:]...)
+		b.checks = b.checks[:n]
+		for _, f := range g.filters {
+			if f.isJoin() {
+				checks = append(checks, check{a: f.name, op: f.op, b: f.other})
+			}
+		}
+		if len(checks) != 0 {
+			it = newCheckIterator(b.qs, it, checks)
+		}
+		return it, nil
+	}
+	switch {
+	case len(g.triples) == 1 && len(g.optionals) == 0:
+		// A single pattern without variables.
+		t := g.triples[0]
+		and := iterator.NewAnd(b.qs)
+		for _, d := range t.directions() {
+			and.AddSubIterator(iterator.NewLinksTo(b.qs, b.fixed(t.term(d).val), d))
+		}
+		return iterator.NewHasA(b.qs, and, quad.Subject), nil
+	case len(g.triples) == 0 && len(g.optionals) == 0:
+		return nil, fmt.Errorf("empty patterns are not supported")
+	}
+	return nil, fmt.Errorf("patterns without variables are not supported")
+}
+
+// alias returns an iterator for a variable that is already in the tree,
+// tagged with a new name, which is checked to be the same node as the
+// variable.
+func (b *builder) alias(name string) graph.Iterator {
+	b.aliases++
+	tag := fmt.Sprintf("%s#%d", name, b.aliases)
+	b.checks = append(b.checks, check{a: name, op: filterEq, b: tag})
+	all := b.qs.NodesAllIterator()
+	all.Tagger().Add(tag)
+	return all
+}
+
+func (b *builder) fixed(v quad.Value) graph.Iterator {
+	val := b.qs.ValueOf(v)
+	if val == nil {
+		return iterator.NewNull()
+	}
+	fixed := b.qs.FixedIterator()
+	fixed.Add(val)
+	return fixed
+}
+
+// filterIterators returns the iterators for the filters of a variable.
+func (b *builder) filterIterators(name string) []graph.Iterator {
+	var out []graph.Iterator
+	for _, f := range b.filters[name] {
+		switch f.op {
+		case filterEq:
+			out = append(out, b.fixed(f.val))
+		case filterNe:
+			if b.qs.ValueOf(f.val) != nil {
+				out = append(out, iterator.NewNot(b.fixed(f.val), b.qs.NodesAllIterator()))
+			}
+		default:
+			out = append(out, iterator.NewComparison(b.qs.NodesAllIterator(), iterator.Operator(f.op), f.val, b.qs))
+		}
+	}
+	return out
+}
+
+func (s *scope) isVisited(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.visited[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// complete checks that all the patterns of the group are in the tree.
+func (s *scope) complete() error {
+	for i, ok := range s.triples {
+		if !ok {
+			return fmt.Errorf("patterns that share no variable with the rest of the query are not supported: %v", s.g.triples[i])
+		}
+	}
+	for _, ok := range s.optionals {
+		if !ok {
+			return fmt.Errorf("OPTIONAL patterns that share no variable with the rest of the query are not supported")
+		}
+	}
+	for _, ok := range s.unions {
+		if !ok {
+			return fmt.Errorf("UNION patterns that share no variable with the rest of the query are not supported")
+		}
+	}
+	return nil
+}
+
+// buildNode builds the iterator for a term, with the constraints of all the
+// patterns of the group it takes part in, that are not in the tree yet.
+func (s *scope) buildNode(t term) (graph.Iterator, error) {
+	qs := s.b.qs
+	if !t.isVar() {
+		return s.b.fixed(t.val), nil
+	}
+	s.visited[t.name] = true
+	s.b.bound[t.name] = true
+	and := iterator.NewAnd(qs)
+	and.AddSubIterator(qs.NodesAllIterator())
+	for _, it := range s.b.filterIterators(t.name) {
+		and.AddSubIterator(it)
+	}
+	for i, tr := range s.g.triples {
+		if s.triples[i] || !tr.hasVar(t.name) {
+			continue
+		}
+		s.triples[i] = true
+		it, err := s.buildTriple(tr, t.name)
+		if err != nil {
+			return nil, err
+		}
+		and.AddSubIterator(it)
+	}
+	for i, o := range s.g.optionals {
+		if s.optionals[i] || !o.hasVar(t.name) {
+			continue
+		}
+		s.optionals[i] = true
+		sub := s.b.newScope(o, s)
+		it, err := sub.buildNode(t)
+		if err == nil {
+			err = sub.complete()
+		}
+		if err != nil {
+			return nil, err
+		}
+		and.AddSubIterator(iterator.NewOptional(it))
+	}
+	// Only the alternatives of an UNION inside OPTIONAL end up here, the
+	// others are distributed by buildGroup.
+	for i, u := range s.g.unions {
+		if s.unions[i] || !u[0].hasVar(t.name) {
+			continue
+		}
+		s.unions[i] = true
+		or := iterator.NewOr()
+		for _, br := range u {
+			if !br.hasVar(t.name) {
+				return nil, fmt.Errorf("all the alternatives of UNION must use ?%s", t.name)
+			}
+			sub := s.b.newScope(br, s)
+			it, err := sub.buildNode(t)
+			if err == nil {
+				err = sub.complete()
+			}
+			if err != nil {
+				return nil, err
+			}
+			or.AddSubIterator(it)
+		}
+		and.AddSubIterator(or)
+	}
+	and.Tagger().Add(t.name)
+	return and, nil
+}
+
+// buildTriple builds the iterator for the values of a variable that match a
+// pattern.
+func (s *scope) buildTriple(t *triple, name string) (graph.Iterator, error) {
+	qs := s.b.qs
+	var dir quad.Direction
+	for _, d := range t.directions() {
+		if tm := t.term(d); tm.isVar() && tm.name == name {
+			dir = d
+			break
+		}
+	}
+	and := iterator.NewAnd(qs)
+	for _, d := range t.directions() {
+		if d == dir {
+			continue
+		}
+		tm := t.term(d)
+		if tm.isVar() && s.isVisited(tm.name) {
+			if s.parent != nil {
+				return nil, fmt.Errorf("cyclic patterns inside OPTIONAL are not supported: %v", t)
+			}
+			and.AddSubIterator(iterator.NewLinksTo(qs, s.b.alias(tm.name), d))
+			continue
+		}
+		it, err := s.buildNode(tm)
+		if err != nil {
+			return nil, err
+		}
+		and.AddSubIterator(iterator.NewLinksTo(qs, it, d))
+	}
+	return iterator.NewHasA(qs, and, dir), nil
+}
+
+// directions returns the directions the pattern constrains.
+func (t *triple) directions() []quad.Direction {
+	if t.hasLabel {
+		return []quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
+	}
+	return []quad.Direction{quad.Subject, quad.Predicate, quad.Object}
+}
+
+func (t *triple) hasVar(name string) bool {
+	for _, d := range t.directions() {
+		if tm := t.term(d); tm.isVar() && tm.name == name {
+			return true
+		}
+	}
+	return false
+}
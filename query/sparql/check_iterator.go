@@ -0,0 +1,264 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+// Defines the check iterator, which only keeps the paths of its subiterator
+// whose tags pass a set of comparisons between two tags.
+//
+// The trees of iterators can not express a variable that is reached twice,
+// so the second occurrence of a variable in a cycle is tagged with an alias,
+// and the check iterator keeps the paths where the variable and its alias are
+// the same node. FILTER comparisons between two variables are checked in the
+// same way.
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+var checkType graph.Type
+
+func init() {
+	checkType = graph.RegisterIterator("sparql_check")
+}
+
+// check compares the values of two tags.
+type check struct {
+	a, b string
+	op   filterOp
+}
+
+func (c check) String() string {
+	return fmt.Sprintf("?%s %v ?%s", c.a, c.op, c.b)
+}
+
+type checkIterator struct {
+	uid    uint64
+	tags   graph.Tagger
+	qs     graph.QuadStore
+	subIt  graph.Iterator
+	checks []check
+	result graph.Value
+	err    error
+}
+
+func newCheckIterator(qs graph.QuadStore, subIt graph.Iterator, checks []check) *checkIterator {
+	return &checkIterator{
+		uid:    iterator.NextUID(),
+		qs:     qs,
+		subIt:  subIt,
+		checks: checks,
+	}
+}
+
+func (it *checkIterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *checkIterator) Reset() {
+	it.subIt.Reset()
+	it.result = nil
+	it.err = nil
+}
+
+func (it *checkIterator) Close() error {
+	return it.subIt.Close()
+}
+
+func (it *checkIterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *checkIterator) TagResults(dst map[string]graph.Value) {
+	for _, tag := range it.tags.Tags() {
+		dst[tag] = it.Result()
+	}
+
+	for tag, value := range it.tags.Fixed() {
+		dst[tag] = value
+	}
+
+	it.subIt.TagResults(dst)
+}
+
+func (it *checkIterator) Clone() graph.Iterator {
+	out := newCheckIterator(it.qs, it.subIt.Clone(), it.checks)
+	out.tags.CopyFrom(it)
+	return out
+}
+
+// pass checks the tags of the current path of the subiterator. A tag that is
+// not set, because it is part of an optional pattern that did not match,
+// fails the check.
+func (it *checkIterator) pass() bool {
+	tags := make(map[string]graph.Value)
+	it.subIt.TagResults(tags)
+	for _, c := range it.checks {
+		a, ok := tags[c.a]
+		if !ok {
+			return false
+		}
+		b, ok := tags[c.b]
+		if !ok {
+			return false
+		}
+		if !compareValues(it.qs, a, c.op, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextPassingPath advances the subiterator to the next path of its current
+// result that passes the checks.
+func (it *checkIterator) nextPassingPath(ctx context.Context) bool {
+	for it.subIt.NextPath(ctx) {
+		if it.pass() {
+			return true
+		}
+	}
+	it.err = it.subIt.Err()
+	return false
+}
+
+func (it *checkIterator) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	for graph.Next(ctx, it.subIt) {
+		if it.pass() || it.nextPassingPath(ctx) {
+			it.result = it.subIt.Result()
+			return graph.NextLogOut(it, it.result, true)
+		}
+		if it.err != nil {
+			return graph.NextLogOut(it, nil, false)
+		}
+	}
+	it.err = it.subIt.Err()
+	return graph.NextLogOut(it, nil, false)
+}
+
+func (it *checkIterator) NextPath(ctx context.Context) bool {
+	return it.nextPassingPath(ctx)
+}
+
+func (it *checkIterator) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	if !it.subIt.Contains(ctx, val) {
+		it.err = it.subIt.Err()
+		return graph.ContainsLogOut(it, val, false)
+	}
+	if !it.pass() && !it.nextPassingPath(ctx) {
+		return graph.ContainsLogOut(it, val, false)
+	}
+	it.result = val
+	return graph.ContainsLogOut(it, val, true)
+}
+
+func (it *checkIterator) Err() error {
+	return it.err
+}
+
+func (it *checkIterator) Result() graph.Value {
+	return it.result
+}
+
+func (it *checkIterator) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.subIt}
+}
+
+func (it *checkIterator) Type() graph.Type { return checkType }
+
+func (it *checkIterator) Describe() graph.Description {
+	primary := it.subIt.Describe()
+	return graph.Description{
+		UID:      it.UID(),
+		Name:     fmt.Sprint(it.checks),
+		Type:     it.Type(),
+		Tags:     it.tags.Tags(),
+		Iterator: &primary,
+	}
+}
+
+func (it *checkIterator) Optimize() (graph.Iterator, bool) {
+	newSub, changed := it.subIt.Optimize()
+	if changed {
+		it.subIt.Close()
+		it.subIt = newSub
+		if it.subIt.Type() == graph.Null {
+			return it.subIt, true
+		}
+	}
+	return it, false
+}
+
+// The checks are cheap next to the tree they are made on, but they read the
+// tags of every path.
+func (it *checkIterator) Stats() graph.IteratorStats {
+	subStats := it.subIt.Stats()
+	return graph.IteratorStats{
+		ContainsCost: subStats.ContainsCost * 2,
+		NextCost:     subStats.NextCost * 2,
+		Size:         subStats.Size,
+	}
+}
+
+// Size returns the size of the subiterator, which is an upper bound.
+func (it *checkIterator) Size() (int64, bool) {
+	size, _ := it.subIt.Size()
+	return size, false
+}
+
+var _ graph.Nexter = &checkIterator{}
+
+// compareValues compares two nodes. Nodes are equal when they are the same
+// node of the store. The order only holds between values of the same type,
+// in the same way as in a FILTER comparing a variable to a value.
+func compareValues(qs graph.QuadStore, a graph.Value, op filterOp, b graph.Value) bool {
+	switch op {
+	case filterEq:
+		return graph.ToKey(a) == graph.ToKey(b)
+	case filterNe:
+		return graph.ToKey(a) != graph.ToKey(b)
+	}
+	cop := iterator.Operator(op)
+	switch av := qs.NameOf(a).(type) {
+	case quad.Int:
+		if bv, ok := qs.NameOf(b).(quad.Int); ok {
+			return iterator.RunIntOp(av, cop, bv)
+		}
+	case quad.Float:
+		if bv, ok := qs.NameOf(b).(quad.Float); ok {
+			return iterator.RunFloatOp(av, cop, bv)
+		}
+	case quad.String:
+		if bv, ok := qs.NameOf(b).(quad.String); ok {
+			return iterator.RunStrOp(string(av), cop, string(bv))
+		}
+	case quad.IRI:
+		if bv, ok := qs.NameOf(b).(quad.IRI); ok {
+			return iterator.RunStrOp(string(av), cop, string(bv))
+		}
+	case quad.Time:
+		if bv, ok := qs.NameOf(b).(quad.Time); ok {
+			return iterator.RunTimeOp(time.Time(av), cop, time.Time(bv))
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+// Splits a SPARQL query into tokens.
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIRI
+	tokPrefixedName
+	tokVar
+	tokBlank
+	tokString
+	tokLangTag
+	tokInteger
+	tokDecimal
+	tokKeyword
+	tokPunct
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of query"
+	case tokIRI:
+		return "<" + t.val + ">"
+	case tokVar:
+		return "?" + t.val
+	case tokBlank:
+		return "_:" + t.val
+	case tokString:
+		return fmt.Sprintf("%q", t.val)
+	case tokLangTag:
+		return "@" + t.val
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// is checks whether the token is the given punctuation or keyword. Keywords
+// are case insensitive.
+func (t token) is(s string) bool {
+	switch t.typ {
+	case tokPunct:
+		return t.val == s
+	case tokKeyword:
+		return strings.EqualFold(t.val, s)
+	}
+	return false
+}
+
+// operators lists multi-character punctuation, which has to be matched before
+// the single characters.
+var operators = []string{"^^", "&&", "||", "!=", "<=", ">="}
+
+const punctuation = "{}()[].,;*=<>!"
+
+func isNameChar(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lex splits the input into tokens. The last token is always tokEOF.
+func lex(input string) ([]token, error) {
+	var toks []token
+	s := []rune(input)
+	for i := 0; ; {
+		for i < len(s) && (unicode.IsSpace(s[i]) || s[i] == '#') {
+			if s[i] == '#' {
+				for i < len(s) && s[i] != '\n' {
+					i++
+				}
+				continue
+			}
+			i++
+		}
+		if i >= len(s) {
+			toks = append(toks, token{typ: tokEOF, pos: i})
+			return toks, nil
+		}
+		start := i
+		r := s[i]
+		switch {
+		case r == '<':
+			// Either an IRI or a comparison operator.
+			j := i + 1
+			for j < len(s) && s[j] != '>' && !unicode.IsSpace(s[j]) && !strings.ContainsRune(`<"{}|^`+"`\\", s[j]) {
+				j++
+			}
+			if j < len(s) && s[j] == '>' {
+				toks = append(toks, token{typ: tokIRI, val: string(s[i+1 : j]), pos: start})
+				i = j + 1
+				continue
+			}
+		case r == '?' || r == '$':
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("empty variable name at char %d", start)
+			}
+			toks = append(toks, token{typ: tokVar, val: string(s[i+1 : j]), pos: start})
+			i = j
+			continue
+		case r == '_' && i+1 < len(s) && s[i+1] == ':':
+			j := i + 2
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			toks = append(toks, token{typ: tokBlank, val: string(s[i+2 : j]), pos: start})
+			i = j
+			continue
+		case r == '"' || r == '\'':
+			val, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at char %d", err, start)
+			}
+			toks = append(toks, token{typ: tokString, val: val, pos: start})
+			i += n
+			continue
+		case r == '@':
+			j := i + 1
+			for j < len(s) && (isNameChar(s[j])) {
+				j++
+			}
+			toks = append(toks, token{typ: tokLangTag, val: string(s[i+1 : j]), pos: start})
+			i = j
+			continue
+		case unicode.IsDigit(r) || ((r == '-' || r == '+') && i+1 < len(s) && unicode.IsDigit(s[i+1])):
+			j := i + 1
+			typ := tokInteger
+			for j < len(s) && (unicode.IsDigit(s[j]) || s[j] == '.' && typ == tokInteger && j+1 < len(s) && unicode.IsDigit(s[j+1])) {
+				if s[j] == '.' {
+					typ = tokDecimal
+				}
+				j++
+			}
+			toks = append(toks, token{typ: typ, val: string(s[i:j]), pos: start})
+			i = j
+			continue
+		case unicode.IsLetter(r) || r == ':':
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			if j < len(s) && s[j] == ':' {
+				// A prefixed name, which may have an empty local part.
+				j++
+				for j < len(s) && (isNameChar(s[j]) || s[j] == '.' && j+1 < len(s) && isNameChar(s[j+1])) {
+					j++
+				}
+				toks = append(toks, token{typ: tokPrefixedName, val: string(s[i:j]), pos: start})
+			} else {
+				toks = append(toks, token{typ: tokKeyword, val: string(s[i:j]), pos: start})
+			}
+			i = j
+			continue
+		}
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(string(s[i:]), op) {
+				toks = append(toks, token{typ: tokPunct, val: op, pos: start})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if !strings.ContainsRune(punctuation, r) {
+			return nil, fmt.Errorf("unexpected character %q at char %d", r, start)
+		}
+		toks = append(toks, token{typ: tokPunct, val: string(r), pos: start})
+		i++
+	}
+}
+
+// lexString reads a quoted string from the start of s, and returns its
+// unescaped value and the number of runes read.
+func lexString(s []rune) (string, int, error) {
+	quote := s[0]
+	var out []rune
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return string(out), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			i++
+			if i >= len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			switch s[i] {
+			case 't':
+				out = append(out, '\t')
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '"', '\'', '\\':
+				out = append(out, s[i])
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence \\%c", s[i])
+			}
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,599 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+// Parses the supported subset of SPARQL 1.1: SELECT and ASK queries with
+// basic graph patterns, FILTER, OPTIONAL, UNION, GRAPH, LIMIT and OFFSET.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/quad"
+)
+
+const rdfType = quad.IRI("http://www.w3.org/1999/02/22-rdf-syntax-ns#type")
+
+type queryForm int
+
+const (
+	formSelect queryForm = iota
+	formAsk
+)
+
+// Query is a parsed SPARQL query.
+type Query struct {
+	form queryForm
+	// vars are the projected variables. All the variables are projected if
+	// it is empty.
+	vars   []string
+	where  *group
+	limit  int64
+	offset int64
+}
+
+// Vars returns the names of the variables in the results of the query.
+func (q *Query) Vars() []string {
+	if len(q.vars) != 0 {
+		return q.vars
+	}
+	var vars []string
+	seen := make(map[string]bool)
+	q.where.eachVar(func(name string) {
+		if !seen[name] && !isBlankVar(name) {
+			seen[name] = true
+			vars = append(vars, name)
+		}
+	})
+	return vars
+}
+
+// term is either a variable or a fixed value in a pattern.
+type term struct {
+	name string
+	val  quad.Value
+}
+
+func (t term) isVar() bool { return t.name != "" }
+
+func (t term) String() string {
+	if t.isVar() {
+		return "?" + t.name
+	}
+	return quad.StringOf(t.val)
+}
+
+// blankPrefix marks variables created for blank nodes, which are not
+// projected by SELECT *.
+const blankPrefix = "_:"
+
+func isBlankVar(name string) bool {
+	return strings.HasPrefix(name, blankPrefix)
+}
+
+type triple struct {
+	dirs [4]term
+	// hasLabel is set for the patterns inside GRAPH.
+	hasLabel bool
+}
+
+func (t *triple) term(d quad.Direction) term {
+	return t.dirs[d-quad.Subject]
+}
+
+func (t *triple) String() string {
+	s := fmt.Sprintf("%v %v %v", t.dirs[0], t.dirs[1], t.dirs[2])
+	if t.hasLabel {
+		s = fmt.Sprintf("GRAPH %v { %s }", t.dirs[3], s)
+	}
+	return s
+}
+
+type filterOp int
+
+const (
+	filterLT  = filterOp(iterator.CompareLT)
+	filterLTE = filterOp(iterator.CompareLTE)
+	filterGT  = filterOp(iterator.CompareGT)
+	filterGTE = filterOp(iterator.CompareGTE)
+	filterEq  = filterOp(-1)
+	filterNe  = filterOp(-2)
+)
+
+// filter compares a variable to a fixed value, or to another variable.
+type filter struct {
+	name string
+	op   filterOp
+	val  quad.Value
+	// other is the name of the variable compared to, if any.
+	other string
+}
+
+func (f filter) isJoin() bool { return f.other != "" }
+
+// group is a group graph pattern.
+type group struct {
+	triples   []*triple
+	filters   []filter
+	optionals []*group
+	// unions holds the alternatives of every UNION in the group.
+	unions [][]*group
+}
+
+func (g *group) eachVar(fn func(name string)) {
+	for _, t := range g.triples {
+		for _, tm := range t.dirs {
+			if tm.isVar() {
+				fn(tm.name)
+			}
+		}
+	}
+	for _, o := range g.optionals {
+		o.eachVar(fn)
+	}
+	for _, u := range g.unions {
+		for _, b := range u {
+			b.eachVar(fn)
+		}
+	}
+}
+
+func (g *group) hasVar(name string) bool {
+	found := false
+	g.eachVar(func(n string) {
+		if n == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// merge adds the patterns of a nested group to the group.
+func (g *group) merge(o *group) {
+	g.triples = append(g.triples, o.triples...)
+	g.filters = append(g.filters, o.filters...)
+	g.optionals = append(g.optionals, o.optionals...)
+	g.unions = append(g.unions, o.unions...)
+}
+
+type parser struct {
+	toks     []token
+	pos      int
+	prefixes map[string]string
+	base     string
+	blanks   int
+}
+
+// Parse parses a SPARQL query.
+func Parse(input string) (*Query, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		toks:     toks,
+		prefixes: make(map[string]string),
+	}
+	return p.parseQuery()
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	return fmt.Errorf("%s at char %d", fmt.Sprintf(format, args...), t.pos)
+}
+
+func (p *parser) expect(s string) error {
+	if !p.peek().is(s) {
+		return p.errorf("expected %q, got %v", s, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	if err := p.parsePrologue(); err != nil {
+		return nil, err
+	}
+	q := &Query{}
+	switch t := p.next(); {
+	case t.is("SELECT"):
+		q.form = formSelect
+		if p.peek().is("*") {
+			p.next()
+		} else {
+			for p.peek().typ == tokVar {
+				q.vars = append(q.vars, p.next().val)
+			}
+			if len(q.vars) == 0 {
+				return nil, p.errorf("expected variables, got %v", p.peek())
+			}
+		}
+	case t.is("ASK"):
+		q.form = formAsk
+	default:
+		return nil, fmt.Errorf("expected SELECT or ASK, got %v at char %d", t, t.pos)
+	}
+	if p.peek().is("WHERE") {
+		p.next()
+	}
+	g, err := p.parseGroup(term{}, false)
+	if err != nil {
+		return nil, err
+	}
+	q.where = g
+	for {
+		switch t := p.peek(); {
+		case t.is("LIMIT"):
+			p.next()
+			if q.limit, err = p.parseCount(); err != nil {
+				return nil, err
+			}
+		case t.is("OFFSET"):
+			p.next()
+			if q.offset, err = p.parseCount(); err != nil {
+				return nil, err
+			}
+		case t.typ == tokEOF:
+			return q, nil
+		default:
+			return nil, p.errorf("unexpected %v", t)
+		}
+	}
+}
+
+func (p *parser) parsePrologue() error {
+	for {
+		switch t := p.peek(); {
+		case t.is("PREFIX"):
+			p.next()
+			name := p.next()
+			if name.typ != tokPrefixedName || !strings.HasSuffix(name.val, ":") {
+				return fmt.Errorf("expected prefix name, got %v at char %d", name, name.pos)
+			}
+			iri := p.next()
+			if iri.typ != tokIRI {
+				return fmt.Errorf("expected IRI, got %v at char %d", iri, iri.pos)
+			}
+			p.prefixes[strings.TrimSuffix(name.val, ":")] = p.resolve(iri.val)
+		case t.is("BASE"):
+			p.next()
+			iri := p.next()
+			if iri.typ != tokIRI {
+				return fmt.Errorf("expected IRI, got %v at char %d", iri, iri.pos)
+			}
+			p.base = iri.val
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseCount() (int64, error) {
+	t := p.next()
+	if t.typ != tokInteger {
+		return 0, fmt.Errorf("expected integer, got %v at char %d", t, t.pos)
+	}
+	n, err := strconv.ParseInt(t.val, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q at char %d", t.val, t.pos)
+	}
+	return n, nil
+}
+
+// resolve makes a relative IRI absolute, using the base IRI.
+func (p *parser) resolve(iri string) string {
+	if p.base == "" || strings.Contains(iri, ":") {
+		return iri
+	}
+	return p.base + iri
+}
+
+// parseGroup parses a group graph pattern. The label applies to all the
+// triples in the group, if hasLabel is set.
+func (p *parser) parseGroup(label term, hasLabel bool) (*group, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	g := &group{}
+	for {
+		switch t := p.peek(); {
+		case t.is("}"):
+			p.next()
+			return g, nil
+		case t.is("."):
+			p.next()
+		case t.is("FILTER"):
+			p.next()
+			filters, err := p.parseFilter()
+			if err != nil {
+				return nil, err
+			}
+			g.filters = append(g.filters, filters...)
+		case t.is("OPTIONAL"):
+			p.next()
+			o, err := p.parseGroup(label, hasLabel)
+			if err != nil {
+				return nil, err
+			}
+			g.optionals = append(g.optionals, o)
+		case t.is("GRAPH"):
+			p.next()
+			l, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			sub, err := p.parseGroup(l, true)
+			if err != nil {
+				return nil, err
+			}
+			g.merge(sub)
+		case t.is("{"):
+			sub, err := p.parseGroup(label, hasLabel)
+			if err != nil {
+				return nil, err
+			}
+			if !p.peek().is("UNION") {
+				g.merge(sub)
+				continue
+			}
+			branches := []*group{sub}
+			for p.peek().is("UNION") {
+				p.next()
+				b, err := p.parseGroup(label, hasLabel)
+				if err != nil {
+					return nil, err
+				}
+				branches = append(branches, b)
+			}
+			g.unions = append(g.unions, branches)
+		case t.typ == tokEOF:
+			return nil, p.errorf("expected %q, got %v", "}", t)
+		default:
+			triples, err := p.parseTriples()
+			if err != nil {
+				return nil, err
+			}
+			for _, tr := range triples {
+				tr.dirs[quad.Label-quad.Subject] = label
+				tr.hasLabel = hasLabel
+			}
+			g.triples = append(g.triples, triples...)
+		}
+	}
+}
+
+// parseTriples parses the triples sharing a subject, with the ';' and ','
+// abbreviations.
+func (p *parser) parseTriples() ([]*triple, error) {
+	s, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	var out []*triple
+	for {
+		var pred term
+		if p.peek().is("a") {
+			p.next()
+			pred = term{val: rdfType}
+		} else if pred, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		for {
+			o, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, &triple{dirs: [4]term{s, pred, o}})
+			if !p.peek().is(",") {
+				break
+			}
+			p.next()
+		}
+		if !p.peek().is(";") {
+			return out, nil
+		}
+		for p.peek().is(";") {
+			p.next()
+		}
+		if t := p.peek(); t.is(".") || t.is("}") {
+			return out, nil
+		}
+	}
+}
+
+// parseTerm parses a variable or a fixed value.
+func (p *parser) parseTerm() (term, error) {
+	switch t := p.peek(); {
+	case t.typ == tokVar:
+		p.next()
+		return term{name: t.val}, nil
+	case t.typ == tokBlank:
+		p.next()
+		return term{name: blankPrefix + t.val}, nil
+	case t.is("["):
+		p.next()
+		if err := p.expect("]"); err != nil {
+			return term{}, err
+		}
+		p.blanks++
+		return term{name: fmt.Sprintf("%sanon%d", blankPrefix, p.blanks)}, nil
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return term{}, err
+	}
+	return term{val: v}, nil
+}
+
+// parseValue parses an IRI or a literal.
+func (p *parser) parseValue() (quad.Value, error) {
+	t := p.next()
+	switch t.typ {
+	case tokIRI:
+		return quad.IRI(p.resolve(t.val)), nil
+	case tokPrefixedName:
+		i := strings.Index(t.val, ":")
+		ns, ok := p.prefixes[t.val[:i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown prefix %q at char %d", t.val[:i], t.pos)
+		}
+		return quad.IRI(ns + t.val[i+1:]), nil
+	case tokString:
+		switch n := p.peek(); {
+		case n.typ == tokLangTag:
+			p.next()
+			return quad.LangString{Value: quad.String(t.val), Lang: n.val}, nil
+		case n.is("^^"):
+			p.next()
+			typ, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			iri, ok := typ.(quad.IRI)
+			if !ok {
+				return nil, fmt.Errorf("expected IRI, got %v at char %d", typ, n.pos)
+			}
+			v, err := quad.TypedString{Value: quad.String(t.val), Type: iri}.ToNative()
+			if err != nil {
+				return nil, fmt.Errorf("%v at char %d", err, t.pos)
+			}
+			return v, nil
+		}
+		return quad.String(t.val), nil
+	case tokInteger:
+		n, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q at char %d", t.val, t.pos)
+		}
+		return quad.Int(n), nil
+	case tokDecimal:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at char %d", t.val, t.pos)
+		}
+		return quad.Float(f), nil
+	case tokKeyword:
+		switch {
+		case t.is("true"):
+			return quad.Bool(true), nil
+		case t.is("false"):
+			return quad.Bool(false), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %v at char %d", t, t.pos)
+}
+
+var filterOps = map[string]filterOp{
+	"<":  filterLT,
+	"<=": filterLTE,
+	">":  filterGT,
+	">=": filterGTE,
+	"=":  filterEq,
+	"!=": filterNe,
+}
+
+func (op filterOp) String() string {
+	for s, o := range filterOps {
+		if o == op {
+			return s
+		}
+	}
+	return fmt.Sprintf("filterOp(%d)", int(op))
+}
+
+// reverseOps maps an operator to the one to use when the arguments are
+// swapped.
+var reverseOps = map[filterOp]filterOp{
+	filterLT:  filterGT,
+	filterLTE: filterGTE,
+	filterGT:  filterLT,
+	filterGTE: filterLTE,
+	filterEq:  filterEq,
+	filterNe:  filterNe,
+}
+
+// parseFilter parses a FILTER constraint. Only conjunctions of comparisons
+// between a variable and a fixed value or another variable are supported.
+func (p *parser) parseFilter() ([]filter, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var out []filter
+	for {
+		var (
+			fs  []filter
+			err error
+		)
+		if p.peek().is("(") {
+			fs, err = p.parseFilter()
+		} else {
+			var f filter
+			f, err = p.parseComparison()
+			fs = []filter{f}
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fs...)
+		switch t := p.next(); {
+		case t.is(")"):
+			return out, nil
+		case t.is("&&"):
+		case t.is("||"):
+			return nil, fmt.Errorf("disjunctions in FILTER are not supported at char %d", t.pos)
+		default:
+			return nil, fmt.Errorf("unexpected %v in FILTER at char %d", t, t.pos)
+		}
+	}
+}
+
+func (p *parser) parseComparison() (filter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return filter{}, err
+	}
+	t := p.next()
+	op, ok := filterOps[t.val]
+	if t.typ != tokPunct || !ok {
+		return filter{}, fmt.Errorf("expected comparison operator, got %v at char %d", t, t.pos)
+	}
+	right, err := p.parseTerm()
+	if err != nil {
+		return filter{}, err
+	}
+	switch {
+	case left.isVar() && !right.isVar():
+		return filter{name: left.name, op: op, val: right.val}, nil
+	case !left.isVar() && right.isVar():
+		return filter{name: right.name, op: reverseOps[op], val: left.val}, nil
+	case left.isVar() && right.isVar():
+		return filter{name: left.name, op: op, other: right.name}, nil
+	}
+	return filter{}, fmt.Errorf("FILTER comparing two values is not supported at char %d", t.pos)
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+// Defines a running session of the SPARQL query language.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/graph/iterator"
+	"github.com/google/cayley/query"
+)
+
+type Session struct {
+	qs    graph.QuadStore
+	debug bool
+
+	query      *Query
+	err        error
+	answer     bool
+	dataOutput []interface{}
+}
+
+func NewSession(qs graph.QuadStore) *Session {
+	return &Session{qs: qs}
+}
+
+func (s *Session) Debug(ok bool) {
+	s.debug = ok
+}
+
+func (s *Session) Parse(input string) (query.ParseResult, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return query.ParseFail, err
+	}
+	depth := 0
+	for _, t := range toks {
+		switch {
+		case t.is("{"):
+			depth++
+		case t.is("}"):
+			depth--
+		}
+	}
+	if depth > 0 {
+		return query.ParseMore, nil
+	}
+	if _, err := Parse(input); err != nil {
+		return query.ParseFail, err
+	}
+	return query.Parsed, nil
+}
+
+func (s *Session) ShapeOf(input string) (interface{}, error) {
+	q, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	it, err := buildIteratorTree(s.qs, q)
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]interface{})
+	iterator.OutputQueryShapeForIterator(it, s.qs, output)
+	return output, nil
+}
+
+// Execute runs the query. The results of a SELECT query are sent as the tags
+// of the iterator tree, while the answer to an ASK query is sent as a bool.
+func (s *Session) Execute(ctx context.Context, input string, out chan interface{}, _ int) {
+	defer close(out)
+	s.err = nil
+	q, err := Parse(input)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.query = q
+	it, err := buildIteratorTree(s.qs, q)
+	if err != nil {
+		s.err = err
+		return
+	}
+	it = query.Optimize(ctx, it)
+	defer it.Close()
+	if s.debug {
+		b, err := json.MarshalIndent(it.Describe(), "", "  ")
+		if err != nil {
+			fmt.Printf("failed to format description: %v", err)
+		} else {
+			fmt.Printf("%s", b)
+		}
+	}
+	if q.form == formAsk {
+		found := graph.Next(ctx, it)
+		if s.err = it.Err(); s.err != nil {
+			return
+		}
+		select {
+		case out <- found:
+		case <-ctx.Done():
+		}
+		return
+	}
+	for graph.Next(ctx, it) {
+		tags := make(map[string]graph.Value)
+		it.TagResults(tags)
+		select {
+		case out <- tags:
+		case <-ctx.Done():
+			return
+		}
+		for it.NextPath(ctx) {
+			tags := make(map[string]graph.Value)
+			it.TagResults(tags)
+			select {
+			case out <- tags:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	s.err = it.Err()
+}
+
+func (s *Session) Format(result interface{}) string {
+	switch r := result.(type) {
+	case bool:
+		return fmt.Sprintln("=>", r)
+	case map[string]graph.Value:
+		out := fmt.Sprintln("****")
+		for _, name := range s.query.Vars() {
+			if v, ok := r[name]; ok {
+				out += fmt.Sprintf("%s : %s\n", name, s.qs.NameOf(v))
+			}
+		}
+		return out
+	}
+	return ""
+}
+
+// StreamResult returns the output of a single result: the answer of an ASK
+// query, or an object mapping the variables of a SELECT query to their values.
+func (s *Session) StreamResult(result interface{}) (interface{}, bool) {
+	switch r := result.(type) {
+	case bool:
+		return r, true
+	case map[string]graph.Value:
+		obj := make(map[string]string)
+		for _, name := range s.query.Vars() {
+			v, ok := r[name]
+			if !ok {
+				continue
+			}
+			if qv := s.qs.NameOf(v); qv != nil {
+				obj[name] = qv.String()
+			}
+		}
+		return obj, true
+	}
+	return nil, false
+}
+
+// Collate adds a result to the output. Every result of a SELECT query is
+// an object mapping the projected variables to their values.
+func (s *Session) Collate(result interface{}) {
+	if r, ok := result.(bool); ok {
+		s.answer = r
+		return
+	}
+	if obj, ok := s.StreamResult(result); ok {
+		s.dataOutput = append(s.dataOutput, obj)
+	}
+}
+
+func (s *Session) Results() (interface{}, error) {
+	defer s.Clear()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.query != nil && s.query.form == formAsk {
+		return s.answer, nil
+	}
+	return s.dataOutput, nil
+}
+
+func (s *Session) Clear() {
+	s.answer = false
+	s.dataOutput = nil
+}
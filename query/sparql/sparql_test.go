@@ -0,0 +1,301 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+	"github.com/google/cayley/quad"
+	"github.com/google/cayley/quad/cquads"
+
+	_ "github.com/google/cayley/graph/memstore"
+	_ "github.com/google/cayley/writer"
+)
+
+// The test graph is the one in data/testdata.nq, with the ages of some of
+// the people added.
+var ages = []quad.Quad{
+	{Subject: quad.IRI("alice"), Predicate: quad.IRI("age"), Object: quad.Int(30)},
+	{Subject: quad.IRI("bob"), Predicate: quad.IRI("age"), Object: quad.Int(25)},
+	{Subject: quad.IRI("fred"), Predicate: quad.IRI("age"), Object: quad.Int(40)},
+}
+
+func makeTestSession(t testing.TB) *Session {
+	f, err := os.Open("../../data/testdata.nq")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+	qs, _ := graph.NewQuadStore("memstore", "", nil)
+	w, _ := graph.NewQuadWriter("single", qs, nil)
+	dec := cquads.NewDecoder(f)
+	for q, err := dec.ReadQuad(); err == nil; q, err = dec.ReadQuad() {
+		w.WriteQuad(q)
+	}
+	for _, q := range ages {
+		w.WriteQuad(q)
+	}
+	return NewSession(qs)
+}
+
+func runQuery(ses *Session, query string) (interface{}, error) {
+	c := make(chan interface{}, 5)
+	go ses.Execute(context.TODO(), query, c, -1)
+	for res := range c {
+		ses.Collate(res)
+	}
+	return ses.Results()
+}
+
+// rows formats the results as sorted strings, so they are easy to compare.
+func rows(res interface{}) []string {
+	var out []string
+	for _, r := range res.([]interface{}) {
+		obj := r.(map[string]string)
+		var keys []string
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s := ""
+		for _, k := range keys {
+			s += fmt.Sprintf("%s=%s ", k, obj[k])
+		}
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var testSelectQueries = []struct {
+	message string
+	query   string
+	expect  []string
+}{
+	{
+		message: "match a single pattern",
+		query:   `SELECT ?x WHERE { ?x <follows> <fred> }`,
+		expect:  []string{"x=<bob> ", "x=<emily> "},
+	},
+	{
+		message: "join patterns on a variable",
+		query: `
+			SELECT * WHERE {
+				<charlie> <follows> ?x .
+				?x <status> "cool_person" .
+			}
+		`,
+		expect: []string{"x=<bob> ", "x=<dani> "},
+	},
+	{
+		message: "use prefixes and abbreviations",
+		query: `
+			PREFIX : <>
+			SELECT ?x ?y WHERE {
+				?x :follows ?y ; :status "cool_person" .
+			}
+		`,
+		expect: []string{"x=<bob> y=<fred> ", "x=<dani> y=<bob> ", "x=<dani> y=<greg> "},
+	},
+	{
+		message: "project the variables",
+		query:   `SELECT ?y WHERE { ?x <follows> ?y . ?x <status> "cool_person" }`,
+		expect:  []string{"y=<bob> ", "y=<fred> ", "y=<greg> "},
+	},
+	{
+		message: "find the predicates",
+		query:   `SELECT ?p WHERE { <dani> ?p <greg> }`,
+		expect:  []string{"p=<follows> "},
+	},
+	{
+		message: "filter by comparison",
+		query:   `SELECT ?x ?age WHERE { ?x <age> ?age FILTER(?age > 25 && ?age < 40) }`,
+		expect:  []string{`age="30"^^<http://schema.org/Integer> x=<alice> `},
+	},
+	{
+		message: "filter by equality",
+		query:   `SELECT ?x WHERE { ?x <follows> ?y FILTER(?y != <bob>) FILTER(<dani> = ?x) }`,
+		expect:  []string{"x=<dani> "},
+	},
+	{
+		message: "compare two variables",
+		query: `
+			SELECT ?x ?y WHERE {
+				?x <follows> ?y ; <age> ?a .
+				?y <age> ?b .
+				FILTER(?a > ?b)
+			}
+		`,
+		expect: []string{"x=<alice> y=<bob> "},
+	},
+	{
+		message: "filter out equal variables",
+		query:   `SELECT ?x ?y WHERE { ?x <follows> ?z . ?y <follows> ?z FILTER(?z = <greg> && ?x != ?y) }`,
+		expect:  []string{"x=<dani> y=<fred> ", "x=<fred> y=<dani> "},
+	},
+	{
+		message: "join a cycle of patterns",
+		query: `
+			SELECT * WHERE {
+				?a <follows> ?b .
+				?b <follows> ?c .
+				?a <follows> ?c .
+			}
+		`,
+		expect: []string{"a=<charlie> b=<dani> c=<bob> "},
+	},
+	{
+		message: "join a cycle of two patterns",
+		query:   `SELECT * WHERE { ?x <follows> ?y . ?y <follows> ?x }`,
+		expect:  nil,
+	},
+	{
+		message: "use an optional pattern",
+		query: `
+			SELECT ?x ?age WHERE {
+				<charlie> <follows> ?x .
+				OPTIONAL { ?x <age> ?age }
+			}
+		`,
+		expect: []string{`age="25"^^<http://schema.org/Integer> x=<bob> `, "x=<dani> "},
+	},
+	{
+		message: "use an union",
+		query: `
+			SELECT ?x WHERE {
+				{ ?x <follows> <greg> } UNION { ?x <age> 30 }
+			}
+		`,
+		expect: []string{"x=<alice> ", "x=<dani> ", "x=<fred> "},
+	},
+	{
+		message: "use an union with a shared variable",
+		query: `
+			SELECT ?x ?y WHERE {
+				?x <status> "cool_person" .
+				{ ?x <follows> ?y } UNION { ?y <follows> ?x }
+			}
+		`,
+		expect: []string{
+			"x=<bob> y=<alice> ", "x=<bob> y=<charlie> ", "x=<bob> y=<dani> ", "x=<bob> y=<fred> ",
+			"x=<dani> y=<bob> ", "x=<dani> y=<charlie> ", "x=<dani> y=<greg> ",
+			"x=<greg> y=<dani> ", "x=<greg> y=<fred> ",
+		},
+	},
+	{
+		message: "use a label context",
+		query:   `SELECT ?x WHERE { GRAPH <smart_graph> { ?x <status> ?s } }`,
+		expect:  []string{"x=<emily> ", "x=<greg> "},
+	},
+	{
+		message: "find the labels",
+		query:   `SELECT ?g WHERE { GRAPH ?g { <greg> <status> ?s } }`,
+		expect:  []string{"g=<smart_graph> "},
+	},
+}
+
+func TestSelect(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, test := range testSelectQueries {
+		res, err := runQuery(ses, test.query)
+		if err != nil {
+			t.Errorf("Failed to %s: %v", test.message, err)
+			continue
+		}
+		got := rows(res)
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Failed to %s, got: %q expected: %q", test.message, got, test.expect)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	ses := makeTestSession(t)
+	res, err := runQuery(ses, `SELECT ?x WHERE { ?x <follows> ?y }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := res.([]interface{})
+	for _, test := range []struct {
+		limit, offset int
+	}{
+		{limit: 2},
+		{limit: 3, offset: 2},
+		{limit: 5, offset: len(all) - 1},
+	} {
+		query := fmt.Sprintf(`SELECT ?x WHERE { ?x <follows> ?y } LIMIT %d OFFSET %d`, test.limit, test.offset)
+		res, err := runQuery(ses, query)
+		if err != nil {
+			t.Errorf("Failed to run %q: %v", query, err)
+			continue
+		}
+		// The order of the results is not defined, but it is the same for
+		// every run of the query.
+		end := test.offset + test.limit
+		if end > len(all) {
+			end = len(all)
+		}
+		if got := res.([]interface{}); !reflect.DeepEqual(got, all[test.offset:end]) {
+			t.Errorf("Unexpected results for %q, got: %v expected: %v", query, got, all[test.offset:end])
+		}
+	}
+}
+
+func TestAsk(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, test := range []struct {
+		query  string
+		expect bool
+	}{
+		{`ASK { <alice> <follows> <bob> }`, true},
+		{`ASK { <bob> <follows> <alice> }`, false},
+		{`ASK WHERE { ?x <follows> ?y . ?y <follows> <greg> }`, true},
+	} {
+		res, err := runQuery(ses, test.query)
+		if err != nil {
+			t.Errorf("Failed to run %q: %v", test.query, err)
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("Unexpected answer to %q, got: %v expected: %v", test.query, res, test.expect)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ses := makeTestSession(t)
+	for _, query := range []string{
+		`SELECT WHERE { ?x <follows> ?y }`,
+		`SELECT * WHERE { ?x <follows> ?y`,
+		`SELECT * WHERE { ?x foo:follows ?y }`,
+		`SELECT * WHERE { ?x <follows> ?y FILTER(1 < 2) }`,
+		`SELECT * WHERE { ?x <follows> ?y FILTER(?z > 1) }`,
+		`SELECT * WHERE { ?x <follows> ?y FILTER(?x != ?z) }`,
+		`SELECT * WHERE { ?x <follows> ?y OPTIONAL { ?y <follows> ?x } }`,
+		`SELECT * WHERE { ?x <follows> ?y OPTIONAL { ?y <age> ?a FILTER(?a > ?y) } }`,
+		`SELECT * WHERE { ?x <follows> ?y . ?z <status> ?s }`,
+	} {
+		if _, err := runQuery(ses, query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/cayley/graph"
+)
+
+// Stats summarizes the statistics of the iterator trees run by a query.
+type Stats struct {
+	// Iterators is the number of iterator trees run by the query. The calls
+	// are counted over the first MaxExplainedIterators trees.
+	Iterators    int   `json:"iterators"`
+	Next         int64 `json:"next"`
+	Contains     int64 `json:"contains"`
+	ContainsNext int64 `json:"contains_next"`
+}
+
+func (s *Stats) add(st graph.StatsContainer) {
+	s.Next += st.Next
+	s.Contains += st.Contains
+	s.ContainsNext += st.ContainsNext
+	for _, sub := range st.SubIts {
+		s.add(sub)
+	}
+}
+
+type statsKey struct{}
+
+type statsCollector struct {
+	mu  sync.Mutex
+	its []graph.Iterator
+	n   int
+}
+
+func (c *statsCollector) collect(it graph.Iterator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+	if len(c.its) < MaxExplainedIterators {
+		c.its = append(c.its, it)
+	}
+}
+
+// WithStats returns a context recording the iterator trees run by a query,
+// and a function summarizing their statistics once the query is done.
+func WithStats(ctx context.Context) (context.Context, func() *Stats) {
+	c := &statsCollector{}
+	return context.WithValue(ctx, statsKey{}, c), func() *Stats {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		s := &Stats{Iterators: c.n}
+		for _, it := range c.its {
+			s.add(graph.DumpStats(it))
+		}
+		return s
+	}
+}
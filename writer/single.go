@@ -32,37 +32,14 @@ type Single struct {
 }
 
 func NewSingleReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWriter, error) {
-	var (
-		ignoreMissing   bool
-		ignoreDuplicate bool
-		err             error
-	)
-
-	if *graph.IgnoreMissing {
-		ignoreMissing = true
-	} else {
-		ignoreMissing, _, err = opts.BoolKey("ignore_missing")
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if *graph.IgnoreDup {
-		ignoreDuplicate = true
-	} else {
-		ignoreDuplicate, _, err = opts.BoolKey("ignore_duplicate")
-		if err != nil {
-			return nil, err
-		}
+	ignoreOpts, err := graph.IgnoreOptsFrom(opts)
+	if err != nil {
+		return nil, err
 	}
-
 	return &Single{
-		currentID: qs.Horizon(),
-		qs:        qs,
-		ignoreOpts: graph.IgnoreOpts{
-			IgnoreDup:     ignoreDuplicate,
-			IgnoreMissing: ignoreMissing,
-		},
+		currentID:  qs.Horizon(),
+		qs:         qs,
+		ignoreOpts: ignoreOpts,
 	}, nil
 }
 